@@ -0,0 +1,130 @@
+package connector
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/connctd/connector-go/connctd"
+)
+
+// actionTag is the struct tag used by UnmarshalActionParameters.
+const actionTag = "action"
+
+var errorInvalidActionTarget = errors.New("action parameters can only be unmarshaled into a pointer to a struct")
+
+// UnmarshalActionParameters parses the parameters of the action request and stores them in the struct pointed to by v.
+// Struct fields are mapped to parameters by the "action" tag, e.g. `action:"brightness"`.
+// Parameters that have to be present can be marked with `action:"brightness,required"`.
+// Values are converted according to the value type the action declares for the parameter: NUMBER parameters can be
+// stored in integer or float fields, BOOLEAN parameters in bool fields and parameters of all types in string fields.
+// Missing required parameters are reported with ErrorMissingActionParameter, parameters without declaration with
+// ErrorUndeclaredActionParameter and values not matching their declared type or field with ErrorInvalidActionParameter.
+func UnmarshalActionParameters(request ActionRequest, parameters []connctd.ActionParameter, v interface{}) error {
+	target := reflect.ValueOf(v)
+	if target.Kind() != reflect.Pointer || target.IsNil() || target.Elem().Kind() != reflect.Struct {
+		return errorInvalidActionTarget
+	}
+	target = target.Elem()
+
+	declared := make(map[string]connctd.ValueType, len(parameters))
+	for _, parameter := range parameters {
+		declared[parameter.Name] = parameter.Type
+	}
+
+	for i := 0; i < target.NumField(); i++ {
+		field := target.Type().Field(i)
+		tag, ok := field.Tag.Lookup(actionTag)
+		if !ok || !field.IsExported() {
+			continue
+		}
+
+		name, options, _ := strings.Cut(tag, ",")
+		value, ok := request.Parameters[name]
+		if !ok {
+			if options == "required" {
+				return fmt.Errorf("%w: %s", ErrorMissingActionParameter, name)
+			}
+			continue
+		}
+
+		valueType, ok := declared[name]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrorUndeclaredActionParameter, name)
+		}
+		if err := connctd.ValidateValueType(valueType, value); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrorInvalidActionParameter, name, err)
+		}
+		if err := setActionParameter(target.Field(i), valueType, value); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrorInvalidActionParameter, name, err)
+		}
+	}
+
+	return nil
+}
+
+// setActionParameter converts the value of the given value type to the type of the field and sets it.
+func setActionParameter(field reflect.Value, valueType connctd.ValueType, value string) error {
+	kind := field.Kind()
+	switch {
+	case kind == reflect.String:
+		field.SetString(value)
+	case kind == reflect.Bool && valueType == connctd.ValueTypeBoolean:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case (kind == reflect.Float32 || kind == reflect.Float64) && valueType == connctd.ValueTypeNumber:
+		f, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case (kind == reflect.Uint || kind == reflect.Uint8 || kind == reflect.Uint16 || kind == reflect.Uint32 || kind == reflect.Uint64) && valueType == connctd.ValueTypeNumber:
+		u, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(u)
+	case (kind == reflect.Int || kind == reflect.Int8 || kind == reflect.Int16 || kind == reflect.Int32 || kind == reflect.Int64) && valueType == connctd.ValueTypeNumber:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	default:
+		return fmt.Errorf("field of type %s can not hold %s parameters", field.Type(), valueType)
+	}
+
+	return nil
+}
+
+// ValidateActionRequestParameters checks the parameters of the action request against the parameters declared by the action.
+// All declared parameters are required and their values have to match the declared value type.
+// Parameters that are not declared by the action are reported with ErrorUndeclaredActionParameter.
+func ValidateActionRequestParameters(request ActionRequest, parameters []connctd.ActionParameter) error {
+	declared := make(map[string]bool, len(parameters))
+	for _, parameter := range parameters {
+		declared[parameter.Name] = true
+
+		value, ok := request.Parameters[parameter.Name]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrorMissingActionParameter, parameter.Name)
+		}
+
+		if err := connctd.ValidateValueType(parameter.Type, value); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrorInvalidActionParameter, parameter.Name, err)
+		}
+	}
+
+	for name := range request.Parameters {
+		if !declared[name] {
+			return fmt.Errorf("%w: %s", ErrorUndeclaredActionParameter, name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,159 @@
+package connector
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/connctd/connector-go/connctd"
+	"github.com/stretchr/testify/assert"
+)
+
+type dimParameters struct {
+	Brightness int     `action:"brightness,required"`
+	Fade       float64 `action:"fade"`
+	On         bool    `action:"on"`
+	Scene      string  `action:"scene"`
+	Ignored    string
+}
+
+var dimDeclaration = []connctd.ActionParameter{
+	{Name: "brightness", Type: connctd.ValueTypeNumber},
+	{Name: "fade", Type: connctd.ValueTypeNumber},
+	{Name: "on", Type: connctd.ValueTypeBoolean},
+	{Name: "scene", Type: connctd.ValueTypeString},
+}
+
+func TestUnmarshalActionParameters(t *testing.T) {
+	tests := []struct {
+		name       string
+		parameters map[string]string
+		expected   dimParameters
+		err        error
+	}{
+		{
+			name:       "all parameters",
+			parameters: map[string]string{"brightness": "80", "fade": "0.5", "on": "true", "scene": "evening", "Ignored": "foo"},
+			expected:   dimParameters{Brightness: 80, Fade: 0.5, On: true, Scene: "evening"},
+		},
+		{
+			name:       "optional parameters missing",
+			parameters: map[string]string{"brightness": "10"},
+			expected:   dimParameters{Brightness: 10},
+		},
+		{
+			name:       "required parameter missing",
+			parameters: map[string]string{"on": "true"},
+			err:        ErrorMissingActionParameter,
+		},
+		{
+			name:       "invalid number",
+			parameters: map[string]string{"brightness": "bright"},
+			err:        ErrorInvalidActionParameter,
+		},
+		{
+			name:       "invalid boolean",
+			parameters: map[string]string{"brightness": "10", "on": "yes please"},
+			err:        ErrorInvalidActionParameter,
+		},
+		{
+			name:       "invalid integer",
+			parameters: map[string]string{"brightness": "10.5"},
+			err:        ErrorInvalidActionParameter,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var parameters dimParameters
+			err := UnmarshalActionParameters(ActionRequest{Parameters: test.parameters}, dimDeclaration, &parameters)
+			if test.err != nil {
+				assert.True(t, errors.Is(err, test.err), err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, parameters)
+		})
+	}
+}
+
+func TestUnmarshalActionParametersInvalidTarget(t *testing.T) {
+	var parameters dimParameters
+	assert.Error(t, UnmarshalActionParameters(ActionRequest{}, dimDeclaration, parameters))
+	assert.Error(t, UnmarshalActionParameters(ActionRequest{}, dimDeclaration, nil))
+}
+
+func TestUnmarshalActionParametersByDeclaredType(t *testing.T) {
+	var parameters struct {
+		Level string `action:"level"`
+		On    bool   `action:"on"`
+	}
+
+	// string fields hold parameters of every type, but the value has to match the declared type
+	declared := []connctd.ActionParameter{{Name: "level", Type: connctd.ValueTypeNumber}, {Name: "on", Type: connctd.ValueTypeBoolean}}
+	request := ActionRequest{Parameters: map[string]string{"level": "42", "on": "true"}}
+	assert.NoError(t, UnmarshalActionParameters(request, declared, &parameters))
+	assert.Equal(t, "42", parameters.Level)
+	assert.True(t, parameters.On)
+
+	request.Parameters["level"] = "high"
+	err := UnmarshalActionParameters(request, declared, &parameters)
+	assert.True(t, errors.Is(err, ErrorInvalidActionParameter), err)
+
+	// the field has to be able to hold the declared type
+	declared = []connctd.ActionParameter{{Name: "level", Type: connctd.ValueTypeString}, {Name: "on", Type: connctd.ValueTypeString}}
+	request.Parameters["on"] = "true"
+	err = UnmarshalActionParameters(request, declared, &parameters)
+	assert.True(t, errors.Is(err, ErrorInvalidActionParameter), err)
+
+	// parameters without declaration are rejected
+	err = UnmarshalActionParameters(request, declared[:1], &parameters)
+	assert.True(t, errors.Is(err, ErrorUndeclaredActionParameter), err)
+}
+
+func TestValidateActionRequestParameters(t *testing.T) {
+	declared := []connctd.ActionParameter{
+		{Name: "brightness", Type: connctd.ValueTypeNumber},
+		{Name: "on", Type: connctd.ValueTypeBoolean},
+		{Name: "scene", Type: connctd.ValueTypeString},
+	}
+
+	tests := []struct {
+		name       string
+		parameters map[string]string
+		err        error
+	}{
+		{"valid", map[string]string{"brightness": "0.5", "on": "false", "scene": "evening"}, nil},
+		{"missing", map[string]string{"brightness": "0.5", "on": "false"}, ErrorMissingActionParameter},
+		{"undeclared", map[string]string{"brightness": "0.5", "on": "false", "scene": "evening", "color": "red"}, ErrorUndeclaredActionParameter},
+		{"invalid number", map[string]string{"brightness": "dark", "on": "false", "scene": "evening"}, ErrorInvalidActionParameter},
+		{"invalid boolean", map[string]string{"brightness": "0.5", "on": "maybe", "scene": "evening"}, ErrorInvalidActionParameter},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateActionRequestParameters(ActionRequest{Parameters: test.parameters}, declared)
+			if test.err == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.True(t, errors.Is(err, test.err), err)
+			}
+		})
+	}
+}
+
+func TestValidateActionRequestParametersWithCustomValueType(t *testing.T) {
+	colorHex := connctd.ValueType("TEST_COLOR_HEX")
+	connctd.RegisterValueType(colorHex, func(value string) error {
+		if len(value) != 7 || !strings.HasPrefix(value, "#") {
+			return errors.New("expected #rrggbb")
+		}
+		return nil
+	})
+	t.Cleanup(func() { connctd.UnregisterValueType(colorHex) })
+	declared := []connctd.ActionParameter{{Name: "color", Type: colorHex}}
+
+	assert.NoError(t, ValidateActionRequestParameters(ActionRequest{Parameters: map[string]string{"color": "#ff8800"}}, declared))
+	err := ValidateActionRequestParameters(ActionRequest{Parameters: map[string]string{"color": "orange"}}, declared)
+	assert.True(t, errors.Is(err, ErrorInvalidActionParameter), err)
+}
@@ -0,0 +1,212 @@
+package connector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// RequestKind identifies the protocol request an archived request belongs to.
+type RequestKind string
+
+// Protocol requests that can be archived:
+const (
+	RequestKindAddInstallation    RequestKind = "ADD_INSTALLATION"
+	RequestKindUpdateInstallation RequestKind = "UPDATE_INSTALLATION"
+	RequestKindRemoveInstallation RequestKind = "REMOVE_INSTALLATION"
+	RequestKindAddInstance        RequestKind = "ADD_INSTANCE"
+	RequestKindRemoveInstance     RequestKind = "REMOVE_INSTANCE"
+	RequestKindPerformAction      RequestKind = "PERFORM_ACTION"
+)
+
+// redactedToken replaces tokens in archived request bodies.
+const redactedToken = "REDACTED"
+
+// ArchivedRequest is a validated protocol request stored for debugging purposes.
+// Tokens contained in the body are redacted.
+type ArchivedRequest struct {
+	ID         string          `json:"id"`
+	Kind       RequestKind     `json:"kind"`
+	ReceivedAt time.Time       `json:"receivedAt"`
+	ResourceID string          `json:"resourceId,omitempty"`
+	Body       json.RawMessage `json:"body,omitempty"`
+}
+
+// requestArchive keeps the most recent requests up to its capacity.
+type requestArchive struct {
+	mutex    sync.Mutex
+	capacity int
+	lastID   int
+	requests []ArchivedRequest
+}
+
+func (a *requestArchive) add(request ArchivedRequest) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.lastID++
+	request.ID = strconv.Itoa(a.lastID)
+
+	a.requests = append(a.requests, request)
+	if len(a.requests) > a.capacity {
+		a.requests = a.requests[len(a.requests)-a.capacity:]
+	}
+}
+
+func (a *requestArchive) get(id string) (ArchivedRequest, bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for _, request := range a.requests {
+		if request.ID == id {
+			return request, true
+		}
+	}
+	return ArchivedRequest{}, false
+}
+
+func (a *requestArchive) list() []ArchivedRequest {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	return append([]ArchivedRequest{}, a.requests...)
+}
+
+// EnableRequestArchive makes the handler store up to capacity of the most recently validated requests.
+// Archived requests can be inspected with ArchivedRequests and fed back to the service with ReplayRequest.
+// The capacity is at least one, smaller values are raised to one.
+// Archiving is disabled by default and should only be enabled for debugging.
+func (c *ConnectorHandler) EnableRequestArchive(capacity int) {
+	if capacity < 1 {
+		capacity = 1
+	}
+	c.archive.Store(&requestArchive{capacity: capacity})
+}
+
+// ArchivedRequests returns all archived requests, oldest first.
+func (c *ConnectorHandler) ArchivedRequests() []ArchivedRequest {
+	archive := c.archive.Load()
+	if archive == nil {
+		return nil
+	}
+	return archive.list()
+}
+
+// ReplayRequest passes the archived request with the given ID to the service again.
+// Note that the tokens of replayed installations and instances are redacted.
+func (c *ConnectorHandler) ReplayRequest(ctx context.Context, archivedID string) error {
+	archive := c.archive.Load()
+	if archive == nil {
+		return ErrorArchiveDisabled
+	}
+
+	request, ok := archive.get(archivedID)
+	if !ok {
+		return ErrorArchivedRequestNotFound
+	}
+
+	switch request.Kind {
+	case RequestKindAddInstallation:
+		var req InstallationRequest
+		if err := json.Unmarshal(request.Body, &req); err != nil {
+			return fmt.Errorf("failed to decode archived request: %w", err)
+		}
+		_, err := c.service.AddInstallation(ctx, req)
+		return err
+	case RequestKindUpdateInstallation:
+		var req InstallationRequest
+		if err := json.Unmarshal(request.Body, &req); err != nil {
+			return fmt.Errorf("failed to decode archived request: %w", err)
+		}
+		if req.ID == "" {
+			req.ID = request.ResourceID
+		}
+		_, err := c.service.UpdateInstallation(ctx, req)
+		return err
+	case RequestKindRemoveInstallation:
+		return c.service.RemoveInstallation(ctx, request.ResourceID)
+	case RequestKindAddInstance:
+		var req InstantiationRequest
+		if err := json.Unmarshal(request.Body, &req); err != nil {
+			return fmt.Errorf("failed to decode archived request: %w", err)
+		}
+		_, err := c.service.AddInstance(ctx, req)
+		return err
+	case RequestKindRemoveInstance:
+		return c.service.RemoveInstance(ctx, request.ResourceID)
+	case RequestKindPerformAction:
+		var req ActionRequest
+		if err := json.Unmarshal(request.Body, &req); err != nil {
+			return fmt.Errorf("failed to decode archived request: %w", err)
+		}
+		_, err := c.service.PerformAction(ctx, req)
+		return err
+	}
+
+	return fmt.Errorf("unknown request kind %s", request.Kind)
+}
+
+// archived stores requests in the archive, if archiving is enabled, before passing them to the next handler.
+func (c *ConnectorHandler) archived(kind RequestKind, next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		archive := c.archive.Load()
+		if archive == nil {
+			next(w, r)
+			return
+		}
+
+		body, err := requestBody(r)
+		if err != nil {
+			writeError(w, ErrorBadRequestBody)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		archive.add(ArchivedRequest{
+			Kind:       kind,
+			ReceivedAt: time.Now(),
+			ResourceID: mux.Vars(r)["id"],
+			Body:       redactToken(body),
+		})
+
+		next(w, r)
+	})
+}
+
+// redactToken replaces the token of a json request body.
+// Bodies which are not json objects are not archived.
+func redactToken(body []byte) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil
+	}
+
+	if _, ok := fields["token"]; ok {
+		fields["token"] = json.RawMessage(`"` + redactedToken + `"`)
+	}
+
+	redacted, err := json.Marshal(fields)
+	if err != nil {
+		return nil
+	}
+	return redacted
+}
+
+// Errors returned when replaying archived requests:
+var (
+	ErrorArchiveDisabled         = errors.New("request archive is not enabled")
+	ErrorArchivedRequestNotFound = errors.New("archived request not found")
+)
@@ -0,0 +1,100 @@
+package connector
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingService records installation and instantiation requests and panics on all other calls.
+// Installation requests fail with err if it is set, updates are answered with updateResponse.
+type recordingService struct {
+	ConnectorService
+	installations  []InstallationRequest
+	updates        []InstallationRequest
+	instances      []InstantiationRequest
+	err            error
+	updateResponse *InstallationResponse
+}
+
+func (s *recordingService) UpdateInstallation(ctx context.Context, request InstallationRequest) (*InstallationResponse, error) {
+	s.updates = append(s.updates, request)
+	return s.updateResponse, nil
+}
+
+func (s *recordingService) AddInstallation(ctx context.Context, request InstallationRequest) (*InstallationResponse, error) {
+	s.installations = append(s.installations, request)
+	return nil, s.err
+}
+
+func (s *recordingService) AddInstance(ctx context.Context, request InstantiationRequest) (*InstantiationResponse, error) {
+	s.instances = append(s.instances, request)
+	return nil, nil
+}
+
+func TestReplayArchivedRequest(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	service := &recordingService{}
+	handler := NewConnectorHandler(nil, service, pub)
+
+	send := func() {
+		body := []byte(`{"id":"installation-1","token":"secret-token","state":1}`)
+		req := httptest.NewRequest(http.MethodPost, "https://example.com/installations", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		require.NoError(t, signRequest(priv, req, body))
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	}
+
+	// archiving is disabled by default
+	send()
+	assert.Empty(t, handler.ArchivedRequests())
+	assert.Equal(t, ErrorArchiveDisabled, handler.ReplayRequest(context.Background(), "1"))
+
+	handler.EnableRequestArchive(2)
+	send()
+	send()
+	send()
+
+	// only the latest requests are kept
+	archived := handler.ArchivedRequests()
+	require.Len(t, archived, 2)
+	assert.Equal(t, "2", archived[0].ID)
+	assert.Equal(t, "3", archived[1].ID)
+	assert.Equal(t, RequestKindAddInstallation, archived[1].Kind)
+	assert.NotContains(t, string(archived[1].Body), "secret-token")
+
+	assert.Equal(t, ErrorArchivedRequestNotFound, handler.ReplayRequest(context.Background(), "1"))
+
+	require.NoError(t, handler.ReplayRequest(context.Background(), "3"))
+	require.Len(t, service.installations, 5)
+	assert.Equal(t, "installation-1", service.installations[4].ID)
+	assert.Equal(t, InstallationToken(redactedToken), service.installations[4].Token)
+}
+
+func TestRequestArchiveCapacity(t *testing.T) {
+	for _, capacity := range []int{-1, 0, 1} {
+		handler := NewConnectorHandler(nil, &recordingService{}, nil)
+		handler.EnableRequestArchive(capacity)
+
+		// capacities below one keep the latest request
+		archive := handler.archive.Load()
+		archive.add(ArchivedRequest{Kind: RequestKindAddInstallation})
+		archive.add(ArchivedRequest{Kind: RequestKindAddInstance})
+
+		archived := handler.ArchivedRequests()
+		require.Len(t, archived, 1, capacity)
+		assert.Equal(t, "2", archived[0].ID)
+		assert.Equal(t, RequestKindAddInstance, archived[0].Kind)
+	}
+}
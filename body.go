@@ -0,0 +1,30 @@
+package connector
+
+import (
+	"context"
+	"io"
+	"net/http"
+)
+
+type bufferedBodyContextKey struct{}
+
+// withBufferedBody returns a context carrying the already read request body.
+func withBufferedBody(ctx context.Context, body []byte) context.Context {
+	return context.WithValue(ctx, bufferedBodyContextKey{}, body)
+}
+
+// BodyFromContext returns the request body buffered by the signature validation handler.
+// Handlers behind the signature validation can use it instead of reading and copying the body again.
+// It returns false if the body was not buffered.
+func BodyFromContext(ctx context.Context) ([]byte, bool) {
+	body, ok := ctx.Value(bufferedBodyContextKey{}).([]byte)
+	return body, ok
+}
+
+// requestBody returns the buffered body of the request if available, otherwise it reads the body.
+func requestBody(r *http.Request) ([]byte, error) {
+	if body, ok := BodyFromContext(r.Context()); ok {
+		return body, nil
+	}
+	return io.ReadAll(r.Body)
+}
@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -21,11 +22,14 @@ import (
 
 	"github.com/go-logr/logr"
 	"github.com/go-logr/stdr"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
 	// APIBaseURL defines how to reach connctd API.
-	APIBaseURL = "https://connectors.connctd.io/api/v1/"
+	APIBaseURL = "https://connectors.connctd.io/api/" + APIVersion + "/"
 
 	connectorThingsEndpoint            = "connectorhub/callback/instances/things"
 	connectorActionsEndpoint           = "connectorhub/callback/instances/actions/requests"
@@ -33,6 +37,96 @@ const (
 	connectorInstallationStateEndpoint = "connectorhub/callback/installations/state"
 )
 
+// Operation identifies a call of the API client.
+type Operation string
+
+// Operations offered by the API client:
+const (
+	OperationCreateThing              Operation = "CreateThing"
+	OperationUpdateThingPropertyValue Operation = "UpdateThingPropertyValue"
+	OperationUpdateThingStatus        Operation = "UpdateThingStatus"
+	OperationUpdateActionStatus       Operation = "UpdateActionStatus"
+	OperationUpdateInstallationState  Operation = "UpdateInstallationState"
+	OperationUpdateInstanceState      Operation = "UpdateInstanceState"
+	OperationDeleteThing              Operation = "DeleteThing"
+	OperationUpdateThingBatch         Operation = "UpdateThingBatch"
+	OperationListThings               Operation = "ListThings"
+	OperationGetThing                 Operation = "GetThing"
+)
+
+// EndpointSpec defines the HTTP method and path used for an operation.
+// The path is relative to the base URL, resource IDs are appended by the client.
+type EndpointSpec struct {
+	Method string
+	Path   string
+}
+
+// EndpointPaths define the paths of the resources of the connctd API, relative to the base URL.
+type EndpointPaths struct {
+	Things            string
+	Actions           string
+	InstanceState     string
+	InstallationState string
+}
+
+// DefaultEndpointPaths returns the paths of the current connctd API.
+func DefaultEndpointPaths() EndpointPaths {
+	return EndpointPaths{
+		Things:            connectorThingsEndpoint,
+		Actions:           connectorActionsEndpoint,
+		InstanceState:     connectorInstanceStateEndpoint,
+		InstallationState: connectorInstallationStateEndpoint,
+	}
+}
+
+// withDefaults returns the paths with empty paths replaced by the DefaultEndpointPaths.
+func (p EndpointPaths) withDefaults() EndpointPaths {
+	defaults := DefaultEndpointPaths()
+	if p.Things == "" {
+		p.Things = defaults.Things
+	}
+	if p.Actions == "" {
+		p.Actions = defaults.Actions
+	}
+	if p.InstanceState == "" {
+		p.InstanceState = defaults.InstanceState
+	}
+	if p.InstallationState == "" {
+		p.InstallationState = defaults.InstallationState
+	}
+	return p
+}
+
+// DefaultEndpoints returns the endpoints of the current connctd API.
+func DefaultEndpoints() map[Operation]EndpointSpec {
+	return endpointsFor(DefaultEndpointPaths())
+}
+
+// endpointsFor returns the endpoints of all operations using the given paths.
+func endpointsFor(paths EndpointPaths) map[Operation]EndpointSpec {
+	return map[Operation]EndpointSpec{
+		OperationCreateThing:              {Method: http.MethodPost, Path: paths.Things},
+		OperationUpdateThingPropertyValue: {Method: http.MethodPut, Path: paths.Things},
+		OperationUpdateThingStatus:        {Method: http.MethodPut, Path: paths.Things},
+		OperationUpdateActionStatus:       {Method: http.MethodPut, Path: paths.Actions},
+		OperationUpdateInstallationState:  {Method: http.MethodPost, Path: paths.InstallationState},
+		OperationUpdateInstanceState:      {Method: http.MethodPost, Path: paths.InstanceState},
+		OperationDeleteThing:              {Method: http.MethodDelete, Path: paths.Things},
+		OperationUpdateThingBatch:         {Method: http.MethodPut, Path: paths.Things},
+		OperationListThings:               {Method: http.MethodGet, Path: paths.Things},
+		OperationGetThing:                 {Method: http.MethodGet, Path: paths.Things},
+	}
+}
+
+// DefaultRequestTimeout is the request timeout used by the DefaultOptions.
+const DefaultRequestTimeout = 30 * time.Second
+
+// DefaultListThingsPageSize is the default number of things requested per page by ListThings.
+const DefaultListThingsPageSize = 100
+
+// MaxListThingsPages is the maximum number of pages requested by ListThings before it fails with ErrorTooManyPages.
+const MaxListThingsPages = 1000
+
 // DefaultOptions returns default client options.
 func DefaultOptions() *ClientOptions {
 	url, _ := url.Parse(APIBaseURL)
@@ -42,6 +136,7 @@ func DefaultOptions() *ClientOptions {
 		HTTPClient: &http.Client{
 			Timeout: time.Second * 5,
 		},
+		RequestTimeout: DefaultRequestTimeout,
 	}
 }
 
@@ -59,6 +154,10 @@ type Client interface {
 	// UpdateThingPropertyValue returns an error if the update was not successful.
 	UpdateThingPropertyValue(ctx context.Context, token InstantiationToken, thingID string, componentID string, propertyID string, value string, lastUpdate time.Time) error
 
+	// UpdateThingPropertyValueWithUnit updates a property value like UpdateThingPropertyValue,
+	// but additionally sets the current unit of the property. An empty unit keeps the unit declared at thing creation.
+	UpdateThingPropertyValueWithUnit(ctx context.Context, token InstantiationToken, thingID string, componentID string, propertyID string, value string, unit string, lastUpdate time.Time) error
+
 	// UpdateThingStatus updates the status of a thing.
 	// It can be used to set the availability of a thing.
 	UpdateThingStatus(ctx context.Context, token InstantiationToken, thingID string, status connctd.StatusType) error
@@ -78,31 +177,210 @@ type Client interface {
 
 	// DeleteThing can be used to delete a thing.
 	DeleteThing(ctx context.Context, token InstantiationToken, thingID string) error
+
+	// UpdateThingBatch updates several property values and optionally the status of a thing with a single request.
+	// The platform applies either all or none of the updates.
+	UpdateThingBatch(ctx context.Context, token InstantiationToken, thingID string, batch UpdateThingBatchRequest) error
+
+	// ListThings returns all things of the instance the token belongs to.
+	// The things are requested page by page, an error is returned if any of the pages could not be retrieved.
+	ListThings(ctx context.Context, token InstantiationToken) ([]connctd.Thing, error)
+
+	// GetThing returns the thing with the given ID as stored by the connctd platform.
+	// It can be used to reconcile local state against the platform.
+	GetThing(ctx context.Context, token InstantiationToken, thingID string) (connctd.Thing, error)
 }
 
 // ClientOptions allow modification of API client behaviour.
 type ClientOptions struct {
 	ConnctdBaseURL *url.URL
 	HTTPClient     *http.Client
+
+	// Paths overrides the paths of the API resources for all operations, e.g. to use a mock with a different layout.
+	// Empty paths use the DefaultEndpointPaths. They are joined onto the base URL like the default paths.
+	Paths EndpointPaths
+
+	// Endpoints overrides the method and path of single operations, taking precedence over Paths.
+	// Operations that are not contained use the endpoints derived from Paths.
+	Endpoints map[Operation]EndpointSpec
+
+	// OnPayloadSize is called after each response with the sizes of the request and response bodies in bytes.
+	// It can be used to monitor the bandwidth used per operation.
+	OnPayloadSize func(operation Operation, requestSize int, responseSize int)
+
+	// OnRequest is called with each request right before it is sent, e.g. to start a trace span.
+	// Every attempt of a retried operation is a separate request.
+	OnRequest func(req *http.Request)
+
+	// OnResponse is called after each request with the response or the error of the http client and the duration of the call,
+	// e.g. to record latency and status code per endpoint. The response is nil if the request failed.
+	// Panics of OnRequest and OnResponse are recovered and logged.
+	OnResponse func(req *http.Request, resp *http.Response, err error, duration time.Duration)
+
+	// TracerProvider enables OpenTelemetry tracing. Each operation is recorded as a client span named after the operation,
+	// e.g. connector.CreateThing, including all of its attempts. The span context is propagated in the request headers.
+	// Tracing is disabled if it is nil.
+	TracerProvider trace.TracerProvider
+
+	// Propagator injects the span context into the request headers. If nil, the global propagator of OpenTelemetry is used.
+	Propagator propagation.TextMapPropagator
+
+	// MaxConcurrentRequests limits the number of requests sent to the connctd platform at the same time.
+	// Further requests block until a request finished or their context is done. Zero means unlimited.
+	MaxConcurrentRequests int
+
+	// RequestTimeout limits the duration of each request, including the time waiting for a free request slot and retries.
+	// It only applies if the context of the caller has no deadline, e.g. if context.Background() is passed.
+	// Zero means that requests are only limited by the context of the caller and the timeout of the HTTP client.
+	RequestTimeout time.Duration
+
+	// OperationTimeouts overrides the RequestTimeout for single operations, e.g. to allow more time for CreateThing.
+	// Note that the timeout of the HTTP client still applies to every request.
+	OperationTimeouts map[Operation]time.Duration
+
+	// Retry enables retries of failed requests. By default requests are not retried.
+	Retry RetryOptions
+
+	// UserAgent is sent with every request, e.g. to distinguish connectors in the logs of the platform.
+	// If it is empty, the UserAgent of the SDK is used.
+	UserAgent string
+
+	// DefaultHeaders are added to every request. They can not overwrite the Authorization,
+	// Content-Type and User-Agent headers set by the client.
+	DefaultHeaders http.Header
+
+	// ListThingsPageSize is the number of things requested per page by ListThings. Zero uses the DefaultListThingsPageSize.
+	ListThingsPageSize int
+
+	// RateLimiter is waited on before each request, including retries, so all operations of the client share one budget.
+	// If it is nil, requests are not rate limited.
+	RateLimiter RateLimiter
+}
+
+// RateLimiter limits the rate of outgoing requests. It is implemented by *rate.Limiter of golang.org/x/time/rate.
+type RateLimiter interface {
+	// Wait blocks until a request may be sent. It returns an error if the context is done before.
+	Wait(ctx context.Context) error
+}
+
+// RetryOptions define how the API client retries requests failing with a transport error or a retryable status code.
+// Retries stop as soon as the context of the request is done.
+type RetryOptions struct {
+	// MaxAttempts is the maximum number of attempts including the first request. Values below 2 disable retries.
+	MaxAttempts int
+
+	// BaseDelay is the delay before the first retry. It is doubled for each further retry.
+	BaseDelay time.Duration
+
+	// MaxDelay limits the delay between two attempts. Zero means unlimited.
+	MaxDelay time.Duration
+
+	// Jitter is the fraction of each delay that is randomized, between 0 and 1.
+	Jitter float64
+
+	// RetryableStatusCode decides which status codes are retried. DefaultRetryableStatusCode is used if it is nil.
+	RetryableStatusCode func(statusCode int) bool
+
+	// RetryCreateThing enables retries of CreateThing.
+	// Thing creation is not idempotent, so a retry after a lost response can create the thing twice.
+	RetryCreateThing bool
+}
+
+// DefaultRetryableStatusCode returns true for status codes reported by the platform or a proxy for transient failures.
+func DefaultRetryableStatusCode(statusCode int) bool {
+	return statusCode == http.StatusBadGateway || statusCode == http.StatusServiceUnavailable || statusCode == http.StatusGatewayTimeout
+}
+
+// retryableStatusCode returns true if requests with the status code should be retried.
+func (o RetryOptions) retryableStatusCode(statusCode int) bool {
+	if o.RetryableStatusCode != nil {
+		return o.RetryableStatusCode(statusCode)
+	}
+	return DefaultRetryableStatusCode(statusCode)
 }
 
 // APIClient implements Client interface.
 type APIClient struct {
-	httpClient *http.Client
-	baseURL    url.URL
-	logger     logr.Logger
+	httpClient    *http.Client
+	baseURL       url.URL
+	endpoints     map[Operation]EndpointSpec
+	onPayloadSize func(operation Operation, requestSize int, responseSize int)
+	onRequest     func(req *http.Request)
+	onResponse    func(req *http.Request, resp *http.Response, err error, duration time.Duration)
+	tracer        trace.Tracer
+	propagator    propagation.TextMapPropagator
+	requestSlots  chan struct{}
+	timeouts      map[Operation]time.Duration
+	timeout       time.Duration
+	retry         RetryOptions
+	rateLimiter   RateLimiter
+	pageSize      int
+	userAgent     string
+	headers       http.Header
+	logger        logr.Logger
 }
 
 // NewClient creates a new API client.
 func NewClient(opts *ClientOptions, logger logr.Logger) (Client, error) {
 	httpClient := http.DefaultClient
 	url, _ := url.Parse(APIBaseURL)
+	endpoints := DefaultEndpoints()
+	var onPayloadSize func(operation Operation, requestSize int, responseSize int)
+	var onRequest func(req *http.Request)
+	var onResponse func(req *http.Request, resp *http.Response, err error, duration time.Duration)
+	var tracer trace.Tracer
+	var propagator propagation.TextMapPropagator
+	var requestSlots chan struct{}
+	var timeout time.Duration
+	var retry RetryOptions
+	var rateLimiter RateLimiter
+	pageSize := DefaultListThingsPageSize
+	userAgent := UserAgent()
+	var headers http.Header
+	timeouts := map[Operation]time.Duration{}
 
 	if opts != nil {
+		onPayloadSize = opts.OnPayloadSize
+		onRequest = opts.OnRequest
+		onResponse = opts.OnResponse
+
+		if opts.TracerProvider != nil {
+			tracer = opts.TracerProvider.Tracer(tracerName)
+			propagator = opts.Propagator
+			if propagator == nil {
+				propagator = otel.GetTextMapPropagator()
+			}
+		}
+		timeout = opts.RequestTimeout
+		retry = opts.Retry
+		rateLimiter = opts.RateLimiter
+		headers = opts.DefaultHeaders.Clone()
+
+		if opts.UserAgent != "" {
+			userAgent = opts.UserAgent
+		}
+
+		if opts.ListThingsPageSize > 0 {
+			pageSize = opts.ListThingsPageSize
+		}
+
+		for operation, operationTimeout := range opts.OperationTimeouts {
+			timeouts[operation] = operationTimeout
+		}
+
+		if opts.MaxConcurrentRequests > 0 {
+			requestSlots = make(chan struct{}, opts.MaxConcurrentRequests)
+		}
+
 		if opts.HTTPClient != nil {
 			httpClient = opts.HTTPClient
 		}
 
+		endpoints = endpointsFor(opts.Paths.withDefaults())
+		for operation, spec := range opts.Endpoints {
+			endpoints[operation] = spec
+		}
+
 		if opts.ConnctdBaseURL != nil {
 			// url needs to end with slash
 			if !strings.HasSuffix(opts.ConnctdBaseURL.String(), "/") {
@@ -113,7 +391,25 @@ func NewClient(opts *ClientOptions, logger logr.Logger) (Client, error) {
 		}
 	}
 
-	return &APIClient{httpClient: httpClient, baseURL: *url, logger: logger.WithName("connector-go-client")}, nil
+	return &APIClient{
+		httpClient:    httpClient,
+		baseURL:       *url,
+		endpoints:     endpoints,
+		onPayloadSize: onPayloadSize,
+		onRequest:     onRequest,
+		onResponse:    onResponse,
+		tracer:        tracer,
+		propagator:    propagator,
+		requestSlots:  requestSlots,
+		timeouts:      timeouts,
+		timeout:       timeout,
+		retry:         retry,
+		rateLimiter:   rateLimiter,
+		pageSize:      pageSize,
+		userAgent:     userAgent,
+		headers:       headers,
+		logger:        logger.WithName("connector-go-client"),
+	}, nil
 }
 
 // CreateThing implements interface definition.
@@ -122,36 +418,16 @@ func (a *APIClient) CreateThing(ctx context.Context, token InstantiationToken, t
 		Thing: thing,
 	}
 
-	payload, err := json.Marshal(message)
-	if err != nil {
-		return connctd.Thing{}, fmt.Errorf("failed to marshal thing: %w", err)
-	}
-
-	req, err := http.NewRequest(http.MethodPost, a.baseURL.String()+connectorThingsEndpoint, bytes.NewBuffer(payload))
-	if err != nil {
-		return connctd.Thing{}, fmt.Errorf("failed to create new request: %w", err)
-	}
-
-	// set headers
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+string(token))
-
-	resp, err := a.httpClient.Do(req.WithContext(ctx))
+	statusCode, body, err := a.do(ctx, OperationCreateThing, a.endpoints[OperationCreateThing].Path, string(token), message)
 	if err != nil {
 		a.logger.WithValues("thing", thing).Error(err, "Failed to create thing", "name", thing.Name)
 		return connctd.Thing{}, fmt.Errorf("failed to create thing: %w", err)
 	}
 
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return connctd.Thing{}, fmt.Errorf("could not read response body: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusCreated {
-		a.logger.Error(ErrorUnexpectedStatusCode, "Could not create thing", "expectedStatusCode", http.StatusCreated, "givenStatusCode", resp.StatusCode, "body", string(body))
-		return connctd.Thing{}, ErrorUnexpectedStatusCode
+	if statusCode != http.StatusCreated {
+		apiErr := newAPIError(statusCode, body)
+		a.logger.Error(apiErr, "Could not create thing", "expectedStatusCode", http.StatusCreated, "givenStatusCode", statusCode, "body", string(body))
+		return connctd.Thing{}, apiErr
 	}
 
 	var res AddThingResponse
@@ -165,12 +441,19 @@ func (a *APIClient) CreateThing(ctx context.Context, token InstantiationToken, t
 
 // UpdateThingPropertyValue implements interface definition.
 func (a *APIClient) UpdateThingPropertyValue(ctx context.Context, token InstantiationToken, thingID string, componentID string, propertyID string, value string, lastUpdate time.Time) error {
+	return a.UpdateThingPropertyValueWithUnit(ctx, token, thingID, componentID, propertyID, value, "", lastUpdate)
+}
+
+// UpdateThingPropertyValueWithUnit implements interface definition.
+func (a *APIClient) UpdateThingPropertyValueWithUnit(ctx context.Context, token InstantiationToken, thingID string, componentID string, propertyID string, value string, unit string, lastUpdate time.Time) error {
 	message := UpdateThingPropertyValueRequest{
 		Value:      value,
+		Unit:       unit,
 		LastUpdate: lastUpdate,
 	}
 
-	return a.doRequest(ctx, http.MethodPut, path.Join(connectorThingsEndpoint, thingID, "components", componentID, "properties", propertyID), string(token), message, http.StatusNoContent)
+	endpoint := a.endpoints[OperationUpdateThingPropertyValue]
+	return a.doRequest(ctx, OperationUpdateThingPropertyValue, path.Join(endpoint.Path, thingID, "components", componentID, "properties", propertyID), string(token), message, http.StatusNoContent)
 }
 
 // UpdateThingStatus implements interface definition.
@@ -179,7 +462,8 @@ func (a *APIClient) UpdateThingStatus(ctx context.Context, token InstantiationTo
 		Status: status,
 	}
 
-	return a.doRequest(ctx, http.MethodPut, path.Join(connectorThingsEndpoint, thingID, "status"), string(token), message, http.StatusNoContent)
+	endpoint := a.endpoints[OperationUpdateThingStatus]
+	return a.doRequest(ctx, OperationUpdateThingStatus, path.Join(endpoint.Path, thingID, "status"), string(token), message, http.StatusNoContent)
 }
 
 // UpdateActionStatus implements interface definition.
@@ -189,7 +473,8 @@ func (a *APIClient) UpdateActionStatus(ctx context.Context, token InstantiationT
 		Error:  e,
 	}
 
-	return a.doRequest(ctx, http.MethodPut, path.Join(connectorActionsEndpoint, actionRequestID), string(token), message, http.StatusNoContent)
+	endpoint := a.endpoints[OperationUpdateActionStatus]
+	return a.doRequest(ctx, OperationUpdateActionStatus, path.Join(endpoint.Path, actionRequestID), string(token), message, http.StatusNoContent)
 }
 
 // UpdateInstallationState implements interface definition.
@@ -199,7 +484,8 @@ func (a *APIClient) UpdateInstallationState(ctx context.Context, token Installat
 		Details: details,
 	}
 
-	return a.doRequest(ctx, http.MethodPost, connectorInstallationStateEndpoint, string(token), message, http.StatusNoContent)
+	endpoint := a.endpoints[OperationUpdateInstallationState]
+	return a.doRequest(ctx, OperationUpdateInstallationState, endpoint.Path, string(token), message, http.StatusNoContent)
 }
 
 // UpdateInstanceState implements interface definition.
@@ -209,66 +495,305 @@ func (a *APIClient) UpdateInstanceState(ctx context.Context, token Instantiation
 		Details: details,
 	}
 
-	return a.doRequest(ctx, http.MethodPost, connectorInstanceStateEndpoint, string(token), message, http.StatusNoContent)
+	endpoint := a.endpoints[OperationUpdateInstanceState]
+	return a.doRequest(ctx, OperationUpdateInstanceState, endpoint.Path, string(token), message, http.StatusNoContent)
 }
 
 // DeleteThing implements interface definition.
 func (a *APIClient) DeleteThing(ctx context.Context, token InstantiationToken, thingID string) error {
-	return a.doRequest(ctx, http.MethodDelete, path.Join(connectorThingsEndpoint, thingID), string(token), nil, http.StatusNoContent)
+	endpoint := a.endpoints[OperationDeleteThing]
+	return a.doRequest(ctx, OperationDeleteThing, path.Join(endpoint.Path, thingID), string(token), nil, http.StatusNoContent)
+}
+
+// UpdateThingBatch implements interface definition.
+func (a *APIClient) UpdateThingBatch(ctx context.Context, token InstantiationToken, thingID string, batch UpdateThingBatchRequest) error {
+	endpoint := a.endpoints[OperationUpdateThingBatch]
+	return a.doRequest(ctx, OperationUpdateThingBatch, path.Join(endpoint.Path, thingID, "batch"), string(token), batch, http.StatusNoContent)
+}
+
+// ListThings implements interface definition.
+// Pages are requested with the query parameters limit and offset until a page is empty or contains less than limit things.
+// To not loop forever on a platform ignoring the offset, it fails with ErrorRepeatedPage if a page equals the previous one
+// and with ErrorTooManyPages after MaxListThingsPages pages.
+func (a *APIClient) ListThings(ctx context.Context, token InstantiationToken) ([]connctd.Thing, error) {
+	things := []connctd.Thing{}
+	var previous []connctd.Thing
+	for pages, offset := 0, 0; ; pages, offset = pages+1, offset+a.pageSize {
+		if pages == MaxListThingsPages {
+			return nil, fmt.Errorf("failed to list things at offset %d: %w", offset, ErrorTooManyPages)
+		}
+
+		page, err := a.listThingsPage(ctx, token, offset)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list things at offset %d: %w", offset, err)
+		}
+		if len(page) == 0 {
+			return things, nil
+		}
+		if samePage(previous, page) {
+			return nil, fmt.Errorf("failed to list things at offset %d: %w", offset, ErrorRepeatedPage)
+		}
+
+		things = append(things, page...)
+		if len(page) < a.pageSize {
+			return things, nil
+		}
+		previous = page
+	}
+}
+
+// samePage returns true if both pages contain the things with the same IDs in the same order.
+func samePage(previous []connctd.Thing, page []connctd.Thing) bool {
+	if len(previous) != len(page) {
+		return false
+	}
+	for i := range page {
+		if previous[i].ID != page[i].ID {
+			return false
+		}
+	}
+	return true
+}
+
+// listThingsPage returns a single page of things starting at the offset.
+func (a *APIClient) listThingsPage(ctx context.Context, token InstantiationToken, offset int) ([]connctd.Thing, error) {
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(a.pageSize))
+	query.Set("offset", strconv.Itoa(offset))
+
+	statusCode, body, err := a.do(ctx, OperationListThings, a.endpoints[OperationListThings].Path+"?"+query.Encode(), string(token), nil)
+	if err != nil {
+		a.logger.Error(err, "Failed to list things", "offset", offset)
+		return nil, err
+	}
+
+	if statusCode != http.StatusOK {
+		apiErr := newAPIError(statusCode, body)
+		a.logger.Error(apiErr, "Could not list things", "offset", offset, "expectedStatusCode", http.StatusOK, "givenStatusCode", statusCode, "body", string(body))
+		return nil, apiErr
+	}
+
+	var things []connctd.Thing
+	if err := json.Unmarshal(body, &things); err != nil {
+		return nil, ErrorUnexpectedResponse
+	}
+	return things, nil
+}
+
+// GetThing implements interface definition.
+func (a *APIClient) GetThing(ctx context.Context, token InstantiationToken, thingID string) (connctd.Thing, error) {
+	endpoint := a.endpoints[OperationGetThing]
+	statusCode, body, err := a.do(ctx, OperationGetThing, path.Join(endpoint.Path, thingID), string(token), nil)
+	if err != nil {
+		a.logger.Error(err, "Failed to get thing", "thingId", thingID)
+		return connctd.Thing{}, fmt.Errorf("failed to get thing: %w", err)
+	}
+
+	if statusCode != http.StatusOK {
+		apiErr := newAPIError(statusCode, body)
+		a.logger.Error(apiErr, "Could not get thing", "thingId", thingID, "expectedStatusCode", http.StatusOK, "givenStatusCode", statusCode, "body", string(body))
+		return connctd.Thing{}, apiErr
+	}
+
+	var thing connctd.Thing
+	if err := json.Unmarshal(body, &thing); err != nil {
+		return connctd.Thing{}, ErrorUnexpectedResponse
+	}
+	return thing, nil
+}
+
+func (a *APIClient) doRequest(ctx context.Context, operation Operation, endpoint string, token string, payload interface{}, expectedStatusCode int) error {
+	statusCode, body, err := a.do(ctx, operation, endpoint, token, payload)
+	if err != nil {
+		return err
+	}
+
+	if statusCode != expectedStatusCode {
+		apiErr := newAPIError(statusCode, body)
+		a.logger.Error(apiErr, "Unexpected response status code received", "endpoint", endpoint, "expectedStatusCode", expectedStatusCode, "givenStatusCode", statusCode, "body", string(body))
+		return apiErr
+	}
+
+	return nil
+}
+
+// do sends a request for the given operation and returns the status code and body of the response.
+// The payload is sent as json if given. Failed requests are retried according to the RetryOptions.
+// If tracing is enabled, all attempts are recorded in a single span.
+func (a *APIClient) do(ctx context.Context, operation Operation, endpoint string, token string, payload interface{}) (int, []byte, error) {
+	if a.tracer == nil {
+		return a.doAttempts(ctx, operation, endpoint, token, payload)
+	}
+
+	ctx, span := a.startSpan(ctx, operation)
+	statusCode, respBody, err := a.doAttempts(ctx, operation, endpoint, token, payload)
+	endSpan(span, statusCode, err)
+	return statusCode, respBody, err
 }
 
-func (a *APIClient) doRequest(ctx context.Context, method string, endpoint string, token string, payload interface{}, expectedStatusCode int) error {
-	logger := a.logger.WithValues("endpoint", endpoint, "expectedStatusCode", expectedStatusCode)
+// doAttempts sends the request until it succeeds or the retry options give up.
+func (a *APIClient) doAttempts(ctx context.Context, operation Operation, endpoint string, token string, payload interface{}) (int, []byte, error) {
+	logger := a.logger.WithValues("endpoint", endpoint)
+
+	// the deadline of the caller takes precedence
+	if _, ok := ctx.Deadline(); !ok {
+		if timeout := a.operationTimeout(operation); timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+	}
 
-	var err error
-	var req *http.Request
+	var payloadBytes []byte
 
 	// append payload if given
 	if payload != nil {
-		payloadBytes, err := json.Marshal(payload)
+		var err error
+		payloadBytes, err = json.Marshal(payload)
 		if err != nil {
 			logger.Error(err, "Failed to marshal request")
-			return fmt.Errorf("failed to marshal request: %w", err)
+			return 0, nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+	}
+
+	maxAttempts := a.retry.MaxAttempts
+	if operation == OperationCreateThing && !a.retry.RetryCreateThing {
+		maxAttempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		statusCode, respBody, err := a.send(ctx, logger, operation, endpoint, token, payloadBytes, payload != nil)
+
+		retryable := (err != nil && ctx.Err() == nil) || (err == nil && a.retry.retryableStatusCode(statusCode))
+		if !retryable || attempt >= maxAttempts {
+			return statusCode, respBody, err
 		}
 
-		req, err = http.NewRequest(method, a.baseURL.String()+endpoint, bytes.NewBuffer(payloadBytes))
-		if err != nil {
-			logger.Error(err, "Failed to create new request")
-			return fmt.Errorf("failed to create new request: %w", err)
+		delay := retryDelay(a.retry.BaseDelay, a.retry.MaxDelay, a.retry.Jitter, attempt)
+		logger.Info("Retrying request", "attempt", attempt, "statusCode", statusCode, "delay", delay)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return statusCode, respBody, err
+		}
+	}
+}
+
+// send sends a single request and returns the status code and body of the response.
+func (a *APIClient) send(ctx context.Context, logger logr.Logger, operation Operation, endpoint string, token string, payloadBytes []byte, hasPayload bool) (int, []byte, error) {
+	var body io.Reader
+	if hasPayload {
+		body = bytes.NewReader(payloadBytes)
+	}
+
+	req, err := http.NewRequest(a.endpoints[operation].Method, a.baseURL.String()+endpoint, body)
+	if err != nil {
+		logger.Error(err, "Failed to create new request")
+		return 0, nil, fmt.Errorf("failed to create new request: %w", err)
+	}
+
+	for key, values := range a.headers {
+		for _, value := range values {
+			req.Header.Add(key, value)
 		}
+	}
 
+	// set additional headers, replacing default headers with the same key
+	if hasPayload {
 		req.Header.Set("Content-Type", "application/json")
 	} else {
-		req, err = http.NewRequest(method, a.baseURL.String()+endpoint, nil)
-		if err != nil {
-			logger.Error(err, "Failed to create new request")
-			return fmt.Errorf("failed to create new request: %w", err)
+		req.Header.Del("Content-Type")
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("User-Agent", a.userAgent)
+	if messageID, ok := MessageIDFromContext(ctx); ok {
+		req.Header.Set(MessageIDHeaderKey, messageID)
+	}
+
+	if a.tracer != nil {
+		a.propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+	}
+
+	if a.rateLimiter != nil {
+		if err := a.rateLimiter.Wait(ctx); err != nil {
+			logger.Error(err, "Gave up waiting for the rate limiter")
+			return 0, nil, fmt.Errorf("failed to send request: %w", err)
 		}
 	}
 
-	// set additional headers
-	req.Header.Set("Authorization", "Bearer "+token)
+	if a.requestSlots != nil {
+		select {
+		case a.requestSlots <- struct{}{}:
+			defer func() { <-a.requestSlots }()
+		case <-ctx.Done():
+			logger.Error(ctx.Err(), "Gave up waiting for a free request slot")
+			return 0, nil, fmt.Errorf("failed to send request: %w", ctx.Err())
+		}
+	}
 
-	resp, err := a.httpClient.Do(req.WithContext(ctx))
+	req = req.WithContext(ctx)
+	a.callOnRequest(logger, req)
+
+	start := time.Now()
+	resp, err := a.httpClient.Do(req)
+	a.callOnResponse(logger, req, resp, err, time.Since(start))
 	if err != nil {
 		logger.Error(err, "Failed to send request")
-		return fmt.Errorf("failed to send request: %w", err)
+		return 0, nil, fmt.Errorf("failed to send request: %w", err)
 	}
 
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
 		logger.Error(err, "Failed to read response body")
-		return fmt.Errorf("could not read response body: %w", err)
+		return 0, nil, fmt.Errorf("could not read response body: %w", err)
 	}
 
-	if resp.StatusCode != expectedStatusCode {
-		logger.Error(ErrorUnexpectedStatusCode, "Unexpected response status code received", "endpoint", endpoint, "givenStatusCode", resp.StatusCode, "body", string(body))
-		return ErrorUnexpectedStatusCode
+	if a.onPayloadSize != nil {
+		a.onPayloadSize(operation, len(payloadBytes), len(respBody))
 	}
 
-	return nil
+	return resp.StatusCode, respBody, nil
+}
+
+// callOnRequest calls the OnRequest hook, if any, and recovers from its panics.
+func (a *APIClient) callOnRequest(logger logr.Logger, req *http.Request) {
+	if a.onRequest == nil {
+		return
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error(fmt.Errorf("%v", r), "Recovered from panic in OnRequest hook")
+		}
+	}()
+	a.onRequest(req)
+}
+
+// callOnResponse calls the OnResponse hook, if any, and recovers from its panics.
+func (a *APIClient) callOnResponse(logger logr.Logger, req *http.Request, resp *http.Response, err error, duration time.Duration) {
+	if a.onResponse == nil {
+		return
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error(fmt.Errorf("%v", r), "Recovered from panic in OnResponse hook")
+		}
+	}()
+	a.onResponse(req, resp, err, duration)
+}
+
+// operationTimeout returns the timeout of the given operation, falling back to the request timeout.
+func (a *APIClient) operationTimeout(operation Operation) time.Duration {
+	if timeout, ok := a.timeouts[operation]; ok {
+		return timeout
+	}
+	return a.timeout
 }
 
 // The following errors can be returned by the API client:
@@ -277,4 +802,45 @@ var (
 	ErrorMissingLogger        = errors.New("a logger needs to be passed")
 	ErrorUnexpectedStatusCode = errors.New("the resulting status code does not match with expectation")
 	ErrorUnexpectedResponse   = errors.New("remote site replied with unexpected contents")
+	ErrorRepeatedPage         = errors.New("remote site replied with the same page of things again")
+	ErrorTooManyPages         = errors.New("remote site replied with too many pages of things")
 )
+
+// APIError is returned by the API client if the connctd platform replied with an unexpected status code.
+// Code and Description are taken from the error response of the platform if it could be parsed.
+// For compatibility errors.Is(err, ErrorUnexpectedStatusCode) reports true for all API errors.
+type APIError struct {
+	StatusCode  int
+	Code        string
+	Description string
+	Body        []byte
+}
+
+// newAPIError creates an API error for the response with the given status code and body.
+func newAPIError(statusCode int, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: statusCode, Body: body}
+
+	var platformErr Error
+	if err := json.Unmarshal(body, &platformErr); err == nil {
+		apiErr.Code = platformErr.APIError
+		apiErr.Description = platformErr.Description
+	}
+	return apiErr
+}
+
+// Error returns the status code and, if given, the error code and description of the response.
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("%s: status %d", ErrorUnexpectedStatusCode.Error(), e.StatusCode)
+	if e.Code != "" {
+		msg += " " + e.Code
+	}
+	if e.Description != "" {
+		msg += ": " + e.Description
+	}
+	return msg
+}
+
+// Is reports true for ErrorUnexpectedStatusCode.
+func (e *APIError) Is(target error) bool {
+	return target == ErrorUnexpectedStatusCode
+}
@@ -3,9 +3,14 @@ package connector
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -75,7 +80,7 @@ func TestCreateThing(t *testing.T) {
 			thing, err := client.CreateThing(context.Background(), "", connctd.Thing{Name: "DummyThing"})
 
 			if currTest.expectedError != nil {
-				assert.Equal(r, currTest.expectedError, err)
+				assert.True(r, errors.Is(err, currTest.expectedError), err)
 			}
 
 			if currTest.expectedThingID != "" {
@@ -97,9 +102,20 @@ var deleteThingTests = []struct {
 		},
 		expectedError: ErrorUnexpectedStatusCode,
 	},
+	{
+		name: "Delete thing fails if thing does not exist",
+		handler: func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		},
+		expectedError: ErrorUnexpectedStatusCode,
+	},
 	{
 		name: "Delete thing successful",
 		handler: func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodDelete || r.URL.Path != "/"+connectorThingsEndpoint+"/fooid" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
 			w.WriteHeader(http.StatusNoContent)
 		},
 	},
@@ -114,14 +130,13 @@ func TestDeleteThing(t *testing.T) {
 			url, err := url.Parse(dummyServer.URL + "/")
 			require.Nil(r, err)
 
-			client, err := NewClient(&ClientOptions{ConnctdBaseURL: url}, DefaultLogger)
+			// use the interface, so the test fails if DeleteThing is removed from it
+			var client Client
+			client, err = NewClient(&ClientOptions{ConnctdBaseURL: url}, DefaultLogger)
 			require.Nil(r, err)
 
 			err = client.DeleteThing(context.Background(), "", "fooid")
-
-			if currTest.expectedError != nil {
-				assert.Equal(r, currTest.expectedError, err)
-			}
+			assert.True(r, errors.Is(err, currTest.expectedError), err)
 		})
 	}
 }
@@ -177,12 +192,43 @@ func TestUpdateThingPropertyValue(t *testing.T) {
 			require.Nil(r, err)
 
 			err = client.UpdateThingPropertyValue(context.Background(), "", "fooThingID", "fooComponentID", "fooPropertyID", "foo", time.Now())
-			assert.Equal(r, currTest.expectedError, err)
+			assert.True(r, errors.Is(err, currTest.expectedError), err)
 		})
 	}
 
 }
 
+func TestUpdateThingPropertyValueWithUnit(t *testing.T) {
+	var bodies []map[string]interface{}
+	dummyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		bodies = append(bodies, body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer dummyServer.Close()
+
+	url, err := url.Parse(dummyServer.URL + "/")
+	require.Nil(t, err)
+
+	client, err := NewClient(&ClientOptions{ConnctdBaseURL: url}, DefaultLogger)
+	require.Nil(t, err)
+
+	require.Nil(t, client.UpdateThingPropertyValueWithUnit(context.Background(), "", "thing", "sensor", "temperature", "70", "°F", time.Now()))
+	require.Nil(t, client.UpdateThingPropertyValue(context.Background(), "", "thing", "sensor", "temperature", "21", time.Now()))
+
+	require.Len(t, bodies, 2)
+	assert.Equal(t, "70", bodies[0]["value"])
+	assert.Equal(t, "°F", bodies[0]["unit"])
+
+	// without unit the declared unit of the property is kept
+	assert.Equal(t, "21", bodies[1]["value"])
+	assert.NotContains(t, bodies[1], "unit")
+}
+
 var updateInstanceStateTests = []struct {
 	name          string
 	handler       http.HandlerFunc
@@ -228,7 +274,7 @@ func TestUpdateInstanceState(t *testing.T) {
 			require.Nil(r, err)
 
 			err = client.UpdateInstanceState(context.Background(), "", InstantiationStateComplete, nil)
-			assert.Equal(r, currTest.expectedError, err)
+			assert.True(r, errors.Is(err, currTest.expectedError), err)
 		})
 	}
 
@@ -269,7 +315,7 @@ func TestUpdateActionStatus(t *testing.T) {
 			err = client.UpdateActionStatus(context.Background(), "", "fooid", ActionRequestStatusCompleted, "")
 
 			if currTest.expectedError != nil {
-				assert.Equal(r, currTest.expectedError, err)
+				assert.True(r, errors.Is(err, currTest.expectedError), err)
 			}
 		})
 	}
@@ -310,7 +356,7 @@ func TestUpdateThingStatus(t *testing.T) {
 			err = client.UpdateThingStatus(context.Background(), "", "foothingid", connctd.StatusTypeAvailable)
 
 			if currTest.expectedError != nil {
-				assert.Equal(r, currTest.expectedError, err)
+				assert.True(r, errors.Is(err, currTest.expectedError), err)
 			}
 		})
 	}
@@ -351,8 +397,820 @@ func TestUpdateInstallationState(t *testing.T) {
 			err = client.UpdateInstallationState(context.Background(), "footoken", InstallationStateOngoing, nil)
 
 			if currTest.expectedError != nil {
-				assert.Equal(r, currTest.expectedError, err)
+				assert.True(r, errors.Is(err, currTest.expectedError), err)
 			}
 		})
 	}
 }
+
+func TestEndpointOverride(t *testing.T) {
+	var method, requestPath string
+	dummyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		requestPath = r.URL.Path
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer dummyServer.Close()
+
+	url, err := url.Parse(dummyServer.URL + "/")
+	require.Nil(t, err)
+
+	client, err := NewClient(&ClientOptions{
+		ConnctdBaseURL: url,
+		Endpoints: map[Operation]EndpointSpec{
+			OperationUpdateInstanceState: {Method: http.MethodPut, Path: "custom/state"},
+		},
+	}, DefaultLogger)
+	require.Nil(t, err)
+
+	err = client.UpdateInstanceState(context.Background(), "", InstantiationStateComplete, nil)
+	require.Nil(t, err)
+	assert.Equal(t, http.MethodPut, method)
+	assert.Equal(t, "/custom/state", requestPath)
+
+	// operations without override keep their defaults
+	err = client.UpdateInstallationState(context.Background(), "", InstallationStateComplete, nil)
+	require.Nil(t, err)
+	assert.Equal(t, http.MethodPost, method)
+	assert.Equal(t, "/"+connectorInstallationStateEndpoint, requestPath)
+}
+
+func TestEndpointPaths(t *testing.T) {
+	var method, requestPath string
+	dummyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		requestPath = r.URL.Path
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer dummyServer.Close()
+
+	url, err := url.Parse(dummyServer.URL + "/")
+	require.Nil(t, err)
+
+	client, err := NewClient(&ClientOptions{
+		ConnctdBaseURL: url,
+		Paths: EndpointPaths{
+			Things:        "mock/things",
+			InstanceState: "mock/instance/state",
+		},
+		Endpoints: map[Operation]EndpointSpec{
+			OperationUpdateThingStatus: {Method: http.MethodPut, Path: "custom/status"},
+		},
+	}, DefaultLogger)
+	require.Nil(t, err)
+
+	err = client.DeleteThing(context.Background(), "", "42")
+	require.Nil(t, err)
+	assert.Equal(t, http.MethodDelete, method)
+	assert.Equal(t, "/mock/things/42", requestPath)
+
+	err = client.UpdateInstanceState(context.Background(), "", InstantiationStateComplete, nil)
+	require.Nil(t, err)
+	assert.Equal(t, "/mock/instance/state", requestPath)
+
+	// empty paths keep their defaults
+	err = client.UpdateInstallationState(context.Background(), "", InstallationStateComplete, nil)
+	require.Nil(t, err)
+	assert.Equal(t, "/"+connectorInstallationStateEndpoint, requestPath)
+
+	// endpoint overrides take precedence over the paths
+	err = client.UpdateThingStatus(context.Background(), "", "42", connctd.StatusTypeAvailable)
+	require.Nil(t, err)
+	assert.Equal(t, "/custom/status/42/status", requestPath)
+}
+
+func TestUpdateThingBatch(t *testing.T) {
+	var method, requestPath string
+	var request UpdateThingBatchRequest
+	dummyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		requestPath = r.URL.Path
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer dummyServer.Close()
+
+	url, err := url.Parse(dummyServer.URL + "/")
+	require.Nil(t, err)
+
+	client, err := NewClient(&ClientOptions{ConnctdBaseURL: url}, DefaultLogger)
+	require.Nil(t, err)
+
+	batch := UpdateThingBatchRequest{
+		Properties: []ThingPropertyValue{
+			{ComponentID: "sensor", PropertyID: "temperature", Value: "21.5"},
+			{ComponentID: "sensor", PropertyID: "humidity", Value: "40"},
+		},
+		Status: connctd.StatusTypeAvailable,
+	}
+	err = client.UpdateThingBatch(context.Background(), "", "foothingid", batch)
+	require.Nil(t, err)
+
+	assert.Equal(t, http.MethodPut, method)
+	assert.Equal(t, "/"+connectorThingsEndpoint+"/foothingid/batch", requestPath)
+	assert.Equal(t, batch.Properties[1].Value, request.Properties[1].Value)
+	assert.Equal(t, batch.Status, request.Status)
+}
+
+func TestOperationTimeouts(t *testing.T) {
+	dummyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(200 * time.Millisecond):
+		case <-r.Context().Done():
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer dummyServer.Close()
+
+	url, err := url.Parse(dummyServer.URL + "/")
+	require.Nil(t, err)
+
+	client, err := NewClient(&ClientOptions{
+		ConnctdBaseURL:    url,
+		RequestTimeout:    20 * time.Millisecond,
+		OperationTimeouts: map[Operation]time.Duration{OperationUpdateInstanceState: time.Second},
+	}, DefaultLogger)
+	require.Nil(t, err)
+
+	// the operation timeout overrides the request timeout
+	err = client.UpdateInstanceState(context.Background(), "", InstantiationStateComplete, nil)
+	assert.Nil(t, err)
+
+	// other operations use the request timeout
+	err = client.UpdateInstallationState(context.Background(), "", InstallationStateComplete, nil)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), err)
+
+	// a shorter deadline of the caller still applies
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = client.UpdateInstanceState(ctx, "", InstantiationStateComplete, nil)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), err)
+}
+
+func TestListThings(t *testing.T) {
+	dummyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/"+connectorThingsEndpoint {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`[{"id":"thing-1","name":"Lamp"},{"id":"thing-2","name":"Sensor"}]`))
+	}))
+	defer dummyServer.Close()
+
+	url, err := url.Parse(dummyServer.URL + "/")
+	require.Nil(t, err)
+
+	client, err := NewClient(&ClientOptions{ConnctdBaseURL: url}, DefaultLogger)
+	require.Nil(t, err)
+
+	things, err := client.ListThings(context.Background(), "token")
+	require.Nil(t, err)
+	require.Len(t, things, 2)
+	assert.Equal(t, "thing-2", things[1].ID)
+	assert.Equal(t, "Sensor", things[1].Name)
+}
+
+func TestListThingsPagination(t *testing.T) {
+	var pages = map[string]string{
+		"0": `[{"id":"thing-1"},{"id":"thing-2"}]`,
+		"2": `[{"id":"thing-3"}]`,
+	}
+
+	var requestedOffsets []string
+	failOffset := ""
+	dummyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		offset := r.URL.Query().Get("offset")
+		requestedOffsets = append(requestedOffsets, offset)
+		if r.URL.Query().Get("limit") != "2" || offset == failOffset {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(pages[offset]))
+	}))
+	defer dummyServer.Close()
+
+	url, err := url.Parse(dummyServer.URL + "/")
+	require.Nil(t, err)
+
+	client, err := NewClient(&ClientOptions{ConnctdBaseURL: url, ListThingsPageSize: 2}, DefaultLogger)
+	require.Nil(t, err)
+
+	things, err := client.ListThings(context.Background(), "token")
+	require.Nil(t, err)
+	require.Len(t, things, 3)
+	assert.Equal(t, "thing-1", things[0].ID)
+	assert.Equal(t, "thing-3", things[2].ID)
+	assert.Equal(t, []string{"0", "2"}, requestedOffsets)
+
+	// a failing page fails the whole listing
+	failOffset = "2"
+	things, err = client.ListThings(context.Background(), "token")
+	assert.Nil(t, things)
+	assert.True(t, errors.Is(err, ErrorUnexpectedStatusCode), err)
+	assert.Contains(t, err.Error(), "offset 2")
+}
+
+func TestListThingsStopsPaging(t *testing.T) {
+	var handler func(offset int) string
+	requests := 0
+	dummyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(handler(offset)))
+	}))
+	defer dummyServer.Close()
+
+	url, err := url.Parse(dummyServer.URL + "/")
+	require.Nil(t, err)
+	client, err := NewClient(&ClientOptions{ConnctdBaseURL: url, ListThingsPageSize: 1}, DefaultLogger)
+	require.Nil(t, err)
+
+	// an empty page ends the listing
+	handler = func(offset int) string {
+		if offset == 0 {
+			return `[{"id":"thing-1"}]`
+		}
+		return `[]`
+	}
+	things, err := client.ListThings(context.Background(), "token")
+	require.Nil(t, err)
+	assert.Len(t, things, 1)
+	assert.Equal(t, 2, requests)
+
+	// a platform ignoring the offset returns the same page again
+	requests = 0
+	handler = func(offset int) string { return `[{"id":"thing-1"}]` }
+	things, err = client.ListThings(context.Background(), "token")
+	assert.Nil(t, things)
+	assert.True(t, errors.Is(err, ErrorRepeatedPage), err)
+	assert.Equal(t, 2, requests)
+
+	// the number of pages is bounded
+	requests = 0
+	handler = func(offset int) string { return fmt.Sprintf(`[{"id":"thing-%d"}]`, offset) }
+	things, err = client.ListThings(context.Background(), "token")
+	assert.Nil(t, things)
+	assert.True(t, errors.Is(err, ErrorTooManyPages), err)
+	assert.Equal(t, MaxListThingsPages, requests)
+}
+
+func TestGetThing(t *testing.T) {
+	var getThingTests = []struct {
+		name          string
+		handler       http.HandlerFunc
+		expectedError error
+		expectedThing connctd.Thing
+	}{
+		{
+			name: "thing returned",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodGet || r.URL.Path != "/"+connectorThingsEndpoint+"/thing-1" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"id":"thing-1","name":"Lamp","status":"AVAILABLE"}`))
+			},
+			expectedThing: connctd.Thing{ID: "thing-1", Name: "Lamp", Status: connctd.StatusTypeAvailable},
+		},
+		{
+			name: "unexpected status code",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			},
+			expectedError: ErrorUnexpectedStatusCode,
+		},
+		{
+			name: "unexpected response",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"id":`))
+			},
+			expectedError: ErrorUnexpectedResponse,
+		},
+	}
+
+	for _, curr := range getThingTests {
+		t.Run(curr.name, func(t *testing.T) {
+			dummyServer := httptest.NewServer(curr.handler)
+			defer dummyServer.Close()
+
+			url, err := url.Parse(dummyServer.URL + "/")
+			require.Nil(t, err)
+
+			client, err := NewClient(&ClientOptions{ConnctdBaseURL: url}, DefaultLogger)
+			require.Nil(t, err)
+
+			thing, err := client.GetThing(context.Background(), "token", "thing-1")
+			assert.True(t, errors.Is(err, curr.expectedError), err)
+			assert.Equal(t, curr.expectedThing, thing)
+		})
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	var userAgent string
+	dummyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer dummyServer.Close()
+
+	url, err := url.Parse(dummyServer.URL + "/")
+	require.Nil(t, err)
+
+	client, err := NewClient(&ClientOptions{ConnctdBaseURL: url}, DefaultLogger)
+	require.Nil(t, err)
+
+	err = client.UpdateInstanceState(context.Background(), "", InstantiationStateComplete, nil)
+	require.Nil(t, err)
+	assert.Regexp(t, `^connector-go/\S+ api/v1$`, userAgent)
+	assert.Equal(t, "connector-go/"+SDKVersion+" api/"+APIVersion, userAgent)
+}
+
+func TestPayloadSizeObservation(t *testing.T) {
+	responseBody := []byte(`{"id":"123"}`)
+	dummyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write(responseBody)
+	}))
+	defer dummyServer.Close()
+
+	url, err := url.Parse(dummyServer.URL + "/")
+	require.Nil(t, err)
+
+	type observation struct {
+		operation    Operation
+		requestSize  int
+		responseSize int
+	}
+	var observations []observation
+
+	client, err := NewClient(&ClientOptions{
+		ConnctdBaseURL: url,
+		OnPayloadSize: func(operation Operation, requestSize int, responseSize int) {
+			observations = append(observations, observation{operation, requestSize, responseSize})
+		},
+	}, DefaultLogger)
+	require.Nil(t, err)
+
+	thing := connctd.Thing{Name: "DummyThing"}
+	_, err = client.CreateThing(context.Background(), "", thing)
+	require.Nil(t, err)
+
+	expectedBody, err := json.Marshal(AddThingRequest{Thing: thing})
+	require.Nil(t, err)
+
+	assert.Equal(t, []observation{{OperationCreateThing, len(expectedBody), len(responseBody)}}, observations)
+}
+
+func TestMaxConcurrentRequests(t *testing.T) {
+	const limit = 3
+
+	var inFlight, maxInFlight int32
+	dummyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if current <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, current) {
+				break
+			}
+		}
+
+		time.Sleep(5 * time.Millisecond)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer dummyServer.Close()
+
+	url, err := url.Parse(dummyServer.URL + "/")
+	require.Nil(t, err)
+
+	client, err := NewClient(&ClientOptions{ConnctdBaseURL: url, MaxConcurrentRequests: limit}, DefaultLogger)
+	require.Nil(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Nil(t, client.UpdateThingPropertyValue(context.Background(), "", "thing", "component", "property", "value", time.Now()))
+		}()
+	}
+	wg.Wait()
+
+	assert.LessOrEqual(t, atomic.LoadInt32(&maxInFlight), int32(limit))
+	assert.Greater(t, atomic.LoadInt32(&maxInFlight), int32(0))
+
+	// waiting for a free slot respects the context
+	blocking, err := NewClient(&ClientOptions{ConnctdBaseURL: url, MaxConcurrentRequests: 1}, DefaultLogger)
+	require.Nil(t, err)
+	blocking.(*APIClient).requestSlots <- struct{}{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = blocking.UpdateThingPropertyValue(ctx, "", "thing", "component", "property", "value", time.Now())
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestClientRetries(t *testing.T) {
+	var retryTests = []struct {
+		name             string
+		failures         int32
+		failureStatus    int
+		options          RetryOptions
+		createThing      bool
+		expectedError    bool
+		expectedRequests int32
+	}{
+		{
+			name:             "retries disabled by default",
+			failures:         1,
+			failureStatus:    http.StatusServiceUnavailable,
+			expectedError:    true,
+			expectedRequests: 1,
+		},
+		{
+			name:             "succeeds after failures",
+			failures:         2,
+			failureStatus:    http.StatusServiceUnavailable,
+			options:          RetryOptions{MaxAttempts: 3, BaseDelay: time.Millisecond, Jitter: 0.5},
+			expectedRequests: 3,
+		},
+		{
+			name:             "attempts exhausted",
+			failures:         3,
+			failureStatus:    http.StatusBadGateway,
+			options:          RetryOptions{MaxAttempts: 3, BaseDelay: time.Millisecond},
+			expectedError:    true,
+			expectedRequests: 3,
+		},
+		{
+			name:             "client errors are not retried",
+			failures:         1,
+			failureStatus:    http.StatusBadRequest,
+			options:          RetryOptions{MaxAttempts: 3, BaseDelay: time.Millisecond},
+			expectedError:    true,
+			expectedRequests: 1,
+		},
+		{
+			name:          "custom retryable status codes",
+			failures:      1,
+			failureStatus: http.StatusTooManyRequests,
+			options: RetryOptions{MaxAttempts: 3, BaseDelay: time.Millisecond, RetryableStatusCode: func(statusCode int) bool {
+				return statusCode == http.StatusTooManyRequests
+			}},
+			expectedRequests: 2,
+		},
+		{
+			name:             "create thing is not retried by default",
+			failures:         1,
+			failureStatus:    http.StatusServiceUnavailable,
+			options:          RetryOptions{MaxAttempts: 3, BaseDelay: time.Millisecond},
+			createThing:      true,
+			expectedError:    true,
+			expectedRequests: 1,
+		},
+		{
+			name:             "create thing retried if enabled",
+			failures:         1,
+			failureStatus:    http.StatusServiceUnavailable,
+			options:          RetryOptions{MaxAttempts: 3, BaseDelay: time.Millisecond, RetryCreateThing: true},
+			createThing:      true,
+			expectedRequests: 2,
+		},
+	}
+
+	for _, curr := range retryTests {
+		t.Run(curr.name, func(t *testing.T) {
+			var requests int32
+			dummyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&requests, 1) <= curr.failures {
+					w.WriteHeader(curr.failureStatus)
+					return
+				}
+
+				// the payload has to be sent again with each attempt
+				var payload map[string]interface{}
+				if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+
+				if r.URL.Path == "/"+connectorThingsEndpoint {
+					w.WriteHeader(http.StatusCreated)
+					json.NewEncoder(w).Encode(connctd.Thing{ID: "thing"})
+					return
+				}
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer dummyServer.Close()
+
+			url, err := url.Parse(dummyServer.URL + "/")
+			require.Nil(t, err)
+
+			client, err := NewClient(&ClientOptions{ConnctdBaseURL: url, Retry: curr.options}, DefaultLogger)
+			require.Nil(t, err)
+
+			if curr.createThing {
+				_, err = client.CreateThing(context.Background(), "", connctd.Thing{Name: "thing"})
+			} else {
+				err = client.UpdateInstanceState(context.Background(), "", InstantiationStateComplete, nil)
+			}
+
+			assert.Equal(t, curr.expectedError, err != nil, err)
+			assert.Equal(t, curr.expectedRequests, atomic.LoadInt32(&requests))
+		})
+	}
+}
+
+func TestClientRetriesStopOnCancel(t *testing.T) {
+	var requests int32
+	dummyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer dummyServer.Close()
+
+	url, err := url.Parse(dummyServer.URL + "/")
+	require.Nil(t, err)
+
+	client, err := NewClient(&ClientOptions{
+		ConnctdBaseURL: url,
+		Retry:          RetryOptions{MaxAttempts: 5, BaseDelay: time.Hour},
+	}, DefaultLogger)
+	require.Nil(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err = client.UpdateInstanceState(ctx, "", InstantiationStateComplete, nil)
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
+
+func TestRetryDelay(t *testing.T) {
+	assert.Equal(t, 100*time.Millisecond, retryDelay(100*time.Millisecond, time.Second, 0, 1))
+	assert.Equal(t, 400*time.Millisecond, retryDelay(100*time.Millisecond, time.Second, 0, 3))
+	assert.Equal(t, time.Second, retryDelay(100*time.Millisecond, time.Second, 0, 10))
+
+	for i := 0; i < 10; i++ {
+		delay := retryDelay(100*time.Millisecond, time.Second, 0.5, 1)
+		assert.True(t, delay >= 50*time.Millisecond && delay <= 100*time.Millisecond, delay)
+	}
+}
+
+// intervalLimiter allows one request per interval.
+type intervalLimiter struct {
+	mtx      sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func (l *intervalLimiter) Wait(ctx context.Context) error {
+	l.mtx.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mtx.Unlock()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	var mtx sync.Mutex
+	var received []time.Time
+	dummyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		received = append(received, time.Now())
+		mtx.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer dummyServer.Close()
+
+	url, err := url.Parse(dummyServer.URL + "/")
+	require.Nil(t, err)
+
+	interval := 20 * time.Millisecond
+	client, err := NewClient(&ClientOptions{
+		ConnctdBaseURL: url,
+		RateLimiter:    &intervalLimiter{interval: interval},
+	}, DefaultLogger)
+	require.Nil(t, err)
+
+	// different operations share the limiter
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 0 {
+				assert.Nil(t, client.UpdateInstanceState(context.Background(), "", InstantiationStateComplete, nil))
+			} else {
+				assert.Nil(t, client.UpdateActionStatus(context.Background(), "", "action", ActionRequestStatusCompleted, ""))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	require.Len(t, received, 4)
+	assert.True(t, received[3].Sub(received[0]) >= 3*interval-time.Millisecond, received[3].Sub(received[0]))
+
+	// a cancelled context aborts the wait
+	limited, err := NewClient(&ClientOptions{
+		ConnctdBaseURL: url,
+		RateLimiter:    &intervalLimiter{interval: time.Hour, next: time.Now().Add(time.Hour)},
+	}, DefaultLogger)
+	require.Nil(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = limited.UpdateInstanceState(ctx, "", InstantiationStateComplete, nil)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), err)
+	assert.Len(t, received, 4)
+}
+
+func TestAPIError(t *testing.T) {
+	dummyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/"+connectorThingsEndpoint+"/thing-1" {
+			ErrorThingNotFound.Write(w)
+			return
+		}
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte("<html>bad gateway</html>"))
+	}))
+	defer dummyServer.Close()
+
+	url, err := url.Parse(dummyServer.URL + "/")
+	require.Nil(t, err)
+
+	client, err := NewClient(&ClientOptions{ConnctdBaseURL: url}, DefaultLogger)
+	require.Nil(t, err)
+
+	// the error response of the platform is parsed
+	err = client.DeleteThing(context.Background(), "token", "thing-1")
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr), err)
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	assert.Equal(t, "THING_NOT_FOUND", apiErr.Code)
+	assert.Equal(t, "Thing not found", apiErr.Description)
+	assert.True(t, errors.Is(err, ErrorUnexpectedStatusCode))
+	assert.Equal(t, ErrorKindPermanent, KindOf(err))
+
+	// other bodies are kept as is
+	_, err = client.GetThing(context.Background(), "token", "thing-2")
+	require.True(t, errors.As(err, &apiErr), err)
+	assert.Equal(t, http.StatusBadGateway, apiErr.StatusCode)
+	assert.Empty(t, apiErr.Code)
+	assert.Equal(t, "<html>bad gateway</html>", string(apiErr.Body))
+	assert.True(t, errors.Is(err, ErrorUnexpectedStatusCode))
+	assert.True(t, IsRetryable(err))
+}
+
+func TestRequestTimeout(t *testing.T) {
+	dummyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(100 * time.Millisecond):
+		case <-r.Context().Done():
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer dummyServer.Close()
+
+	url, err := url.Parse(dummyServer.URL + "/")
+	require.Nil(t, err)
+
+	assert.Equal(t, DefaultRequestTimeout, DefaultOptions().RequestTimeout)
+
+	options := DefaultOptions()
+	options.ConnctdBaseURL = url
+	options.RequestTimeout = 20 * time.Millisecond
+	client, err := NewClient(options, DefaultLogger)
+	require.Nil(t, err)
+
+	// a slow response does not block callers without deadline
+	start := time.Now()
+	err = client.UpdateThingStatus(context.Background(), "", "thing", connctd.StatusTypeAvailable)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), err)
+	assert.True(t, time.Since(start) < 100*time.Millisecond)
+
+	// the deadline of the caller is not shortened
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err = client.UpdateThingStatus(ctx, "", "thing", connctd.StatusTypeAvailable)
+	assert.Nil(t, err)
+}
+
+func TestCustomHeaders(t *testing.T) {
+	var received http.Header
+	dummyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer dummyServer.Close()
+
+	url, err := url.Parse(dummyServer.URL + "/")
+	require.Nil(t, err)
+
+	headers := http.Header{}
+	headers.Set("X-Connector", "weather")
+	headers.Add("X-Tags", "a")
+	headers.Add("X-Tags", "b")
+	headers.Set("Authorization", "Bearer other")
+	headers.Set("Content-Type", "text/plain")
+
+	client, err := NewClient(&ClientOptions{
+		ConnctdBaseURL: url,
+		UserAgent:      "weather-connector/1.0",
+		DefaultHeaders: headers,
+	}, DefaultLogger)
+	require.Nil(t, err)
+
+	// modifications after the creation of the client are ignored
+	headers.Set("X-Connector", "modified")
+
+	err = client.UpdateInstanceState(context.Background(), "token", InstantiationStateComplete, nil)
+	require.Nil(t, err)
+	assert.Equal(t, "weather-connector/1.0", received.Get("User-Agent"))
+	assert.Equal(t, "weather", received.Get("X-Connector"))
+	assert.Equal(t, []string{"a", "b"}, received.Values("X-Tags"))
+	assert.Equal(t, "Bearer token", received.Get("Authorization"))
+	assert.Equal(t, "application/json", received.Get("Content-Type"))
+
+	// requests without payload do not claim a content type
+	err = client.DeleteThing(context.Background(), "token", "thing-1")
+	require.Nil(t, err)
+	assert.Empty(t, received.Get("Content-Type"))
+}
+
+func TestRequestHooks(t *testing.T) {
+	dummyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"42"}`))
+	}))
+	defer dummyServer.Close()
+
+	url, err := url.Parse(dummyServer.URL + "/")
+	require.Nil(t, err)
+
+	var requestPath, responsePath string
+	var status int
+	var duration time.Duration
+	client, err := NewClient(&ClientOptions{
+		ConnctdBaseURL: url,
+		OnRequest: func(req *http.Request) {
+			requestPath = req.URL.Path
+		},
+		OnResponse: func(req *http.Request, resp *http.Response, err error, d time.Duration) {
+			require.Nil(t, err)
+			responsePath = req.URL.Path
+			status = resp.StatusCode
+			duration = d
+		},
+	}, DefaultLogger)
+	require.Nil(t, err)
+
+	thing, err := client.CreateThing(context.Background(), "", connctd.Thing{Name: "DummyThing"})
+	require.Nil(t, err)
+	assert.Equal(t, "42", thing.ID)
+	assert.Equal(t, "/"+connectorThingsEndpoint, requestPath)
+	assert.Equal(t, "/"+connectorThingsEndpoint, responsePath)
+	assert.Equal(t, http.StatusCreated, status)
+	assert.True(t, duration > 0)
+
+	// hooks are called for failing requests and their panics do not affect the request
+	dummyServer.Close()
+	var responseErr error
+	client, err = NewClient(&ClientOptions{
+		ConnctdBaseURL: url,
+		OnRequest: func(req *http.Request) {
+			panic("request hook")
+		},
+		OnResponse: func(req *http.Request, resp *http.Response, err error, d time.Duration) {
+			responseErr = err
+			panic("response hook")
+		},
+	}, DefaultLogger)
+	require.Nil(t, err)
+
+	_, err = client.CreateThing(context.Background(), "", connctd.Thing{Name: "DummyThing"})
+	assert.Error(t, err)
+	assert.Error(t, responseErr)
+}
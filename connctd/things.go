@@ -1,9 +1,13 @@
 package connctd
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 	"unicode/utf8"
 )
@@ -12,6 +16,15 @@ var (
 	urlConform, _ = regexp.Compile("^[a-zA-Z0-9-_]{1,200}$")
 )
 
+// Errors returned by Property.ValidateValueInRange:
+var (
+	ErrorValueOutOfRange = errors.New("value is out of range")
+	ErrorValueNotAllowed = errors.New("value is not allowed")
+)
+
+// ErrorInvalidValue is returned by ValidateValueType if the value does not match its value type.
+var ErrorInvalidValue = errors.New("value does not match the value type")
+
 // Thing describes a third party device or service
 type Thing struct {
 	ID              string           `json:"id"`
@@ -53,6 +66,16 @@ func (t *Thing) Verify() error {
 	return nil
 }
 
+// Normalize sets the MainComponentID to the ID of the only component if the thing has exactly one component
+// and no main component is set. Afterwards the thing is verified.
+// Things with multiple components are left untouched.
+func (t *Thing) Normalize() error {
+	if t.MainComponentID == "" && len(t.Components) == 1 {
+		t.MainComponentID = t.Components[0].ID
+	}
+	return t.Verify()
+}
+
 func (c *Component) Verify() error {
 	if c.ID == "" {
 		return fmt.Errorf("component has no valid id")
@@ -104,10 +127,51 @@ func (p *Property) Verify() error {
 		return fmt.Errorf("one or more property ids are missing")
 	} else if !urlConform.MatchString(p.ID) {
 		return fmt.Errorf("at least one property id contains invalid characters. Allowed is a-Z, 0-9, -, _")
-	} else if err := verifyString(p.Name); err != nil {
+	} else if err := VerifyString(p.Name); err != nil {
+		return err
+	} else if p.Min != nil && p.Max != nil && *p.Min > *p.Max {
+		return fmt.Errorf("min of property %s is greater than its max", p.ID)
+	}
+
+	return nil
+}
+
+// ValidateValue checks that the value matches the value type of the property and satisfies its constraints,
+// see ValidateValueType and ValidateValueInRange.
+func (p *Property) ValidateValue(value string) error {
+	if err := ValidateValueType(p.Type, value); err != nil {
 		return err
 	}
+	return p.ValidateValueInRange(value)
+}
+
+// ValidateValueInRange checks the value against the optional Min, Max and Enum constraints of the property.
+// Values of properties without constraints are always valid.
+func (p *Property) ValidateValueInRange(value string) error {
+	if len(p.Enum) > 0 {
+		allowed := false
+		for _, enumValue := range p.Enum {
+			allowed = allowed || enumValue == value
+		}
+		if !allowed {
+			return fmt.Errorf("%w: %q is not one of %v", ErrorValueNotAllowed, value, p.Enum)
+		}
+	}
+
+	if p.Min == nil && p.Max == nil {
+		return nil
+	}
 
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("%w: %q is not a number", ErrorValueOutOfRange, value)
+	}
+	if p.Min != nil && number < *p.Min {
+		return fmt.Errorf("%w: %v is less than %v", ErrorValueOutOfRange, number, *p.Min)
+	}
+	if p.Max != nil && number > *p.Max {
+		return fmt.Errorf("%w: %v is greater than %v", ErrorValueOutOfRange, number, *p.Max)
+	}
 	return nil
 }
 
@@ -120,8 +184,9 @@ func (a *Action) Verify() error {
 	return nil
 }
 
-// verifyString checks for invalid user input
-func verifyString(input string) error {
+// VerifyString checks for invalid user input.
+// It returns an error if the input is not valid UTF-8.
+func VerifyString(input string) error {
 	if !utf8.ValidString(input) {
 		return fmt.Errorf("at least one given string contains invalid characters")
 	}
@@ -156,6 +221,13 @@ type Property struct {
 	Type         ValueType `json:"type"`
 	LastUpdate   time.Time `json:"lastUpdate"`
 	PropertyType string    `json:"propertyType"`
+
+	// Min and Max optionally limit the values of number properties.
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
+
+	// Enum optionally restricts the property to the given values.
+	Enum []string `json:"enum,omitempty"`
 }
 
 // ValueType defines the type of a value
@@ -186,7 +258,8 @@ const (
 )
 
 var (
-	// AllValueTypes declares list of possible value types
+	// AllValueTypes declares list of the predefined value types.
+	// Custom value types are not added, use IsValueType to include them.
 	AllValueTypes = map[ValueType]struct{}{
 		ValueTypeNumber:  {},
 		ValueTypeString:  {},
@@ -200,3 +273,82 @@ var (
 		StatusTypeUnavailable: {},
 	}
 )
+
+// valueTypes holds the parse functions of all known value types, see RegisterValueType.
+// It must only be accessed while holding valueTypesMutex.
+var (
+	valueTypesMutex sync.RWMutex
+	valueTypes      = map[ValueType]func(value string) error{
+		ValueTypeNumber: func(value string) error {
+			_, err := strconv.ParseFloat(value, 64)
+			return err
+		},
+		ValueTypeBoolean: func(value string) error {
+			_, err := strconv.ParseBool(value)
+			return err
+		},
+		ValueTypeString: VerifyString,
+	}
+)
+
+// RegisterValueType adds a custom value type, e.g. COLOR_HEX.
+// The parse function returns an error for values not matching the type. It is used by ValidateValueType,
+// so values of the type are validated like the ones of the predefined types. A nil parse function accepts all values.
+// Registering a type again replaces its parse function. It is safe to register types concurrently with validations.
+func RegisterValueType(vt ValueType, parse func(value string) error) {
+	valueTypesMutex.Lock()
+	defer valueTypesMutex.Unlock()
+
+	valueTypes[vt] = parse
+}
+
+// UnregisterValueType removes a custom value type added with RegisterValueType, e.g. at the end of a test.
+// The predefined value types can not be removed.
+func UnregisterValueType(vt ValueType) {
+	if _, ok := AllValueTypes[vt]; ok {
+		return
+	}
+
+	valueTypesMutex.Lock()
+	defer valueTypesMutex.Unlock()
+
+	delete(valueTypes, vt)
+}
+
+// IsValueType reports whether the value type is predefined or was registered with RegisterValueType.
+func IsValueType(vt ValueType) bool {
+	valueTypesMutex.RLock()
+	defer valueTypesMutex.RUnlock()
+
+	_, ok := valueTypes[vt]
+	return ok
+}
+
+// ValueTypes returns the predefined and the registered value types, sorted by name.
+func ValueTypes() []ValueType {
+	valueTypesMutex.RLock()
+	defer valueTypesMutex.RUnlock()
+
+	types := make([]ValueType, 0, len(valueTypes))
+	for vt := range valueTypes {
+		types = append(types, vt)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+// ValidateValueType checks the value with the parse function of its value type and
+// returns ErrorInvalidValue if it does not match. Values of unknown value types are not checked.
+func ValidateValueType(vt ValueType, value string) error {
+	valueTypesMutex.RLock()
+	parse := valueTypes[vt]
+	valueTypesMutex.RUnlock()
+
+	if parse == nil {
+		return nil
+	}
+	if err := parse(value); err != nil {
+		return fmt.Errorf("%w: %q is not a valid %s: %v", ErrorInvalidValue, value, vt, err)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package connctd
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testComponent(id string) Component {
+	return Component{
+		ID:            id,
+		ComponentType: "sensor",
+		Properties:    []Property{{ID: "value", Name: "Value", Type: ValueTypeNumber}},
+	}
+}
+
+func TestThingNormalize(t *testing.T) {
+	tests := []struct {
+		name            string
+		thing           Thing
+		mainComponentID string
+		valid           bool
+	}{
+		{
+			name:            "single component without main component",
+			thing:           Thing{DisplayType: "sensor", Components: []Component{testComponent("a")}},
+			mainComponentID: "a",
+			valid:           true,
+		},
+		{
+			name:            "single component with main component",
+			thing:           Thing{DisplayType: "sensor", MainComponentID: "a", Components: []Component{testComponent("a")}},
+			mainComponentID: "a",
+			valid:           true,
+		},
+		{
+			name:            "single component with invalid main component",
+			thing:           Thing{DisplayType: "sensor", MainComponentID: "b", Components: []Component{testComponent("a")}},
+			mainComponentID: "b",
+			valid:           false,
+		},
+		{
+			name:            "multiple components without main component",
+			thing:           Thing{DisplayType: "sensor", Components: []Component{testComponent("a"), testComponent("b")}},
+			mainComponentID: "",
+			valid:           false,
+		},
+		{
+			name:            "multiple components with main component",
+			thing:           Thing{DisplayType: "sensor", MainComponentID: "b", Components: []Component{testComponent("a"), testComponent("b")}},
+			mainComponentID: "b",
+			valid:           true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.thing.Normalize()
+			if test.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+			assert.Equal(t, test.mainComponentID, test.thing.MainComponentID)
+		})
+	}
+}
+
+func TestPropertyValidateValueInRange(t *testing.T) {
+	min, max := 0.0, 100.0
+	humidity := Property{ID: "humidity", Type: ValueTypeNumber, Min: &min, Max: &max}
+	mode := Property{ID: "mode", Type: ValueTypeString, Enum: []string{"heat", "cool"}}
+
+	tests := []struct {
+		name          string
+		property      Property
+		value         string
+		expectedError error
+	}{
+		{"in range", humidity, "42.5", nil},
+		{"lower bound", humidity, "0", nil},
+		{"upper bound", humidity, "100", nil},
+		{"below min", humidity, "-1", ErrorValueOutOfRange},
+		{"above max", humidity, "100.1", ErrorValueOutOfRange},
+		{"not a number", humidity, "wet", ErrorValueOutOfRange},
+		{"enum value", mode, "cool", nil},
+		{"enum mismatch", mode, "dry", ErrorValueNotAllowed},
+		{"no constraints", Property{ID: "value", Type: ValueTypeNumber}, "-1000", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.property.ValidateValueInRange(test.value)
+			assert.True(t, errors.Is(err, test.expectedError), err)
+		})
+	}
+
+	// min must not exceed max
+	invalid := Property{ID: "humidity", Min: &max, Max: &min}
+	assert.Error(t, invalid.Verify())
+	assert.NoError(t, humidity.Verify())
+}
+
+// registerColorHex registers the COLOR_HEX value type for the duration of the test.
+func registerColorHex(t *testing.T) ValueType {
+	colorHex := ValueType("COLOR_HEX")
+	pattern := regexp.MustCompile("^#[0-9a-fA-F]{6}$")
+	RegisterValueType(colorHex, func(value string) error {
+		if !pattern.MatchString(value) {
+			return errors.New("expected #rrggbb")
+		}
+		return nil
+	})
+	t.Cleanup(func() { UnregisterValueType(colorHex) })
+	return colorHex
+}
+
+func TestRegisterValueType(t *testing.T) {
+	assert.False(t, IsValueType("COLOR_HEX"))
+	colorHex := registerColorHex(t)
+	assert.True(t, IsValueType(colorHex))
+	assert.Equal(t, []ValueType{ValueTypeBoolean, colorHex, ValueTypeNumber, ValueTypeString}, ValueTypes())
+	assert.NotContains(t, AllValueTypes, colorHex)
+
+	color := Property{ID: "color", Type: colorHex}
+	assert.NoError(t, color.ValidateValue("#ff8800"))
+	assert.True(t, errors.Is(color.ValidateValue("orange"), ErrorInvalidValue))
+
+	// constraints are still checked after the value type
+	enum := Property{ID: "color", Type: colorHex, Enum: []string{"#ffffff"}}
+	assert.True(t, errors.Is(enum.ValidateValue("#ff8800"), ErrorValueNotAllowed))
+}
+
+func TestUnregisterValueType(t *testing.T) {
+	colorHex := registerColorHex(t)
+	UnregisterValueType(colorHex)
+	assert.False(t, IsValueType(colorHex))
+	assert.NoError(t, ValidateValueType(colorHex, "orange"))
+
+	// predefined types are kept
+	UnregisterValueType(ValueTypeNumber)
+	assert.True(t, IsValueType(ValueTypeNumber))
+	assert.Error(t, ValidateValueType(ValueTypeNumber, "one"))
+}
+
+func TestValidateValueType(t *testing.T) {
+	tests := []struct {
+		valueType ValueType
+		value     string
+		valid     bool
+	}{
+		{ValueTypeNumber, "-1.5", true},
+		{ValueTypeNumber, "one", false},
+		{ValueTypeBoolean, "true", true},
+		{ValueTypeBoolean, "yes", false},
+		{ValueTypeString, "anything", true},
+		{ValueTypeString, "\xff", false},
+		{ValueType("UNKNOWN"), "anything", true},
+	}
+
+	for _, test := range tests {
+		err := ValidateValueType(test.valueType, test.value)
+		if test.valid {
+			assert.NoError(t, err, test.value)
+		} else {
+			assert.True(t, errors.Is(err, ErrorInvalidValue), test.value)
+		}
+	}
+}
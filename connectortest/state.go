@@ -0,0 +1,164 @@
+// Package connectortest provides helpers for integration tests of connectors.
+package connectortest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/connctd/connector-go"
+)
+
+// ErrorNoRegistrations is returned by StateSnapshot if the provider does not report its registrations.
+var ErrorNoRegistrations = errors.New("provider does not implement RegistrationReporter")
+
+// RegistrationReporter is implemented by providers which can report the installations and instances registered with them.
+// The provider.DefaultProvider implements it, so providers embedding it can be passed to StateSnapshot.
+type RegistrationReporter interface {
+	RegisteredInstallationIDs() []string
+	RegisteredInstanceIDs() []string
+}
+
+// State is the state of a connector captured by StateSnapshot.
+// All slices are sorted by ID and empty slices are nil, so states can be compared with Diff or assert.Equal.
+type State struct {
+	Installations []connector.Installation
+	Instances     []connector.Instance
+
+	// ProviderInstallations and ProviderInstances are the IDs registered with the provider
+	ProviderInstallations []string
+	ProviderInstances     []string
+}
+
+// StateSnapshot captures the installations, instances and thing mappings stored in the database
+// and the registrations of the provider. The provider may be nil to only capture the database.
+func StateSnapshot(ctx context.Context, db connector.Database, provider connector.Provider) (*State, error) {
+	installations, err := db.GetInstallations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve installations: %w", err)
+	}
+	instances, err := db.GetInstances(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve instances: %w", err)
+	}
+
+	state := &State{}
+	for _, installation := range installations {
+		installation.Configuration = sortedConfiguration(installation.Configuration)
+		state.Installations = append(state.Installations, *installation)
+	}
+	for _, instance := range instances {
+		instance.Configuration = sortedConfiguration(instance.Configuration)
+		instance.ThingMapping = sortedThingMapping(instance.ID, instance.ThingMapping)
+		state.Instances = append(state.Instances, *instance)
+	}
+	sort.Slice(state.Installations, func(i, j int) bool { return state.Installations[i].ID < state.Installations[j].ID })
+	sort.Slice(state.Instances, func(i, j int) bool { return state.Instances[i].ID < state.Instances[j].ID })
+
+	if provider == nil {
+		return state, nil
+	}
+	reporter, ok := provider.(RegistrationReporter)
+	if !ok {
+		return nil, ErrorNoRegistrations
+	}
+	state.ProviderInstallations = sortedIDs(reporter.RegisteredInstallationIDs())
+	state.ProviderInstances = sortedIDs(reporter.RegisteredInstanceIDs())
+	return state, nil
+}
+
+// Diff returns a line for each difference between the expected and the actual state.
+// It returns nil if both states are equal. Tokens are printed redacted.
+func Diff(expected, actual *State) []string {
+	var diff []string
+
+	expectedInstallations := make(map[string]connector.Installation, len(expected.Installations))
+	for _, installation := range expected.Installations {
+		expectedInstallations[installation.ID] = installation
+	}
+	for _, got := range actual.Installations {
+		want, ok := expectedInstallations[got.ID]
+		if !ok {
+			diff = append(diff, fmt.Sprintf("installation %s: unexpected", got.ID))
+			continue
+		}
+		delete(expectedInstallations, got.ID)
+		diff = appendFieldDiff(diff, "installation "+got.ID, "token", want.Token, got.Token)
+		diff = appendFieldDiff(diff, "installation "+got.ID, "configuration", want.Configuration, got.Configuration)
+	}
+	for _, installation := range expected.Installations {
+		if _, ok := expectedInstallations[installation.ID]; ok {
+			diff = append(diff, fmt.Sprintf("installation %s: missing", installation.ID))
+		}
+	}
+
+	expectedInstances := make(map[string]connector.Instance, len(expected.Instances))
+	for _, instance := range expected.Instances {
+		expectedInstances[instance.ID] = instance
+	}
+	for _, got := range actual.Instances {
+		want, ok := expectedInstances[got.ID]
+		if !ok {
+			diff = append(diff, fmt.Sprintf("instance %s: unexpected", got.ID))
+			continue
+		}
+		delete(expectedInstances, got.ID)
+		diff = appendFieldDiff(diff, "instance "+got.ID, "installation", want.InstallationID, got.InstallationID)
+		diff = appendFieldDiff(diff, "instance "+got.ID, "token", want.Token, got.Token)
+		diff = appendFieldDiff(diff, "instance "+got.ID, "configuration", want.Configuration, got.Configuration)
+		diff = appendFieldDiff(diff, "instance "+got.ID, "thing mapping", want.ThingMapping, got.ThingMapping)
+	}
+	for _, instance := range expected.Instances {
+		if _, ok := expectedInstances[instance.ID]; ok {
+			diff = append(diff, fmt.Sprintf("instance %s: missing", instance.ID))
+		}
+	}
+
+	diff = appendFieldDiff(diff, "provider", "installations", expected.ProviderInstallations, actual.ProviderInstallations)
+	diff = appendFieldDiff(diff, "provider", "instances", expected.ProviderInstances, actual.ProviderInstances)
+	return diff
+}
+
+// appendFieldDiff adds a line to the diff if the expected and actual value differ.
+func appendFieldDiff(diff []string, subject string, field string, expected, actual interface{}) []string {
+	if reflect.DeepEqual(expected, actual) {
+		return diff
+	}
+	return append(diff, fmt.Sprintf("%s: %s: expected %v, got %v", subject, field, expected, actual))
+}
+
+// sortedConfiguration returns a sorted copy of the configuration, since the order depends on the database.
+func sortedConfiguration(config []connector.Configuration) []connector.Configuration {
+	if len(config) == 0 {
+		return nil
+	}
+	sorted := append([]connector.Configuration{}, config...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+	return sorted
+}
+
+// sortedThingMapping returns a sorted copy of the mappings with the instance ID set, which not every database returns.
+func sortedThingMapping(instanceID string, mappings []connector.ThingMapping) []connector.ThingMapping {
+	if len(mappings) == 0 {
+		return nil
+	}
+	sorted := make([]connector.ThingMapping, len(mappings))
+	for i, mapping := range mappings {
+		mapping.InstanceID = instanceID
+		sorted[i] = mapping
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ThingID < sorted[j].ThingID })
+	return sorted
+}
+
+// sortedIDs returns a sorted copy of the IDs.
+func sortedIDs(ids []string) []string {
+	if len(ids) == 0 {
+		return nil
+	}
+	sorted := append([]string{}, ids...)
+	sort.Strings(sorted)
+	return sorted
+}
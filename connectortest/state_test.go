@@ -0,0 +1,122 @@
+package connectortest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/connctd/connector-go"
+	"github.com/connctd/connector-go/connctd"
+	"github.com/connctd/connector-go/db"
+	"github.com/connctd/connector-go/provider"
+	"github.com/connctd/connector-go/service"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// thingClient creates things with consecutive IDs.
+// Calls to other methods of the connctd API panic.
+type thingClient struct {
+	connector.Client
+	created int
+}
+
+func (c *thingClient) CreateThing(ctx context.Context, token connector.InstantiationToken, thing connctd.Thing) (connctd.Thing, error) {
+	c.created++
+	thing.ID = fmt.Sprintf("thing-%d", c.created)
+	return thing, nil
+}
+
+type testProvider struct {
+	provider.DefaultProvider
+}
+
+var lamp = connctd.Thing{
+	Name:            "lamp",
+	DisplayType:     "core.LIGHT",
+	MainComponentID: "light",
+	Components: []connctd.Component{{
+		ID:            "light",
+		ComponentType: "core.LIGHT",
+		Properties:    []connctd.Property{{ID: "on", Type: connctd.ValueTypeBoolean}},
+	}},
+}
+
+func TestStateSnapshotAfterAddInstance(t *testing.T) {
+	ctx := context.Background()
+	database := db.NewInMemoryDatabase()
+	p := &testProvider{provider.New()}
+	templates := func(request connector.InstantiationRequest) []connector.ThingTemplate {
+		return []connector.ThingTemplate{{Thing: lamp, ExternalID: "lamp"}}
+	}
+
+	svc, err := service.NewConnectorService(database, &thingClient{}, p, templates, service.DefaultConnectorServiceOptions, logr.Discard())
+	require.NoError(t, err)
+
+	_, err = svc.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "installation-token"})
+	require.NoError(t, err)
+	_, err = svc.AddInstance(ctx, connector.InstantiationRequest{
+		ID:             "instance-1",
+		InstallationID: "installation-1",
+		Token:          "instance-token",
+		Configuration:  []connector.Configuration{{ID: "host", Value: "example.com"}},
+	})
+	require.NoError(t, err)
+	// the provider applies the registrations with its next update
+	p.Update()
+
+	expected := &State{
+		Installations: []connector.Installation{{ID: "installation-1", Token: "installation-token"}},
+		Instances: []connector.Instance{{
+			ID:             "instance-1",
+			InstallationID: "installation-1",
+			Token:          "instance-token",
+			ThingMapping:   []connector.ThingMapping{{InstanceID: "instance-1", ThingID: "thing-1", ExternalID: "lamp"}},
+			Configuration:  []connector.Configuration{{ID: "host", Value: "example.com"}},
+		}},
+		ProviderInstallations: []string{"installation-1"},
+		ProviderInstances:     []string{"instance-1"},
+	}
+
+	state, err := StateSnapshot(ctx, database, p)
+	require.NoError(t, err)
+	assert.Empty(t, Diff(expected, state))
+	assert.Equal(t, expected, state)
+
+	// removing the instance shows up as difference to the previous snapshot
+	require.NoError(t, svc.RemoveInstance(ctx, "instance-1"))
+	state, err = StateSnapshot(ctx, database, p)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"instance instance-1: missing",
+		"provider: instances: expected [instance-1], got []",
+	}, Diff(expected, state))
+}
+
+func TestDiff(t *testing.T) {
+	expected := &State{
+		Installations: []connector.Installation{{ID: "installation-1", Token: "token"}},
+		Instances:     []connector.Instance{{ID: "instance-1", InstallationID: "installation-1"}},
+	}
+	actual := &State{
+		Installations: []connector.Installation{{ID: "installation-1", Token: "token", Configuration: []connector.Configuration{{ID: "host", Value: "example.com"}}}},
+		Instances:     []connector.Instance{{ID: "instance-2", InstallationID: "installation-1"}},
+	}
+
+	assert.Empty(t, Diff(expected, expected))
+	assert.Equal(t, []string{
+		"installation installation-1: configuration: expected [], got [{host example.com}]",
+		"instance instance-2: unexpected",
+		"instance instance-1: missing",
+	}, Diff(expected, actual))
+}
+
+func TestStateSnapshotWithoutRegistrations(t *testing.T) {
+	_, err := StateSnapshot(context.Background(), db.NewInMemoryDatabase(), struct{ connector.Provider }{})
+	assert.Equal(t, ErrorNoRegistrations, err)
+
+	state, err := StateSnapshot(context.Background(), db.NewInMemoryDatabase(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, &State{}, state)
+}
@@ -3,9 +3,9 @@ package connector
 import (
 	"crypto/ed25519"
 	"encoding/json"
-	"errors"
-	"io"
+	"mime"
 	"net/http"
+	"sync/atomic"
 
 	"github.com/gorilla/mux"
 )
@@ -15,6 +15,7 @@ import (
 type ConnectorHandler struct {
 	router  *mux.Router
 	service ConnectorService
+	archive atomic.Pointer[requestArchive]
 }
 
 // ServeHTTP implements the http.Handler interface by delegating to the router
@@ -43,17 +44,19 @@ func NewConnectorHandler(subrouter *mux.Router, service ConnectorService, public
 	c := baseConnectorHandler(subrouter, service)
 
 	c.router.Path("/installations").Methods(http.MethodPost).Handler(NewSignatureValidationHandler(
-		AutoProxyRequestValidationPreProcessor(), publicKey, AddInstallation(c.service)))
+		AutoProxyRequestValidationPreProcessor(), publicKey, c.archived(RequestKindAddInstallation, AddInstallation(c.service))))
+	c.router.Path("/installations/{id}").Methods(http.MethodPut).Handler(NewSignatureValidationHandler(
+		AutoProxyRequestValidationPreProcessor(), publicKey, c.archived(RequestKindUpdateInstallation, UpdateInstallation(c.service))))
 	c.router.Path("/installations/{id}").Methods(http.MethodDelete).Handler(NewSignatureValidationHandler(
-		AutoProxyRequestValidationPreProcessor(), publicKey, RemoveInstallation(c.service)))
+		AutoProxyRequestValidationPreProcessor(), publicKey, c.archived(RequestKindRemoveInstallation, RemoveInstallation(c.service))))
 
 	c.router.Path("/instances").Methods(http.MethodPost).Handler(NewSignatureValidationHandler(
-		AutoProxyRequestValidationPreProcessor(), publicKey, AddInstance(c.service)))
+		AutoProxyRequestValidationPreProcessor(), publicKey, c.archived(RequestKindAddInstance, AddInstance(c.service))))
 	c.router.Path("/instances/{id}").Methods(http.MethodDelete).Handler(NewSignatureValidationHandler(
-		AutoProxyRequestValidationPreProcessor(), publicKey, RemoveInstance(c.service)))
+		AutoProxyRequestValidationPreProcessor(), publicKey, c.archived(RequestKindRemoveInstance, RemoveInstance(c.service))))
 
 	c.router.Path("/actions").Methods(http.MethodPost).Handler(NewSignatureValidationHandler(
-		AutoProxyRequestValidationPreProcessor(), publicKey, PerformAction(c.service)))
+		AutoProxyRequestValidationPreProcessor(), publicKey, c.archived(RequestKindPerformAction, PerformAction(c.service))))
 
 	return c
 }
@@ -65,28 +68,31 @@ func NewProxiedConnectorHandler(subrouter *mux.Router, service ConnectorService,
 	c := baseConnectorHandler(subrouter, service)
 
 	c.router.Path("/installations").Methods(http.MethodPost).Handler(NewSignatureValidationHandler(
-		ProxiedRequestValidationPreProcessor("https", host), publicKey, AddInstallation(c.service),
+		ProxiedRequestValidationPreProcessor("https", host), publicKey, c.archived(RequestKindAddInstallation, AddInstallation(c.service)),
+	))
+	c.router.Path("/installations/{id}").Methods(http.MethodPut).Handler(NewSignatureValidationHandler(
+		ProxiedRequestValidationPreProcessor("https", host), publicKey, c.archived(RequestKindUpdateInstallation, UpdateInstallation(c.service)),
 	))
 	c.router.Path("/installations/{id}").Methods(http.MethodDelete).Handler(NewSignatureValidationHandler(
-		ProxiedRequestValidationPreProcessor("https", host), publicKey, RemoveInstallation(c.service),
+		ProxiedRequestValidationPreProcessor("https", host), publicKey, c.archived(RequestKindRemoveInstallation, RemoveInstallation(c.service)),
 	))
 
 	c.router.Path("/instances").Methods(http.MethodPost).Handler(NewSignatureValidationHandler(
-		ProxiedRequestValidationPreProcessor("https", host), publicKey, AddInstallation(c.service),
+		ProxiedRequestValidationPreProcessor("https", host), publicKey, c.archived(RequestKindAddInstance, AddInstance(c.service)),
 	))
 	c.router.Path("/instances/{id}").Methods(http.MethodDelete).Handler(NewSignatureValidationHandler(
-		ProxiedRequestValidationPreProcessor("https", host), publicKey, RemoveInstance(c.service),
+		ProxiedRequestValidationPreProcessor("https", host), publicKey, c.archived(RequestKindRemoveInstance, RemoveInstance(c.service)),
 	))
 
 	c.router.Path("/actions").Methods(http.MethodPost).Handler(NewSignatureValidationHandler(
-		ProxiedRequestValidationPreProcessor("https", host), publicKey, PerformAction(c.service),
+		ProxiedRequestValidationPreProcessor("https", host), publicKey, c.archived(RequestKindPerformAction, PerformAction(c.service)),
 	))
 
 	return c
 }
 
 // AddInstallation is called whenever a connector is installed via the connctd platform.
-// It will validate the request and delegate valid requests to the service.
+// It will validate the request, including its initialized state, and delegate valid requests to the service.
 // It expects an error from errors.go.
 // The status code will be set to one defined in the error and the InstantiationResponse will be returned to the connctd platform.
 func AddInstallation(service ConnectorService) http.HandlerFunc {
@@ -98,6 +104,12 @@ func AddInstallation(service ConnectorService) http.HandlerFunc {
 			return
 		}
 
+		// new installations are always initialized, other states indicate stale or duplicate requests
+		if req.State != InstallationStateInitialized {
+			writeError(w, ErrorUnexpectedState)
+			return
+		}
+
 		response, err := service.AddInstallation(r.Context(), req)
 		if err != nil {
 			writeStatus(w, err)
@@ -119,7 +131,7 @@ func AddInstallation(service ConnectorService) http.HandlerFunc {
 				return
 			}
 			w.Header().Add("Content-Type", "application/json")
-			w.WriteHeader(http.StatusAccepted)
+			w.WriteHeader(responseStatus(response.FurtherStep))
 			w.Write(b)
 			return
 		}
@@ -130,6 +142,73 @@ func AddInstallation(service ConnectorService) http.HandlerFunc {
 	})
 }
 
+// responseStatus returns the status code of a successful installation or instantiation response.
+// Responses requiring a further step are accepted, responses only carrying details are created.
+func responseStatus(furtherStep Step) int {
+	if furtherStep.Type == 0 {
+		return http.StatusCreated
+	}
+	return http.StatusAccepted
+}
+
+// UpdateInstallation is called whenever an existing installation is updated via the connctd platform.
+// The installation ID is taken from the path and must match the ID in the request body if that is set.
+// Responses are handled like the ones of AddInstallation: responses requiring a further step are answered with 202,
+// responses only carrying details with 201. A successful update without a response is answered with status code 204.
+func UpdateInstallation(service ConnectorService) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := mux.Vars(r)["id"]
+		if !ok {
+			writeError(w, ErrorMissingInstallationID)
+			return
+		}
+
+		var req InstallationRequest
+		if err := decodeJSONBody(w, r, &req); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		if req.ID == "" {
+			req.ID = id
+		} else if req.ID != id {
+			writeError(w, ErrorInstallationIDMismatch)
+			return
+		}
+
+		response, err := service.UpdateInstallation(r.Context(), req)
+		if err != nil {
+			writeStatus(w, err)
+			if response != nil {
+				b, err := json.Marshal(response)
+				if err != nil {
+					writeError(w, err)
+					return
+				}
+				w.Write(b)
+			}
+			return
+		}
+
+		if response != nil {
+			b, err := json.Marshal(response)
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			w.Header().Add("Content-Type", "application/json")
+			w.WriteHeader(responseStatus(response.FurtherStep))
+			w.Write(b)
+			return
+		}
+
+		// We set the content type to application/json to prevent ngrok from interpreting the response as HTML
+		// and serving a landing page instead.
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
 // RemoveInstallation is called whenever an installation is removed by the the connctd platform.
 func RemoveInstallation(service ConnectorService) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -154,7 +233,7 @@ func RemoveInstallation(service ConnectorService) http.HandlerFunc {
 }
 
 // AddInstance is called whenever a connector is instantiated via the connctd platform.
-// It will validate the request and delegate valid requests to the service.
+// It will validate the request, including its initialized state, and delegate valid requests to the service.
 // It expects an error from errors.go.
 // The status code will be set to one defined in the error and the InstantiationResponse will be returned to the connctd platform.
 func AddInstance(service ConnectorService) http.HandlerFunc {
@@ -165,6 +244,12 @@ func AddInstance(service ConnectorService) http.HandlerFunc {
 			return
 		}
 
+		// new instances are always initialized, other states indicate stale or duplicate requests
+		if req.State != InstantiationStateInitialized {
+			writeError(w, ErrorUnexpectedState)
+			return
+		}
+
 		response, err := service.AddInstance(r.Context(), req)
 		if err != nil {
 			writeStatus(w, err)
@@ -187,7 +272,7 @@ func AddInstance(service ConnectorService) http.HandlerFunc {
 				return
 			}
 			w.Header().Add("Content-Type", "application/json")
-			w.WriteHeader(http.StatusAccepted)
+			w.WriteHeader(responseStatus(response.FurtherStep))
 			w.Write(b)
 			return
 		}
@@ -273,11 +358,11 @@ func PerformAction(service ConnectorService) http.HandlerFunc {
 
 // helps to decode the request body
 func decodeJSONBody(w http.ResponseWriter, r *http.Request, dest interface{}) error {
-	if r.Header.Get("Content-Type") != "application/json" {
+	if !isJSONContentType(r.Header.Get("Content-Type")) {
 		return ErrorBadContentType
 	}
 
-	body, err := io.ReadAll(r.Body)
+	body, err := requestBody(r)
 	if err != nil {
 		return ErrorBadRequestBody
 	}
@@ -289,26 +374,18 @@ func decodeJSONBody(w http.ResponseWriter, r *http.Request, dest interface{}) er
 	return nil
 }
 
-// helps to encode an error
+// isJSONContentType reports whether the content type header denotes JSON, parameters like the charset are ignored.
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "application/json"
+}
+
+// helps to encode an error, see Retryable and Permanent for the choice of the status code
 func writeError(w http.ResponseWriter, err error) {
-	var e *Error
-	if errors.As(err, &e) {
-		e.Write(w)
-	} else {
-		NewError(
-			"INTERNAL_SERVER_ERROR",
-			err.Error(),
-			http.StatusInternalServerError,
-		).Write(w)
-	}
+	apiError(err).Write(w)
 }
 
 // helps to set the status according to an error
 func writeStatus(w http.ResponseWriter, err error) {
-	var e *Error
-	if errors.As(err, &e) {
-		w.WriteHeader(e.Status)
-	} else {
-		w.WriteHeader(http.StatusInternalServerError)
-	}
+	w.WriteHeader(apiError(err).Status)
 }
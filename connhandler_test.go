@@ -0,0 +1,265 @@
+package connector
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateInstallationRoute(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	service := &recordingService{}
+	handler := NewConnectorHandler(nil, service, pub)
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+	}{
+		{"id from path", `{"token":"token-2","configuration":[{"id":"foo","value":"bar"}]}`, http.StatusNoContent},
+		{"matching id", `{"id":"installation-1","token":"token-2"}`, http.StatusNoContent},
+		{"mismatching id", `{"id":"installation-2","token":"token-2"}`, ErrorInstallationIDMismatch.Status},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service.updates = nil
+
+			body := []byte(test.body)
+			req := httptest.NewRequest(http.MethodPut, "https://example.com/installations/installation-1", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			require.NoError(t, signRequest(priv, req, body))
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, test.expectedStatus, rec.Code, rec.Body.String())
+
+			if test.expectedStatus == http.StatusNoContent {
+				require.Len(t, service.updates, 1)
+				assert.Equal(t, "installation-1", service.updates[0].ID)
+				assert.Equal(t, InstallationToken("token-2"), service.updates[0].Token)
+			} else {
+				assert.Empty(t, service.updates)
+			}
+		})
+	}
+}
+
+func TestUpdateInstallationResponseStatus(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		response       *InstallationResponse
+		expectedStatus int
+	}{
+		{"details", &InstallationResponse{Details: []byte(`{"devices":2}`)}, http.StatusCreated},
+		{"further step", &InstallationResponse{FurtherStep: Step{Type: StepRedirect, Content: "https://example.com/login"}}, http.StatusAccepted},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handler := NewConnectorHandler(nil, &recordingService{updateResponse: test.response}, pub)
+
+			body := []byte(`{"token":"token-2"}`)
+			req := httptest.NewRequest(http.MethodPut, "https://example.com/installations/installation-1", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			require.NoError(t, signRequest(priv, req, body))
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, test.expectedStatus, rec.Code, rec.Body.String())
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		})
+	}
+}
+
+func TestProxiedInstanceRoute(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	service := &recordingService{}
+	handler := NewProxiedConnectorHandler(nil, service, "example.com", pub)
+	handler.EnableRequestArchive(1)
+
+	body := []byte(`{"id":"instance-1","installation_id":"installation-1","token":"token","state":1}`)
+	req := httptest.NewRequest(http.MethodPost, "https://example.com/instances", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	require.NoError(t, signRequest(priv, req, body))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	require.Len(t, service.instances, 1)
+	assert.Equal(t, "instance-1", service.instances[0].ID)
+	assert.Empty(t, service.installations)
+
+	archived := handler.ArchivedRequests()
+	require.Len(t, archived, 1)
+	assert.Equal(t, RequestKindAddInstance, archived[0].Kind)
+}
+
+func TestErrorKindStatus(t *testing.T) {
+	dbFailure := errors.New("connection refused")
+
+	tests := []struct {
+		name           string
+		err            error
+		expectedKind   ErrorKind
+		expectedStatus int
+	}{
+		{"unmarked error", dbFailure, ErrorKindUnknown, http.StatusInternalServerError},
+		{"sdk client error", ErrorInvalidJsonBody, ErrorKindPermanent, http.StatusBadRequest},
+		{"sdk server error", ErrorInternal, ErrorKindRetryable, http.StatusInternalServerError},
+		{"retryable error", Retryable(dbFailure), ErrorKindRetryable, http.StatusServiceUnavailable},
+		{"retryable server error", Retryable(ErrorInternal), ErrorKindRetryable, http.StatusInternalServerError},
+		{"retryable client error", Retryable(ErrorInstallationNotFound), ErrorKindRetryable, http.StatusServiceUnavailable},
+		{"permanent error", Permanent(dbFailure), ErrorKindPermanent, http.StatusUnprocessableEntity},
+		{"permanent client error", Permanent(ErrorUnknownInstallation), ErrorKindPermanent, http.StatusBadRequest},
+		{"wrapped permanent error", fmt.Errorf("failed to add installation: %w", Permanent(dbFailure)), ErrorKindPermanent, http.StatusUnprocessableEntity},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expectedKind, KindOf(test.err))
+
+			handler := AddInstallation(&recordingService{err: test.err})
+			req := httptest.NewRequest(http.MethodPost, "/installations", bytes.NewReader([]byte(`{"id":"installation-1","token":"token","state":1}`)))
+			req.Header.Set("Content-Type", "application/json")
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, test.expectedStatus, rec.Code)
+		})
+	}
+
+	assert.Nil(t, Retryable(nil))
+	assert.Nil(t, Permanent(nil))
+}
+
+func TestRequestStateValidation(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+	}{
+		{"initialized", `{"id":"id-1","installation_id":"installation-1","token":"token","state":1}`, http.StatusCreated},
+		{"missing state", `{"id":"id-1","installation_id":"installation-1","token":"token"}`, ErrorUnexpectedState.Status},
+		{"complete", `{"id":"id-1","installation_id":"installation-1","token":"token","state":2}`, ErrorUnexpectedState.Status},
+		{"failed", `{"id":"id-1","installation_id":"installation-1","token":"token","state":4}`, ErrorUnexpectedState.Status},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := &recordingService{}
+			handlers := map[string]http.Handler{
+				"installation": AddInstallation(service),
+				"instance":     AddInstance(service),
+			}
+
+			for kind, handler := range handlers {
+				req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(test.body)))
+				req.Header.Set("Content-Type", "application/json")
+
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+				assert.Equal(t, test.expectedStatus, rec.Code, kind)
+			}
+
+			// rejected requests do not reach the service
+			accepted := test.expectedStatus == http.StatusCreated
+			assert.Equal(t, accepted, len(service.installations) == 1)
+			assert.Equal(t, accepted, len(service.instances) == 1)
+		})
+	}
+}
+
+// failingReader fails all reads.
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("body must not be read again")
+}
+
+func TestJSONContentType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		valid       bool
+	}{
+		{"application/json", true},
+		{"application/json; charset=utf-8", true},
+		{"Application/JSON;charset=UTF-8", true},
+		{"text/plain", false},
+		{"application/json-seq", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.contentType, func(t *testing.T) {
+			body := `{"id":"installation-1","token":"token","state":1}`
+			newRequest := func() *http.Request {
+				req := httptest.NewRequest(http.MethodPost, "https://example.com/installations", strings.NewReader(body))
+				req.Header.Set("Content-Type", test.contentType)
+				return req
+			}
+			expectedStatus := http.StatusCreated
+			if !test.valid {
+				expectedStatus = ErrorBadContentType.Status
+			}
+
+			// the middleware and the handlers accept the same content types
+			rec := httptest.NewRecorder()
+			ContentTypeMiddleware()(AddInstallation(&recordingService{})).ServeHTTP(rec, newRequest())
+			assert.Equal(t, expectedStatus, rec.Code, rec.Body.String())
+
+			rec = httptest.NewRecorder()
+			AddInstallation(&recordingService{}).ServeHTTP(rec, newRequest())
+			assert.Equal(t, expectedStatus, rec.Code, rec.Body.String())
+		})
+	}
+}
+
+func TestDecodeBufferedBody(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	service := &recordingService{}
+	next := AddInstallation(service)
+
+	// the body of the request is consumed, so it can only be decoded from the context
+	handler := NewSignatureValidationHandler(ProxiedRequestValidationPreProcessor("https", "example.com"), pub, func(w http.ResponseWriter, r *http.Request) {
+		body, ok := BodyFromContext(r.Context())
+		require.True(t, ok)
+		assert.Contains(t, string(body), "installation-1")
+
+		r.Body = io.NopCloser(failingReader{})
+		next(w, r)
+	})
+
+	body := []byte(`{"id":"installation-1","token":"token","state":1}`)
+	req := httptest.NewRequest(http.MethodPost, "https://example.com/installations", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	require.NoError(t, signRequest(priv, req, body))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	require.Len(t, service.installations, 1)
+	assert.Equal(t, "installation-1", service.installations[0].ID)
+
+	_, ok := BodyFromContext(req.Context())
+	assert.False(t, ok)
+}
@@ -0,0 +1,79 @@
+package crypto
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"strings"
+	"sync"
+)
+
+const (
+	// SignatureAlgorithmHeaderKey defines the header carrying the algorithm of the signature.
+	// Requests without this header are signed with DefaultAlgorithm.
+	SignatureAlgorithmHeaderKey = "Signature-Algorithm"
+
+	// AlgorithmEd25519 identifies ed25519 signatures
+	AlgorithmEd25519 = "ed25519"
+
+	// DefaultAlgorithm is used if a request does not specify an algorithm
+	DefaultAlgorithm = AlgorithmEd25519
+)
+
+// Verifier verifies signatures of a single algorithm.
+type Verifier interface {
+	// Verify reports whether signature is a valid signature of message by publicKey.
+	// It must not panic on malformed keys or signatures.
+	Verify(publicKey []byte, message []byte, signature []byte) bool
+}
+
+// VerifierFunc allows ordinary functions to be used as Verifier.
+type VerifierFunc func(publicKey []byte, message []byte, signature []byte) bool
+
+// Verify calls f(publicKey, message, signature).
+func (f VerifierFunc) Verify(publicKey []byte, message []byte, signature []byte) bool {
+	return f(publicKey, message, signature)
+}
+
+var (
+	verifiersMutex sync.RWMutex
+	verifiers      = map[string]Verifier{
+		AlgorithmEd25519: VerifierFunc(verifyEd25519),
+	}
+)
+
+// RegisterVerifier makes a verifier available for the given algorithm identifier.
+// Identifiers are case insensitive. A verifier registered for an existing identifier replaces the previous one.
+func RegisterVerifier(algorithm string, verifier Verifier) {
+	verifiersMutex.Lock()
+	defer verifiersMutex.Unlock()
+
+	verifiers[strings.ToLower(algorithm)] = verifier
+}
+
+// LookupVerifier returns the verifier registered for the algorithm identifier.
+// An empty identifier selects the DefaultAlgorithm. It returns ErrorUnknownAlgorithm if no verifier is registered.
+func LookupVerifier(algorithm string) (Verifier, error) {
+	if algorithm == "" {
+		algorithm = DefaultAlgorithm
+	}
+
+	verifiersMutex.RLock()
+	defer verifiersMutex.RUnlock()
+
+	verifier, ok := verifiers[strings.ToLower(algorithm)]
+	if !ok {
+		return nil, ErrorUnknownAlgorithm
+	}
+	return verifier, nil
+}
+
+// verifyEd25519 is like Verify, but rejects keys of the wrong size instead of panicking.
+func verifyEd25519(publicKey []byte, message []byte, signature []byte) bool {
+	if len(publicKey) != ed25519.PublicKeySize {
+		return false
+	}
+	return ed25519.Verify(publicKey, message, signature)
+}
+
+// ErrorUnknownAlgorithm is returned by LookupVerifier if no verifier is registered for an algorithm
+var ErrorUnknownAlgorithm = errors.New("unknown signature algorithm")
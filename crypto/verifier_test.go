@@ -0,0 +1,35 @@
+package crypto
+
+import (
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupVerifier(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	message := []byte("message")
+	signature := Sign(priv, message)
+
+	for _, algorithm := range []string{"", "ed25519", "ED25519"} {
+		verifier, err := LookupVerifier(algorithm)
+		require.NoError(t, err, algorithm)
+		assert.True(t, verifier.Verify(pub, message, signature), algorithm)
+		assert.False(t, verifier.Verify(pub, []byte("manipulated"), signature), algorithm)
+		assert.False(t, verifier.Verify([]byte("short key"), message, signature), algorithm)
+	}
+
+	_, err = LookupVerifier("rsa-sha256")
+	assert.Equal(t, ErrorUnknownAlgorithm, err)
+
+	RegisterVerifier("test-always-valid", VerifierFunc(func(publicKey []byte, message []byte, signature []byte) bool {
+		return true
+	}))
+	verifier, err := LookupVerifier("test-always-valid")
+	require.NoError(t, err)
+	assert.True(t, verifier.Verify(nil, nil, nil))
+}
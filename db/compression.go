@@ -0,0 +1,69 @@
+package db
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/connctd/connector-go"
+)
+
+// compressedValuePrefix marks gzip compressed and base64 encoded configuration values.
+const compressedValuePrefix = "gzip:"
+
+// encodeConfigValue compresses the configuration value if compression is enabled.
+func (m *DBClient) encodeConfigValue(value string) (string, error) {
+	if !m.compressConfig {
+		return value, nil
+	}
+	return compressValue(value)
+}
+
+// compressValue returns the marked, gzip compressed and base64 encoded value.
+func compressValue(value string) (string, error) {
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	if _, err := w.Write([]byte(value)); err != nil {
+		return "", fmt.Errorf("failed to compress configuration value: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return "", fmt.Errorf("failed to compress configuration value: %w", err)
+	}
+	return compressedValuePrefix + base64.StdEncoding.EncodeToString(b.Bytes()), nil
+}
+
+// decompressValue returns the original value of a compressed configuration value.
+// Values without marker, e.g. ones stored before compression was enabled, are returned unchanged.
+// This also applies to uncompressed values that happen to start with the marker.
+func decompressValue(value string) string {
+	if !strings.HasPrefix(value, compressedValuePrefix) {
+		return value
+	}
+
+	compressed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, compressedValuePrefix))
+	if err != nil {
+		return value
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return value
+	}
+
+	decompressed, err := io.ReadAll(r)
+	if err != nil {
+		return value
+	}
+	return string(decompressed)
+}
+
+// decodeConfiguration decompresses all values of the configuration in place.
+func decodeConfiguration(config []connector.Configuration) []connector.Configuration {
+	for i := range config {
+		config[i].Value = decompressValue(config[i].Value)
+	}
+	return config
+}
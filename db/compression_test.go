@@ -0,0 +1,67 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/connctd/connector-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressConfig(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t, DBOptions{CompressConfig: true})
+
+	catalog := `[` + strings.Repeat(`{"id":"device","type":"core.LAMP","name":"Kitchen lamp"},`, 200) + `{}]`
+
+	require.NoError(t, client.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token"}))
+	require.NoError(t, client.AddInstallationConfiguration(ctx, "installation-1", []connector.Configuration{{ID: "catalog", Value: catalog}}))
+	require.NoError(t, client.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "token"}))
+	require.NoError(t, client.AddInstanceConfiguration(ctx, "instance-1", []connector.Configuration{{ID: "catalog", Value: catalog}}))
+	require.NoError(t, client.SetInstanceConfigurationValue(ctx, "instance-1", "room", "kitchen"))
+
+	// values are stored compressed
+	var stored string
+	require.NoError(t, client.DB.Get(&stored, `SELECT value FROM instance_configuration WHERE instance_id = ? AND id = ?`, "instance-1", "catalog"))
+	assert.True(t, strings.HasPrefix(stored, compressedValuePrefix))
+	assert.Less(t, len(stored), len(catalog))
+
+	// legacy rows stored without compression can still be read
+	_, err := client.DB.Exec(statementInsertInstallationConfig, "installation-1", "legacy", "plain value")
+	require.NoError(t, err)
+
+	config, err := client.GetInstallationConfiguration(ctx, "installation-1")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []connector.Configuration{{ID: "catalog", Value: catalog}, {ID: "legacy", Value: "plain value"}}, config)
+
+	instance, err := client.GetInstance(ctx, "instance-1")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []connector.Configuration{{ID: "catalog", Value: catalog}, {ID: "room", Value: "kitchen"}}, instance.Configuration)
+
+	instanceConfigs, err := client.GetInstanceConfigurations(ctx, []string{"instance-1"})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, instance.Configuration, instanceConfigs["instance-1"])
+
+	installationConfig, err := client.GetInstancesInstallationConfiguration(ctx, "instance-1")
+	require.NoError(t, err)
+	require.Len(t, installationConfig, 2)
+	for _, c := range installationConfig {
+		assert.NotContains(t, c.Value, compressedValuePrefix)
+	}
+
+	installations, err := client.GetInstallations(ctx)
+	require.NoError(t, err)
+	require.Len(t, installations, 1)
+	assert.ElementsMatch(t, config, installations[0].Configuration)
+}
+
+func TestDecompressValue(t *testing.T) {
+	compressed, err := compressValue("value")
+	require.NoError(t, err)
+
+	assert.Equal(t, "value", decompressValue(compressed))
+	assert.Equal(t, "value", decompressValue("value"))
+	assert.Equal(t, "gzip:not compressed", decompressValue("gzip:not compressed"))
+}
@@ -0,0 +1,446 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/connctd/connector-go"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDatabaseConformance runs the same tests against all implementations of connector.Database.
+func TestDatabaseConformance(t *testing.T) {
+	implementations := map[string]func(t *testing.T) connector.Database{
+		"sql":    func(t *testing.T) connector.Database { return newTestClient(t, DBOptions{}) },
+		"memory": func(t *testing.T) connector.Database { return NewInMemoryDatabase() },
+		// sqlite understands the placeholders of postgres and sqlserver as well
+		"sql with postgres placeholders":  func(t *testing.T) connector.Database { return newRebindingTestClient(t, sqlx.DOLLAR) },
+		"sql with sqlserver placeholders": func(t *testing.T) connector.Database { return newRebindingTestClient(t, sqlx.AT) },
+	}
+
+	for name, newDatabase := range implementations {
+		t.Run(name, func(t *testing.T) {
+			t.Run("installations", func(t *testing.T) { testInstallations(t, newDatabase(t)) })
+			t.Run("instances", func(t *testing.T) { testInstances(t, newDatabase(t)) })
+			t.Run("thing mappings", func(t *testing.T) { testThingMappings(t, newDatabase(t)) })
+			t.Run("mapping reconciliation", func(t *testing.T) { testMappingReconciliation(t, newDatabase(t)) })
+			t.Run("cascading removal", func(t *testing.T) { testCascadingRemoval(t, newDatabase(t)) })
+			t.Run("property values", func(t *testing.T) { testPropertyValues(t, newDatabase(t)) })
+			t.Run("pending instance states", func(t *testing.T) { testPendingInstanceStates(t, newDatabase(t)) })
+			t.Run("transactional adds", func(t *testing.T) { testTransactionalAdds(t, newDatabase(t)) })
+			t.Run("token updates", func(t *testing.T) { testTokenUpdates(t, newDatabase(t)) })
+			t.Run("bulk configurations", func(t *testing.T) { testBulkConfigurations(t, newDatabase(t)) })
+		})
+	}
+}
+
+// newRebindingTestClient returns a test client rebinding its statements to the placeholders of the given bind type.
+func newRebindingTestClient(t *testing.T, bindType int) *DBClient {
+	client := newTestClient(t, DBOptions{})
+	client.bindType = bindType
+	return client
+}
+
+func testInstallations(t *testing.T, database connector.Database) {
+	ctx := context.Background()
+
+	require.NoError(t, database.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token-1"}))
+	require.NoError(t, database.AddInstallationConfiguration(ctx, "installation-1", []connector.Configuration{{ID: "key", Value: "value"}}))
+	assert.Error(t, database.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token-2"}))
+
+	installations, err := database.GetInstallations(ctx)
+	require.NoError(t, err)
+	require.Len(t, installations, 1)
+	assert.Equal(t, "installation-1", installations[0].ID)
+	assert.Equal(t, connector.InstallationToken("token-1"), installations[0].Token)
+	assert.Equal(t, []connector.Configuration{{ID: "key", Value: "value"}}, installations[0].Configuration)
+
+	installation, err := database.GetInstallationByToken(ctx, "token-1")
+	require.NoError(t, err)
+	assert.Equal(t, "installation-1", installation.ID)
+	assert.Equal(t, []connector.Configuration{{ID: "key", Value: "value"}}, installation.Configuration)
+
+	_, err = database.GetInstallationByToken(ctx, "unknown")
+	assert.Equal(t, connector.ErrorInstallationNotFound, err)
+
+	require.NoError(t, database.UpdateInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token-2", Configuration: []connector.Configuration{{ID: "other", Value: "value"}}}))
+	assert.Equal(t, connector.ErrorInstallationNotFound, database.UpdateInstallation(ctx, connector.InstallationRequest{ID: "unknown", Token: "token"}))
+
+	config, err := database.GetInstallationConfiguration(ctx, "installation-1")
+	require.NoError(t, err)
+	assert.Equal(t, []connector.Configuration{{ID: "other", Value: "value"}}, config)
+
+	_, err = database.GetInstallationConfiguration(ctx, "unknown")
+	assert.Equal(t, connector.ErrorInstallationNotFound, err)
+
+	_, err = database.GetInstallationByToken(ctx, "token-1")
+	assert.Equal(t, connector.ErrorInstallationNotFound, err)
+
+	count, err := database.CountInstallations(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	require.NoError(t, database.RemoveInstallation(ctx, "installation-1"))
+	assert.Equal(t, connector.ErrorInstallationNotFound, database.RemoveInstallation(ctx, "installation-1"))
+	assert.Equal(t, connector.ErrorInstallationNotFound, database.RemoveInstallation(ctx, "unknown"))
+
+	installations, err = database.GetInstallations(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, installations)
+}
+
+func testTokenUpdates(t *testing.T, database connector.Database) {
+	ctx := context.Background()
+
+	require.NoError(t, database.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token-1", Configuration: []connector.Configuration{{ID: "key", Value: "value"}}}))
+	require.NoError(t, database.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "token-1"}))
+
+	require.NoError(t, database.UpdateInstallationToken(ctx, "installation-1", "token-2"))
+	installation, err := database.GetInstallationByToken(ctx, "token-2")
+	require.NoError(t, err)
+	assert.Equal(t, "installation-1", installation.ID)
+
+	_, err = database.GetInstallationByToken(ctx, "token-1")
+	assert.Equal(t, connector.ErrorInstallationNotFound, err)
+
+	// updating to the current token is not mistaken for a missing installation
+	require.NoError(t, database.UpdateInstallationToken(ctx, "installation-1", "token-2"))
+
+	require.NoError(t, database.UpdateInstanceToken(ctx, "instance-1", "token-2"))
+	instance, err := database.GetInstance(ctx, "instance-1")
+	require.NoError(t, err)
+	assert.Equal(t, connector.InstantiationToken("token-2"), instance.Token)
+	require.NoError(t, database.UpdateInstanceToken(ctx, "instance-1", "token-2"))
+
+	assert.Equal(t, connector.ErrorInstallationNotFound, database.UpdateInstallationToken(ctx, "unknown", "token"))
+	assert.Equal(t, connector.ErrorInstanceNotFound, database.UpdateInstanceToken(ctx, "unknown", "token"))
+}
+
+func testTransactionalAdds(t *testing.T, database connector.Database) {
+	ctx := context.Background()
+	transactional, ok := database.(connector.TransactionalDatabase)
+	require.True(t, ok)
+
+	invalid := []connector.Configuration{{ID: "key", Value: "value"}, {ID: "invalid", Value: "\xff"}}
+	assert.Error(t, transactional.AddInstallationWithConfiguration(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token-1", Configuration: invalid}))
+
+	count, err := database.CountInstallations(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	config := []connector.Configuration{{ID: "key", Value: "value"}}
+	require.NoError(t, transactional.AddInstallationWithConfiguration(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token-1", Configuration: config}))
+
+	installationConfig, err := database.GetInstallationConfiguration(ctx, "installation-1")
+	require.NoError(t, err)
+	assert.Equal(t, config, installationConfig)
+
+	assert.Error(t, transactional.AddInstanceWithConfiguration(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "token-1", Configuration: invalid}))
+	assert.Equal(t, connector.ErrorUnknownInstallation, transactional.AddInstanceWithConfiguration(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "unknown", Token: "token-1"}))
+
+	count, err = database.CountInstances(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	require.NoError(t, transactional.AddInstanceWithConfiguration(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "token-1", Configuration: config}))
+
+	instanceConfig, err := database.GetInstanceConfiguration(ctx, "instance-1")
+	require.NoError(t, err)
+	assert.Equal(t, config, instanceConfig)
+}
+
+func testInstances(t *testing.T, database connector.Database) {
+	ctx := context.Background()
+
+	require.NoError(t, database.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token"}))
+	require.NoError(t, database.AddInstallationConfiguration(ctx, "installation-1", []connector.Configuration{{ID: "installation-key", Value: "value"}}))
+
+	assert.Equal(t, connector.ErrorUnknownInstallation, database.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "unknown", Token: "token"}))
+	require.NoError(t, database.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "instance-token"}))
+	assert.Error(t, database.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "instance-token"}))
+
+	require.NoError(t, database.AddInstanceConfiguration(ctx, "instance-1", []connector.Configuration{{ID: "key", Value: "value"}}))
+	require.NoError(t, database.SetInstanceConfigurationValue(ctx, "instance-1", "key", "updated"))
+	require.NoError(t, database.SetInstanceConfigurationValue(ctx, "instance-1", "added", "value"))
+	require.NoError(t, database.DeleteInstanceConfigurationValue(ctx, "instance-1", "added"))
+	require.NoError(t, database.DeleteInstanceConfigurationValue(ctx, "instance-1", "unknown"))
+
+	instance, err := database.GetInstance(ctx, "instance-1")
+	require.NoError(t, err)
+	assert.Equal(t, "installation-1", instance.InstallationID)
+	assert.Equal(t, connector.InstantiationToken("instance-token"), instance.Token)
+	assert.Equal(t, []connector.Configuration{{ID: "key", Value: "updated"}}, instance.Configuration)
+
+	_, err = database.GetInstance(ctx, "unknown")
+	assert.Equal(t, connector.ErrorInstanceNotFound, err)
+
+	instances, err := database.GetInstances(ctx)
+	require.NoError(t, err)
+	require.Len(t, instances, 1)
+	assert.Equal(t, "instance-1", instances[0].ID)
+
+	installationConfig, err := database.GetInstancesInstallationConfiguration(ctx, "instance-1")
+	require.NoError(t, err)
+	require.Len(t, installationConfig, 1)
+	assert.Equal(t, connector.Configuration{ID: "installation-key", Value: "value"}, *installationConfig[0])
+
+	count, err := database.CountInstances(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	require.NoError(t, database.RemoveInstance(ctx, "instance-1"))
+	assert.Equal(t, connector.ErrorInstanceNotFound, database.RemoveInstance(ctx, "instance-1"))
+	assert.Equal(t, connector.ErrorInstanceNotFound, database.RemoveInstance(ctx, "unknown"))
+
+	_, err = database.GetInstance(ctx, "instance-1")
+	assert.Error(t, err)
+
+	config, err := database.GetInstanceConfiguration(ctx, "instance-1")
+	require.NoError(t, err)
+	assert.Empty(t, config)
+}
+
+func testThingMappings(t *testing.T, database connector.Database) {
+	ctx := context.Background()
+
+	require.NoError(t, database.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token"}))
+	require.NoError(t, database.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "token"}))
+	require.NoError(t, database.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-2", InstallationID: "installation-1", Token: "token"}))
+	require.NoError(t, database.AddInstanceConfiguration(ctx, "instance-2", []connector.Configuration{{ID: "room", Value: "kitchen"}}))
+
+	require.NoError(t, database.AddThingMapping(ctx, "instance-1", "thing-1", " External-1 "))
+	require.NoError(t, database.AddThingMapping(ctx, "instance-1", "thing-2", "external-2"))
+	require.NoError(t, database.AddThingMapping(ctx, "instance-2", "thing-3", "external-3"))
+	assert.Equal(t, connector.ErrorMappingExists, database.AddThingMapping(ctx, "instance-1", "thing-1", "external-1"))
+
+	mappings, err := database.GetMappingByInstanceId(ctx, "instance-1")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []connector.ThingMapping{
+		{InstanceID: "instance-1", ThingID: "thing-1", ExternalID: "external-1"},
+		{InstanceID: "instance-1", ThingID: "thing-2", ExternalID: "external-2"},
+	}, mappings)
+
+	mapping, err := database.GetMappingByExternalId(ctx, "instance-1", "EXTERNAL-1")
+	require.NoError(t, err)
+	assert.Equal(t, "thing-1", mapping.ThingID)
+
+	instance, err := database.GetInstanceByThingId(ctx, "thing-2")
+	require.NoError(t, err)
+	assert.Equal(t, "instance-1", instance.ID)
+	assert.Len(t, instance.ThingMapping, 2)
+
+	_, err = database.GetInstanceByThingId(ctx, "unknown")
+	assert.Equal(t, connector.ErrorInstanceNotFound, err)
+
+	instances, err := database.GetInstancesByThingIds(ctx, []string{"thing-1", "thing-2", "thing-3", "unknown"})
+	require.NoError(t, err)
+	require.Len(t, instances, 3)
+	assert.Equal(t, "instance-1", instances["thing-1"].ID)
+	assert.Same(t, instances["thing-1"], instances["thing-2"])
+	assert.Equal(t, "instance-2", instances["thing-3"].ID)
+	assert.ElementsMatch(t, mappings, instances["thing-1"].ThingMapping)
+	assert.Empty(t, instances["thing-1"].Configuration)
+	assert.Equal(t, []connector.ThingMapping{{InstanceID: "instance-2", ThingID: "thing-3", ExternalID: "external-3"}}, instances["thing-3"].ThingMapping)
+	assert.Equal(t, []connector.Configuration{{ID: "room", Value: "kitchen"}}, instances["thing-3"].Configuration)
+
+	count, err := database.CountThingMappings(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	require.NoError(t, database.RemoveThingMapping(ctx, "instance-1", "thing-1"))
+
+	mappings, err = database.GetMappingByInstanceId(ctx, "instance-1")
+	require.NoError(t, err)
+	require.Len(t, mappings, 1)
+	assert.Equal(t, "thing-2", mappings[0].ThingID)
+}
+
+// testMappingReconciliation covers the lookups and removals used to reconcile things with the platform.
+func testMappingReconciliation(t *testing.T, database connector.Database) {
+	ctx := context.Background()
+
+	require.NoError(t, database.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token"}))
+	require.NoError(t, database.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "token"}))
+	require.NoError(t, database.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-2", InstallationID: "installation-1", Token: "token"}))
+	require.NoError(t, database.AddThingMapping(ctx, "instance-1", "thing-1", "external-1"))
+	require.NoError(t, database.AddThingMapping(ctx, "instance-2", "thing-2", "external-1"))
+
+	// external ids are scoped to their instance
+	mapping, err := database.GetMappingByExternalId(ctx, "instance-2", " EXTERNAL-1 ")
+	require.NoError(t, err)
+	assert.Equal(t, connector.ThingMapping{InstanceID: "instance-2", ThingID: "thing-2", ExternalID: "external-1"}, *mapping)
+
+	// unknown external ids return an empty mapping
+	mapping, err = database.GetMappingByExternalId(ctx, "instance-1", "unknown")
+	require.NoError(t, err)
+	assert.Empty(t, mapping.ThingID)
+
+	mapping, err = database.GetMappingByExternalId(ctx, "unknown", "external-1")
+	require.NoError(t, err)
+	assert.Empty(t, mapping.ThingID)
+
+	// a mapping is only removed from its own instance
+	require.NoError(t, database.RemoveThingMapping(ctx, "instance-1", "thing-2"))
+	count, err := database.CountThingMappings(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	require.NoError(t, database.RemoveThingMapping(ctx, "instance-1", "thing-1"))
+	mapping, err = database.GetMappingByExternalId(ctx, "instance-1", "external-1")
+	require.NoError(t, err)
+	assert.Empty(t, mapping.ThingID)
+
+	// the external id can be mapped again after the removal
+	require.NoError(t, database.AddThingMapping(ctx, "instance-1", "thing-3", "external-1"))
+	mapping, err = database.GetMappingByExternalId(ctx, "instance-1", "external-1")
+	require.NoError(t, err)
+	assert.Equal(t, "thing-3", mapping.ThingID)
+
+	// re-keyed mappings are only found by their new external id
+	require.NoError(t, database.UpdateThingMappingExternalId(ctx, "instance-1", "thing-3", "New-External"))
+	mapping, err = database.GetMappingByExternalId(ctx, "instance-1", "external-1")
+	require.NoError(t, err)
+	assert.Empty(t, mapping.ThingID)
+
+	mapping, err = database.GetMappingByExternalId(ctx, "instance-1", "new-external")
+	require.NoError(t, err)
+	assert.Equal(t, "thing-3", mapping.ThingID)
+
+	instance, err := database.GetInstanceByThingId(ctx, "thing-3")
+	require.NoError(t, err)
+	externalId, ok := instance.ExternalIdByThingId("thing-3")
+	assert.True(t, ok)
+	assert.Equal(t, "new-external", externalId)
+
+	// keeping the external id is allowed, taking the one of another thing of the instance is not
+	require.NoError(t, database.UpdateThingMappingExternalId(ctx, "instance-1", "thing-3", "new-external"))
+	require.NoError(t, database.AddThingMapping(ctx, "instance-1", "thing-4", "external-4"))
+	assert.Equal(t, connector.ErrorMappingExists, database.UpdateThingMappingExternalId(ctx, "instance-1", "thing-4", "new-external"))
+	require.NoError(t, database.UpdateThingMappingExternalId(ctx, "instance-2", "thing-2", "new-external"))
+
+	assert.Equal(t, connector.ErrorMappingNotFound, database.UpdateThingMappingExternalId(ctx, "instance-1", "thing-2", "other"))
+	assert.Equal(t, connector.ErrorMappingNotFound, database.UpdateThingMappingExternalId(ctx, "unknown", "thing-1", "other"))
+	assert.Equal(t, connector.ErrorInvalidExternalID, database.UpdateThingMappingExternalId(ctx, "instance-1", "thing-3", " "))
+}
+
+func testCascadingRemoval(t *testing.T, database connector.Database) {
+	ctx := context.Background()
+
+	require.NoError(t, database.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token"}))
+	require.NoError(t, database.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-2", Token: "token"}))
+	require.NoError(t, database.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "token"}))
+	require.NoError(t, database.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-2", InstallationID: "installation-2", Token: "token"}))
+	require.NoError(t, database.AddInstanceConfiguration(ctx, "instance-1", []connector.Configuration{{ID: "key", Value: "value"}}))
+	require.NoError(t, database.AddThingMapping(ctx, "instance-1", "thing-1", "external-1"))
+	require.NoError(t, database.AddThingMapping(ctx, "instance-2", "thing-2", "external-2"))
+
+	require.NoError(t, database.RemoveInstallation(ctx, "installation-1"))
+
+	_, err := database.GetInstance(ctx, "instance-1")
+	assert.Error(t, err)
+
+	_, err = database.GetInstanceByThingId(ctx, "thing-1")
+	assert.Error(t, err)
+
+	instances, err := database.GetInstances(ctx)
+	require.NoError(t, err)
+	require.Len(t, instances, 1)
+	assert.Equal(t, "instance-2", instances[0].ID)
+
+	count, err := database.CountThingMappings(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func testPropertyValues(t *testing.T, database connector.Database) {
+	ctx := context.Background()
+
+	require.NoError(t, database.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token"}))
+	require.NoError(t, database.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "token"}))
+
+	lastUpdate := time.UnixMilli(time.Now().UnixMilli())
+	value := connector.PropertyValue{InstanceID: "instance-1", ThingID: "thing-1", ComponentID: "sensor", PropertyID: "value", Value: "1", LastUpdate: lastUpdate}
+	require.NoError(t, database.SetLastPropertyValue(ctx, value))
+
+	value.Value = "2"
+	require.NoError(t, database.SetLastPropertyValue(ctx, value))
+
+	values, err := database.GetLastPropertyValues(ctx, "thing-1")
+	require.NoError(t, err)
+	require.Len(t, values, 1)
+	assert.Equal(t, "2", values[0].Value)
+	assert.True(t, lastUpdate.Equal(values[0].LastUpdate))
+
+	values, err = database.GetLastPropertyValues(ctx, "unknown")
+	require.NoError(t, err)
+	assert.Empty(t, values)
+
+	require.NoError(t, database.RemoveInstance(ctx, "instance-1"))
+
+	values, err = database.GetLastPropertyValues(ctx, "thing-1")
+	require.NoError(t, err)
+	assert.Empty(t, values)
+}
+
+func testPendingInstanceStates(t *testing.T, database connector.Database) {
+	ctx := context.Background()
+
+	require.NoError(t, database.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token"}))
+	require.NoError(t, database.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "token"}))
+
+	pending, err := database.GetPendingInstanceState(ctx, "instance-1")
+	require.NoError(t, err)
+	assert.Nil(t, pending)
+
+	first := connector.PendingInstanceState{InstanceID: "instance-1", State: connector.InstantiationStateFailed, Details: json.RawMessage(`{"reason":"offline"}`)}
+	second := connector.PendingInstanceState{InstanceID: "instance-1", State: connector.InstantiationStateComplete}
+	require.NoError(t, database.SetPendingInstanceState(ctx, first))
+	require.NoError(t, database.SetPendingInstanceState(ctx, second))
+
+	// the replaced state is not removed
+	require.NoError(t, database.RemovePendingInstanceState(ctx, first))
+
+	states, err := database.GetPendingInstanceStates(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []connector.PendingInstanceState{second}, states)
+	pending, err = database.GetPendingInstanceState(ctx, "instance-1")
+	require.NoError(t, err)
+	assert.Equal(t, &second, pending)
+
+	require.NoError(t, database.RemovePendingInstanceState(ctx, second))
+
+	states, err = database.GetPendingInstanceStates(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, states)
+	pending, err = database.GetPendingInstanceState(ctx, "instance-1")
+	require.NoError(t, err)
+	assert.Nil(t, pending)
+}
+
+func testBulkConfigurations(t *testing.T, database connector.Database) {
+	ctx := context.Background()
+
+	require.NoError(t, database.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token"}))
+	for _, id := range []string{"instance-1", "instance-2", "instance-3"} {
+		require.NoError(t, database.AddInstance(ctx, connector.InstantiationRequest{ID: id, InstallationID: "installation-1", Token: "token"}))
+	}
+	require.NoError(t, database.AddInstanceConfiguration(ctx, "instance-1", []connector.Configuration{{ID: "host", Value: "example.com"}, {ID: "port", Value: "80"}}))
+	require.NoError(t, database.AddInstanceConfiguration(ctx, "instance-2", []connector.Configuration{{ID: "host", Value: "example.org"}}))
+
+	configs, err := database.GetInstanceConfigurations(ctx, []string{"instance-1", "instance-2", "instance-3", "unknown"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]connector.Configuration{
+		"instance-1": {{ID: "host", Value: "example.com"}, {ID: "port", Value: "80"}},
+		"instance-2": {{ID: "host", Value: "example.org"}},
+		"instance-3": {},
+		"unknown":    {},
+	}, configs)
+
+	configs, err = database.GetInstanceConfigurations(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, configs)
+}
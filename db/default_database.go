@@ -1,13 +1,23 @@
 // Package db implements default implementations for the database interface used by the default service.
-// It currently supports Mysql, Postgres and Sqlite3.
+// It currently supports Mysql, Postgres, Sqlite3 and SQL Server. CockroachDB can be used with the Postgres driver.
+// All statements are written with ? placeholders, which are rebound to the placeholders of the driver before execution.
+// The TEXT columns of the default layout can't be compared by SQL Server, so connectors using it should
+// overwrite MigrationQueries with a layout using VARCHAR(MAX) columns instead.
 package db
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/connctd/connector-go"
+	"github.com/connctd/connector-go/connctd"
 
 	// registers mysql driver at db journeys registry
 	_ "github.com/db-journey/mysql-driver"
@@ -18,6 +28,9 @@ import (
 	// registers sqlite3 driver at db journeys registry
 	_ "github.com/mattn/go-sqlite3"
 
+	// registers sqlserver driver
+	_ "github.com/microsoft/go-mssqldb"
+
 	"github.com/go-logr/logr"
 	"github.com/jmoiron/sqlx"
 )
@@ -25,6 +38,50 @@ import (
 type DBOptions struct {
 	Driver DBDriverName
 	DSN    string
+
+	// SoftDelete marks removed installations and instances as deleted instead of deleting them.
+	// Deleted rows are ignored by all queries and can be restored until they are purged with PurgeDeleted.
+	// It requires the deleted_at columns created by SoftDeleteMigrationQueries.
+	SoftDelete bool
+
+	// TokenHashKey enables storing a keyed hash (HMAC-SHA256) of each installation token.
+	// Lookups by token use the hash instead of the token, so they keep working if tokens are not stored in plaintext.
+	// It requires the token_hash column created by TokenHashMigrationQueries.
+	TokenHashKey []byte
+
+	// ReplicaDSN is the DSN of an optional read replica using the same driver.
+	// If set, queries of Get and Count methods are sent to the replica while all writes use the primary database.
+	// Reads can be forced to the primary with WithPrimary.
+	ReplicaDSN string
+
+	// CompressConfig stores configuration values gzip compressed.
+	// Compressed values are marked, so they can coexist with uncompressed values and are decompressed
+	// by all methods returning configurations, independent of this option.
+	// Note that compressed values must still fit in the value column.
+	CompressConfig bool
+
+	// JSONConfigStorage stores the whole configuration of an installation or instance as a single JSON document
+	// instead of one row per parameter. Values are not limited by the size of the value column,
+	// so they can e.g. hold nested structures. Values are stored uncompressed, independent of CompressConfig.
+	// It requires the configuration columns created by JSONConfigMigrationQueries.
+	JSONConfigStorage bool
+
+	// TokenCipher encrypts installation and instance tokens before they are stored and decrypts them when read.
+	// Tokens stored before encryption was enabled are still read unchanged.
+	// It is also used for instance secrets, which can't be stored without it.
+	// Since encrypted tokens can't be compared, GetInstallationByToken requires a TokenHashKey to find installations with encrypted tokens.
+	TokenCipher TokenCipher
+
+	// QueryTimeouts bound the duration of reads and writes, e.g. to keep slow queries from stalling the handling of actions.
+	// By default only the context passed by the caller applies.
+	QueryTimeouts QueryTimeouts
+
+	// Connection pool settings applied to the primary database and the replica.
+	// Zero values keep the defaults of database/sql, see sql.DB.SetMaxOpenConns and the related methods.
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
 }
 
 var DefaultOptions = &DBOptions{
@@ -39,30 +96,107 @@ var (
 	DriverMysql      = DBDriverName("mysql")
 	DriverPostgresql = DBDriverName("postgres")
 	DriverSqlite3    = DBDriverName("sqlite3")
+	DriverSqlserver  = DBDriverName("sqlserver")
 )
 
 var (
 	statementInsertInstallation                       = `INSERT INTO installations (id, token) VALUES (?, ?)`
+	statementInsertInstallationWithTokenHash          = `INSERT INTO installations (id, token, token_hash) VALUES (?, ?, ?)`
+	statementGetInstallationByToken                   = `SELECT id, token FROM installations WHERE token = ?`
+	statementGetInstallationByTokenHash               = `SELECT id, token FROM installations WHERE token_hash = ?`
 	statementInsertInstallationConfig                 = `INSERT INTO installation_configuration (installation_id, id, value) VALUES (?, ?, ?)`
-	statementGetInstallations                         = `SELECT id FROM installations`
+	statementGetInstallations                         = `SELECT id, token FROM installations`
+	statementCountInstallationsByID                   = `SELECT COUNT(*) FROM installations WHERE id = ?`
 	statementGetConfigurationByInstallationID         = `SELECT id, value FROM installation_configuration WHERE installation_id = ?`
 	statementGetInstallationConfigurationByInstanceID = `SELECT l.id AS id, l.value AS value FROM installation_configuration l, instances i WHERE i.id = ? AND l.installation_id = i.installation_id`
 	statementRemoveInstallationById                   = `DELETE FROM installations WHERE id = ?`
+	statementUpdateInstallationToken                  = `UPDATE installations SET token = ? WHERE id = ?`
+	statementUpdateInstallationTokenWithHash          = `UPDATE installations SET token = ?, token_hash = ? WHERE id = ?`
+	statementRemoveInstallationConfig                 = `DELETE FROM installation_configuration WHERE installation_id = ?`
 
-	statementInsertInstance               = `INSERT INTO instances (id, installation_id, token) VALUES (?, ?, ?)`
-	statementGetInstanceByID              = `SELECT id, token, installation_id FROM instances WHERE id = ?`
-	statementGetInstanceByThingID         = `SELECT id, token, installation_id FROM instances, (SELECT instance_id FROM instance_thing_mapping WHERE thing_id = ? LIMIT 1) mapping WHERE id = instance_id;`
-	statementGetInstances                 = `SELECT id, token, installation_id FROM instances`
-	statementInsertInstanceConfig         = `INSERT INTO instance_configuration (instance_id, id, value) VALUES (?, ?, ?)`
-	statementGetConfigurationByInstanceID = `SELECT id, value FROM instance_configuration WHERE instance_id = ?`
-	statementGetThingsByInstanceID        = `SELECT instance_id, thing_id, external_id FROM instance_thing_mapping WHERE instance_id = ?`
-	statementGetThingsByExternalID        = `SELECT instance_id, thing_id, external_id FROM instance_thing_mapping WHERE instance_id = ? AND external_id = ?`
+	statementInsertInstance                = `INSERT INTO instances (id, installation_id, token) VALUES (?, ?, ?)`
+	statementGetInstanceByID               = `SELECT id, token, installation_id FROM instances WHERE id = ?`
+	statementGetInstanceByThingID          = `SELECT id, token, installation_id FROM instances WHERE id = (SELECT MIN(instance_id) FROM instance_thing_mapping WHERE thing_id = ?)`
+	statementGetInstancesByThingIDs        = `SELECT m.thing_id AS thing_id, i.id AS id, i.token AS token, i.installation_id AS installation_id FROM instances i, instance_thing_mapping m WHERE i.id = m.instance_id AND m.thing_id IN (?)`
+	statementGetInstances                  = `SELECT id, token, installation_id FROM instances`
+	statementCountInstancesByID            = `SELECT COUNT(*) FROM instances WHERE id = ?`
+	statementUpdateInstanceToken           = `UPDATE instances SET token = ? WHERE id = ?`
+	statementInsertInstanceConfig          = `INSERT INTO instance_configuration (instance_id, id, value) VALUES (?, ?, ?)`
+	statementGetConfigurationByInstanceID  = `SELECT id, value FROM instance_configuration WHERE instance_id = ?`
+	statementGetConfigurationByInstanceIDs = `SELECT instance_id, id, value FROM instance_configuration WHERE instance_id IN (?)`
+	statementUpdateInstanceConfigValue     = `UPDATE instance_configuration SET value = ? WHERE instance_id = ? AND id = ?`
+	statementRemoveInstanceConfigValue     = `DELETE FROM instance_configuration WHERE instance_id = ? AND id = ?`
+	statementGetThingsByInstanceID         = `SELECT instance_id, thing_id, external_id FROM instance_thing_mapping WHERE instance_id = ?`
+	statementGetThingsByExternalID         = `SELECT instance_id, thing_id, external_id FROM instance_thing_mapping WHERE instance_id = ? AND LOWER(LTRIM(RTRIM(external_id))) = ?`
+	statementGetThingsByInstanceIDs        = `SELECT instance_id, thing_id, external_id FROM instance_thing_mapping WHERE instance_id IN (?)`
 
 	statementRemoveInstanceById = `DELETE FROM instances WHERE id = ?`
 
+	// delete the rows referencing an instance explicitly, since foreign keys are not enforced by all databases
+	statementRemoveInstanceConfig           = `DELETE FROM instance_configuration WHERE instance_id = ?`
+	statementRemoveThingMappingsByInstance  = `DELETE FROM instance_thing_mapping WHERE instance_id = ?`
+	statementRemovePropertyValuesByInstance = `DELETE FROM property_values WHERE instance_id = ?`
+	statementRemoveSecretsByInstance        = `DELETE FROM instance_secrets WHERE instance_id = ?`
+
 	statementInsertThingId = `INSERT INTO instance_thing_mapping (instance_id, thing_id, external_id) VALUES (?, ?, ?)`
 
 	statementRemoveThingMapping = `DELETE FROM instance_thing_mapping WHERE instance_id = ? AND thing_id = ?`
+
+	statementCountThingMappingsByThingID    = `SELECT COUNT(*) FROM instance_thing_mapping WHERE instance_id = ? AND thing_id = ?`
+	statementCountOtherThingMappingsByExtID = `SELECT COUNT(*) FROM instance_thing_mapping WHERE instance_id = ? AND LOWER(LTRIM(RTRIM(external_id))) = ? AND thing_id <> ?`
+	statementUpdateThingMappingExternalID   = `UPDATE instance_thing_mapping SET external_id = ? WHERE instance_id = ? AND thing_id = ?`
+
+	statementCountInstallations = `SELECT COUNT(*) FROM installations`
+	statementCountInstances     = `SELECT COUNT(*) FROM instances`
+	statementCountThingMappings = `SELECT COUNT(*) FROM instance_thing_mapping`
+
+	statementUpdatePropertyValue        = `UPDATE property_values SET value = ?, last_update = ? WHERE instance_id = ? AND thing_id = ? AND component_id = ? AND property_id = ?`
+	statementInsertPropertyValue        = `INSERT INTO property_values (instance_id, thing_id, component_id, property_id, value, last_update) VALUES (?, ?, ?, ?, ?, ?)`
+	statementGetPropertyValuesByThingID = `SELECT instance_id, thing_id, component_id, property_id, value, last_update FROM property_values WHERE thing_id = ?`
+
+	statementUpdatePendingInstanceState   = `UPDATE pending_instance_states SET state = ?, details = ? WHERE instance_id = ?`
+	statementInsertPendingInstanceState   = `INSERT INTO pending_instance_states (instance_id, state, details) VALUES (?, ?, ?)`
+	statementGetPendingInstanceStates     = `SELECT instance_id, state, details FROM pending_instance_states`
+	statementGetPendingInstanceState      = `SELECT instance_id, state, details FROM pending_instance_states WHERE instance_id = ?`
+	statementRemovePendingInstanceState   = `DELETE FROM pending_instance_states WHERE instance_id = ? AND state = ? AND details = ?`
+	statementRemovePendingStateByInstance = `DELETE FROM pending_instance_states WHERE instance_id = ?`
+)
+
+// Statements used instead of the above if soft delete is enabled:
+var (
+	statementSoftGetInstallations                         = `SELECT id, token FROM installations WHERE deleted_at IS NULL`
+	statementSoftCountInstallationsByID                   = `SELECT COUNT(*) FROM installations WHERE id = ? AND deleted_at IS NULL`
+	statementSoftGetInstallationConfigurationByInstanceID = `SELECT l.id AS id, l.value AS value FROM installation_configuration l, instances i WHERE i.id = ? AND i.deleted_at IS NULL AND l.installation_id = i.installation_id`
+	statementSoftRemoveInstallationById                   = `UPDATE installations SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL`
+	statementSoftRemoveInstancesByInstallationId          = `UPDATE instances SET deleted_at = ? WHERE installation_id = ? AND deleted_at IS NULL`
+	statementSoftCountInstallations                       = `SELECT COUNT(*) FROM installations WHERE deleted_at IS NULL`
+
+	statementSoftGetInstanceByID        = `SELECT id, token, installation_id FROM instances WHERE id = ? AND deleted_at IS NULL`
+	statementSoftGetInstanceByThingID   = `SELECT id, token, installation_id FROM instances WHERE id = (SELECT MIN(instance_id) FROM instance_thing_mapping WHERE thing_id = ?) AND deleted_at IS NULL`
+	statementSoftGetInstancesByThingIDs = `SELECT m.thing_id AS thing_id, i.id AS id, i.token AS token, i.installation_id AS installation_id FROM instances i, instance_thing_mapping m WHERE i.id = m.instance_id AND i.deleted_at IS NULL AND m.thing_id IN (?)`
+	statementSoftGetInstances           = `SELECT id, token, installation_id FROM instances WHERE deleted_at IS NULL`
+	statementSoftRemoveInstanceById     = `UPDATE instances SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL`
+	statementSoftCountInstances         = `SELECT COUNT(*) FROM instances WHERE deleted_at IS NULL`
+	statementSoftCountInstancesByID     = `SELECT COUNT(*) FROM instances WHERE id = ? AND deleted_at IS NULL`
+	statementSoftCountThingMappings     = `SELECT COUNT(*) FROM instance_thing_mapping m, instances i WHERE m.instance_id = i.id AND i.deleted_at IS NULL`
+	statementSoftGetPendingStates       = `SELECT p.instance_id AS instance_id, p.state AS state, p.details AS details FROM pending_instance_states p, instances i WHERE p.instance_id = i.id AND i.deleted_at IS NULL`
+	statementSoftGetPendingState        = `SELECT p.instance_id AS instance_id, p.state AS state, p.details AS details FROM pending_instance_states p, instances i WHERE p.instance_id = ? AND p.instance_id = i.id AND i.deleted_at IS NULL`
+
+	statementSoftGetConfigurationByInstanceID  = `SELECT c.id AS id, c.value AS value FROM instance_configuration c, instances i WHERE c.instance_id = ? AND c.instance_id = i.id AND i.deleted_at IS NULL`
+	statementSoftGetConfigurationByInstanceIDs = `SELECT c.instance_id AS instance_id, c.id AS id, c.value AS value FROM instance_configuration c, instances i WHERE c.instance_id IN (?) AND c.instance_id = i.id AND i.deleted_at IS NULL`
+	statementSoftGetThingsByInstanceID         = `SELECT m.instance_id AS instance_id, m.thing_id AS thing_id, m.external_id AS external_id FROM instance_thing_mapping m, instances i WHERE m.instance_id = ? AND m.instance_id = i.id AND i.deleted_at IS NULL`
+	statementSoftGetThingsByExternalID         = `SELECT m.instance_id AS instance_id, m.thing_id AS thing_id, m.external_id AS external_id FROM instance_thing_mapping m, instances i WHERE m.instance_id = ? AND LOWER(LTRIM(RTRIM(m.external_id))) = ? AND m.instance_id = i.id AND i.deleted_at IS NULL`
+	statementSoftGetThingsByInstanceIDs        = `SELECT m.instance_id AS instance_id, m.thing_id AS thing_id, m.external_id AS external_id FROM instance_thing_mapping m, instances i WHERE m.instance_id IN (?) AND m.instance_id = i.id AND i.deleted_at IS NULL`
+	statementSoftGetPropertyValuesByThingID    = `SELECT p.instance_id AS instance_id, p.thing_id AS thing_id, p.component_id AS component_id, p.property_id AS property_id, p.value AS value, p.last_update AS last_update FROM property_values p, instances i WHERE p.thing_id = ? AND p.instance_id = i.id AND i.deleted_at IS NULL`
+
+	statementRestoreInstallationById          = `UPDATE installations SET deleted_at = NULL WHERE id = ? AND deleted_at = ?`
+	statementRestoreInstancesByInstallationId = `UPDATE instances SET deleted_at = NULL WHERE installation_id = ? AND deleted_at = ?`
+	statementRestoreInstanceById              = `UPDATE instances SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL`
+	statementGetInstallationDeletedAt         = `SELECT deleted_at FROM installations WHERE id = ? AND deleted_at IS NOT NULL`
+	statementPurgeInstances                   = `DELETE FROM instances WHERE deleted_at < ?`
+	statementPurgeInstallations               = `DELETE FROM installations WHERE deleted_at < ?`
+	statementGetPurgeableInstanceIDs          = `SELECT id FROM instances WHERE deleted_at < ?`
+	statementGetPurgeableInstallationIDs      = `SELECT id FROM installations WHERE deleted_at < ?`
 )
 
 // The default database layout:
@@ -91,6 +225,20 @@ const (
 			REFERENCES instances(id) ON DELETE CASCADE
 	)`
 
+	StatementCreateThingMappingUniqueIndex = `CREATE UNIQUE INDEX instance_thing_mapping_thing_id ON instance_thing_mapping (instance_id, thing_id)`
+
+	// The following indexes let lookups of instances by thing id, of mappings by external id and of configurations
+	// use index scans instead of full table scans, so their cost grows logarithmically instead of linearly with the
+	// number of rows. Existing databases can create them by executing the statements once.
+	StatementCreateThingMappingThingIndex      = `CREATE INDEX instance_thing_mapping_by_thing ON instance_thing_mapping (thing_id)`
+	StatementCreateThingMappingExternalIndex   = `CREATE INDEX instance_thing_mapping_by_external_id ON instance_thing_mapping (instance_id, external_id)`
+	StatementCreateInstallConfigInstallIndex   = `CREATE INDEX installation_configuration_by_installation ON installation_configuration (installation_id)`
+	StatementCreateInstanceConfigInstanceIndex = `CREATE INDEX instance_configuration_by_instance ON instance_configuration (instance_id)`
+
+	// StatementCreateInstanceConfigUniqueIndex makes configuration parameters unique per instance, so concurrent calls of
+	// SetInstanceConfigurationValue can't insert the same parameter twice. Existing databases must remove duplicates first.
+	StatementCreateInstanceConfigUniqueIndex = `CREATE UNIQUE INDEX instance_configuration_by_id ON instance_configuration (instance_id, id)`
+
 	StatementCreateInstallConfigTable = `CREATE TABLE installation_configuration (
 		installation_id CHAR (36) NOT NULL,
 		id CHAR (36) NOT NULL,
@@ -106,6 +254,36 @@ const (
 		FOREIGN KEY (instance_id)
 			REFERENCES instances(id) ON DELETE CASCADE
 	)`
+
+	StatementCreatePropertyValueTable = `CREATE TABLE property_values (
+		instance_id CHAR (36) NOT NULL,
+		thing_id CHAR (36) NOT NULL,
+		component_id VARCHAR (200) NOT NULL,
+		property_id VARCHAR (200) NOT NULL,
+		value TEXT NOT NULL,
+		last_update BIGINT NOT NULL,
+		UNIQUE(instance_id, thing_id, component_id, property_id),
+		FOREIGN KEY (instance_id)
+			REFERENCES instances(id) ON DELETE CASCADE
+	)`
+
+	StatementCreatePendingInstanceStateTable = `CREATE TABLE pending_instance_states (
+		instance_id CHAR (36) NOT NULL,
+		state INT NOT NULL,
+		details TEXT NOT NULL,
+		UNIQUE(instance_id),
+		FOREIGN KEY (instance_id)
+			REFERENCES instances(id) ON DELETE CASCADE
+	)`
+
+	StatementCreateInstanceSecretsTable = `CREATE TABLE instance_secrets (
+		instance_id CHAR (36) NOT NULL,
+		id VARCHAR (200) NOT NULL,
+		value TEXT NOT NULL,
+		UNIQUE(instance_id, id),
+		FOREIGN KEY (instance_id)
+			REFERENCES instances(id) ON DELETE CASCADE
+	)`
 )
 
 // MigrationQueries will be executed when the connector calls Migrate:
@@ -113,13 +291,51 @@ var MigrationQueries = []string{
 	StatementCreateInstallationTable,
 	StatementCreateInstanceTable,
 	StatementCreateInstaceThingMapping,
+	StatementCreateThingMappingUniqueIndex,
 	StatementCreateInstallConfigTable,
 	StatementCreateInstanceConfigTable,
+	StatementCreatePropertyValueTable,
+	StatementCreatePendingInstanceStateTable,
+	StatementCreateThingMappingThingIndex,
+	StatementCreateThingMappingExternalIndex,
+	StatementCreateInstallConfigInstallIndex,
+	StatementCreateInstanceConfigInstanceIndex,
+	StatementCreateInstanceSecretsTable,
+	StatementCreateInstanceConfigUniqueIndex,
+}
+
+// SoftDeleteMigrationQueries add the columns needed for soft delete.
+// Migrate executes them after MigrationQueries if soft delete is enabled.
+// Connectors enabling soft delete for an existing database should execute them once on their own.
+var SoftDeleteMigrationQueries = []string{
+	`ALTER TABLE installations ADD COLUMN deleted_at BIGINT DEFAULT NULL`,
+	`ALTER TABLE instances ADD COLUMN deleted_at BIGINT DEFAULT NULL`,
+}
+
+// TokenHashMigrationQueries add the column needed for token hashes.
+// Migrate executes them after MigrationQueries if a token hash key is set.
+// Connectors enabling token hashes for an existing database should execute them once on their own.
+var TokenHashMigrationQueries = []string{
+	`ALTER TABLE installations ADD COLUMN token_hash CHAR (64) DEFAULT NULL`,
+	`CREATE INDEX installations_token_hash ON installations (token_hash)`,
 }
 
 type DBClient struct {
 	DB     *sqlx.DB
 	Logger logr.Logger
+
+	// Replica is the read replica, nil if none is configured
+	Replica *sqlx.DB
+
+	driver   DBDriverName
+	bindType int
+
+	softDelete     bool
+	tokenHashKey   []byte
+	compressConfig bool
+	jsonConfig     bool
+	tokenCipher    TokenCipher
+	queryTimeouts  QueryTimeouts
 }
 
 // NewDBClient creates a new mysql client
@@ -130,15 +346,69 @@ func NewDBClient(dbOptions *DBOptions, logger logr.Logger) (*DBClient, error) {
 		return nil, fmt.Errorf("can't connect to db with DSN: %w", err)
 	}
 
-	return &DBClient{db, logger}, nil
+	dbOptions.configurePool(db)
+
+	client := &DBClient{DB: db, Logger: logger, driver: dbOptions.Driver, bindType: sqlx.BindType(string(dbOptions.Driver)), softDelete: dbOptions.SoftDelete, tokenHashKey: dbOptions.TokenHashKey, compressConfig: dbOptions.CompressConfig, jsonConfig: dbOptions.JSONConfigStorage, tokenCipher: dbOptions.TokenCipher, queryTimeouts: dbOptions.QueryTimeouts}
+
+	if dbOptions.ReplicaDSN != "" {
+		client.Replica, err = sqlx.Connect(string(dbOptions.Driver), dbOptions.ReplicaDSN)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("can't connect to replica db with DSN: %w", err)
+		}
+		dbOptions.configurePool(client.Replica)
+	}
+
+	return client, nil
+}
+
+// configurePool applies the configured connection pool settings.
+func (o *DBOptions) configurePool(db *sqlx.DB) {
+	if o.MaxOpenConns != 0 {
+		db.SetMaxOpenConns(o.MaxOpenConns)
+	}
+	if o.MaxIdleConns != 0 {
+		db.SetMaxIdleConns(o.MaxIdleConns)
+	}
+	if o.ConnMaxLifetime != 0 {
+		db.SetConnMaxLifetime(o.ConnMaxLifetime)
+	}
+	if o.ConnMaxIdleTime != 0 {
+		db.SetConnMaxIdleTime(o.ConnMaxIdleTime)
+	}
+}
+
+// rebind replaces the ? placeholders of the statement with the ones of the driver.
+func (m *DBClient) rebind(statement string) string {
+	return sqlx.Rebind(m.bindType, statement)
+}
+
+// statement returns the soft delete variant of a statement if soft delete is enabled.
+func (m *DBClient) statement(statement string, softDeleteStatement string) string {
+	if m.softDelete {
+		return softDeleteStatement
+	}
+	return statement
 }
 
 // Migrate will execute all queries in MigrationQueries
 // It returns error if any of the queries fails to execute.
 // Migrate is not called by the default service but may be called once by the connector to initially migrate a database.
+// Migrate does not track the schema version, use MigrateUp for versioned migrations instead.
 // Note that MigrationQueries can be overwritten.
 func (m *DBClient) Migrate() error {
-	for _, q := range MigrationQueries {
+	queries := append([]string{}, MigrationQueries...)
+	if m.softDelete {
+		queries = append(queries, SoftDeleteMigrationQueries...)
+	}
+	if m.tokenHashKey != nil {
+		queries = append(queries, TokenHashMigrationQueries...)
+	}
+	if m.jsonConfig {
+		queries = append(queries, JSONConfigMigrationQueries...)
+	}
+
+	for _, q := range queries {
 		_, err := m.DB.Exec(q)
 		if err != nil {
 			return fmt.Errorf("failed to migrate db (query: %v) %v", q, err)
@@ -150,7 +420,24 @@ func (m *DBClient) Migrate() error {
 // AddInstallation adds an installation request to the database.
 // It assumes that all data is verified beforehand and therefore does not validate anything on it's own.
 func (m *DBClient) AddInstallation(ctx context.Context, installationRequest connector.InstallationRequest) error {
-	_, err := m.DB.Exec(statementInsertInstallation, installationRequest.ID, installationRequest.Token)
+	ctx, cancel := m.writeContext(ctx)
+	defer cancel()
+
+	return m.insertInstallation(ctx, m.DB, installationRequest)
+}
+
+// insertInstallation inserts the installation without its configuration.
+func (m *DBClient) insertInstallation(ctx context.Context, exec sqlx.ExecerContext, installationRequest connector.InstallationRequest) error {
+	token, err := m.encryptToken(string(installationRequest.Token))
+	if err != nil {
+		return err
+	}
+
+	if m.tokenHashKey != nil {
+		_, err = exec.ExecContext(ctx, m.rebind(statementInsertInstallationWithTokenHash), installationRequest.ID, token, m.hashToken(string(installationRequest.Token)))
+	} else {
+		_, err = exec.ExecContext(ctx, m.rebind(statementInsertInstallation), installationRequest.ID, token)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to insert installation: %w", err)
 	}
@@ -159,209 +446,1032 @@ func (m *DBClient) AddInstallation(ctx context.Context, installationRequest conn
 }
 
 // AddInstallationConfiguration adds all configuration parameters to the database.
+// It rejects the whole configuration if any of the values is not valid UTF-8.
 func (m *DBClient) AddInstallationConfiguration(ctx context.Context, installationId string, config []connector.Configuration) error {
-	for _, c := range config {
-		_, err := m.DB.Exec(statementInsertInstallationConfig, installationId, c.ID, c.Value)
-		if err != nil {
-			return fmt.Errorf("failed to insert installation config: %w", err)
-		}
+	ctx, cancel := m.writeContext(ctx)
+	defer cancel()
+
+	if err := verifyConfiguration(config); err != nil {
+		return err
 	}
 
-	return nil
+	if m.jsonConfig {
+		return m.appendJSONConfig(ctx, installationJSONConfig, installationId, config)
+	}
+
+	return m.insertConfiguration(ctx, m.DB, statementInsertInstallationConfig, installationId, config)
 }
 
-// GetInstallations returns a list of all existing installations together with their provided configuration parameters.
-func (m *DBClient) GetInstallations(ctx context.Context) ([]*connector.Installation, error) {
-	var installations []*connector.Installation
-	err := m.DB.Select(&installations, statementGetInstallations)
+// AddInstallationWithConfiguration adds the installation together with its configuration in a single transaction,
+// so either both are stored or nothing is. It rejects the whole installation if any of the values is not valid UTF-8.
+func (m *DBClient) AddInstallationWithConfiguration(ctx context.Context, installationRequest connector.InstallationRequest) error {
+	ctx, cancel := m.writeContext(ctx)
+	defer cancel()
+
+	if err := verifyConfiguration(installationRequest.Configuration); err != nil {
+		return err
+	}
+
+	tx, err := m.DB.BeginTxx(ctx, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve instance: %w", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	for i, installation := range installations {
-		var configurations []connector.Configuration
-		err := m.DB.Select(&configurations, statementGetConfigurationByInstallationID, installation.ID)
-		if err != nil {
-			return nil, fmt.Errorf("failed to retrieve instance: %w", err)
-		}
-		installations[i].Configuration = configurations
+	defer tx.Rollback()
+
+	if err := m.insertInstallation(ctx, tx, installationRequest); err != nil {
+		return err
 	}
-	return installations, nil
-}
 
-// GetInstancesInstallationConfiguration retrieves the configuration of the installation of an instance
-func (m *DBClient) GetInstancesInstallationConfiguration(ctx context.Context, instanceID string) ([]*connector.Configuration, error) {
-	var configurations []*connector.Configuration
-	if err := m.DB.Select(&configurations, statementGetInstallationConfigurationByInstanceID, instanceID); err != nil {
-		return nil, fmt.Errorf("failed to retrieve instances installation configuration: %w", err)
+	if m.jsonConfig {
+		err = m.setJSONConfig(ctx, tx, installationJSONConfig, installationRequest.ID, installationRequest.Configuration)
+	} else {
+		err = m.insertConfiguration(ctx, tx, statementInsertInstallationConfig, installationRequest.ID, installationRequest.Configuration)
+	}
+	if err != nil {
+		return err
 	}
 
-	return configurations, nil
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit installation: %w", err)
+	}
+
+	return nil
 }
 
-// RemoveInstallation removes the instance with the given id from the database.
-// This will also remove instances belonging to this installation, as well as the configuration parameters.
-// Removal of config parameters and instances is implemented via cascading foreign keys in the database.
-// If your database does not support cascading foreign keys, you should delete them manually.
-func (m *DBClient) RemoveInstallation(ctx context.Context, installationId string) error {
-	_, err := m.DB.Exec(statementRemoveInstallationById, installationId)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return connector.ErrorInstallationNotFound
+// configInsertBatchSize is the maximum number of configuration parameters inserted by a single statement.
+// It keeps the number of placeholders below the limits of all supported drivers, the lowest being 999 of older sqlite versions.
+const configInsertBatchSize = 300
+
+// insertConfiguration inserts the encoded configuration parameters with the given single row insert statement.
+// The parameters are inserted with multi row statements of up to configInsertBatchSize rows each, to save round trips.
+func (m *DBClient) insertConfiguration(ctx context.Context, exec sqlx.ExecerContext, statement string, id string, config []connector.Configuration) error {
+	for start := 0; start < len(config); start += configInsertBatchSize {
+		end := start + configInsertBatchSize
+		if end > len(config) {
+			end = len(config)
+		}
+
+		args := make([]interface{}, 0, 3*(end-start))
+		for _, c := range config[start:end] {
+			value, err := m.encodeConfigValue(c.Value)
+			if err != nil {
+				return err
+			}
+			args = append(args, id, c.ID, value)
+		}
+
+		if _, err := exec.ExecContext(ctx, m.rebind(multiRowInsert(statement, end-start)), args...); err != nil {
+			return fmt.Errorf("failed to insert config: %w", err)
 		}
-		return fmt.Errorf("failed to remove installation: %w", err)
 	}
 
 	return nil
 }
 
-// AddInstance adds an instantiation to the database.
-func (m *DBClient) AddInstance(ctx context.Context, instantiationRequest connector.InstantiationRequest) error {
-	_, err := m.DB.Exec(statementInsertInstance, instantiationRequest.ID, instantiationRequest.InstallationID, instantiationRequest.Token)
+// multiRowInsert repeats the values of a single row insert statement for the given number of rows.
+func multiRowInsert(statement string, rows int) string {
+	values := statement[strings.LastIndex(statement, "VALUES ")+len("VALUES "):]
+	return statement + strings.Repeat(", "+values, rows-1)
+}
+
+// UpdateInstallation replaces the token and the configuration of an existing installation.
+// It returns connector.ErrorInstallationNotFound if the installation does not exist.
+func (m *DBClient) UpdateInstallation(ctx context.Context, installationRequest connector.InstallationRequest) error {
+	ctx, cancel := m.writeContext(ctx)
+	defer cancel()
+
+	if err := verifyConfiguration(installationRequest.Configuration); err != nil {
+		return err
+	}
+
+	tx, err := m.DB.BeginTxx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to insert instance: %w", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	return nil
-}
+	var installations int
+	if err := tx.GetContext(ctx, &installations, m.rebind(m.statement(statementCountInstallationsByID, statementSoftCountInstallationsByID)), installationRequest.ID); err != nil {
+		return fmt.Errorf("failed to retrieve installation: %w", err)
+	}
+	if installations == 0 {
+		return connector.ErrorInstallationNotFound
+	}
 
-// AddInstanceConfiguration adds all configuration parameters to the database.
-func (m *DBClient) AddInstanceConfiguration(ctx context.Context, instanceId string, config []connector.Configuration) error {
-	for _, c := range config {
-		_, err := m.DB.Exec(statementInsertInstanceConfig, instanceId, c.ID, c.Value)
-		if err != nil {
-			return fmt.Errorf("failed to insert installation config: %w", err)
+	if err := m.updateInstallationToken(ctx, tx, installationRequest.ID, installationRequest.Token); err != nil {
+		return err
+	}
+
+	if m.jsonConfig {
+		if err := m.setJSONConfig(ctx, tx, installationJSONConfig, installationRequest.ID, installationRequest.Configuration); err != nil {
+			return err
+		}
+	} else {
+		if _, err := tx.ExecContext(ctx, m.rebind(statementRemoveInstallationConfig), installationRequest.ID); err != nil {
+			return fmt.Errorf("failed to remove installation config: %w", err)
+		}
+
+		if err := m.insertConfiguration(ctx, tx, statementInsertInstallationConfig, installationRequest.ID, installationRequest.Configuration); err != nil {
+			return err
 		}
 	}
 
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit installation update: %w", err)
+	}
+
 	return nil
 }
 
-// GetInstance returns the instance with the given id.
-func (m *DBClient) GetInstance(ctx context.Context, instanceId string) (*connector.Instance, error) {
-	var instance connector.Instance
-	err := m.DB.Get(&instance, statementGetInstanceByID, instanceId)
+// UpdateInstallationToken replaces the token of an existing installation, e.g. after the platform rotated it.
+// It returns connector.ErrorInstallationNotFound if the installation does not exist.
+func (m *DBClient) UpdateInstallationToken(ctx context.Context, installationId string, token connector.InstallationToken) error {
+	ctx, cancel := m.writeContext(ctx)
+	defer cancel()
+
+	tx, err := m.DB.BeginTxx(ctx, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve instance: %w", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	config, err := m.GetInstanceConfiguration(ctx, instance.ID)
+	// the number of affected rows can't be used, since some drivers only count changed rows
+	var installations int
+	if err := tx.GetContext(ctx, &installations, m.rebind(m.statement(statementCountInstallationsByID, statementSoftCountInstallationsByID)), installationId); err != nil {
+		return fmt.Errorf("failed to retrieve installation: %w", err)
+	}
+	if installations == 0 {
+		return connector.ErrorInstallationNotFound
+	}
+
+	if err := m.updateInstallationToken(ctx, tx, installationId, token); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit installation token: %w", err)
+	}
+
+	return nil
+}
+
+// updateInstallationToken stores the encrypted token and its hash, if enabled.
+func (m *DBClient) updateInstallationToken(ctx context.Context, exec sqlx.ExecerContext, installationId string, token connector.InstallationToken) error {
+	stored, err := m.encryptToken(string(token))
 	if err != nil {
-		return nil, err
+		return err
 	}
-	instance.Configuration = config
 
-	thingMapping, err := m.GetMappingByInstanceId(ctx, instance.ID)
+	if m.tokenHashKey != nil {
+		_, err = exec.ExecContext(ctx, m.rebind(statementUpdateInstallationTokenWithHash), stored, m.hashToken(string(token)), installationId)
+	} else {
+		_, err = exec.ExecContext(ctx, m.rebind(statementUpdateInstallationToken), stored, installationId)
+	}
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("failed to update installation token: %w", err)
 	}
-	instance.ThingMapping = thingMapping
 
-	return &instance, nil
+	return nil
 }
 
-// GetInstances returns all instances.
-func (m *DBClient) GetInstances(ctx context.Context) ([]*connector.Instance, error) {
-	var instances []*connector.Instance
-	err := m.DB.Select(&instances, statementGetInstances)
+// GetInstallations returns a list of all existing installations together with their provided configuration parameters.
+func (m *DBClient) GetInstallations(ctx context.Context) ([]*connector.Installation, error) {
+	ctx, cancel := m.bulkReadContext(ctx)
+	defer cancel()
+
+	var installations []*connector.Installation
+	err := m.reader(ctx).SelectContext(ctx, &installations, m.rebind(m.statement(statementGetInstallations, statementSoftGetInstallations)))
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve instance: %w", err)
 	}
-	for i, instance := range instances {
-		config, err := m.GetInstanceConfiguration(ctx, instance.ID)
-		if err != nil {
+	for i, installation := range installations {
+		if err := m.decryptInstallationToken(installation); err != nil {
 			return nil, err
 		}
-		instances[i].Configuration = config
 
-		thingMapping, err := m.GetMappingByInstanceId(ctx, instance.ID)
+		if m.jsonConfig {
+			config, err := m.getJSONConfig(ctx, m.reader(ctx), installationJSONConfig, installation.ID)
+			if err != nil {
+				return nil, err
+			}
+			installations[i].Configuration = config
+			continue
+		}
+
+		var configurations []connector.Configuration
+		err := m.reader(ctx).SelectContext(ctx, &configurations, m.rebind(statementGetConfigurationByInstallationID), installation.ID)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("failed to retrieve instance: %w", err)
 		}
-		instance.ThingMapping = thingMapping
+		installations[i].Configuration = decodeConfiguration(configurations)
 	}
-	return instances, nil
+	return installations, nil
 }
 
-// GetInstanceByThingId returns the instance with the given thing id.
-func (m *DBClient) GetInstanceByThingId(ctx context.Context, thingId string) (*connector.Instance, error) {
-	var instance connector.Instance
-	err := m.DB.Get(&instance, statementGetInstanceByThingID, thingId)
-	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve instance: %w", err)
+// GetInstallationByToken returns the installation with the given token together with its configuration.
+// If a token hash key is set, the installation is looked up by the hash of the token.
+// It returns connector.ErrorInstallationNotFound if no installation uses the token.
+func (m *DBClient) GetInstallationByToken(ctx context.Context, token connector.InstallationToken) (*connector.Installation, error) {
+	ctx, cancel := m.readContext(ctx)
+	defer cancel()
+
+	query, arg := statementGetInstallationByToken, string(token)
+	if m.tokenHashKey != nil {
+		query, arg = statementGetInstallationByTokenHash, m.hashToken(string(token))
+	}
+	if m.softDelete {
+		query += " AND deleted_at IS NULL"
 	}
 
-	config, err := m.GetInstanceConfiguration(ctx, instance.ID)
-	if err != nil {
+	var installation connector.Installation
+	if err := m.reader(ctx).GetContext(ctx, &installation, m.rebind(query), arg); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, connector.ErrorInstallationNotFound
+		}
+		return nil, fmt.Errorf("failed to retrieve installation: %w", err)
+	}
+
+	if err := m.decryptInstallationToken(&installation); err != nil {
 		return nil, err
 	}
-	instance.Configuration = config
 
-	thingMapping, err := m.GetMappingByInstanceId(ctx, instance.ID)
+	configurations, err := m.GetInstallationConfiguration(ctx, installation.ID)
 	if err != nil {
 		return nil, err
 	}
-	instance.ThingMapping = thingMapping
+	installation.Configuration = configurations
 
-	return &instance, nil
+	return &installation, nil
 }
 
-// GetInstanceConfigurations returns all configuration parameters for the given instance id.
-// If no parameters where found it return an empty slice.
-func (m *DBClient) GetInstanceConfiguration(ctx context.Context, instanceId string) ([]connector.Configuration, error) {
-	var configurations []connector.Configuration
-	err := m.DB.Select(&configurations, statementGetConfigurationByInstanceID, instanceId)
-	if err != nil && err != sql.ErrNoRows {
-		return nil, fmt.Errorf("failed to retrieve instance configuration")
+// hashToken returns the hex encoded HMAC-SHA256 of the token.
+func (m *DBClient) hashToken(token string) string {
+	mac := hmac.New(sha256.New, m.tokenHashKey)
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// GetInstallationConfiguration returns all configuration parameters of the installation with the given id.
+// If no parameters were found it returns an empty slice.
+// It returns connector.ErrorInstallationNotFound if the installation does not exist.
+func (m *DBClient) GetInstallationConfiguration(ctx context.Context, installationId string) ([]connector.Configuration, error) {
+	ctx, cancel := m.readContext(ctx)
+	defer cancel()
+
+	if m.jsonConfig {
+		config, err := m.getJSONConfig(ctx, m.reader(ctx), installationJSONConfig, installationId)
+		if err != nil {
+			return nil, err
+		}
+		if config == nil {
+			config = []connector.Configuration{}
+		}
+		return config, nil
 	}
-	return configurations, nil
+
+	var count int
+	if err := m.reader(ctx).GetContext(ctx, &count, m.rebind(m.statement(statementCountInstallationsByID, statementSoftCountInstallationsByID)), installationId); err != nil {
+		return nil, fmt.Errorf("failed to retrieve installation: %w", err)
+	}
+	if count == 0 {
+		return nil, connector.ErrorInstallationNotFound
+	}
+
+	configurations := []connector.Configuration{}
+	if err := m.reader(ctx).SelectContext(ctx, &configurations, m.rebind(statementGetConfigurationByInstallationID), installationId); err != nil {
+		return nil, fmt.Errorf("failed to retrieve installation configuration: %w", err)
+	}
+	return decodeConfiguration(configurations), nil
 }
 
-// GetMappingByInstanceId returns all things mapped to the instance with the given id.
-func (m *DBClient) GetMappingByInstanceId(ctx context.Context, instanceId string) ([]connector.ThingMapping, error) {
-	var thingMappings []connector.ThingMapping
-	err := m.DB.Select(&thingMappings, statementGetThingsByInstanceID, instanceId)
-	if err != nil && err != sql.ErrNoRows {
-		return nil, fmt.Errorf("failed to retrieve thing ids %v", err)
+// GetInstancesInstallationConfiguration retrieves the configuration of the installation of an instance
+func (m *DBClient) GetInstancesInstallationConfiguration(ctx context.Context, instanceID string) ([]*connector.Configuration, error) {
+	ctx, cancel := m.readContext(ctx)
+	defer cancel()
+
+	if m.jsonConfig {
+		return m.getInstancesInstallationJSONConfig(ctx, instanceID)
+	}
+
+	var configurations []*connector.Configuration
+	if err := m.reader(ctx).SelectContext(ctx, &configurations, m.rebind(m.statement(statementGetInstallationConfigurationByInstanceID, statementSoftGetInstallationConfigurationByInstanceID)), instanceID); err != nil {
+		return nil, fmt.Errorf("failed to retrieve instances installation configuration: %w", err)
+	}
+	for _, c := range configurations {
+		c.Value = decompressValue(c.Value)
 	}
-	return thingMappings, nil
+
+	return configurations, nil
 }
 
-// RemoveInstance removes the instance with the given id from the database.
-func (m *DBClient) RemoveInstance(ctx context.Context, instanceId string) error {
-	_, err := m.DB.Exec(statementRemoveInstanceById, instanceId)
+// RemoveInstallation removes the instance with the given id from the database.
+// This will also remove instances belonging to this installation, as well as the configuration parameters.
+// Removal of config parameters and instances is implemented via cascading foreign keys in the database.
+// If your database does not support cascading foreign keys, you should delete them manually.
+// If soft delete is enabled, the installation and its instances are marked as deleted instead.
+// It returns connector.ErrorInstallationNotFound if the installation does not exist.
+func (m *DBClient) RemoveInstallation(ctx context.Context, installationId string) error {
+	ctx, cancel := m.writeContext(ctx)
+	defer cancel()
+
+	if m.softDelete {
+		return m.softRemoveInstallation(ctx, installationId)
+	}
+
+	result, err := m.DB.ExecContext(ctx, m.rebind(statementRemoveInstallationById), installationId)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return connector.ErrorInstanceNotFound
-		}
-		return fmt.Errorf("failed to remove instance")
+		return fmt.Errorf("failed to remove installation: %w", err)
 	}
 
-	return nil
+	return removedRows(result, connector.ErrorInstallationNotFound)
 }
 
-// AddThingMapping adds a mapping of the instance id to a thing and external id.
-func (m *DBClient) AddThingMapping(ctx context.Context, instanceId string, thingId string, externalId string) error {
-	_, err := m.DB.Exec(statementInsertThingId, instanceId, thingId, externalId)
+// removedRows returns the notFound error if the statement with the given result did not remove any row.
+func removedRows(result sql.Result, notFound error) error {
+	rows, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to insert thing id: %w", err)
+		return fmt.Errorf("failed to retrieve number of removed rows: %w", err)
+	}
+	if rows == 0 {
+		return notFound
 	}
-
 	return nil
 }
 
-// GetMappingByExternalId searches for a thing mapping with specific external id
+// AddInstance adds an instantiation to the database.
+// It returns connector.ErrorUnknownInstallation if the referenced installation does not exist.
+// The existence is checked explicitly, since not all databases enforce foreign keys.
+func (m *DBClient) AddInstance(ctx context.Context, instantiationRequest connector.InstantiationRequest) error {
+	ctx, cancel := m.writeContext(ctx)
+	defer cancel()
+
+	tx, err := m.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := m.insertInstance(ctx, tx, instantiationRequest); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit instance: %w", err)
+	}
+
+	return nil
+}
+
+// AddInstanceWithConfiguration adds the instance together with its configuration in a single transaction,
+// so either both are stored or nothing is. It returns errors like AddInstance and AddInstanceConfiguration.
+func (m *DBClient) AddInstanceWithConfiguration(ctx context.Context, instantiationRequest connector.InstantiationRequest) error {
+	ctx, cancel := m.writeContext(ctx)
+	defer cancel()
+
+	if err := verifyConfiguration(instantiationRequest.Configuration); err != nil {
+		return err
+	}
+
+	tx, err := m.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := m.insertInstance(ctx, tx, instantiationRequest); err != nil {
+		return err
+	}
+
+	if m.jsonConfig {
+		err = m.setJSONConfig(ctx, tx, instanceJSONConfig, instantiationRequest.ID, instantiationRequest.Configuration)
+	} else {
+		err = m.insertConfiguration(ctx, tx, statementInsertInstanceConfig, instantiationRequest.ID, instantiationRequest.Configuration)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit instance: %w", err)
+	}
+
+	return nil
+}
+
+// insertInstance inserts the instance without its configuration after checking that its installation exists.
+func (m *DBClient) insertInstance(ctx context.Context, tx *sqlx.Tx, instantiationRequest connector.InstantiationRequest) error {
+	var installations int
+	if err := tx.GetContext(ctx, &installations, m.rebind(m.statement(statementCountInstallationsByID, statementSoftCountInstallationsByID)), instantiationRequest.InstallationID); err != nil {
+		return fmt.Errorf("failed to retrieve installation: %w", err)
+	}
+
+	if installations == 0 {
+		return connector.ErrorUnknownInstallation
+	}
+
+	token, err := m.encryptToken(string(instantiationRequest.Token))
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, m.rebind(statementInsertInstance), instantiationRequest.ID, instantiationRequest.InstallationID, token); err != nil {
+		return fmt.Errorf("failed to insert instance: %w", err)
+	}
+
+	return nil
+}
+
+// AddInstanceConfiguration adds all configuration parameters to the database.
+// It rejects the whole configuration if any of the values is not valid UTF-8.
+func (m *DBClient) AddInstanceConfiguration(ctx context.Context, instanceId string, config []connector.Configuration) error {
+	ctx, cancel := m.writeContext(ctx)
+	defer cancel()
+
+	if err := verifyConfiguration(config); err != nil {
+		return err
+	}
+
+	if m.jsonConfig {
+		return m.appendJSONConfig(ctx, instanceJSONConfig, instanceId, config)
+	}
+
+	return m.insertConfiguration(ctx, m.DB, statementInsertInstanceConfig, instanceId, config)
+}
+
+// UpdateInstanceToken replaces the token of an existing instance, e.g. after the platform rotated it.
+// It returns connector.ErrorInstanceNotFound if the instance does not exist.
+func (m *DBClient) UpdateInstanceToken(ctx context.Context, instanceId string, token connector.InstantiationToken) error {
+	ctx, cancel := m.writeContext(ctx)
+	defer cancel()
+
+	stored, err := m.encryptToken(string(token))
+	if err != nil {
+		return err
+	}
+
+	tx, err := m.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var instances int
+	if err := tx.GetContext(ctx, &instances, m.rebind(m.statement(statementCountInstancesByID, statementSoftCountInstancesByID)), instanceId); err != nil {
+		return fmt.Errorf("failed to retrieve instance: %w", err)
+	}
+	if instances == 0 {
+		return connector.ErrorInstanceNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, m.rebind(statementUpdateInstanceToken), stored, instanceId); err != nil {
+		return fmt.Errorf("failed to update instance token: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit instance token: %w", err)
+	}
+
+	return nil
+}
+
+// SetInstanceConfigurationValue sets a single configuration parameter of an instance.
+// Existing parameters are updated, missing ones are added. All other parameters are left untouched.
+func (m *DBClient) SetInstanceConfigurationValue(ctx context.Context, instanceId string, key string, value string) error {
+	ctx, cancel := m.writeContext(ctx)
+	defer cancel()
+
+	if err := connctd.VerifyString(value); err != nil {
+		return fmt.Errorf("invalid value for configuration parameter %s: %w", key, err)
+	}
+
+	if m.jsonConfig {
+		return m.setJSONConfigValue(ctx, instanceId, key, value)
+	}
+
+	value, err := m.encodeConfigValue(value)
+	if err != nil {
+		return err
+	}
+
+	err = m.setInstanceConfigValue(ctx, instanceId, key, value)
+	if IsUniqueViolation(err) {
+		// a concurrent call inserted the parameter in the meantime, so it can be updated now
+		err = m.setInstanceConfigValue(ctx, instanceId, key, value)
+	}
+	return err
+}
+
+// setInstanceConfigValue updates the configuration parameter or inserts it if it does not exist yet.
+// It returns a unique violation if the parameter was inserted concurrently.
+func (m *DBClient) setInstanceConfigValue(ctx context.Context, instanceId string, key string, value string) error {
+	tx, err := m.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, m.rebind(statementUpdateInstanceConfigValue), value, instanceId, key)
+	if err != nil {
+		return fmt.Errorf("failed to update instance config: %w", err)
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to update instance config: %w", err)
+	}
+
+	if updated == 0 {
+		if _, err := tx.ExecContext(ctx, m.rebind(statementInsertInstanceConfig), instanceId, key, value); err != nil {
+			return fmt.Errorf("failed to insert instance config: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit instance config: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteInstanceConfigurationValue removes a single configuration parameter of an instance.
+// It does not return an error if the parameter does not exist.
+func (m *DBClient) DeleteInstanceConfigurationValue(ctx context.Context, instanceId string, key string) error {
+	ctx, cancel := m.writeContext(ctx)
+	defer cancel()
+
+	if m.jsonConfig {
+		return m.deleteJSONConfigValue(ctx, instanceId, key)
+	}
+
+	_, err := m.DB.ExecContext(ctx, m.rebind(statementRemoveInstanceConfigValue), instanceId, key)
+	if err != nil {
+		return fmt.Errorf("failed to remove instance config: %w", err)
+	}
+
+	return nil
+}
+
+// GetInstance returns the instance with the given id.
+func (m *DBClient) GetInstance(ctx context.Context, instanceId string) (*connector.Instance, error) {
+	ctx, cancel := m.readContext(ctx)
+	defer cancel()
+
+	var instance connector.Instance
+	err := m.reader(ctx).GetContext(ctx, &instance, m.rebind(m.statement(statementGetInstanceByID, statementSoftGetInstanceByID)), instanceId)
+	if err == sql.ErrNoRows {
+		return nil, connector.ErrorInstanceNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve instance: %w", err)
+	}
+
+	if err := m.decryptInstanceToken(&instance); err != nil {
+		return nil, err
+	}
+
+	config, err := m.GetInstanceConfiguration(ctx, instance.ID)
+	if err != nil {
+		return nil, err
+	}
+	instance.Configuration = config
+
+	thingMapping, err := m.GetMappingByInstanceId(ctx, instance.ID)
+	if err != nil {
+		return nil, err
+	}
+	instance.ThingMapping = thingMapping
+
+	return &instance, nil
+}
+
+// GetInstances returns all instances.
+func (m *DBClient) GetInstances(ctx context.Context) ([]*connector.Instance, error) {
+	ctx, cancel := m.bulkReadContext(ctx)
+	defer cancel()
+
+	var instances []*connector.Instance
+	err := m.reader(ctx).SelectContext(ctx, &instances, m.rebind(m.statement(statementGetInstances, statementSoftGetInstances)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve instance: %w", err)
+	}
+	for i, instance := range instances {
+		if err := m.decryptInstanceToken(instance); err != nil {
+			return nil, err
+		}
+
+		config, err := m.GetInstanceConfiguration(ctx, instance.ID)
+		if err != nil {
+			return nil, err
+		}
+		instances[i].Configuration = config
+
+		thingMapping, err := m.GetMappingByInstanceId(ctx, instance.ID)
+		if err != nil {
+			return nil, err
+		}
+		instance.ThingMapping = thingMapping
+	}
+	return instances, nil
+}
+
+// GetInstanceByThingId returns the instance with the given thing id.
+func (m *DBClient) GetInstanceByThingId(ctx context.Context, thingId string) (*connector.Instance, error) {
+	ctx, cancel := m.readContext(ctx)
+	defer cancel()
+
+	var instance connector.Instance
+	err := m.reader(ctx).GetContext(ctx, &instance, m.rebind(m.statement(statementGetInstanceByThingID, statementSoftGetInstanceByThingID)), thingId)
+	if err == sql.ErrNoRows {
+		return nil, connector.ErrorInstanceNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve instance: %w", err)
+	}
+
+	if err := m.decryptInstanceToken(&instance); err != nil {
+		return nil, err
+	}
+
+	config, err := m.GetInstanceConfiguration(ctx, instance.ID)
+	if err != nil {
+		return nil, err
+	}
+	instance.Configuration = config
+
+	thingMapping, err := m.GetMappingByInstanceId(ctx, instance.ID)
+	if err != nil {
+		return nil, err
+	}
+	instance.ThingMapping = thingMapping
+
+	return &instance, nil
+}
+
+// GetInstancesByThingIds returns the instances of the given things with a single query.
+// The resulting map is keyed by thing id. Things without an instance are not contained.
+func (m *DBClient) GetInstancesByThingIds(ctx context.Context, thingIds []string) (map[string]*connector.Instance, error) {
+	ctx, cancel := m.bulkReadContext(ctx)
+	defer cancel()
+
+	result := make(map[string]*connector.Instance)
+	if len(thingIds) == 0 {
+		return result, nil
+	}
+
+	query, args, err := sqlx.In(m.statement(statementGetInstancesByThingIDs, statementSoftGetInstancesByThingIDs), thingIds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build instance query: %w", err)
+	}
+
+	var rows []struct {
+		ThingID string `db:"thing_id"`
+		connector.Instance
+	}
+	if err := m.reader(ctx).SelectContext(ctx, &rows, m.rebind(query), args...); err != nil {
+		return nil, fmt.Errorf("failed to retrieve instances: %w", err)
+	}
+
+	// several things can belong to the same instance, so each instance is only created once
+	instances := make(map[string]*connector.Instance)
+	var instanceIds []string
+	for _, row := range rows {
+		instance, ok := instances[row.ID]
+		if !ok {
+			instance = &connector.Instance{ID: row.ID, InstallationID: row.InstallationID, Token: row.Token}
+			if err := m.decryptInstanceToken(instance); err != nil {
+				return nil, err
+			}
+			instances[row.ID] = instance
+			instanceIds = append(instanceIds, row.ID)
+		}
+		result[row.ThingID] = instance
+	}
+
+	// the configurations and mappings of all instances are retrieved with one query each
+	configurations, err := m.GetInstanceConfigurations(ctx, instanceIds)
+	if err != nil {
+		return nil, err
+	}
+	thingMappings, err := m.getMappingsByInstanceIds(ctx, instanceIds)
+	if err != nil {
+		return nil, err
+	}
+	for id, instance := range instances {
+		instance.Configuration = configurations[id]
+		instance.ThingMapping = thingMappings[id]
+	}
+
+	return result, nil
+}
+
+// getMappingsByInstanceIds returns the thing mappings of the given instances with a single query, keyed by instance id.
+func (m *DBClient) getMappingsByInstanceIds(ctx context.Context, instanceIds []string) (map[string][]connector.ThingMapping, error) {
+	result := make(map[string][]connector.ThingMapping, len(instanceIds))
+	if len(instanceIds) == 0 {
+		return result, nil
+	}
+
+	query, args, err := sqlx.In(m.statement(statementGetThingsByInstanceIDs, statementSoftGetThingsByInstanceIDs), instanceIds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build thing mapping query: %w", err)
+	}
+
+	var thingMappings []connector.ThingMapping
+	if err := m.reader(ctx).SelectContext(ctx, &thingMappings, m.rebind(query), args...); err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to retrieve thing ids: %w", err)
+	}
+
+	for _, mapping := range normalizedMappings(thingMappings) {
+		result[mapping.InstanceID] = append(result[mapping.InstanceID], mapping)
+	}
+	return result, nil
+}
+
+// GetInstanceConfigurations returns all configuration parameters for the given instance id.
+// If no parameters where found it return an empty slice.
+func (m *DBClient) GetInstanceConfiguration(ctx context.Context, instanceId string) ([]connector.Configuration, error) {
+	ctx, cancel := m.readContext(ctx)
+	defer cancel()
+
+	if m.jsonConfig {
+		config, err := m.getJSONConfig(ctx, m.reader(ctx), instanceJSONConfig, instanceId)
+		if err == connector.ErrorInstanceNotFound {
+			return nil, nil
+		}
+		return config, err
+	}
+
+	var configurations []connector.Configuration
+	err := m.reader(ctx).SelectContext(ctx, &configurations, m.rebind(m.statement(statementGetConfigurationByInstanceID, statementSoftGetConfigurationByInstanceID)), instanceId)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to retrieve instance configuration: %w", err)
+	}
+	return decodeConfiguration(configurations), nil
+}
+
+// GetInstanceConfigurations returns the configuration parameters of the given instances with a single query.
+// The resulting map is keyed by instance id and contains all given ids.
+// Instances without configuration are mapped to an empty slice.
+func (m *DBClient) GetInstanceConfigurations(ctx context.Context, instanceIds []string) (map[string][]connector.Configuration, error) {
+	ctx, cancel := m.bulkReadContext(ctx)
+	defer cancel()
+
+	result := make(map[string][]connector.Configuration, len(instanceIds))
+	if len(instanceIds) == 0 {
+		return result, nil
+	}
+	for _, id := range instanceIds {
+		result[id] = []connector.Configuration{}
+	}
+
+	if m.jsonConfig {
+		return m.getJSONConfigs(ctx, result, instanceIds)
+	}
+
+	query, args, err := sqlx.In(m.statement(statementGetConfigurationByInstanceIDs, statementSoftGetConfigurationByInstanceIDs), instanceIds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build instance configuration query: %w", err)
+	}
+
+	var rows []struct {
+		InstanceID string `db:"instance_id"`
+		connector.Configuration
+	}
+	if err := m.reader(ctx).SelectContext(ctx, &rows, m.rebind(query), args...); err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to retrieve instance configurations: %w", err)
+	}
+
+	for _, row := range rows {
+		row.Value = decompressValue(row.Value)
+		result[row.InstanceID] = append(result[row.InstanceID], row.Configuration)
+	}
+	return result, nil
+}
+
+// GetMappingByInstanceId returns all things mapped to the instance with the given id.
+func (m *DBClient) GetMappingByInstanceId(ctx context.Context, instanceId string) ([]connector.ThingMapping, error) {
+	ctx, cancel := m.readContext(ctx)
+	defer cancel()
+
+	var thingMappings []connector.ThingMapping
+	err := m.reader(ctx).SelectContext(ctx, &thingMappings, m.rebind(m.statement(statementGetThingsByInstanceID, statementSoftGetThingsByInstanceID)), instanceId)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to retrieve thing ids: %w", err)
+	}
+	return normalizedMappings(thingMappings), nil
+}
+
+// normalizedMappings normalizes the external ids of the mappings in place, so mappings stored
+// with raw external ids match the normalized ids of thing templates and lookups.
+func normalizedMappings(thingMappings []connector.ThingMapping) []connector.ThingMapping {
+	for i := range thingMappings {
+		thingMappings[i].ExternalID = connector.NormalizeExternalID(thingMappings[i].ExternalID)
+	}
+	return thingMappings
+}
+
+// RemoveInstance removes the instance with the given id from the database.
+// If soft delete is enabled, the instance is marked as deleted instead.
+// Otherwise its configuration, secrets, thing mappings, property values and pending states are removed in the same transaction.
+// Tables missing in databases created by older versions of Migrate are skipped.
+// It returns connector.ErrorInstanceNotFound if the instance does not exist.
+func (m *DBClient) RemoveInstance(ctx context.Context, instanceId string) error {
+	ctx, cancel := m.writeContext(ctx)
+	defer cancel()
+
+	if m.softDelete {
+		result, err := m.DB.ExecContext(ctx, m.rebind(statementSoftRemoveInstanceById), time.Now().UnixMilli(), instanceId)
+		if err != nil {
+			return fmt.Errorf("failed to remove instance: %w", err)
+		}
+		return removedRows(result, connector.ErrorInstanceNotFound)
+	}
+
+	tx, err := m.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := m.removeInstanceData(ctx, tx, instanceId); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, m.rebind(statementRemoveInstanceById), instanceId)
+	if err != nil {
+		return fmt.Errorf("failed to remove instance: %w", err)
+	}
+	if err := removedRows(result, connector.ErrorInstanceNotFound); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit instance removal: %w", err)
+	}
+
+	return nil
+}
+
+// optionalInstanceTables are the tables referencing instances which were added after the initial layout.
+// Databases created by older versions of Migrate lack them, so their rows are only removed if the table exists.
+var optionalInstanceTables = []struct {
+	table  string
+	remove string
+}{
+	{"property_values", statementRemovePropertyValuesByInstance},
+	{"pending_instance_states", statementRemovePendingStateByInstance},
+	{"instance_secrets", statementRemoveSecretsByInstance},
+}
+
+// statementsGetExistingTables return which of the given tables exist, listing tables is not portable
+var statementsGetExistingTables = map[DBDriverName]string{
+	DriverMysql:      `SELECT table_name FROM information_schema.tables WHERE table_name IN (?) AND table_schema = DATABASE()`,
+	DriverPostgresql: `SELECT table_name FROM information_schema.tables WHERE table_name IN (?) AND table_schema = current_schema()`,
+	DriverSqlite3:    `SELECT name FROM sqlite_master WHERE type = 'table' AND name IN (?)`,
+	DriverSqlserver:  `SELECT table_name FROM information_schema.tables WHERE table_name IN (?) AND table_schema = SCHEMA_NAME()`,
+}
+
+// statementGetExistingTables is used for other drivers
+var statementGetExistingTables = `SELECT table_name FROM information_schema.tables WHERE table_name IN (?)`
+
+// removeInstanceData removes all rows referencing the instance within the transaction.
+func (m *DBClient) removeInstanceData(ctx context.Context, tx *sqlx.Tx, instanceId string) error {
+	statements := []string{statementRemoveInstanceConfig, statementRemoveThingMappingsByInstance}
+
+	tables, err := m.existingTables(ctx, tx)
+	if err != nil {
+		return err
+	}
+	for _, optional := range optionalInstanceTables {
+		if tables[optional.table] {
+			statements = append(statements, optional.remove)
+		}
+	}
+
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, m.rebind(statement), instanceId); err != nil {
+			return fmt.Errorf("failed to remove instance data: %w", err)
+		}
+	}
+	return nil
+}
+
+// existingTables returns which of the optional instance tables exist.
+func (m *DBClient) existingTables(ctx context.Context, tx *sqlx.Tx) (map[string]bool, error) {
+	statement, ok := statementsGetExistingTables[m.driver]
+	if !ok {
+		statement = statementGetExistingTables
+	}
+
+	names := make([]string, len(optionalInstanceTables))
+	for i, optional := range optionalInstanceTables {
+		names[i] = optional.table
+	}
+	query, args, err := sqlx.In(statement, names)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build table query: %w", err)
+	}
+
+	var existing []string
+	if err := tx.SelectContext(ctx, &existing, m.rebind(query), args...); err != nil {
+		return nil, fmt.Errorf("failed to retrieve existing tables: %w", err)
+	}
+
+	tables := make(map[string]bool, len(existing))
+	for _, table := range existing {
+		tables[strings.ToLower(table)] = true
+	}
+	return tables, nil
+}
+
+// AddThingMapping adds a mapping of the instance id to a thing and external id.
+// The external id is stored in its normalized form, see connector.NormalizeExternalID.
+// It returns connector.ErrorMappingExists if the thing is already mapped to the instance.
+func (m *DBClient) AddThingMapping(ctx context.Context, instanceId string, thingId string, externalId string) error {
+	ctx, cancel := m.writeContext(ctx)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, m.rebind(statementInsertThingId), instanceId, thingId, connector.NormalizeExternalID(externalId))
+	if err != nil {
+		if IsUniqueViolation(err) {
+			return connector.ErrorMappingExists
+		}
+		return fmt.Errorf("failed to insert thing id: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateThingMappingExternalId replaces the external id of an existing thing mapping, e.g. after the external device was re-provisioned.
+// The external id is normalized like in AddThingMapping. It returns connector.ErrorMappingNotFound if the thing is not mapped
+// to the instance and connector.ErrorMappingExists if another thing of the instance is already mapped to the external id.
+func (m *DBClient) UpdateThingMappingExternalId(ctx context.Context, instanceId string, thingId string, newExternalId string) error {
+	ctx, cancel := m.writeContext(ctx)
+	defer cancel()
+
+	if err := connector.ValidateExternalID(newExternalId); err != nil {
+		return err
+	}
+	externalId := connector.NormalizeExternalID(newExternalId)
+
+	tx, err := m.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var mappings int
+	if err := tx.GetContext(ctx, &mappings, m.rebind(statementCountThingMappingsByThingID), instanceId, thingId); err != nil {
+		return fmt.Errorf("failed to retrieve mapping: %w", err)
+	}
+	if mappings == 0 {
+		return connector.ErrorMappingNotFound
+	}
+
+	// external ids are unique per instance, since they are used to look up the things
+	var others int
+	if err := tx.GetContext(ctx, &others, m.rebind(statementCountOtherThingMappingsByExtID), instanceId, externalId, thingId); err != nil {
+		return fmt.Errorf("failed to retrieve mapping: %w", err)
+	}
+	if others > 0 {
+		return connector.ErrorMappingExists
+	}
+
+	if _, err := tx.ExecContext(ctx, m.rebind(statementUpdateThingMappingExternalID), externalId, instanceId, thingId); err != nil {
+		if IsUniqueViolation(err) {
+			return connector.ErrorMappingExists
+		}
+		return fmt.Errorf("failed to update mapping: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit mapping: %w", err)
+	}
+
+	return nil
+}
+
+// GetMappingByExternalId searches for a thing mapping with specific external id
+// The external id is normalized like in AddThingMapping, stored external ids are normalized before they are compared.
 func (m *DBClient) GetMappingByExternalId(ctx context.Context, instanceId string, externalID string) (*connector.ThingMapping, error) {
+	ctx, cancel := m.readContext(ctx)
+	defer cancel()
+
 	var thingMapping connector.ThingMapping
-	err := m.DB.Get(&thingMapping, statementGetThingsByExternalID, instanceId, externalID)
+	err := m.reader(ctx).GetContext(ctx, &thingMapping, m.rebind(m.statement(statementGetThingsByExternalID, statementSoftGetThingsByExternalID)), instanceId, connector.NormalizeExternalID(externalID))
 	if err != nil && err != sql.ErrNoRows {
-		return nil, fmt.Errorf("failed to retrieve thing by external id %v", err)
+		return nil, fmt.Errorf("failed to retrieve thing by external id: %w", err)
 	}
+	thingMapping.ExternalID = connector.NormalizeExternalID(thingMapping.ExternalID)
 	return &thingMapping, nil
 }
 
 // RemoveThingMapping removes a thing mapping with given instance and thing id
 func (m *DBClient) RemoveThingMapping(ctx context.Context, instanceID string, thingID string) error {
-	_, err := m.DB.Exec(statementRemoveThingMapping, instanceID, thingID)
+	ctx, cancel := m.writeContext(ctx)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, m.rebind(statementRemoveThingMapping), instanceID, thingID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return connector.ErrorMappingNotFound
@@ -371,3 +1481,227 @@ func (m *DBClient) RemoveThingMapping(ctx context.Context, instanceID string, th
 
 	return nil
 }
+
+// CountInstallations returns the number of stored installations.
+// It is considerably cheaper than loading all installations and can be used to publish capacity metrics.
+func (m *DBClient) CountInstallations(ctx context.Context) (int, error) {
+	ctx, cancel := m.readContext(ctx)
+	defer cancel()
+
+	var count int
+	if err := m.reader(ctx).GetContext(ctx, &count, m.rebind(m.statement(statementCountInstallations, statementSoftCountInstallations))); err != nil {
+		return 0, fmt.Errorf("failed to count installations: %w", err)
+	}
+	return count, nil
+}
+
+// CountInstances returns the number of stored instances.
+func (m *DBClient) CountInstances(ctx context.Context) (int, error) {
+	ctx, cancel := m.readContext(ctx)
+	defer cancel()
+
+	var count int
+	if err := m.reader(ctx).GetContext(ctx, &count, m.rebind(m.statement(statementCountInstances, statementSoftCountInstances))); err != nil {
+		return 0, fmt.Errorf("failed to count instances: %w", err)
+	}
+	return count, nil
+}
+
+// CountThingMappings returns the number of stored thing mappings which equals the number of things managed by the connector.
+func (m *DBClient) CountThingMappings(ctx context.Context) (int, error) {
+	ctx, cancel := m.readContext(ctx)
+	defer cancel()
+
+	var count int
+	if err := m.reader(ctx).GetContext(ctx, &count, m.rebind(m.statement(statementCountThingMappings, statementSoftCountThingMappings))); err != nil {
+		return 0, fmt.Errorf("failed to count thing mappings: %w", err)
+	}
+	return count, nil
+}
+
+// SetLastPropertyValue stores the value as the last known value of the property.
+// Previously stored values of the property are replaced.
+func (m *DBClient) SetLastPropertyValue(ctx context.Context, value connector.PropertyValue) error {
+	ctx, cancel := m.writeContext(ctx)
+	defer cancel()
+
+	tx, err := m.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	lastUpdate := value.LastUpdate.UnixMilli()
+	result, err := tx.ExecContext(ctx, m.rebind(statementUpdatePropertyValue), value.Value, lastUpdate, value.InstanceID, value.ThingID, value.ComponentID, value.PropertyID)
+	if err != nil {
+		return fmt.Errorf("failed to update property value: %w", err)
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to update property value: %w", err)
+	}
+
+	if updated == 0 {
+		if _, err := tx.ExecContext(ctx, m.rebind(statementInsertPropertyValue), value.InstanceID, value.ThingID, value.ComponentID, value.PropertyID, value.Value, lastUpdate); err != nil {
+			return fmt.Errorf("failed to insert property value: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit property value: %w", err)
+	}
+
+	return nil
+}
+
+// propertyValueRow is the database representation of a connector.PropertyValue.
+// The time of the last update is stored as unix milliseconds to avoid driver specific time handling.
+type propertyValueRow struct {
+	InstanceID  string `db:"instance_id"`
+	ThingID     string `db:"thing_id"`
+	ComponentID string `db:"component_id"`
+	PropertyID  string `db:"property_id"`
+	Value       string `db:"value"`
+	LastUpdate  int64  `db:"last_update"`
+}
+
+// GetLastPropertyValues returns the last known values of all properties of the given thing.
+// If no values were stored it returns an empty slice.
+func (m *DBClient) GetLastPropertyValues(ctx context.Context, thingId string) ([]connector.PropertyValue, error) {
+	ctx, cancel := m.readContext(ctx)
+	defer cancel()
+
+	var rows []propertyValueRow
+	err := m.reader(ctx).SelectContext(ctx, &rows, m.rebind(m.statement(statementGetPropertyValuesByThingID, statementSoftGetPropertyValuesByThingID)), thingId)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to retrieve property values: %w", err)
+	}
+
+	values := make([]connector.PropertyValue, 0, len(rows))
+	for _, row := range rows {
+		values = append(values, connector.PropertyValue{
+			InstanceID:  row.InstanceID,
+			ThingID:     row.ThingID,
+			ComponentID: row.ComponentID,
+			PropertyID:  row.PropertyID,
+			Value:       row.Value,
+			LastUpdate:  time.UnixMilli(row.LastUpdate),
+		})
+	}
+	return values, nil
+}
+
+// verifyConfiguration checks that all configuration values are valid UTF-8.
+func verifyConfiguration(config []connector.Configuration) error {
+	for _, c := range config {
+		if err := connctd.VerifyString(c.Value); err != nil {
+			return fmt.Errorf("invalid value for configuration parameter %s: %w", c.ID, err)
+		}
+	}
+	return nil
+}
+
+// SetPendingInstanceState stores a state that still has to be reported for the instance.
+// A previously stored state of the instance is replaced.
+func (m *DBClient) SetPendingInstanceState(ctx context.Context, state connector.PendingInstanceState) error {
+	ctx, cancel := m.writeContext(ctx)
+	defer cancel()
+
+	tx, err := m.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, m.rebind(statementUpdatePendingInstanceState), state.State, string(state.Details), state.InstanceID)
+	if err != nil {
+		return fmt.Errorf("failed to update pending instance state: %w", err)
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to update pending instance state: %w", err)
+	}
+
+	if updated == 0 {
+		if _, err := tx.ExecContext(ctx, m.rebind(statementInsertPendingInstanceState), state.InstanceID, state.State, string(state.Details)); err != nil {
+			return fmt.Errorf("failed to insert pending instance state: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit pending instance state: %w", err)
+	}
+
+	return nil
+}
+
+// pendingInstanceStateRow is the database representation of a connector.PendingInstanceState.
+type pendingInstanceStateRow struct {
+	InstanceID string `db:"instance_id"`
+	State      int    `db:"state"`
+	Details    string `db:"details"`
+}
+
+func (r pendingInstanceStateRow) pendingInstanceState() connector.PendingInstanceState {
+	state := connector.PendingInstanceState{
+		InstanceID: r.InstanceID,
+		State:      connector.InstantiationState(r.State),
+	}
+	if r.Details != "" {
+		state.Details = json.RawMessage(r.Details)
+	}
+	return state
+}
+
+// GetPendingInstanceStates returns the pending states of all instances.
+// If no states are pending it returns an empty slice.
+// If soft delete is enabled, states of removed instances are not returned.
+func (m *DBClient) GetPendingInstanceStates(ctx context.Context) ([]connector.PendingInstanceState, error) {
+	ctx, cancel := m.bulkReadContext(ctx)
+	defer cancel()
+
+	var rows []pendingInstanceStateRow
+	err := m.DB.SelectContext(ctx, &rows, m.rebind(m.statement(statementGetPendingInstanceStates, statementSoftGetPendingStates)))
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to retrieve pending instance states: %w", err)
+	}
+
+	states := make([]connector.PendingInstanceState, 0, len(rows))
+	for _, row := range rows {
+		states = append(states, row.pendingInstanceState())
+	}
+	return states, nil
+}
+
+// GetPendingInstanceState returns the pending state of the given instance or nil if no state is pending.
+// If soft delete is enabled, states of removed instances are not returned.
+func (m *DBClient) GetPendingInstanceState(ctx context.Context, instanceId string) (*connector.PendingInstanceState, error) {
+	ctx, cancel := m.readContext(ctx)
+	defer cancel()
+
+	var row pendingInstanceStateRow
+	err := m.DB.GetContext(ctx, &row, m.rebind(m.statement(statementGetPendingInstanceState, statementSoftGetPendingState)), instanceId)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve pending instance state: %w", err)
+	}
+
+	state := row.pendingInstanceState()
+	return &state, nil
+}
+
+// RemovePendingInstanceState removes the pending state of the instance once it was reported.
+// The stored state is only removed if it was not replaced in the meantime.
+func (m *DBClient) RemovePendingInstanceState(ctx context.Context, state connector.PendingInstanceState) error {
+	ctx, cancel := m.writeContext(ctx)
+	defer cancel()
+
+	if _, err := m.DB.ExecContext(ctx, m.rebind(statementRemovePendingInstanceState), state.InstanceID, state.State, string(state.Details)); err != nil {
+		return fmt.Errorf("failed to remove pending instance state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,579 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/connctd/connector-go"
+	"github.com/go-logr/logr"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestClient returns a client with the given options, backed by an in-memory sqlite database migrated with Migrate.
+// The driver is always sqlite and the DSN defaults to an in-memory database with foreign keys enabled.
+func newTestClient(t testing.TB, opts DBOptions) *DBClient {
+	client := openTestClient(t, opts)
+	require.NoError(t, client.Migrate())
+	return client
+}
+
+// openTestClient returns a client like newTestClient, but without migrating the database.
+// The pool is limited to a single connection since every sqlite memory connection opens its own database.
+func openTestClient(t testing.TB, opts DBOptions) *DBClient {
+	opts.Driver = DriverSqlite3
+	if opts.DSN == "" {
+		opts.DSN = "file::memory:?_foreign_keys=on"
+	}
+
+	client, err := NewDBClient(&opts, logr.Discard())
+	require.NoError(t, err)
+	client.DB.SetMaxOpenConns(1)
+	t.Cleanup(func() { client.DB.Close() })
+
+	return client
+}
+
+func TestCount(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t, DBOptions{})
+
+	require.NoError(t, client.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token"}))
+	require.NoError(t, client.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-2", Token: "token"}))
+	require.NoError(t, client.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "token"}))
+	require.NoError(t, client.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-2", InstallationID: "installation-1", Token: "token"}))
+	require.NoError(t, client.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-3", InstallationID: "installation-2", Token: "token"}))
+	require.NoError(t, client.AddThingMapping(ctx, "instance-1", "thing-1", "external-1"))
+
+	installations, err := client.CountInstallations(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, installations)
+
+	instances, err := client.CountInstances(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, instances)
+
+	mappings, err := client.CountThingMappings(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, mappings)
+}
+
+func TestAddConfigurationRejectsInvalidUTF8(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t, DBOptions{})
+
+	require.NoError(t, client.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token"}))
+	require.NoError(t, client.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "token"}))
+
+	config := []connector.Configuration{
+		{ID: "valid", Value: "foo"},
+		{ID: "invalid", Value: string([]byte{0xff, 0xfe})},
+	}
+
+	assert.Error(t, client.AddInstallationConfiguration(ctx, "installation-1", config))
+	assert.Error(t, client.AddInstanceConfiguration(ctx, "instance-1", config))
+
+	installations, err := client.GetInstallations(ctx)
+	require.NoError(t, err)
+	require.Len(t, installations, 1)
+	assert.Empty(t, installations[0].Configuration)
+
+	instanceConfig, err := client.GetInstanceConfiguration(ctx, "instance-1")
+	require.NoError(t, err)
+	assert.Empty(t, instanceConfig)
+}
+
+func TestAddManyConfigurationParameters(t *testing.T) {
+	ctx := context.Background()
+
+	// more parameters than fit into a single insert statement
+	config := make([]connector.Configuration, 2*configInsertBatchSize+1)
+	for i := range config {
+		config[i] = connector.Configuration{ID: fmt.Sprintf("key-%d", i), Value: fmt.Sprintf("value-%d", i)}
+	}
+
+	for name, bindType := range map[string]int{"sqlite": sqlx.QUESTION, "postgres": sqlx.DOLLAR, "sqlserver": sqlx.AT} {
+		t.Run(name, func(t *testing.T) {
+			client := newRebindingTestClient(t, bindType)
+			require.NoError(t, client.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token"}))
+			require.NoError(t, client.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "token"}))
+
+			require.NoError(t, client.AddInstallationConfiguration(ctx, "installation-1", config))
+			require.NoError(t, client.AddInstanceConfiguration(ctx, "instance-1", config[:1]))
+
+			installationConfig, err := client.GetInstallationConfiguration(ctx, "installation-1")
+			require.NoError(t, err)
+			assert.ElementsMatch(t, config, installationConfig)
+
+			instanceConfig, err := client.GetInstanceConfiguration(ctx, "instance-1")
+			require.NoError(t, err)
+			assert.Equal(t, config[:1], instanceConfig)
+		})
+	}
+}
+
+// BenchmarkAddInstanceConfiguration compares the multi row insert of 100 parameters with inserting them one by one.
+func BenchmarkAddInstanceConfiguration(b *testing.B) {
+	ctx := context.Background()
+	config := make([]connector.Configuration, 100)
+	for i := range config {
+		config[i] = connector.Configuration{ID: fmt.Sprintf("key-%d", i), Value: fmt.Sprintf("value-%d", i)}
+	}
+
+	benchmarks := map[string]func(client *DBClient, instanceId string) error{
+		"multi row insert": func(client *DBClient, instanceId string) error {
+			return client.AddInstanceConfiguration(ctx, instanceId, config)
+		},
+		"insert per row": func(client *DBClient, instanceId string) error {
+			for i := range config {
+				if err := client.AddInstanceConfiguration(ctx, instanceId, config[i:i+1]); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+
+	for name, insert := range benchmarks {
+		b.Run(name, func(b *testing.B) {
+			client := newTestClient(b, DBOptions{})
+			require.NoError(b, client.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token"}))
+			require.NoError(b, client.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "token"}))
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := insert(client, "instance-1"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestSetAndDeleteInstanceConfigurationValue(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t, DBOptions{})
+
+	require.NoError(t, client.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token"}))
+	require.NoError(t, client.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "token"}))
+	require.NoError(t, client.AddInstanceConfiguration(ctx, "instance-1", []connector.Configuration{{ID: "foo", Value: "1"}, {ID: "bar", Value: "2"}}))
+
+	// set new key
+	require.NoError(t, client.SetInstanceConfigurationValue(ctx, "instance-1", "baz", "3"))
+	config, err := client.GetInstanceConfiguration(ctx, "instance-1")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []connector.Configuration{{ID: "foo", Value: "1"}, {ID: "bar", Value: "2"}, {ID: "baz", Value: "3"}}, config)
+
+	// update existing key
+	require.NoError(t, client.SetInstanceConfigurationValue(ctx, "instance-1", "foo", "4"))
+	config, err = client.GetInstanceConfiguration(ctx, "instance-1")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []connector.Configuration{{ID: "foo", Value: "4"}, {ID: "bar", Value: "2"}, {ID: "baz", Value: "3"}}, config)
+
+	// delete key
+	require.NoError(t, client.DeleteInstanceConfigurationValue(ctx, "instance-1", "bar"))
+	config, err = client.GetInstanceConfiguration(ctx, "instance-1")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []connector.Configuration{{ID: "foo", Value: "4"}, {ID: "baz", Value: "3"}}, config)
+}
+
+func TestSetInstanceConfigurationValueConcurrently(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t, DBOptions{})
+
+	require.NoError(t, client.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token"}))
+	require.NoError(t, client.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "token"}))
+
+	errs := make(chan error, 10)
+	for i := 0; i < cap(errs); i++ {
+		go func(i int) {
+			errs <- client.SetInstanceConfigurationValue(ctx, "instance-1", "foo", fmt.Sprint(i))
+		}(i)
+	}
+	for i := 0; i < cap(errs); i++ {
+		require.NoError(t, <-errs)
+	}
+
+	config, err := client.GetInstanceConfiguration(ctx, "instance-1")
+	require.NoError(t, err)
+	assert.Len(t, config, 1)
+
+	// the unique index rejects parameters inserted twice
+	_, err = client.DB.Exec(statementInsertInstanceConfig, "instance-1", "foo", "duplicate")
+	assert.True(t, IsUniqueViolation(err))
+}
+
+func TestAddInstanceWithUnknownInstallation(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t, DBOptions{})
+
+	err := client.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "unknown", Token: "token"})
+	assert.Equal(t, connector.ErrorUnknownInstallation, err)
+	assert.Equal(t, http.StatusBadRequest, connector.ErrorUnknownInstallation.Status)
+
+	instances, err := client.CountInstances(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, instances)
+}
+
+func TestAddWithConfigurationRollsBack(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t, DBOptions{})
+	config := []connector.Configuration{{ID: "foo", Value: "bar"}}
+
+	_, err := client.DB.Exec("DROP TABLE installation_configuration")
+	require.NoError(t, err)
+
+	err = client.AddInstallationWithConfiguration(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token", Configuration: config})
+	assert.Error(t, err)
+
+	installations, err := client.CountInstallations(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, installations)
+
+	require.NoError(t, client.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token"}))
+	_, err = client.DB.Exec("DROP TABLE instance_configuration")
+	require.NoError(t, err)
+
+	err = client.AddInstanceWithConfiguration(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "token", Configuration: config})
+	assert.Error(t, err)
+
+	instances, err := client.CountInstances(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, instances)
+
+	err = client.AddInstanceWithConfiguration(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "unknown", Token: "token"})
+	assert.Equal(t, connector.ErrorUnknownInstallation, err)
+}
+
+func TestSetAndGetLastPropertyValues(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t, DBOptions{})
+
+	require.NoError(t, client.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token"}))
+	require.NoError(t, client.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "token"}))
+
+	values, err := client.GetLastPropertyValues(ctx, "thing-1")
+	require.NoError(t, err)
+	assert.Empty(t, values)
+
+	first := time.UnixMilli(1000)
+	second := time.UnixMilli(2000)
+	require.NoError(t, client.SetLastPropertyValue(ctx, connector.PropertyValue{InstanceID: "instance-1", ThingID: "thing-1", ComponentID: "sensor", PropertyID: "temperature", Value: "20", LastUpdate: first}))
+	require.NoError(t, client.SetLastPropertyValue(ctx, connector.PropertyValue{InstanceID: "instance-1", ThingID: "thing-1", ComponentID: "sensor", PropertyID: "humidity", Value: "40", LastUpdate: first}))
+	require.NoError(t, client.SetLastPropertyValue(ctx, connector.PropertyValue{InstanceID: "instance-1", ThingID: "thing-1", ComponentID: "sensor", PropertyID: "temperature", Value: "21", LastUpdate: second}))
+	require.NoError(t, client.SetLastPropertyValue(ctx, connector.PropertyValue{InstanceID: "instance-1", ThingID: "thing-2", ComponentID: "sensor", PropertyID: "temperature", Value: "30", LastUpdate: first}))
+
+	values, err = client.GetLastPropertyValues(ctx, "thing-1")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []connector.PropertyValue{
+		{InstanceID: "instance-1", ThingID: "thing-1", ComponentID: "sensor", PropertyID: "temperature", Value: "21", LastUpdate: second},
+		{InstanceID: "instance-1", ThingID: "thing-1", ComponentID: "sensor", PropertyID: "humidity", Value: "40", LastUpdate: first},
+	}, values)
+}
+
+func TestGetInstallationConfiguration(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t, DBOptions{})
+
+	require.NoError(t, client.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token"}))
+	require.NoError(t, client.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-2", Token: "token"}))
+	require.NoError(t, client.AddInstallationConfiguration(ctx, "installation-1", []connector.Configuration{{ID: "username", Value: "foo"}, {ID: "password", Value: "bar"}}))
+
+	config, err := client.GetInstallationConfiguration(ctx, "installation-1")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []connector.Configuration{{ID: "username", Value: "foo"}, {ID: "password", Value: "bar"}}, config)
+
+	config, err = client.GetInstallationConfiguration(ctx, "installation-2")
+	require.NoError(t, err)
+	assert.NotNil(t, config)
+	assert.Empty(t, config)
+
+	_, err = client.GetInstallationConfiguration(ctx, "unknown")
+	assert.Equal(t, connector.ErrorInstallationNotFound, err)
+}
+
+func TestGetInstallationByToken(t *testing.T) {
+	ctx := context.Background()
+
+	hashed := newTestClient(t, DBOptions{TokenHashKey: []byte("secret")})
+
+	clients := map[string]*DBClient{
+		"plain":  newTestClient(t, DBOptions{}),
+		"hashed": hashed,
+	}
+
+	for name, client := range clients {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, client.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token-1"}))
+			require.NoError(t, client.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-2", Token: "token-2"}))
+			require.NoError(t, client.AddInstallationConfiguration(ctx, "installation-2", []connector.Configuration{{ID: "foo", Value: "bar"}}))
+
+			installation, err := client.GetInstallationByToken(ctx, "token-2")
+			require.NoError(t, err)
+			assert.Equal(t, "installation-2", installation.ID)
+			assert.Equal(t, connector.InstallationToken("token-2"), installation.Token)
+			assert.Equal(t, []connector.Configuration{{ID: "foo", Value: "bar"}}, installation.Configuration)
+
+			_, err = client.GetInstallationByToken(ctx, "unknown")
+			assert.Equal(t, connector.ErrorInstallationNotFound, err)
+
+			// rotated tokens are found by their new value
+			require.NoError(t, client.UpdateInstallationToken(ctx, "installation-2", "token-3"))
+			installation, err = client.GetInstallationByToken(ctx, "token-3")
+			require.NoError(t, err)
+			assert.Equal(t, "installation-2", installation.ID)
+		})
+	}
+
+	// the hash is keyed and does not reveal the token
+	var tokenHash string
+	require.NoError(t, hashed.DB.Get(&tokenHash, `SELECT token_hash FROM installations WHERE id = ?`, "installation-1"))
+	assert.Len(t, tokenHash, 64)
+	assert.NotContains(t, tokenHash, "token-1")
+	assert.Equal(t, hashed.hashToken("token-1"), tokenHash)
+	assert.NotEqual(t, (&DBClient{tokenHashKey: []byte("other")}).hashToken("token-1"), tokenHash)
+}
+
+func TestExternalIDNormalization(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t, DBOptions{})
+
+	require.NoError(t, client.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token"}))
+	require.NoError(t, client.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "token"}))
+	require.NoError(t, client.AddThingMapping(ctx, "instance-1", "thing-1", "  Kitchen Lamp "))
+
+	for _, externalID := range []string{"kitchen lamp", "KITCHEN LAMP", "\tKitchen Lamp\n"} {
+		mapping, err := client.GetMappingByExternalId(ctx, "instance-1", externalID)
+		require.NoError(t, err)
+		assert.Equal(t, "thing-1", mapping.ThingID, externalID)
+		assert.Equal(t, "kitchen lamp", mapping.ExternalID, externalID)
+	}
+
+	// other characters are kept, so similar device ids don't share a mapping
+	mapping, err := client.GetMappingByExternalId(ctx, "instance-1", "kitchen-lamp")
+	require.NoError(t, err)
+	assert.Empty(t, mapping.ThingID)
+	require.NoError(t, client.AddThingMapping(ctx, "instance-1", "thing-2", "Kitchen-Lamp"))
+
+	instance, err := client.GetInstance(ctx, "instance-1")
+	require.NoError(t, err)
+	thingID, ok := instance.ThingIdByExternalId("Kitchen Lamp")
+	assert.True(t, ok)
+	assert.Equal(t, "thing-1", thingID)
+	thingID, ok = instance.ThingIdByExternalId("kitchen-lamp")
+	assert.True(t, ok)
+	assert.Equal(t, "thing-2", thingID)
+}
+
+func TestRawExternalIDs(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t, DBOptions{})
+
+	require.NoError(t, client.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token"}))
+	require.NoError(t, client.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "token"}))
+	// mappings stored before external ids were normalized hold the raw id
+	_, err := client.DB.Exec(statementInsertThingId, "instance-1", "thing-1", " Lamp-1")
+	require.NoError(t, err)
+
+	mapping, err := client.GetMappingByExternalId(ctx, "instance-1", "Lamp-1")
+	require.NoError(t, err)
+	assert.Equal(t, connector.ThingMapping{InstanceID: "instance-1", ThingID: "thing-1", ExternalID: "lamp-1"}, *mapping)
+
+	instance, err := client.GetInstance(ctx, "instance-1")
+	require.NoError(t, err)
+	thingID, ok := instance.ThingIdByExternalId("LAMP-1")
+	assert.True(t, ok)
+	assert.Equal(t, "thing-1", thingID)
+
+	instances, err := client.GetInstancesByThingIds(ctx, []string{"thing-1"})
+	require.NoError(t, err)
+	assert.Equal(t, []connector.ThingMapping{{InstanceID: "instance-1", ThingID: "thing-1", ExternalID: "lamp-1"}}, instances["thing-1"].ThingMapping)
+
+	require.NoError(t, client.AddThingMapping(ctx, "instance-1", "thing-2", "lamp-2"))
+	assert.Equal(t, connector.ErrorMappingExists, client.UpdateThingMappingExternalId(ctx, "instance-1", "thing-2", "lamp-1"))
+}
+
+func TestRemoveInstanceKeepsInstallation(t *testing.T) {
+	// foreign keys are not enforced by sqlite by default, so the removal must not rely on cascading deletes
+	for _, dsn := range []string{"file::memory:", "file::memory:?_foreign_keys=on"} {
+		t.Run(dsn, func(t *testing.T) {
+			ctx := context.Background()
+			client := newTestClient(t, DBOptions{DSN: dsn})
+
+			require.NoError(t, client.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token"}))
+			require.NoError(t, client.AddInstallationConfiguration(ctx, "installation-1", []connector.Configuration{{ID: "host", Value: "example.com"}}))
+			for _, instanceID := range []string{"instance-1", "instance-2"} {
+				require.NoError(t, client.AddInstance(ctx, connector.InstantiationRequest{ID: instanceID, InstallationID: "installation-1", Token: "token"}))
+				require.NoError(t, client.AddInstanceConfiguration(ctx, instanceID, []connector.Configuration{{ID: "room", Value: instanceID}}))
+				require.NoError(t, client.AddThingMapping(ctx, instanceID, "thing-"+instanceID, "external-1"))
+				require.NoError(t, client.SetLastPropertyValue(ctx, connector.PropertyValue{InstanceID: instanceID, ThingID: "thing-" + instanceID, ComponentID: "sensor", PropertyID: "value", Value: "1", LastUpdate: time.UnixMilli(1000)}))
+			}
+
+			require.NoError(t, client.RemoveInstance(ctx, "instance-1"))
+
+			// no rows of the removed instance are left
+			for _, table := range []string{"instances", "instance_configuration", "instance_thing_mapping", "property_values"} {
+				column := "instance_id"
+				if table == "instances" {
+					column = "id"
+				}
+
+				var rows int
+				require.NoError(t, client.DB.Get(&rows, "SELECT COUNT(*) FROM "+table+" WHERE "+column+" = ?", "instance-1"))
+				assert.Equal(t, 0, rows, table)
+				require.NoError(t, client.DB.Get(&rows, "SELECT COUNT(*) FROM "+table+" WHERE "+column+" = ?", "instance-2"))
+				assert.Equal(t, 1, rows, table)
+			}
+
+			// the installation is untouched
+			installations, err := client.GetInstallations(ctx)
+			require.NoError(t, err)
+			require.Len(t, installations, 1)
+			config, err := client.GetInstallationConfiguration(ctx, "installation-1")
+			require.NoError(t, err)
+			assert.Len(t, config, 1)
+		})
+	}
+}
+
+func TestRemoveInstanceWithBaselineLayout(t *testing.T) {
+	ctx := context.Background()
+	client := openTestClient(t, DBOptions{})
+
+	// databases created by the first versions of Migrate lack the tables added later
+	for _, q := range []string{StatementCreateInstallationTable, StatementCreateInstanceTable, StatementCreateInstaceThingMapping, StatementCreateInstallConfigTable, StatementCreateInstanceConfigTable} {
+		_, err := client.DB.Exec(q)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, client.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token"}))
+	require.NoError(t, client.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "token", Configuration: []connector.Configuration{{ID: "room", Value: "kitchen"}}}))
+	require.NoError(t, client.AddThingMapping(ctx, "instance-1", "thing-1", "external-1"))
+
+	require.NoError(t, client.RemoveInstance(ctx, "instance-1"))
+	for _, table := range []string{"instance_configuration", "instance_thing_mapping"} {
+		var rows int
+		require.NoError(t, client.DB.Get(&rows, "SELECT COUNT(*) FROM "+table))
+		assert.Equal(t, 0, rows, table)
+	}
+	assert.Equal(t, connector.ErrorInstanceNotFound, client.RemoveInstance(ctx, "instance-1"))
+}
+
+func TestPendingInstanceStates(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t, DBOptions{})
+
+	require.NoError(t, client.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token"}))
+	require.NoError(t, client.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "token"}))
+
+	states, err := client.GetPendingInstanceStates(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, states)
+
+	ongoing := connector.PendingInstanceState{InstanceID: "instance-1", State: connector.InstantiationStateOngoing}
+	complete := connector.PendingInstanceState{InstanceID: "instance-1", State: connector.InstantiationStateComplete, Details: []byte(`{"things":2}`)}
+	require.NoError(t, client.SetPendingInstanceState(ctx, ongoing))
+	require.NoError(t, client.SetPendingInstanceState(ctx, complete))
+
+	// newer states replace older ones
+	states, err = client.GetPendingInstanceStates(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []connector.PendingInstanceState{complete}, states)
+
+	// replaced states are not removed
+	require.NoError(t, client.RemovePendingInstanceState(ctx, ongoing))
+	states, err = client.GetPendingInstanceStates(ctx)
+	require.NoError(t, err)
+	assert.Len(t, states, 1)
+
+	require.NoError(t, client.RemovePendingInstanceState(ctx, complete))
+	states, err = client.GetPendingInstanceStates(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, states)
+}
+
+func TestCancelledContext(t *testing.T) {
+	client := newTestClient(t, DBOptions{})
+	require.NoError(t, client.AddInstallation(context.Background(), connector.InstallationRequest{ID: "installation-1", Token: "token"}))
+	require.NoError(t, client.AddInstance(context.Background(), connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "token"}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := map[string]func() error{
+		"AddInstallation": func() error {
+			return client.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-2", Token: "token"})
+		},
+		"AddInstance": func() error {
+			return client.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-2", InstallationID: "installation-1", Token: "token"})
+		},
+		"GetInstance": func() error {
+			_, err := client.GetInstance(ctx, "instance-1")
+			return err
+		},
+		"GetInstallations": func() error {
+			_, err := client.GetInstallations(ctx)
+			return err
+		},
+		"AddThingMapping": func() error {
+			return client.AddThingMapping(ctx, "instance-1", "thing-1", "external-1")
+		},
+		"RemoveInstance": func() error {
+			return client.RemoveInstance(ctx, "instance-1")
+		},
+	}
+
+	for name, call := range calls {
+		err := call()
+		assert.True(t, errors.Is(err, context.Canceled), "%s: %v", name, err)
+	}
+
+	// nothing was changed by the cancelled calls
+	instances, err := client.CountInstances(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, instances)
+
+	mappings, err := client.CountThingMappings(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, mappings)
+}
+
+func TestConnectionPoolOptions(t *testing.T) {
+	client, err := NewDBClient(&DBOptions{
+		Driver:          DriverSqlite3,
+		DSN:             "file::memory:?_foreign_keys=on",
+		ReplicaDSN:      "file::memory:?_foreign_keys=on",
+		MaxOpenConns:    3,
+		MaxIdleConns:    2,
+		ConnMaxLifetime: time.Minute,
+		ConnMaxIdleTime: time.Second,
+	}, logr.Discard())
+	require.NoError(t, err)
+	t.Cleanup(func() { client.DB.Close(); client.Replica.Close() })
+
+	assert.Equal(t, 3, client.DB.Stats().MaxOpenConnections)
+	assert.Equal(t, 3, client.Replica.Stats().MaxOpenConnections)
+
+	// zero values keep the defaults
+	defaults, err := NewDBClient(&DBOptions{Driver: DriverSqlite3, DSN: "file::memory:?_foreign_keys=on"}, logr.Discard())
+	require.NoError(t, err)
+	t.Cleanup(func() { defaults.DB.Close() })
+
+	assert.Equal(t, 0, defaults.DB.Stats().MaxOpenConnections)
+}
+
+func TestRebind(t *testing.T) {
+	assert.Equal(t, statementInsertInstallation, newTestClient(t, DBOptions{}).rebind(statementInsertInstallation))
+
+	postgres := &DBClient{bindType: sqlx.BindType(string(DriverPostgresql))}
+	assert.Equal(t, `INSERT INTO installations (id, token) VALUES ($1, $2)`, postgres.rebind(statementInsertInstallation))
+
+	sqlserver := &DBClient{bindType: sqlx.BindType(string(DriverSqlserver))}
+	assert.Equal(t, `INSERT INTO installations (id, token) VALUES (@p1, @p2)`, sqlserver.rebind(statementInsertInstallation))
+}
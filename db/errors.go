@@ -0,0 +1,44 @@
+package db
+
+import (
+	"errors"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+	mssql "github.com/microsoft/go-mssqldb"
+)
+
+// driver specific error codes of unique constraint violations
+const (
+	mysqlDuplicateEntry      = 1062
+	postgresUniqueViolation  = pq.ErrorCode("23505")
+	sqlserverUniqueIndex     = 2601
+	sqlserverUniqueViolation = 2627
+)
+
+// IsUniqueViolation reports whether the error was caused by a violated unique constraint.
+// It understands the errors of all supported drivers.
+func IsUniqueViolation(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlDuplicateEntry
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == postgresUniqueViolation
+	}
+
+	var mssqlErr mssql.Error
+	if errors.As(err, &mssqlErr) {
+		return mssqlErr.Number == sqlserverUniqueIndex || mssqlErr.Number == sqlserverUniqueViolation
+	}
+
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey
+	}
+
+	return false
+}
@@ -0,0 +1,50 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/connctd/connector-go"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	mssql "github.com/microsoft/go-mssqldb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsUniqueViolation(t *testing.T) {
+	assert.True(t, IsUniqueViolation(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}))
+	assert.False(t, IsUniqueViolation(&mysql.MySQLError{Number: 1452, Message: "Cannot add or update a child row"}))
+
+	assert.True(t, IsUniqueViolation(&pq.Error{Code: "23505"}))
+	assert.False(t, IsUniqueViolation(&pq.Error{Code: "23503"}))
+
+	assert.True(t, IsUniqueViolation(mssql.Error{Number: 2627}))
+	assert.True(t, IsUniqueViolation(mssql.Error{Number: 2601}))
+	assert.False(t, IsUniqueViolation(mssql.Error{Number: 547}))
+
+	assert.True(t, IsUniqueViolation(fmt.Errorf("wrapped: %w", &pq.Error{Code: "23505"})))
+	assert.False(t, IsUniqueViolation(errors.New("foo")))
+	assert.False(t, IsUniqueViolation(nil))
+
+	// sqlite is available, so we trigger a real violation
+	ctx := context.Background()
+	client := newTestClient(t, DBOptions{})
+	require.NoError(t, client.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token"}))
+
+	_, err := client.DB.Exec(statementInsertInstallation, "installation-1", "token")
+	assert.True(t, IsUniqueViolation(err))
+}
+
+func TestAddDuplicateThingMapping(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t, DBOptions{})
+
+	require.NoError(t, client.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token"}))
+	require.NoError(t, client.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "token"}))
+	require.NoError(t, client.AddThingMapping(ctx, "instance-1", "thing-1", "external-1"))
+
+	assert.Equal(t, connector.ErrorMappingExists, client.AddThingMapping(ctx, "instance-1", "thing-1", "external-1"))
+}
@@ -0,0 +1,202 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/connctd/connector-go"
+	"github.com/jmoiron/sqlx"
+)
+
+// JSONConfigMigrationQueries add the columns needed for JSON configuration storage.
+// Migrate executes them after MigrationQueries if JSON configuration storage is enabled.
+// Connectors enabling JSON configuration storage for an existing database should execute them once on their own
+// and move existing configurations themselves, since the configuration tables are no longer read.
+var JSONConfigMigrationQueries = []string{
+	`ALTER TABLE installations ADD COLUMN configuration TEXT DEFAULT NULL`,
+	`ALTER TABLE instances ADD COLUMN configuration TEXT DEFAULT NULL`,
+}
+
+// Statements used instead of the configuration tables if JSON configuration storage is enabled:
+var (
+	statementGetInstancesInstallationJSONConfig     = `SELECT l.configuration FROM installations l, instances i WHERE i.id = ? AND l.id = i.installation_id`
+	statementSoftGetInstancesInstallationJSONConfig = `SELECT l.configuration FROM installations l, instances i WHERE i.id = ? AND i.deleted_at IS NULL AND l.id = i.installation_id`
+	statementGetInstanceJSONConfigs                 = `SELECT id, configuration FROM instances WHERE id IN (?)`
+	statementSoftGetInstanceJSONConfigs             = `SELECT id, configuration FROM instances WHERE id IN (?) AND deleted_at IS NULL`
+)
+
+// jsonConfigColumn describes the column holding the JSON encoded configuration of installations or instances.
+type jsonConfigColumn struct {
+	get     string
+	softGet string
+	set     string
+	// notFound is returned if the installation or instance does not exist
+	notFound error
+}
+
+var (
+	installationJSONConfig = jsonConfigColumn{
+		get:      `SELECT configuration FROM installations WHERE id = ?`,
+		softGet:  `SELECT configuration FROM installations WHERE id = ? AND deleted_at IS NULL`,
+		set:      `UPDATE installations SET configuration = ? WHERE id = ?`,
+		notFound: connector.ErrorInstallationNotFound,
+	}
+	instanceJSONConfig = jsonConfigColumn{
+		get:      `SELECT configuration FROM instances WHERE id = ?`,
+		softGet:  `SELECT configuration FROM instances WHERE id = ? AND deleted_at IS NULL`,
+		set:      `UPDATE instances SET configuration = ? WHERE id = ?`,
+		notFound: connector.ErrorInstanceNotFound,
+	}
+)
+
+// getJSONConfig returns the decoded configuration stored in the column.
+// It returns the notFound error of the column if the installation or instance does not exist.
+func (m *DBClient) getJSONConfig(ctx context.Context, q sqlx.QueryerContext, column jsonConfigColumn, id string) ([]connector.Configuration, error) {
+	var raw sql.NullString
+	if err := sqlx.GetContext(ctx, q, &raw, m.rebind(m.statement(column.get, column.softGet)), id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, column.notFound
+		}
+		return nil, fmt.Errorf("failed to retrieve configuration: %w", err)
+	}
+	return decodeJSONConfig(raw)
+}
+
+// setJSONConfig replaces the configuration stored in the column.
+func (m *DBClient) setJSONConfig(ctx context.Context, exec sqlx.ExecerContext, column jsonConfigColumn, id string, config []connector.Configuration) error {
+	raw, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to encode configuration: %w", err)
+	}
+
+	if _, err := exec.ExecContext(ctx, m.rebind(column.set), string(raw), id); err != nil {
+		return fmt.Errorf("failed to store configuration: %w", err)
+	}
+	return nil
+}
+
+// updateJSONConfig applies the update to the stored configuration within a transaction.
+func (m *DBClient) updateJSONConfig(ctx context.Context, column jsonConfigColumn, id string, update func([]connector.Configuration) []connector.Configuration) error {
+	tx, err := m.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	config, err := m.getJSONConfig(ctx, tx, column, id)
+	if err != nil {
+		return err
+	}
+
+	if err := m.setJSONConfig(ctx, tx, column, id, update(config)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit configuration: %w", err)
+	}
+	return nil
+}
+
+// appendJSONConfig adds the parameters to the stored configuration.
+func (m *DBClient) appendJSONConfig(ctx context.Context, column jsonConfigColumn, id string, config []connector.Configuration) error {
+	return m.updateJSONConfig(ctx, column, id, func(existing []connector.Configuration) []connector.Configuration {
+		return append(existing, config...)
+	})
+}
+
+// setJSONConfigValue sets a single parameter of the stored instance configuration.
+func (m *DBClient) setJSONConfigValue(ctx context.Context, instanceId string, key string, value string) error {
+	return m.updateJSONConfig(ctx, instanceJSONConfig, instanceId, func(config []connector.Configuration) []connector.Configuration {
+		for i := range config {
+			if config[i].ID == key {
+				config[i].Value = value
+				return config
+			}
+		}
+		return append(config, connector.Configuration{ID: key, Value: value})
+	})
+}
+
+// deleteJSONConfigValue removes a single parameter of the stored instance configuration.
+// Like the row based storage it does not return an error if the instance or the parameter does not exist.
+func (m *DBClient) deleteJSONConfigValue(ctx context.Context, instanceId string, key string) error {
+	err := m.updateJSONConfig(ctx, instanceJSONConfig, instanceId, func(config []connector.Configuration) []connector.Configuration {
+		remaining := config[:0]
+		for _, c := range config {
+			if c.ID != key {
+				remaining = append(remaining, c)
+			}
+		}
+		return remaining
+	})
+	if err == connector.ErrorInstanceNotFound {
+		return nil
+	}
+	return err
+}
+
+// getInstancesInstallationJSONConfig returns the stored configuration of the installation of an instance.
+func (m *DBClient) getInstancesInstallationJSONConfig(ctx context.Context, instanceID string) ([]*connector.Configuration, error) {
+	var raw sql.NullString
+	err := m.reader(ctx).GetContext(ctx, &raw, m.rebind(m.statement(statementGetInstancesInstallationJSONConfig, statementSoftGetInstancesInstallationJSONConfig)), instanceID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve instances installation configuration: %w", err)
+	}
+
+	config, err := decodeJSONConfig(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	configurations := make([]*connector.Configuration, len(config))
+	for i := range config {
+		configurations[i] = &config[i]
+	}
+	return configurations, nil
+}
+
+// getJSONConfigs adds the stored configurations of the instances to the result with a single query.
+func (m *DBClient) getJSONConfigs(ctx context.Context, result map[string][]connector.Configuration, instanceIds []string) (map[string][]connector.Configuration, error) {
+	query, args, err := sqlx.In(m.statement(statementGetInstanceJSONConfigs, statementSoftGetInstanceJSONConfigs), instanceIds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build instance configuration query: %w", err)
+	}
+
+	var rows []struct {
+		ID            string         `db:"id"`
+		Configuration sql.NullString `db:"configuration"`
+	}
+	if err := m.reader(ctx).SelectContext(ctx, &rows, m.rebind(query), args...); err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to retrieve instance configurations: %w", err)
+	}
+
+	for _, row := range rows {
+		config, err := decodeJSONConfig(row.Configuration)
+		if err != nil {
+			return nil, err
+		}
+		if config != nil {
+			result[row.ID] = config
+		}
+	}
+	return result, nil
+}
+
+// decodeJSONConfig unmarshals a stored configuration. A configuration which was never stored is returned as nil.
+func decodeJSONConfig(raw sql.NullString) ([]connector.Configuration, error) {
+	if !raw.Valid || raw.String == "" {
+		return nil, nil
+	}
+
+	var config []connector.Configuration
+	if err := json.Unmarshal([]byte(raw.String), &config); err != nil {
+		return nil, fmt.Errorf("failed to decode configuration: %w", err)
+	}
+	return config, nil
+}
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/connctd/connector-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONConfigStorage(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t, DBOptions{JSONConfigStorage: true})
+
+	nested := `{"rooms":[{"name":"kitchen","devices":["` + strings.Repeat("lamp", 100) + `"]}]}`
+	config := []connector.Configuration{{ID: "host", Value: "example.com"}, {ID: "rooms", Value: nested}, {ID: "empty", Value: ""}}
+
+	require.NoError(t, client.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token"}))
+	require.NoError(t, client.AddInstallationConfiguration(ctx, "installation-1", config[:2]))
+	require.NoError(t, client.AddInstallationConfiguration(ctx, "installation-1", config[2:]))
+	require.NoError(t, client.AddInstanceWithConfiguration(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "token", Configuration: config}))
+
+	// the configuration is stored as a single document instead of rows
+	var stored string
+	require.NoError(t, client.DB.Get(&stored, `SELECT configuration FROM instances WHERE id = ?`, "instance-1"))
+	assert.Contains(t, stored, `"id":"rooms"`)
+
+	var rows int
+	require.NoError(t, client.DB.Get(&rows, `SELECT COUNT(*) FROM instance_configuration`))
+	assert.Equal(t, 0, rows)
+
+	installationConfig, err := client.GetInstallationConfiguration(ctx, "installation-1")
+	require.NoError(t, err)
+	assert.Equal(t, config, installationConfig)
+
+	instanceConfig, err := client.GetInstanceConfiguration(ctx, "instance-1")
+	require.NoError(t, err)
+	assert.Equal(t, config, instanceConfig)
+
+	instanceConfigs, err := client.GetInstanceConfigurations(ctx, []string{"instance-1", "unknown"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]connector.Configuration{"instance-1": config, "unknown": {}}, instanceConfigs)
+
+	instancesInstallationConfig, err := client.GetInstancesInstallationConfiguration(ctx, "instance-1")
+	require.NoError(t, err)
+	require.Len(t, instancesInstallationConfig, 3)
+	assert.Equal(t, nested, instancesInstallationConfig[1].Value)
+
+	installations, err := client.GetInstallations(ctx)
+	require.NoError(t, err)
+	require.Len(t, installations, 1)
+	assert.Equal(t, config, installations[0].Configuration)
+
+	require.NoError(t, client.SetInstanceConfigurationValue(ctx, "instance-1", "host", "example.org"))
+	require.NoError(t, client.SetInstanceConfigurationValue(ctx, "instance-1", "port", "8080"))
+	require.NoError(t, client.DeleteInstanceConfigurationValue(ctx, "instance-1", "empty"))
+	require.NoError(t, client.DeleteInstanceConfigurationValue(ctx, "unknown", "empty"))
+
+	instance, err := client.GetInstance(ctx, "instance-1")
+	require.NoError(t, err)
+	assert.Equal(t, []connector.Configuration{{ID: "host", Value: "example.org"}, {ID: "rooms", Value: nested}, {ID: "port", Value: "8080"}}, instance.Configuration)
+
+	require.NoError(t, client.UpdateInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token", Configuration: config[:1]}))
+	installationConfig, err = client.GetInstallationConfiguration(ctx, "installation-1")
+	require.NoError(t, err)
+	assert.Equal(t, config[:1], installationConfig)
+
+	assert.Equal(t, connector.ErrorInstallationNotFound, client.AddInstallationConfiguration(ctx, "unknown", config))
+	_, err = client.GetInstallationConfiguration(ctx, "unknown")
+	assert.Equal(t, connector.ErrorInstallationNotFound, err)
+
+	// installations and instances without configuration return no parameters
+	require.NoError(t, client.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-2", Token: "token-2"}))
+	installationConfig, err = client.GetInstallationConfiguration(ctx, "installation-2")
+	require.NoError(t, err)
+	assert.Empty(t, installationConfig)
+}
@@ -0,0 +1,814 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/connctd/connector-go"
+	"github.com/connctd/connector-go/connctd"
+)
+
+// InMemoryDatabase implements the connector.Database interface backed by maps.
+// It behaves like the DBClient without soft delete and is meant for unit tests of connectors,
+// so they do not need a sql database. Nothing is persisted.
+type InMemoryDatabase struct {
+	mutex          sync.RWMutex
+	installations  map[string]*memoryInstallation
+	instances      map[string]*memoryInstance
+	propertyValues map[propertyKey]connector.PropertyValue
+	pendingStates  map[string]connector.PendingInstanceState
+}
+
+type memoryInstallation struct {
+	token         connector.InstallationToken
+	configuration []connector.Configuration
+}
+
+type memoryInstance struct {
+	installationID string
+	token          connector.InstantiationToken
+	configuration  []connector.Configuration
+	secrets        []connector.Secret
+	thingMapping   []connector.ThingMapping
+}
+
+// propertyKey identifies a property of a thing of an instance.
+type propertyKey struct {
+	instanceID  string
+	thingID     string
+	componentID string
+	propertyID  string
+}
+
+// NewInMemoryDatabase returns an empty in-memory database.
+func NewInMemoryDatabase() *InMemoryDatabase {
+	return &InMemoryDatabase{
+		installations:  map[string]*memoryInstallation{},
+		instances:      map[string]*memoryInstance{},
+		propertyValues: map[propertyKey]connector.PropertyValue{},
+		pendingStates:  map[string]connector.PendingInstanceState{},
+	}
+}
+
+// AddInstallation adds an installation request to the database.
+func (m *InMemoryDatabase) AddInstallation(ctx context.Context, installationRequest connector.InstallationRequest) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, ok := m.installations[installationRequest.ID]; ok {
+		return fmt.Errorf("failed to insert installation: installation %s already exists", installationRequest.ID)
+	}
+
+	m.installations[installationRequest.ID] = &memoryInstallation{token: installationRequest.Token}
+	return nil
+}
+
+// AddInstallationConfiguration adds all configuration parameters to the database.
+// It rejects the whole configuration if any of the values is not valid UTF-8.
+// It returns connector.ErrorInstallationNotFound if the installation does not exist.
+func (m *InMemoryDatabase) AddInstallationConfiguration(ctx context.Context, installationId string, config []connector.Configuration) error {
+	if err := verifyConfiguration(config); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	installation, ok := m.installations[installationId]
+	if !ok {
+		return connector.ErrorInstallationNotFound
+	}
+
+	installation.configuration = append(installation.configuration, config...)
+	return nil
+}
+
+// AddInstallationWithConfiguration adds the installation together with its configuration.
+// Nothing is stored if any of the values is not valid UTF-8.
+func (m *InMemoryDatabase) AddInstallationWithConfiguration(ctx context.Context, installationRequest connector.InstallationRequest) error {
+	if err := verifyConfiguration(installationRequest.Configuration); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, ok := m.installations[installationRequest.ID]; ok {
+		return fmt.Errorf("failed to insert installation: installation %s already exists", installationRequest.ID)
+	}
+
+	m.installations[installationRequest.ID] = &memoryInstallation{
+		token:         installationRequest.Token,
+		configuration: append([]connector.Configuration(nil), installationRequest.Configuration...),
+	}
+	return nil
+}
+
+// UpdateInstallation replaces the token and the configuration of an existing installation.
+// It returns connector.ErrorInstallationNotFound if the installation does not exist.
+func (m *InMemoryDatabase) UpdateInstallation(ctx context.Context, installationRequest connector.InstallationRequest) error {
+	if err := verifyConfiguration(installationRequest.Configuration); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	installation, ok := m.installations[installationRequest.ID]
+	if !ok {
+		return connector.ErrorInstallationNotFound
+	}
+
+	installation.token = installationRequest.Token
+	installation.configuration = copyConfiguration(installationRequest.Configuration)
+	return nil
+}
+
+// UpdateInstallationToken replaces the token of an existing installation.
+// It returns connector.ErrorInstallationNotFound if the installation does not exist.
+func (m *InMemoryDatabase) UpdateInstallationToken(ctx context.Context, installationId string, token connector.InstallationToken) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	installation, ok := m.installations[installationId]
+	if !ok {
+		return connector.ErrorInstallationNotFound
+	}
+
+	installation.token = token
+	return nil
+}
+
+// GetInstallations returns all installations together with their configuration, ordered by id.
+func (m *InMemoryDatabase) GetInstallations(ctx context.Context) ([]*connector.Installation, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	installations := make([]*connector.Installation, 0, len(m.installations))
+	for _, id := range m.installationIds() {
+		installations = append(installations, m.installation(id))
+	}
+	return installations, nil
+}
+
+// GetInstallationConfiguration returns all configuration parameters of the installation with the given id.
+// It returns connector.ErrorInstallationNotFound if the installation does not exist.
+func (m *InMemoryDatabase) GetInstallationConfiguration(ctx context.Context, installationId string) ([]connector.Configuration, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	installation, ok := m.installations[installationId]
+	if !ok {
+		return nil, connector.ErrorInstallationNotFound
+	}
+	return append([]connector.Configuration{}, installation.configuration...), nil
+}
+
+// GetInstallationByToken returns the installation with the given token together with its configuration.
+// It returns connector.ErrorInstallationNotFound if no installation uses the token.
+func (m *InMemoryDatabase) GetInstallationByToken(ctx context.Context, token connector.InstallationToken) (*connector.Installation, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	for _, id := range m.installationIds() {
+		if m.installations[id].token == token {
+			installation := m.installation(id)
+			installation.Configuration = append([]connector.Configuration{}, installation.Configuration...)
+			return installation, nil
+		}
+	}
+	return nil, connector.ErrorInstallationNotFound
+}
+
+// RemoveInstallation removes the installation with the given id together with its configuration and instances.
+// It returns connector.ErrorInstallationNotFound if the installation does not exist.
+func (m *InMemoryDatabase) RemoveInstallation(ctx context.Context, installationId string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, ok := m.installations[installationId]; !ok {
+		return connector.ErrorInstallationNotFound
+	}
+
+	for id, instance := range m.instances {
+		if instance.installationID == installationId {
+			m.removeInstance(id)
+		}
+	}
+	delete(m.installations, installationId)
+	return nil
+}
+
+// GetInstancesInstallationConfiguration retrieves the configuration of the installation of an instance.
+func (m *InMemoryDatabase) GetInstancesInstallationConfiguration(ctx context.Context, instanceID string) ([]*connector.Configuration, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	instance, ok := m.instances[instanceID]
+	if !ok {
+		return nil, nil
+	}
+
+	var configurations []*connector.Configuration
+	if installation, ok := m.installations[instance.installationID]; ok {
+		for _, c := range installation.configuration {
+			c := c
+			configurations = append(configurations, &c)
+		}
+	}
+	return configurations, nil
+}
+
+// AddInstance adds an instantiation to the database.
+// It returns connector.ErrorUnknownInstallation if the referenced installation does not exist.
+func (m *InMemoryDatabase) AddInstance(ctx context.Context, instantiationRequest connector.InstantiationRequest) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, ok := m.installations[instantiationRequest.InstallationID]; !ok {
+		return connector.ErrorUnknownInstallation
+	}
+
+	if _, ok := m.instances[instantiationRequest.ID]; ok {
+		return fmt.Errorf("failed to insert instance: instance %s already exists", instantiationRequest.ID)
+	}
+
+	m.instances[instantiationRequest.ID] = &memoryInstance{installationID: instantiationRequest.InstallationID, token: instantiationRequest.Token}
+	return nil
+}
+
+// AddInstanceConfiguration adds all configuration parameters to the database.
+// It rejects the whole configuration if any of the values is not valid UTF-8.
+// It returns connector.ErrorInstanceNotFound if the instance does not exist.
+func (m *InMemoryDatabase) AddInstanceConfiguration(ctx context.Context, instanceId string, config []connector.Configuration) error {
+	if err := verifyConfiguration(config); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	instance, ok := m.instances[instanceId]
+	if !ok {
+		return connector.ErrorInstanceNotFound
+	}
+
+	instance.configuration = append(instance.configuration, config...)
+	return nil
+}
+
+// AddInstanceWithConfiguration adds the instance together with its configuration.
+// Nothing is stored if any of the values is not valid UTF-8 or the installation does not exist.
+func (m *InMemoryDatabase) AddInstanceWithConfiguration(ctx context.Context, instantiationRequest connector.InstantiationRequest) error {
+	if err := verifyConfiguration(instantiationRequest.Configuration); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, ok := m.installations[instantiationRequest.InstallationID]; !ok {
+		return connector.ErrorUnknownInstallation
+	}
+
+	if _, ok := m.instances[instantiationRequest.ID]; ok {
+		return fmt.Errorf("failed to insert instance: instance %s already exists", instantiationRequest.ID)
+	}
+
+	m.instances[instantiationRequest.ID] = &memoryInstance{
+		installationID: instantiationRequest.InstallationID,
+		token:          instantiationRequest.Token,
+		configuration:  append([]connector.Configuration(nil), instantiationRequest.Configuration...),
+	}
+	return nil
+}
+
+// UpdateInstanceToken replaces the token of an existing instance.
+// It returns connector.ErrorInstanceNotFound if the instance does not exist.
+func (m *InMemoryDatabase) UpdateInstanceToken(ctx context.Context, instanceId string, token connector.InstantiationToken) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	instance, ok := m.instances[instanceId]
+	if !ok {
+		return connector.ErrorInstanceNotFound
+	}
+
+	instance.token = token
+	return nil
+}
+
+// SetInstanceConfigurationValue sets a single configuration parameter of an instance.
+// Existing parameters are updated, missing ones are added. All other parameters are left untouched.
+// It returns connector.ErrorInstanceNotFound if the instance does not exist.
+func (m *InMemoryDatabase) SetInstanceConfigurationValue(ctx context.Context, instanceId string, key string, value string) error {
+	if err := connctd.VerifyString(value); err != nil {
+		return fmt.Errorf("invalid value for configuration parameter %s: %w", key, err)
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	instance, ok := m.instances[instanceId]
+	if !ok {
+		return connector.ErrorInstanceNotFound
+	}
+
+	updated := false
+	for i := range instance.configuration {
+		if instance.configuration[i].ID == key {
+			instance.configuration[i].Value = value
+			updated = true
+		}
+	}
+
+	if !updated {
+		instance.configuration = append(instance.configuration, connector.Configuration{ID: key, Value: value})
+	}
+	return nil
+}
+
+// DeleteInstanceConfigurationValue removes a single configuration parameter of an instance.
+// It does not return an error if the parameter does not exist.
+func (m *InMemoryDatabase) DeleteInstanceConfigurationValue(ctx context.Context, instanceId string, key string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	instance, ok := m.instances[instanceId]
+	if !ok {
+		return nil
+	}
+
+	configuration := instance.configuration[:0]
+	for _, c := range instance.configuration {
+		if c.ID != key {
+			configuration = append(configuration, c)
+		}
+	}
+	instance.configuration = configuration
+	return nil
+}
+
+// GetInstance returns the instance with the given id.
+// It returns connector.ErrorInstanceNotFound if the instance does not exist.
+func (m *InMemoryDatabase) GetInstance(ctx context.Context, instanceId string) (*connector.Instance, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if _, ok := m.instances[instanceId]; !ok {
+		return nil, connector.ErrorInstanceNotFound
+	}
+	return m.instance(instanceId), nil
+}
+
+// GetInstances returns all instances, ordered by id.
+func (m *InMemoryDatabase) GetInstances(ctx context.Context) ([]*connector.Instance, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	instances := make([]*connector.Instance, 0, len(m.instances))
+	for _, id := range m.instanceIds() {
+		instances = append(instances, m.instance(id))
+	}
+	return instances, nil
+}
+
+// GetInstanceByThingId returns the instance with the given thing id.
+// It returns connector.ErrorInstanceNotFound if no instance is mapped to the thing.
+func (m *InMemoryDatabase) GetInstanceByThingId(ctx context.Context, thingId string) (*connector.Instance, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if id, ok := m.instanceIdByThingId(thingId); ok {
+		return m.instance(id), nil
+	}
+	return nil, connector.ErrorInstanceNotFound
+}
+
+// GetInstancesByThingIds returns the instances of the given things.
+// The resulting map is keyed by thing id. Things without an instance are not contained.
+func (m *InMemoryDatabase) GetInstancesByThingIds(ctx context.Context, thingIds []string) (map[string]*connector.Instance, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	result := make(map[string]*connector.Instance)
+
+	// several things can belong to the same instance, so each instance is only completed once
+	instances := make(map[string]*connector.Instance)
+	for _, thingId := range thingIds {
+		id, ok := m.instanceIdByThingId(thingId)
+		if !ok {
+			continue
+		}
+
+		instance, ok := instances[id]
+		if !ok {
+			instance = m.instance(id)
+			instances[id] = instance
+		}
+		result[thingId] = instance
+	}
+	return result, nil
+}
+
+// GetInstanceConfiguration returns all configuration parameters for the given instance id.
+func (m *InMemoryDatabase) GetInstanceConfiguration(ctx context.Context, instanceId string) ([]connector.Configuration, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	instance, ok := m.instances[instanceId]
+	if !ok {
+		return nil, nil
+	}
+	return copyConfiguration(instance.configuration), nil
+}
+
+// AddInstanceSecrets stores the secrets of the instance, replacing existing secrets with the same id.
+// Secrets are kept in plaintext, since nothing is persisted.
+// It returns connector.ErrorInstanceNotFound if the instance does not exist.
+func (m *InMemoryDatabase) AddInstanceSecrets(ctx context.Context, instanceId string, secrets []connector.Secret) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	instance, ok := m.instances[instanceId]
+	if !ok {
+		return connector.ErrorInstanceNotFound
+	}
+
+	for _, secret := range secrets {
+		replaced := false
+		for i := range instance.secrets {
+			if instance.secrets[i].ID == secret.ID {
+				instance.secrets[i].Value = secret.Value
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			instance.secrets = append(instance.secrets, secret)
+		}
+	}
+	return nil
+}
+
+// GetInstanceSecrets returns all secrets of the given instance id.
+// If no secrets were found it returns an empty slice.
+func (m *InMemoryDatabase) GetInstanceSecrets(ctx context.Context, instanceId string) ([]connector.Secret, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	instance, ok := m.instances[instanceId]
+	if !ok {
+		return []connector.Secret{}, nil
+	}
+	return append([]connector.Secret{}, instance.secrets...), nil
+}
+
+// GetInstanceConfigurations returns the configuration parameters of the given instances.
+// The resulting map is keyed by instance id and contains all given ids.
+// Instances without configuration are mapped to an empty slice.
+func (m *InMemoryDatabase) GetInstanceConfigurations(ctx context.Context, instanceIds []string) (map[string][]connector.Configuration, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	result := make(map[string][]connector.Configuration, len(instanceIds))
+	for _, id := range instanceIds {
+		result[id] = []connector.Configuration{}
+		if instance, ok := m.instances[id]; ok && len(instance.configuration) > 0 {
+			result[id] = copyConfiguration(instance.configuration)
+		}
+	}
+	return result, nil
+}
+
+// GetMappingByInstanceId returns all things mapped to the instance with the given id.
+func (m *InMemoryDatabase) GetMappingByInstanceId(ctx context.Context, instanceId string) ([]connector.ThingMapping, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	instance, ok := m.instances[instanceId]
+	if !ok {
+		return nil, nil
+	}
+	return copyThingMapping(instance.thingMapping), nil
+}
+
+// GetMappingByExternalId searches for a thing mapping with specific external id.
+// Like the DBClient it returns an empty mapping if no thing is mapped to the external id.
+func (m *InMemoryDatabase) GetMappingByExternalId(ctx context.Context, instanceId string, externalID string) (*connector.ThingMapping, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	externalID = connector.NormalizeExternalID(externalID)
+	if instance, ok := m.instances[instanceId]; ok {
+		for _, mapping := range instance.thingMapping {
+			if mapping.ExternalID == externalID {
+				return &mapping, nil
+			}
+		}
+	}
+	return &connector.ThingMapping{}, nil
+}
+
+// RemoveInstance removes the instance with the given id together with its configuration, secrets, thing mappings,
+// property values and pending states. It returns connector.ErrorInstanceNotFound if the instance does not exist.
+func (m *InMemoryDatabase) RemoveInstance(ctx context.Context, instanceId string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, ok := m.instances[instanceId]; !ok {
+		return connector.ErrorInstanceNotFound
+	}
+
+	m.removeInstance(instanceId)
+	return nil
+}
+
+// AddThingMapping adds a mapping of the instance id to a thing and external id.
+// The external id is stored in its normalized form, see connector.NormalizeExternalID.
+// It returns connector.ErrorMappingExists if the thing is already mapped to the instance
+// and connector.ErrorInstanceNotFound if the instance does not exist.
+func (m *InMemoryDatabase) AddThingMapping(ctx context.Context, instanceId string, thingId string, externalId string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	instance, ok := m.instances[instanceId]
+	if !ok {
+		return connector.ErrorInstanceNotFound
+	}
+
+	for _, mapping := range instance.thingMapping {
+		if mapping.ThingID == thingId {
+			return connector.ErrorMappingExists
+		}
+	}
+
+	instance.thingMapping = append(instance.thingMapping, connector.ThingMapping{
+		InstanceID: instanceId,
+		ThingID:    thingId,
+		ExternalID: connector.NormalizeExternalID(externalId),
+	})
+	return nil
+}
+
+// UpdateThingMappingExternalId replaces the external id of an existing thing mapping.
+// It returns connector.ErrorMappingNotFound if the thing is not mapped to the instance and
+// connector.ErrorMappingExists if another thing of the instance is already mapped to the external id.
+func (m *InMemoryDatabase) UpdateThingMappingExternalId(ctx context.Context, instanceId string, thingId string, newExternalId string) error {
+	if err := connector.ValidateExternalID(newExternalId); err != nil {
+		return err
+	}
+	externalId := connector.NormalizeExternalID(newExternalId)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	instance, ok := m.instances[instanceId]
+	if !ok {
+		return connector.ErrorMappingNotFound
+	}
+
+	index := -1
+	for i, mapping := range instance.thingMapping {
+		if mapping.ThingID == thingId {
+			index = i
+		} else if mapping.ExternalID == externalId {
+			return connector.ErrorMappingExists
+		}
+	}
+	if index < 0 {
+		return connector.ErrorMappingNotFound
+	}
+
+	instance.thingMapping[index].ExternalID = externalId
+	return nil
+}
+
+// RemoveThingMapping removes a thing mapping with given instance and thing id.
+// It does not return an error if the mapping does not exist.
+func (m *InMemoryDatabase) RemoveThingMapping(ctx context.Context, instanceID string, thingID string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	instance, ok := m.instances[instanceID]
+	if !ok {
+		return nil
+	}
+
+	thingMapping := instance.thingMapping[:0]
+	for _, mapping := range instance.thingMapping {
+		if mapping.ThingID != thingID {
+			thingMapping = append(thingMapping, mapping)
+		}
+	}
+	instance.thingMapping = thingMapping
+	return nil
+}
+
+// CountInstallations returns the number of stored installations.
+func (m *InMemoryDatabase) CountInstallations(ctx context.Context) (int, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return len(m.installations), nil
+}
+
+// CountInstances returns the number of stored instances.
+func (m *InMemoryDatabase) CountInstances(ctx context.Context) (int, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return len(m.instances), nil
+}
+
+// CountThingMappings returns the number of stored thing mappings.
+func (m *InMemoryDatabase) CountThingMappings(ctx context.Context) (int, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	count := 0
+	for _, instance := range m.instances {
+		count += len(instance.thingMapping)
+	}
+	return count, nil
+}
+
+// SetLastPropertyValue stores the value as the last known value of the property.
+// Like the DBClient it keeps the time of the last update with millisecond precision.
+// It returns connector.ErrorInstanceNotFound if the instance does not exist.
+func (m *InMemoryDatabase) SetLastPropertyValue(ctx context.Context, value connector.PropertyValue) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, ok := m.instances[value.InstanceID]; !ok {
+		return connector.ErrorInstanceNotFound
+	}
+
+	value.LastUpdate = time.UnixMilli(value.LastUpdate.UnixMilli())
+	m.propertyValues[propertyKey{value.InstanceID, value.ThingID, value.ComponentID, value.PropertyID}] = value
+	return nil
+}
+
+// GetLastPropertyValues returns the last known values of all properties of the given thing.
+func (m *InMemoryDatabase) GetLastPropertyValues(ctx context.Context, thingId string) ([]connector.PropertyValue, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	values := []connector.PropertyValue{}
+	for key, value := range m.propertyValues {
+		if key.thingID == thingId {
+			values = append(values, value)
+		}
+	}
+
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].ComponentID != values[j].ComponentID {
+			return values[i].ComponentID < values[j].ComponentID
+		}
+		return values[i].PropertyID < values[j].PropertyID
+	})
+	return values, nil
+}
+
+// SetPendingInstanceState stores a state that still has to be reported for the instance.
+// A previously stored state of the instance is replaced.
+// It returns connector.ErrorInstanceNotFound if the instance does not exist.
+func (m *InMemoryDatabase) SetPendingInstanceState(ctx context.Context, state connector.PendingInstanceState) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, ok := m.instances[state.InstanceID]; !ok {
+		return connector.ErrorInstanceNotFound
+	}
+
+	state.Details = append([]byte(nil), state.Details...)
+	m.pendingStates[state.InstanceID] = state
+	return nil
+}
+
+// GetPendingInstanceStates returns the pending states of all instances, ordered by instance id.
+func (m *InMemoryDatabase) GetPendingInstanceStates(ctx context.Context) ([]connector.PendingInstanceState, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	states := make([]connector.PendingInstanceState, 0, len(m.pendingStates))
+	for _, id := range m.instanceIds() {
+		state, ok := m.pendingStates[id]
+		if !ok {
+			continue
+		}
+		state.Details = append([]byte(nil), state.Details...)
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// GetPendingInstanceState returns the pending state of the given instance or nil if no state is pending.
+func (m *InMemoryDatabase) GetPendingInstanceState(ctx context.Context, instanceId string) (*connector.PendingInstanceState, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	state, ok := m.pendingStates[instanceId]
+	if !ok {
+		return nil, nil
+	}
+	state.Details = append([]byte(nil), state.Details...)
+	return &state, nil
+}
+
+// RemovePendingInstanceState removes the pending state of the instance once it was reported.
+// The stored state is only removed if it was not replaced in the meantime.
+func (m *InMemoryDatabase) RemovePendingInstanceState(ctx context.Context, state connector.PendingInstanceState) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if stored, ok := m.pendingStates[state.InstanceID]; ok && stored.State == state.State && bytes.Equal(stored.Details, state.Details) {
+		delete(m.pendingStates, state.InstanceID)
+	}
+	return nil
+}
+
+// installation returns a copy of the installation with the given id. The caller must hold the mutex.
+func (m *InMemoryDatabase) installation(id string) *connector.Installation {
+	installation := m.installations[id]
+	return &connector.Installation{
+		ID:            id,
+		Token:         installation.token,
+		Configuration: copyConfiguration(installation.configuration),
+	}
+}
+
+// instance returns a copy of the instance with the given id. The caller must hold the mutex.
+func (m *InMemoryDatabase) instance(id string) *connector.Instance {
+	instance := m.instances[id]
+	return &connector.Instance{
+		ID:             id,
+		InstallationID: instance.installationID,
+		Token:          instance.token,
+		ThingMapping:   copyThingMapping(instance.thingMapping),
+		Configuration:  copyConfiguration(instance.configuration),
+	}
+}
+
+// instanceIdByThingId returns the id of the instance the thing is mapped to. The caller must hold the mutex.
+func (m *InMemoryDatabase) instanceIdByThingId(thingId string) (string, bool) {
+	for _, id := range m.instanceIds() {
+		for _, mapping := range m.instances[id].thingMapping {
+			if mapping.ThingID == thingId {
+				return id, true
+			}
+		}
+	}
+	return "", false
+}
+
+// removeInstance removes the instance and all data referencing it. The caller must hold the mutex.
+func (m *InMemoryDatabase) removeInstance(instanceId string) {
+	for key := range m.propertyValues {
+		if key.instanceID == instanceId {
+			delete(m.propertyValues, key)
+		}
+	}
+	delete(m.pendingStates, instanceId)
+	delete(m.instances, instanceId)
+}
+
+// copyConfiguration returns a copy of the configuration, nil if it is empty like the configuration returned by the DBClient.
+func copyConfiguration(config []connector.Configuration) []connector.Configuration {
+	if len(config) == 0 {
+		return nil
+	}
+	return append([]connector.Configuration{}, config...)
+}
+
+// copyThingMapping returns a copy of the thing mapping, nil if it is empty like the mappings returned by the DBClient.
+func copyThingMapping(mapping []connector.ThingMapping) []connector.ThingMapping {
+	if len(mapping) == 0 {
+		return nil
+	}
+	return append([]connector.ThingMapping{}, mapping...)
+}
+
+// installationIds returns the ids of all installations in ascending order. The caller must hold the mutex.
+func (m *InMemoryDatabase) installationIds() []string {
+	ids := make([]string, 0, len(m.installations))
+	for id := range m.installations {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// instanceIds returns the ids of all instances in ascending order. The caller must hold the mutex.
+func (m *InMemoryDatabase) instanceIds() []string {
+	ids := make([]string, 0, len(m.instances))
+	for id := range m.instances {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,214 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Migration is a versioned change of the database layout.
+// Up applies the change and Down reverts it. Both may contain several statements separated by semicolons.
+// Changes which can't be expressed portably can override Up and Down for specific drivers.
+type Migration struct {
+	Version int
+	Up      string
+	Down    string
+
+	DriverUp   map[DBDriverName]string
+	DriverDown map[DBDriverName]string
+}
+
+// up returns the statements applying the migration with the given driver.
+func (m Migration) up(driver DBDriverName) string {
+	if up, ok := m.DriverUp[driver]; ok {
+		return up
+	}
+	return m.Up
+}
+
+// down returns the statements reverting the migration with the given driver.
+func (m Migration) down(driver DBDriverName) string {
+	if down, ok := m.DriverDown[driver]; ok {
+		return down
+	}
+	return m.Down
+}
+
+// ErrorUnversionedOptions is returned by MigrateUp if the client is configured with SoftDelete, TokenHashKey or JSONConfigStorage.
+// The columns needed by these options are not part of the versioned migrations, so such databases must be created with Migrate
+// or the connector has to append own migrations executing SoftDeleteMigrationQueries, TokenHashMigrationQueries or JSONConfigMigrationQueries.
+var ErrorUnversionedOptions = errors.New("soft delete, token hashes and JSON configuration storage are not supported by versioned migrations")
+
+// Migrations are applied by MigrateUp in the order of their versions.
+// Connectors may append their own migrations, as long as they use versions higher than the ones of the SDK.
+var Migrations = []Migration{
+	{Version: 1, Up: StatementCreateInstallationTable, Down: `DROP TABLE installations`},
+	{Version: 2, Up: StatementCreateInstanceTable, Down: `DROP TABLE instances`},
+	{Version: 3, Up: StatementCreateInstaceThingMapping + ";" + StatementCreateThingMappingUniqueIndex, Down: `DROP TABLE instance_thing_mapping`},
+	{Version: 4, Up: StatementCreateInstallConfigTable, Down: `DROP TABLE installation_configuration`},
+	{Version: 5, Up: StatementCreateInstanceConfigTable, Down: `DROP TABLE instance_configuration`},
+	{Version: 6, Up: StatementCreatePropertyValueTable, Down: `DROP TABLE property_values`},
+	{Version: 7, Up: StatementCreatePendingInstanceStateTable, Down: `DROP TABLE pending_instance_states`},
+	{
+		Version: 8,
+		Up: StatementCreateThingMappingThingIndex + ";" + StatementCreateThingMappingExternalIndex + ";" +
+			StatementCreateInstallConfigInstallIndex + ";" + StatementCreateInstanceConfigInstanceIndex,
+		Down: `DROP INDEX instance_thing_mapping_by_thing; DROP INDEX instance_thing_mapping_by_external_id;
+			DROP INDEX installation_configuration_by_installation; DROP INDEX instance_configuration_by_instance`,
+		// mysql and sqlserver only drop indexes of a given table
+		DriverDown: map[DBDriverName]string{
+			DriverMysql:     statementDropLookupIndexesOfTables,
+			DriverSqlserver: statementDropLookupIndexesOfTables,
+		},
+	},
+	{Version: 9, Up: StatementCreateInstanceSecretsTable, Down: `DROP TABLE instance_secrets`},
+	{
+		Version: 10,
+		Up:      StatementCreateInstanceConfigUniqueIndex,
+		Down:    `DROP INDEX instance_configuration_by_id`,
+		DriverDown: map[DBDriverName]string{
+			DriverMysql:     `DROP INDEX instance_configuration_by_id ON instance_configuration`,
+			DriverSqlserver: `DROP INDEX instance_configuration_by_id ON instance_configuration`,
+		},
+	},
+}
+
+const (
+	statementDropLookupIndexesOfTables = `DROP INDEX instance_thing_mapping_by_thing ON instance_thing_mapping; DROP INDEX instance_thing_mapping_by_external_id ON instance_thing_mapping;
+		DROP INDEX installation_configuration_by_installation ON installation_configuration; DROP INDEX instance_configuration_by_instance ON instance_configuration`
+
+	statementCreateSchemaMigrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INT NOT NULL,
+		applied_at BIGINT NOT NULL,
+		UNIQUE(version)
+	)`
+	// sqlserver does not support CREATE TABLE IF NOT EXISTS
+	statementCreateSchemaMigrationsTableSqlserver = `IF OBJECT_ID('schema_migrations', 'U') IS NULL CREATE TABLE schema_migrations (
+		version INT NOT NULL,
+		applied_at BIGINT NOT NULL,
+		UNIQUE(version)
+	)`
+	statementGetSchemaVersion      = `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`
+	statementInsertSchemaMigration = `INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`
+	statementRemoveSchemaMigration = `DELETE FROM schema_migrations WHERE version = ?`
+)
+
+// CurrentVersion returns the version of the latest applied migration or 0 if no migration was applied yet.
+func (m *DBClient) CurrentVersion(ctx context.Context) (int, error) {
+	createTable := statementCreateSchemaMigrationsTable
+	if m.driver == DriverSqlserver {
+		createTable = statementCreateSchemaMigrationsTableSqlserver
+	}
+	if _, err := m.DB.ExecContext(ctx, createTable); err != nil {
+		return 0, fmt.Errorf("failed to create schema migrations table: %w", err)
+	}
+
+	var version int
+	if err := m.DB.GetContext(ctx, &version, statementGetSchemaVersion); err != nil {
+		return 0, fmt.Errorf("failed to retrieve schema version: %w", err)
+	}
+	return version, nil
+}
+
+// MigrateUp applies all migrations with a version higher than the current one.
+// Each migration is applied in its own transaction together with its record in the schema_migrations table,
+// so calling MigrateUp on an up to date database does nothing.
+// Databases created with Migrate are not tracked and must not be migrated with MigrateUp.
+// It returns ErrorUnversionedOptions without applying anything if an option requires columns the migrations don't create.
+func (m *DBClient) MigrateUp(ctx context.Context) error {
+	if m.softDelete || m.tokenHashKey != nil || m.jsonConfig {
+		return ErrorUnversionedOptions
+	}
+
+	migrations, err := sortedMigrations()
+	if err != nil {
+		return err
+	}
+
+	current, err := m.CurrentVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range migrations {
+		if migration.Version <= current {
+			continue
+		}
+		if err := m.applyMigration(ctx, migration.Version, migration.up(m.driver), statementInsertSchemaMigration, migration.Version, time.Now().Unix()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MigrateDownTo reverts all applied migrations with a version higher than the given one, starting with the latest.
+// It fails without reverting anything else if a migration has no Down statement.
+func (m *DBClient) MigrateDownTo(ctx context.Context, version int) error {
+	migrations, err := sortedMigrations()
+	if err != nil {
+		return err
+	}
+
+	current, err := m.CurrentVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		migration := migrations[i]
+		if migration.Version <= version || migration.Version > current {
+			continue
+		}
+		down := migration.down(m.driver)
+		if down == "" {
+			return fmt.Errorf("migration %d can not be reverted", migration.Version)
+		}
+		if err := m.applyMigration(ctx, migration.Version, down, statementRemoveSchemaMigration, migration.Version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyMigration executes the statements of a migration and updates the schema_migrations table in one transaction.
+func (m *DBClient) applyMigration(ctx context.Context, version int, statements string, record string, args ...interface{}) error {
+	tx, err := m.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, q := range strings.Split(statements, ";") {
+		if strings.TrimSpace(q) == "" {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, q); err != nil {
+			return fmt.Errorf("failed to migrate db to version %d (query: %v): %w", version, q, err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, m.rebind(record), args...); err != nil {
+		return fmt.Errorf("failed to record migration %d: %w", version, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %d: %w", version, err)
+	}
+	return nil
+}
+
+// sortedMigrations returns the migrations ordered by version.
+func sortedMigrations() ([]Migration, error) {
+	migrations := append([]Migration{}, Migrations...)
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version == migrations[i-1].Version {
+			return nil, fmt.Errorf("duplicate migration version %d", migrations[i].Version)
+		}
+	}
+	return migrations, nil
+}
@@ -0,0 +1,122 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/connctd/connector-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionedMigrations(t *testing.T) {
+	ctx := context.Background()
+	client := openTestClient(t, DBOptions{})
+
+	version, err := client.CurrentVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, version)
+
+	require.NoError(t, client.MigrateUp(ctx))
+	require.NoError(t, client.MigrateUp(ctx))
+
+	version, err = client.CurrentVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, len(Migrations), version)
+
+	var applied int
+	require.NoError(t, client.DB.Get(&applied, `SELECT COUNT(*) FROM schema_migrations`))
+	assert.Equal(t, len(Migrations), applied)
+
+	require.NoError(t, client.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token"}))
+	require.NoError(t, client.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "token"}))
+	require.NoError(t, client.SetPendingInstanceState(ctx, connector.PendingInstanceState{InstanceID: "instance-1", State: connector.InstantiationStateComplete}))
+
+	// reverting removes the pending instance states table
+	require.NoError(t, client.MigrateDownTo(ctx, 6))
+	version, err = client.CurrentVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 6, version)
+
+	_, err = client.GetPendingInstanceStates(ctx)
+	assert.Error(t, err)
+
+	instances, err := client.CountInstances(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, instances)
+
+	require.NoError(t, client.MigrateUp(ctx))
+	states, err := client.GetPendingInstanceStates(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, states)
+
+	require.NoError(t, client.MigrateDownTo(ctx, 0))
+	version, err = client.CurrentVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, version)
+
+	_, err = client.CountInstallations(ctx)
+	assert.Error(t, err)
+}
+
+func TestMigrateDownWithoutDownStatement(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t, DBOptions{})
+
+	migrations := Migrations
+	t.Cleanup(func() { Migrations = migrations })
+	Migrations = []Migration{{Version: 1, Up: `CREATE TABLE custom (id INT)`}}
+
+	require.NoError(t, client.MigrateUp(ctx))
+	assert.Error(t, client.MigrateDownTo(ctx, 0))
+
+	version, err := client.CurrentVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, version)
+
+	Migrations = []Migration{{Version: 1}, {Version: 1}}
+	assert.Error(t, client.MigrateUp(ctx))
+}
+
+func TestMigrationsCreateIndexes(t *testing.T) {
+	ctx := context.Background()
+	migrated := newTestClient(t, DBOptions{})
+
+	versioned := openTestClient(t, DBOptions{})
+	require.NoError(t, versioned.MigrateUp(ctx))
+
+	indexes := []string{
+		"instance_thing_mapping_by_thing",
+		"instance_thing_mapping_by_external_id",
+		"installation_configuration_by_installation",
+		"instance_configuration_by_instance",
+		"instance_configuration_by_id",
+	}
+	for _, client := range []*DBClient{migrated, versioned} {
+		for _, index := range indexes {
+			var count int
+			require.NoError(t, client.DB.Get(&count, `SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = ?`, index))
+			assert.Equal(t, 1, count, index)
+		}
+	}
+
+	require.NoError(t, versioned.MigrateDownTo(ctx, 7))
+	for _, index := range indexes {
+		var count int
+		require.NoError(t, versioned.DB.Get(&count, `SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = ?`, index))
+		assert.Equal(t, 0, count, index)
+	}
+}
+
+func TestMigrateUpWithUnversionedOptions(t *testing.T) {
+	for name, options := range map[string]DBOptions{
+		"soft delete": {SoftDelete: true},
+		"token hash":  {TokenHashKey: []byte("key")},
+		"json config": {JSONConfigStorage: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			client := openTestClient(t, options)
+			assert.Equal(t, ErrorUnversionedOptions, client.MigrateUp(context.Background()))
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// Pinger is implemented by database handles that are able to verify their connection, e.g. DBClient.DB.
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// Ping verifies that the database and, if configured, the read replica are reachable, e.g. for a readiness probe.
+// Errors name the driver but not the DSN, so they can be exposed without leaking credentials.
+func (m *DBClient) Ping(ctx context.Context) error {
+	if err := m.DB.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to reach %s database: %w", m.driver, err)
+	}
+	if m.Replica != nil {
+		if err := m.Replica.PingContext(ctx); err != nil {
+			return fmt.Errorf("failed to reach %s replica: %w", m.driver, err)
+		}
+	}
+	return nil
+}
+
+// ConnectivityMonitor periodically pings the database and keeps track of its health.
+// State transitions are logged, so a dropped connection becomes visible before operations start to fail.
+// The connection pool of database/sql reestablishes broken connections on its own. Drivers or setups
+// that need manual intervention can set Reconnect, which is called whenever a ping fails.
+type ConnectivityMonitor struct {
+	// Reconnect is called after a failed ping if set.
+	Reconnect func(ctx context.Context) error
+
+	pinger   Pinger
+	interval time.Duration
+	logger   logr.Logger
+	state    atomic.Int32
+}
+
+// states of the connectivity monitor
+const (
+	stateUnknown int32 = iota
+	stateUp
+	stateDown
+)
+
+// NewConnectivityMonitor creates a new monitor pinging the database in the given interval.
+// The monitor reports an unhealthy database until the first successful check.
+func NewConnectivityMonitor(pinger Pinger, interval time.Duration, logger logr.Logger) *ConnectivityMonitor {
+	return &ConnectivityMonitor{
+		pinger:   pinger,
+		interval: interval,
+		logger:   logger.WithName("db-connectivity-monitor"),
+	}
+}
+
+// Run checks the connectivity immediately and then once per interval until the context is done.
+func (c *ConnectivityMonitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	c.Check(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.Check(ctx)
+		}
+	}
+}
+
+// Check pings the database once, updates the health state and returns it.
+func (c *ConnectivityMonitor) Check(ctx context.Context) bool {
+	pingCtx, cancel := context.WithTimeout(ctx, c.interval)
+	defer cancel()
+
+	err := c.pinger.PingContext(pingCtx)
+	if err != nil && c.Reconnect != nil {
+		if reconnectErr := c.Reconnect(ctx); reconnectErr != nil {
+			c.logger.Error(reconnectErr, "Failed to reconnect to database")
+		} else {
+			err = c.pingAfterReconnect(ctx)
+		}
+	}
+
+	state := stateUp
+	if err != nil {
+		state = stateDown
+	}
+
+	if c.state.Swap(state) != state {
+		if state == stateUp {
+			c.logger.Info("Database connection is up")
+		} else {
+			c.logger.Error(err, "Database connection is down")
+		}
+	}
+
+	return state == stateUp
+}
+
+// pingAfterReconnect pings the database with a timeout of its own, since a timed out first ping
+// has already used up the deadline of the initial ping context.
+func (c *ConnectivityMonitor) pingAfterReconnect(ctx context.Context) error {
+	pingCtx, cancel := context.WithTimeout(ctx, c.interval)
+	defer cancel()
+
+	return c.pinger.PingContext(pingCtx)
+}
+
+// Healthy reports whether the last check was successful.
+func (c *ConnectivityMonitor) Healthy() bool {
+	return c.state.Load() == stateUp
+}
@@ -0,0 +1,102 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakePinger struct {
+	errs []error
+}
+
+func (f *fakePinger) PingContext(ctx context.Context) error {
+	err := f.errs[0]
+	f.errs = f.errs[1:]
+	return err
+}
+
+func TestConnectivityMonitor(t *testing.T) {
+	var logs []string
+	logger := funcr.New(func(prefix, args string) {
+		logs = append(logs, args)
+	}, funcr.Options{})
+
+	pinger := &fakePinger{errs: []error{errors.New("connection refused"), errors.New("connection refused"), nil, nil}}
+	monitor := NewConnectivityMonitor(pinger, time.Second, logger)
+	assert.False(t, monitor.Healthy())
+
+	assert.False(t, monitor.Check(context.Background()))
+	assert.False(t, monitor.Healthy())
+	assert.False(t, monitor.Check(context.Background()))
+
+	assert.True(t, monitor.Check(context.Background()))
+	assert.True(t, monitor.Healthy())
+	assert.True(t, monitor.Check(context.Background()))
+
+	// only transitions are logged
+	if assert.Len(t, logs, 2) {
+		assert.Contains(t, logs[0], "Database connection is down")
+		assert.Contains(t, logs[1], "Database connection is up")
+	}
+}
+
+func TestConnectivityMonitorReconnect(t *testing.T) {
+	pinger := &fakePinger{errs: []error{errors.New("connection refused"), nil}}
+	monitor := NewConnectivityMonitor(pinger, time.Second, funcr.New(func(prefix, args string) {}, funcr.Options{}))
+
+	reconnected := false
+	monitor.Reconnect = func(ctx context.Context) error {
+		reconnected = true
+		return nil
+	}
+
+	assert.True(t, monitor.Check(context.Background()))
+	assert.True(t, reconnected)
+}
+
+// timeoutPinger times out the first ping and succeeds afterwards if the context is not done yet.
+type timeoutPinger struct {
+	pings int
+}
+
+func (f *timeoutPinger) PingContext(ctx context.Context) error {
+	f.pings++
+	if f.pings == 1 {
+		<-ctx.Done()
+	}
+	return ctx.Err()
+}
+
+func TestConnectivityMonitorReconnectAfterTimeout(t *testing.T) {
+	pinger := &timeoutPinger{}
+	monitor := NewConnectivityMonitor(pinger, 10*time.Millisecond, funcr.New(func(prefix, args string) {}, funcr.Options{}))
+	monitor.Reconnect = func(ctx context.Context) error {
+		return nil
+	}
+
+	assert.True(t, monitor.Check(context.Background()))
+	assert.Equal(t, 2, pinger.pings)
+}
+
+func TestPing(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t, DBOptions{})
+	assert.NoError(t, client.Ping(ctx))
+
+	closed, err := NewDBClient(&DBOptions{Driver: DriverSqlite3, DSN: "file:secret.db?mode=memory&_auth_pass=password"}, logr.Discard())
+	require.NoError(t, err)
+	require.NoError(t, closed.DB.Close())
+
+	err = closed.Ping(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "sqlite3")
+	assert.NotContains(t, err.Error(), "password")
+	assert.NotContains(t, err.Error(), "secret.db")
+}
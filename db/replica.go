@@ -0,0 +1,28 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type primaryContextKey struct{}
+
+// WithPrimary returns a context forcing reads of the DBClient to the primary database.
+// It can be used for read-after-write consistency if a read replica is configured, since replicas may lag behind.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryContextKey{}, true)
+}
+
+// reader returns the database used for reads, which is the replica unless none is configured or the context forces the primary.
+func (m *DBClient) reader(ctx context.Context) *sqlx.DB {
+	if m.Replica == nil {
+		return m.DB
+	}
+
+	if primary, _ := ctx.Value(primaryContextKey{}).(bool); primary {
+		return m.DB
+	}
+
+	return m.Replica
+}
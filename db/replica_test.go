@@ -0,0 +1,55 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/connctd/connector-go"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadReplica(t *testing.T) {
+	ctx := context.Background()
+
+	client, err := NewDBClient(&DBOptions{
+		Driver:     DriverSqlite3,
+		DSN:        "file:primary?mode=memory&cache=shared&_foreign_keys=on",
+		ReplicaDSN: "file:replica?mode=memory&cache=shared&_foreign_keys=on",
+	}, logr.Discard())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		client.DB.Close()
+		client.Replica.Close()
+	})
+
+	// both databases are separate, so each query shows which one it used
+	require.NoError(t, client.Migrate())
+	for _, q := range MigrationQueries {
+		_, err := client.Replica.Exec(q)
+		require.NoError(t, err)
+	}
+	_, err = client.Replica.Exec(statementInsertInstallation, "replicated", "token")
+	require.NoError(t, err)
+
+	// writes use the primary
+	require.NoError(t, client.AddInstallation(ctx, connector.InstallationRequest{ID: "written", Token: "token"}))
+
+	// reads use the replica
+	installations, err := client.GetInstallations(ctx)
+	require.NoError(t, err)
+	require.Len(t, installations, 1)
+	assert.Equal(t, "replicated", installations[0].ID)
+
+	// reads can be forced to the primary
+	installations, err = client.GetInstallations(WithPrimary(ctx))
+	require.NoError(t, err)
+	require.Len(t, installations, 1)
+	assert.Equal(t, "written", installations[0].ID)
+
+	// without replica all reads use the primary
+	primaryOnly := newTestClient(t, DBOptions{})
+	assert.Nil(t, primaryOnly.Replica)
+	assert.Equal(t, primaryOnly.DB, primaryOnly.reader(ctx))
+}
@@ -0,0 +1,89 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/connctd/connector-go"
+)
+
+// ErrorNoSecretCipher is returned when storing instance secrets without a configured TokenCipher.
+var ErrorNoSecretCipher = errors.New("instance secrets require a token cipher")
+
+const (
+	statementRemoveInstanceSecret = `DELETE FROM instance_secrets WHERE instance_id = ? AND id = ?`
+	statementInsertInstanceSecret = `INSERT INTO instance_secrets (instance_id, id, value) VALUES (?, ?, ?)`
+	statementGetInstanceSecrets   = `SELECT id, value FROM instance_secrets WHERE instance_id = ?`
+
+	statementSoftGetInstanceSecrets = `SELECT s.id AS id, s.value AS value FROM instance_secrets s, instances i WHERE s.instance_id = ? AND s.instance_id = i.id AND i.deleted_at IS NULL`
+)
+
+// AddInstanceSecrets stores the secrets of the instance encrypted with the TokenCipher,
+// replacing existing secrets with the same id. Secrets are kept apart from the configuration,
+// so they are neither returned by the configuration accessors nor logged with it.
+// It returns ErrorNoSecretCipher if no TokenCipher is configured and
+// connector.ErrorInstanceNotFound if the instance does not exist.
+func (m *DBClient) AddInstanceSecrets(ctx context.Context, instanceId string, secrets []connector.Secret) error {
+	ctx, cancel := m.writeContext(ctx)
+	defer cancel()
+
+	if m.tokenCipher == nil {
+		return ErrorNoSecretCipher
+	}
+
+	tx, err := m.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var instances int
+	if err := tx.GetContext(ctx, &instances, m.rebind(m.statement(statementCountInstancesByID, statementSoftCountInstancesByID)), instanceId); err != nil {
+		return fmt.Errorf("failed to retrieve instance: %w", err)
+	}
+	if instances == 0 {
+		return connector.ErrorInstanceNotFound
+	}
+
+	for _, secret := range secrets {
+		value, err := m.encryptToken(secret.Value)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, m.rebind(statementRemoveInstanceSecret), instanceId, secret.ID); err != nil {
+			return fmt.Errorf("failed to replace instance secret: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, m.rebind(statementInsertInstanceSecret), instanceId, secret.ID, value); err != nil {
+			return fmt.Errorf("failed to insert instance secret: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit instance secrets: %w", err)
+	}
+	return nil
+}
+
+// GetInstanceSecrets returns the decrypted secrets of the given instance id.
+// If no secrets were found it returns an empty slice.
+func (m *DBClient) GetInstanceSecrets(ctx context.Context, instanceId string) ([]connector.Secret, error) {
+	ctx, cancel := m.readContext(ctx)
+	defer cancel()
+
+	secrets := []connector.Secret{}
+	err := m.reader(ctx).SelectContext(ctx, &secrets, m.rebind(m.statement(statementGetInstanceSecrets, statementSoftGetInstanceSecrets)), instanceId)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to retrieve instance secrets: %w", err)
+	}
+
+	for i := range secrets {
+		value, err := m.decryptToken(secrets[i].Value)
+		if err != nil {
+			return nil, err
+		}
+		secrets[i].Value = value
+	}
+	return secrets, nil
+}
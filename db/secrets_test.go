@@ -0,0 +1,84 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/connctd/connector-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstanceSecrets(t *testing.T) {
+	implementations := map[string]func(t *testing.T) connector.Database{
+		"sql": func(t *testing.T) connector.Database {
+			tokenCipher, err := NewAESGCMCipher([]byte("0123456789abcdef"))
+			require.NoError(t, err)
+
+			client := newTestClient(t, DBOptions{TokenCipher: tokenCipher})
+			return client
+		},
+		"memory": func(t *testing.T) connector.Database { return NewInMemoryDatabase() },
+	}
+
+	for name, newDatabase := range implementations {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			database := newDatabase(t)
+
+			require.NoError(t, database.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token"}))
+			require.NoError(t, database.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "token"}))
+			require.NoError(t, database.AddInstanceConfiguration(ctx, "instance-1", []connector.Configuration{{ID: "host", Value: "example.com"}}))
+
+			secrets, err := database.GetInstanceSecrets(ctx, "instance-1")
+			require.NoError(t, err)
+			assert.Equal(t, []connector.Secret{}, secrets)
+
+			require.NoError(t, database.AddInstanceSecrets(ctx, "instance-1", []connector.Secret{{ID: "access-token", Value: "access"}, {ID: "refresh-token", Value: "refresh"}}))
+			require.NoError(t, database.AddInstanceSecrets(ctx, "instance-1", []connector.Secret{{ID: "access-token", Value: "renewed"}}))
+			assert.Equal(t, connector.ErrorInstanceNotFound, database.AddInstanceSecrets(ctx, "unknown", []connector.Secret{{ID: "access-token", Value: "access"}}))
+
+			secrets, err = database.GetInstanceSecrets(ctx, "instance-1")
+			require.NoError(t, err)
+			assert.ElementsMatch(t, []connector.Secret{{ID: "access-token", Value: "renewed"}, {ID: "refresh-token", Value: "refresh"}}, secrets)
+
+			// secrets are not part of the configuration
+			config, err := database.GetInstanceConfiguration(ctx, "instance-1")
+			require.NoError(t, err)
+			assert.Equal(t, []connector.Configuration{{ID: "host", Value: "example.com"}}, config)
+
+			instance, err := database.GetInstance(ctx, "instance-1")
+			require.NoError(t, err)
+			assert.Equal(t, config, instance.Configuration)
+
+			require.NoError(t, database.RemoveInstance(ctx, "instance-1"))
+			secrets, err = database.GetInstanceSecrets(ctx, "instance-1")
+			require.NoError(t, err)
+			assert.Equal(t, []connector.Secret{}, secrets)
+		})
+	}
+}
+
+func TestInstanceSecretsAreEncrypted(t *testing.T) {
+	ctx := context.Background()
+	tokenCipher, err := NewAESGCMCipher([]byte("0123456789abcdef"))
+	require.NoError(t, err)
+
+	client := newTestClient(t, DBOptions{TokenCipher: tokenCipher})
+
+	require.NoError(t, client.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token"}))
+	require.NoError(t, client.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "token"}))
+	require.NoError(t, client.AddInstanceSecrets(ctx, "instance-1", []connector.Secret{{ID: "access-token", Value: "access"}}))
+
+	var stored string
+	require.NoError(t, client.DB.Get(&stored, `SELECT value FROM instance_secrets WHERE instance_id = ?`, "instance-1"))
+	assert.True(t, strings.HasPrefix(stored, encryptedTokenPrefix))
+	assert.NotContains(t, stored, "access")
+
+	// secrets are never stored in plaintext
+	plain := newTestClient(t, DBOptions{})
+	require.NoError(t, plain.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token"}))
+	require.NoError(t, plain.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "token"}))
+	assert.Equal(t, ErrorNoSecretCipher, plain.AddInstanceSecrets(ctx, "instance-1", []connector.Secret{{ID: "access-token", Value: "access"}}))
+}
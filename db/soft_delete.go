@@ -0,0 +1,162 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/connctd/connector-go"
+)
+
+// ErrorSoftDeleteDisabled is returned by methods that require soft delete to be enabled.
+var ErrorSoftDeleteDisabled = errors.New("soft delete is not enabled")
+
+// softRemoveInstallation marks the installation and all of its instances as deleted.
+// All rows share the same deletion time, so RestoreInstallation can restore exactly the instances removed with the installation.
+func (m *DBClient) softRemoveInstallation(ctx context.Context, installationId string) error {
+	tx, err := m.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	deletedAt := time.Now().UnixMilli()
+	result, err := tx.ExecContext(ctx, m.rebind(statementSoftRemoveInstallationById), deletedAt, installationId)
+	if err != nil {
+		return fmt.Errorf("failed to remove installation: %w", err)
+	}
+	if err := removedRows(result, connector.ErrorInstallationNotFound); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, m.rebind(statementSoftRemoveInstancesByInstallationId), deletedAt, installationId); err != nil {
+		return fmt.Errorf("failed to remove instances of installation: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit installation removal: %w", err)
+	}
+
+	return nil
+}
+
+// RestoreInstallation restores a soft deleted installation together with the instances removed with it.
+// It returns connector.ErrorInstallationNotFound if there is no deleted installation with the given id.
+func (m *DBClient) RestoreInstallation(ctx context.Context, installationId string) error {
+	ctx, cancel := m.writeContext(ctx)
+	defer cancel()
+
+	if !m.softDelete {
+		return ErrorSoftDeleteDisabled
+	}
+
+	tx, err := m.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var deletedAt int64
+	if err := tx.GetContext(ctx, &deletedAt, m.rebind(statementGetInstallationDeletedAt), installationId); err != nil {
+		if err == sql.ErrNoRows {
+			return connector.ErrorInstallationNotFound
+		}
+		return fmt.Errorf("failed to retrieve installation: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, m.rebind(statementRestoreInstallationById), installationId, deletedAt); err != nil {
+		return fmt.Errorf("failed to restore installation: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, m.rebind(statementRestoreInstancesByInstallationId), installationId, deletedAt); err != nil {
+		return fmt.Errorf("failed to restore instances of installation: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit installation restore: %w", err)
+	}
+
+	return nil
+}
+
+// RestoreInstance restores a soft deleted instance.
+// It returns connector.ErrorInstanceNotFound if there is no deleted instance with the given id.
+// Note that instances of deleted installations should be restored with RestoreInstallation.
+func (m *DBClient) RestoreInstance(ctx context.Context, instanceId string) error {
+	ctx, cancel := m.writeContext(ctx)
+	defer cancel()
+
+	if !m.softDelete {
+		return ErrorSoftDeleteDisabled
+	}
+
+	result, err := m.DB.ExecContext(ctx, m.rebind(statementRestoreInstanceById), instanceId)
+	if err != nil {
+		return fmt.Errorf("failed to restore instance: %w", err)
+	}
+
+	restored, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to restore instance: %w", err)
+	}
+
+	if restored == 0 {
+		return connector.ErrorInstanceNotFound
+	}
+
+	return nil
+}
+
+// PurgeDeleted finally removes all installations and instances that were soft deleted before the given time.
+// Their configuration parameters, thing mappings, property values, pending states and secrets are removed explicitly
+// like in RemoveInstance, since foreign keys are not enforced by all databases.
+func (m *DBClient) PurgeDeleted(ctx context.Context, olderThan time.Time) error {
+	ctx, cancel := m.writeContext(ctx)
+	defer cancel()
+
+	if !m.softDelete {
+		return ErrorSoftDeleteDisabled
+	}
+
+	tx, err := m.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var instanceIds []string
+	if err := tx.SelectContext(ctx, &instanceIds, m.rebind(statementGetPurgeableInstanceIDs), olderThan.UnixMilli()); err != nil {
+		return fmt.Errorf("failed to retrieve deleted instances: %w", err)
+	}
+	for _, instanceId := range instanceIds {
+		if err := m.removeInstanceData(ctx, tx, instanceId); err != nil {
+			return err
+		}
+	}
+
+	var installationIds []string
+	if err := tx.SelectContext(ctx, &installationIds, m.rebind(statementGetPurgeableInstallationIDs), olderThan.UnixMilli()); err != nil {
+		return fmt.Errorf("failed to retrieve deleted installations: %w", err)
+	}
+	for _, installationId := range installationIds {
+		if _, err := tx.ExecContext(ctx, m.rebind(statementRemoveInstallationConfig), installationId); err != nil {
+			return fmt.Errorf("failed to remove installation configuration: %w", err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, m.rebind(statementPurgeInstances), olderThan.UnixMilli()); err != nil {
+		return fmt.Errorf("failed to purge instances: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, m.rebind(statementPurgeInstallations), olderThan.UnixMilli()); err != nil {
+		return fmt.Errorf("failed to purge installations: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit purge: %w", err)
+	}
+
+	return nil
+}
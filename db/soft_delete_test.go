@@ -0,0 +1,151 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/connctd/connector-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSoftDelete(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t, DBOptions{SoftDelete: true})
+
+	require.NoError(t, client.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token"}))
+	require.NoError(t, client.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-2", Token: "token"}))
+	require.NoError(t, client.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "token"}))
+	require.NoError(t, client.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-2", InstallationID: "installation-2", Token: "token"}))
+	require.NoError(t, client.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-3", InstallationID: "installation-2", Token: "token"}))
+	require.NoError(t, client.AddThingMapping(ctx, "instance-1", "thing-1", "external-1"))
+
+	assertCounts := func(installations, instances int) {
+		t.Helper()
+		count, err := client.CountInstallations(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, installations, count)
+
+		count, err = client.CountInstances(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, instances, count)
+	}
+
+	// removing an installation hides it and its instances
+	require.NoError(t, client.RemoveInstallation(ctx, "installation-1"))
+	assertCounts(1, 2)
+	assert.Equal(t, connector.ErrorInstallationNotFound, client.RemoveInstallation(ctx, "installation-1"))
+
+	installations, err := client.GetInstallations(ctx)
+	require.NoError(t, err)
+	require.Len(t, installations, 1)
+	assert.Equal(t, "installation-2", installations[0].ID)
+
+	_, err = client.GetInstance(ctx, "instance-1")
+	assert.Error(t, err)
+	_, err = client.GetInstanceByThingId(ctx, "thing-1")
+	assert.Error(t, err)
+
+	err = client.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-4", InstallationID: "installation-1", Token: "token"})
+	assert.Equal(t, connector.ErrorUnknownInstallation, err)
+
+	// removing an instance hides it
+	require.NoError(t, client.RemoveInstance(ctx, "instance-2"))
+	assertCounts(1, 1)
+	assert.Equal(t, connector.ErrorInstanceNotFound, client.RemoveInstance(ctx, "instance-2"))
+
+	instances, err := client.GetInstances(ctx)
+	require.NoError(t, err)
+	require.Len(t, instances, 1)
+	assert.Equal(t, "instance-3", instances[0].ID)
+
+	// removed rows can be restored before they are purged
+	require.NoError(t, client.RestoreInstallation(ctx, "installation-1"))
+	require.NoError(t, client.RestoreInstance(ctx, "instance-2"))
+	assertCounts(2, 3)
+
+	instance, err := client.GetInstanceByThingId(ctx, "thing-1")
+	require.NoError(t, err)
+	assert.Equal(t, "instance-1", instance.ID)
+
+	assert.Equal(t, connector.ErrorInstallationNotFound, client.RestoreInstallation(ctx, "installation-1"))
+	assert.Equal(t, connector.ErrorInstanceNotFound, client.RestoreInstance(ctx, "instance-2"))
+
+	// purging only removes rows deleted before the given time
+	require.NoError(t, client.RemoveInstallation(ctx, "installation-1"))
+	require.NoError(t, client.PurgeDeleted(ctx, time.Now().Add(-time.Hour)))
+	require.NoError(t, client.RestoreInstallation(ctx, "installation-1"))
+
+	require.NoError(t, client.RemoveInstallation(ctx, "installation-1"))
+	require.NoError(t, client.PurgeDeleted(ctx, time.Now().Add(time.Hour)))
+	assert.Equal(t, connector.ErrorInstallationNotFound, client.RestoreInstallation(ctx, "installation-1"))
+
+	mappings, err := client.CountThingMappings(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, mappings)
+}
+
+func TestSoftDeletedInstanceData(t *testing.T) {
+	ctx := context.Background()
+	tokenCipher, err := NewAESGCMCipher([]byte("0123456789abcdef"))
+	require.NoError(t, err)
+	// foreign keys are disabled, so purging has to remove the rows of the instance explicitly
+	client := newTestClient(t, DBOptions{DSN: "file::memory:", SoftDelete: true, TokenCipher: tokenCipher})
+
+	require.NoError(t, client.AddInstallationWithConfiguration(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token", Configuration: []connector.Configuration{{ID: "host", Value: "example.com"}}}))
+	require.NoError(t, client.AddInstanceWithConfiguration(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "token", Configuration: []connector.Configuration{{ID: "room", Value: "kitchen"}}}))
+	require.NoError(t, client.AddThingMapping(ctx, "instance-1", "thing-1", "external-1"))
+	require.NoError(t, client.SetLastPropertyValue(ctx, connector.PropertyValue{InstanceID: "instance-1", ThingID: "thing-1", ComponentID: "light", PropertyID: "on", Value: "true"}))
+	require.NoError(t, client.SetPendingInstanceState(ctx, connector.PendingInstanceState{InstanceID: "instance-1", State: connector.InstantiationStateComplete}))
+	require.NoError(t, client.AddInstanceSecrets(ctx, "instance-1", []connector.Secret{{ID: "password", Value: "secret"}}))
+
+	// the data of a removed instance is hidden
+	require.NoError(t, client.RemoveInstance(ctx, "instance-1"))
+
+	mappings, err := client.GetMappingByInstanceId(ctx, "instance-1")
+	require.NoError(t, err)
+	assert.Empty(t, mappings)
+	mapping, err := client.GetMappingByExternalId(ctx, "instance-1", "external-1")
+	require.NoError(t, err)
+	assert.Empty(t, mapping.ThingID)
+	config, err := client.GetInstanceConfiguration(ctx, "instance-1")
+	require.NoError(t, err)
+	assert.Empty(t, config)
+	configs, err := client.GetInstanceConfigurations(ctx, []string{"instance-1"})
+	require.NoError(t, err)
+	assert.Empty(t, configs["instance-1"])
+	values, err := client.GetLastPropertyValues(ctx, "thing-1")
+	require.NoError(t, err)
+	assert.Empty(t, values)
+	secrets, err := client.GetInstanceSecrets(ctx, "instance-1")
+	require.NoError(t, err)
+	assert.Empty(t, secrets)
+
+	// and visible again after restoring it
+	require.NoError(t, client.RestoreInstance(ctx, "instance-1"))
+	mappings, err = client.GetMappingByInstanceId(ctx, "instance-1")
+	require.NoError(t, err)
+	assert.Len(t, mappings, 1)
+	secrets, err = client.GetInstanceSecrets(ctx, "instance-1")
+	require.NoError(t, err)
+	assert.Len(t, secrets, 1)
+
+	// purging removes all rows referencing the instance and the installation
+	require.NoError(t, client.RemoveInstallation(ctx, "installation-1"))
+	require.NoError(t, client.PurgeDeleted(ctx, time.Now().Add(time.Hour)))
+	for _, table := range []string{"installation_configuration", "instance_configuration", "instance_thing_mapping", "property_values", "pending_instance_states", "instance_secrets"} {
+		var count int
+		require.NoError(t, client.DB.Get(&count, `SELECT COUNT(*) FROM `+table))
+		assert.Equal(t, 0, count, table)
+	}
+}
+
+func TestSoftDeleteDisabled(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t, DBOptions{})
+
+	assert.Equal(t, ErrorSoftDeleteDisabled, client.RestoreInstallation(ctx, "installation-1"))
+	assert.Equal(t, ErrorSoftDeleteDisabled, client.RestoreInstance(ctx, "instance-1"))
+	assert.Equal(t, ErrorSoftDeleteDisabled, client.PurgeDeleted(ctx, time.Now()))
+}
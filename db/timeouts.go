@@ -0,0 +1,45 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// QueryTimeouts bound the duration of DBClient methods by the kind of operation.
+// Each timeout is applied to the context passed to the method, so an earlier deadline of the caller still applies.
+// Zero values disable the timeout of the operation kind.
+type QueryTimeouts struct {
+	// ReadTimeout bounds methods reading single installations, instances or their details, e.g. GetInstance.
+	// These are used while handling requests and actions, so a tight timeout keeps a slow query from stalling them.
+	ReadTimeout time.Duration
+
+	// WriteTimeout bounds methods changing the database, including their transactions.
+	WriteTimeout time.Duration
+
+	// BulkReadTimeout bounds methods loading many installations or instances at once, e.g. GetInstances on startup.
+	// The ReadTimeout still applies to each of the queries they are composed of.
+	BulkReadTimeout time.Duration
+}
+
+// readContext applies the ReadTimeout to the context of a read operation.
+func (m *DBClient) readContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, m.queryTimeouts.ReadTimeout)
+}
+
+// writeContext applies the WriteTimeout to the context of a write operation.
+func (m *DBClient) writeContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, m.queryTimeouts.WriteTimeout)
+}
+
+// bulkReadContext applies the BulkReadTimeout to the context of a bulk read operation.
+func (m *DBClient) bulkReadContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, m.queryTimeouts.BulkReadTimeout)
+}
+
+// withTimeout returns the context unchanged if the timeout is not positive.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
@@ -0,0 +1,58 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/connctd/connector-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryTimeouts(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t, DBOptions{})
+	client.queryTimeouts = QueryTimeouts{ReadTimeout: 50 * time.Millisecond, WriteTimeout: 50 * time.Millisecond}
+
+	require.NoError(t, client.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token"}))
+
+	// the only connection is held by another transaction, so the write has to wait for it
+	tx, err := client.DB.BeginTx(ctx, nil)
+	require.NoError(t, err)
+
+	start := time.Now()
+	err = client.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-2", Token: "token-2"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+
+	_, err = client.GetInstallationConfiguration(ctx, "installation-1")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	require.NoError(t, tx.Rollback())
+
+	// fast operations are not affected
+	require.NoError(t, client.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-2", Token: "token-2"}))
+	installations, err := client.GetInstallations(ctx)
+	require.NoError(t, err)
+	assert.Len(t, installations, 2)
+}
+
+func TestBulkReadTimeout(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t, DBOptions{})
+	client.queryTimeouts = QueryTimeouts{BulkReadTimeout: 50 * time.Millisecond}
+
+	require.NoError(t, client.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token"}))
+
+	tx, err := client.DB.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	_, err = client.GetInstances(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	require.NoError(t, tx.Rollback())
+
+	// single reads are not bounded by the bulk read timeout
+	installation, err := client.GetInstallationByToken(ctx, "token")
+	require.NoError(t, err)
+	assert.Equal(t, "installation-1", installation.ID)
+}
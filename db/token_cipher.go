@@ -0,0 +1,127 @@
+package db
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/connctd/connector-go"
+)
+
+// encryptedTokenPrefix marks encrypted and base64 encoded tokens.
+const encryptedTokenPrefix = "enc:"
+
+var (
+	// ErrorNoTokenCipher is returned when reading an encrypted token without a configured TokenCipher.
+	ErrorNoTokenCipher = errors.New("token is encrypted but no token cipher is configured")
+	// ErrorInvalidCiphertext is returned if an encrypted token is too short to be decrypted.
+	ErrorInvalidCiphertext = errors.New("invalid ciphertext")
+)
+
+// TokenCipher encrypts installation and instance tokens before they are stored.
+type TokenCipher interface {
+	Encrypt(plaintext []byte) ([]byte, error)
+	Decrypt(ciphertext []byte) ([]byte, error)
+}
+
+// AESGCMCipher is a TokenCipher using AES in Galois/Counter Mode.
+// Every token is sealed with a random nonce, which is stored in front of the ciphertext.
+type AESGCMCipher struct {
+	aead cipher.AEAD
+}
+
+// NewAESGCMCipher creates a new AES-GCM cipher. The key must be 16, 24 or 32 bytes long
+// to select AES-128, AES-192 or AES-256.
+func NewAESGCMCipher(key []byte) (*AESGCMCipher, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token cipher: %w", err)
+	}
+
+	return &AESGCMCipher{aead: aead}, nil
+}
+
+// Encrypt implements TokenCipher.
+func (c *AESGCMCipher) Encrypt(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize(), c.aead.NonceSize()+len(plaintext)+c.aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return c.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Decrypt implements TokenCipher.
+func (c *AESGCMCipher) Decrypt(ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < c.aead.NonceSize() {
+		return nil, ErrorInvalidCiphertext
+	}
+	nonce, sealed := ciphertext[:c.aead.NonceSize()], ciphertext[c.aead.NonceSize():]
+	return c.aead.Open(nil, nonce, sealed, nil)
+}
+
+// encryptToken encrypts the token if a token cipher is configured.
+func (m *DBClient) encryptToken(token string) (string, error) {
+	if m.tokenCipher == nil {
+		return token, nil
+	}
+
+	ciphertext, err := m.tokenCipher.Encrypt([]byte(token))
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt token: %w", err)
+	}
+	return encryptedTokenPrefix + base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// decryptToken returns the plaintext of a stored token.
+// Tokens without marker, e.g. ones stored before encryption was enabled, are returned unchanged.
+// This also applies to plaintext tokens that happen to start with the marker.
+func (m *DBClient) decryptToken(stored string) (string, error) {
+	if !strings.HasPrefix(stored, encryptedTokenPrefix) {
+		return stored, nil
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, encryptedTokenPrefix))
+	if err != nil {
+		return stored, nil
+	}
+
+	if m.tokenCipher == nil {
+		return "", ErrorNoTokenCipher
+	}
+
+	plaintext, err := m.tokenCipher.Decrypt(ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt token: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// decryptInstanceToken replaces the stored token of the instance with its plaintext.
+func (m *DBClient) decryptInstanceToken(instance *connector.Instance) error {
+	token, err := m.decryptToken(string(instance.Token))
+	if err != nil {
+		return err
+	}
+	instance.Token = connector.InstantiationToken(token)
+	return nil
+}
+
+// decryptInstallationToken replaces the stored token of the installation with its plaintext.
+func (m *DBClient) decryptInstallationToken(installation *connector.Installation) error {
+	token, err := m.decryptToken(string(installation.Token))
+	if err != nil {
+		return err
+	}
+	installation.Token = connector.InstallationToken(token)
+	return nil
+}
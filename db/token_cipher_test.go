@@ -0,0 +1,116 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/connctd/connector-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenCipher(t *testing.T) {
+	ctx := context.Background()
+	tokenCipher, err := NewAESGCMCipher([]byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, err)
+
+	client := newTestClient(t, DBOptions{TokenCipher: tokenCipher, TokenHashKey: []byte("key")})
+
+	require.NoError(t, client.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "installation-token"}))
+	require.NoError(t, client.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "instance-token"}))
+	require.NoError(t, client.AddThingMapping(ctx, "instance-1", "thing-1", "external-1"))
+
+	// tokens are stored encrypted
+	var stored string
+	require.NoError(t, client.DB.Get(&stored, `SELECT token FROM instances WHERE id = ?`, "instance-1"))
+	assert.True(t, strings.HasPrefix(stored, encryptedTokenPrefix))
+	assert.NotContains(t, stored, "instance-token")
+
+	require.NoError(t, client.DB.Get(&stored, `SELECT token FROM installations WHERE id = ?`, "installation-1"))
+	assert.True(t, strings.HasPrefix(stored, encryptedTokenPrefix))
+
+	// legacy rows stored without encryption can still be read
+	_, err = client.DB.Exec(statementInsertInstance, "instance-2", "installation-1", "plain-token")
+	require.NoError(t, err)
+
+	instance, err := client.GetInstance(ctx, "instance-1")
+	require.NoError(t, err)
+	assert.Equal(t, connector.InstantiationToken("instance-token"), instance.Token)
+
+	instance, err = client.GetInstanceByThingId(ctx, "thing-1")
+	require.NoError(t, err)
+	assert.Equal(t, connector.InstantiationToken("instance-token"), instance.Token)
+
+	byThing, err := client.GetInstancesByThingIds(ctx, []string{"thing-1"})
+	require.NoError(t, err)
+	assert.Equal(t, connector.InstantiationToken("instance-token"), byThing["thing-1"].Token)
+
+	instances, err := client.GetInstances(ctx)
+	require.NoError(t, err)
+	tokens := []connector.InstantiationToken{}
+	for _, instance := range instances {
+		tokens = append(tokens, instance.Token)
+	}
+	assert.ElementsMatch(t, []connector.InstantiationToken{"instance-token", "plain-token"}, tokens)
+
+	installations, err := client.GetInstallations(ctx)
+	require.NoError(t, err)
+	require.Len(t, installations, 1)
+	assert.Equal(t, connector.InstallationToken("installation-token"), installations[0].Token)
+
+	installation, err := client.GetInstallationByToken(ctx, "installation-token")
+	require.NoError(t, err)
+	assert.Equal(t, connector.InstallationToken("installation-token"), installation.Token)
+
+	require.NoError(t, client.UpdateInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "new-token"}))
+	installation, err = client.GetInstallationByToken(ctx, "new-token")
+	require.NoError(t, err)
+	assert.Equal(t, connector.InstallationToken("new-token"), installation.Token)
+
+	// encrypted tokens can't be read without the cipher
+	client.tokenCipher = nil
+	_, err = client.GetInstance(ctx, "instance-1")
+	assert.Equal(t, ErrorNoTokenCipher, err)
+}
+
+func TestWithoutTokenCipher(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t, DBOptions{})
+
+	require.NoError(t, client.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "installation-token"}))
+	require.NoError(t, client.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "instance-token"}))
+
+	var stored string
+	require.NoError(t, client.DB.Get(&stored, `SELECT token FROM instances WHERE id = ?`, "instance-1"))
+	assert.Equal(t, "instance-token", stored)
+
+	instance, err := client.GetInstance(ctx, "instance-1")
+	require.NoError(t, err)
+	assert.Equal(t, connector.InstantiationToken("instance-token"), instance.Token)
+}
+
+func TestAESGCMCipher(t *testing.T) {
+	_, err := NewAESGCMCipher([]byte("short"))
+	assert.Error(t, err)
+
+	tokenCipher, err := NewAESGCMCipher([]byte("0123456789abcdef"))
+	require.NoError(t, err)
+
+	first, err := tokenCipher.Encrypt([]byte("token"))
+	require.NoError(t, err)
+	second, err := tokenCipher.Encrypt([]byte("token"))
+	require.NoError(t, err)
+	assert.NotEqual(t, first, second)
+
+	plaintext, err := tokenCipher.Decrypt(first)
+	require.NoError(t, err)
+	assert.Equal(t, "token", string(plaintext))
+
+	first[len(first)-1] ^= 0xff
+	_, err = tokenCipher.Decrypt(first)
+	assert.Error(t, err)
+
+	_, err = tokenCipher.Decrypt([]byte("x"))
+	assert.Equal(t, ErrorInvalidCiphertext, err)
+}
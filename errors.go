@@ -2,6 +2,7 @@ package connector
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 )
 
@@ -9,17 +10,25 @@ import (
 // The ConnectorHandler expects errors of the type connector.Error and will set the status code accordingly.
 // Developers can define new errors using connector.NewError but this should not be necessary for the connector protocol.
 var (
-	ErrorBadContentType        = NewError("BAD_CONTENT_TYPE", "Expected content type to be application/json", http.StatusBadRequest)
-	ErrorMissingInstanceID     = NewError("MISSING_INSTANCE_ID", "Instance ID is missing", http.StatusBadRequest)
-	ErrorMissingInstallationID = NewError("MISSING_INSTALLATION_ID", "Installation ID is missing", http.StatusBadRequest)
-	ErrorBadRequestBody        = NewError("BAD_REQUEST_BODY", "Empty or malformed request body", http.StatusBadRequest)
-	ErrorInvalidJsonBody       = NewError("INVALID_JSON_BODY", "Request body does not contain valid json", http.StatusBadRequest)
-	ErrorInstallationNotFound  = NewError("INSTALLATION_NOT_FOUND", "Installation not found", http.StatusNotFound)
-	ErrorInstanceNotFound      = NewError("INSTANCE_NOT_FOUND", "Instance not found", http.StatusNotFound)
-	ErrorForbidden             = NewError("FORBIDDEN", "Insufficient rights", http.StatusForbidden)
-	ErrorUnauthorized          = NewError("NOT_AUTHORIZED", "Not authorized", http.StatusUnauthorized)
-	ErrorInternal              = NewError("INTERNAL_SERVER_ERROR", "Internal server error", http.StatusInternalServerError)
-	ErrorMappingNotFound       = NewError("MAPPING_NOT_FOUND", "Mapping not found", http.StatusNotFound)
+	ErrorBadContentType            = NewError("BAD_CONTENT_TYPE", "Expected content type to be application/json", http.StatusBadRequest)
+	ErrorMissingInstanceID         = NewError("MISSING_INSTANCE_ID", "Instance ID is missing", http.StatusBadRequest)
+	ErrorMissingInstallationID     = NewError("MISSING_INSTALLATION_ID", "Installation ID is missing", http.StatusBadRequest)
+	ErrorInstallationIDMismatch    = NewError("INSTALLATION_ID_MISMATCH", "Installation ID of the request does not match the path", http.StatusBadRequest)
+	ErrorBadRequestBody            = NewError("BAD_REQUEST_BODY", "Empty or malformed request body", http.StatusBadRequest)
+	ErrorInvalidJsonBody           = NewError("INVALID_JSON_BODY", "Request body does not contain valid json", http.StatusBadRequest)
+	ErrorInstallationNotFound      = NewError("INSTALLATION_NOT_FOUND", "Installation not found", http.StatusNotFound)
+	ErrorInstanceNotFound          = NewError("INSTANCE_NOT_FOUND", "Instance not found", http.StatusNotFound)
+	ErrorForbidden                 = NewError("FORBIDDEN", "Insufficient rights", http.StatusForbidden)
+	ErrorUnauthorized              = NewError("NOT_AUTHORIZED", "Not authorized", http.StatusUnauthorized)
+	ErrorInternal                  = NewError("INTERNAL_SERVER_ERROR", "Internal server error", http.StatusInternalServerError)
+	ErrorMappingNotFound           = NewError("MAPPING_NOT_FOUND", "Mapping not found", http.StatusNotFound)
+	ErrorMappingExists             = NewError("MAPPING_EXISTS", "Mapping already exists", http.StatusConflict)
+	ErrorThingNotFound             = NewError("THING_NOT_FOUND", "Thing not found", http.StatusNotFound)
+	ErrorUnknownInstallation       = NewError("UNKNOWN_INSTALLATION", "Instance references an unknown installation", http.StatusBadRequest)
+	ErrorMissingActionParameter    = NewError("MISSING_ACTION_PARAMETER", "Required action parameter is missing", http.StatusBadRequest)
+	ErrorInvalidActionParameter    = NewError("INVALID_ACTION_PARAMETER", "Action parameter has an invalid value", http.StatusBadRequest)
+	ErrorUndeclaredActionParameter = NewError("UNDECLARED_ACTION_PARAMETER", "Action parameter is not declared by the action", http.StatusBadRequest)
+	ErrorUnexpectedState           = NewError("UNEXPECTED_STATE", "Only requests in the initialized state are accepted", http.StatusConflict)
 )
 
 // NewError constructs an error
@@ -55,3 +64,96 @@ func (e *Error) Write(w http.ResponseWriter) {
 
 	w.Write(b)
 }
+
+// ErrorKind tells the connctd platform whether a failed request should be retried.
+type ErrorKind int
+
+// Kinds of errors:
+const (
+	// ErrorKindUnknown is the kind of errors that are neither marked retryable nor permanent.
+	// The status code is taken from the connector.Error, all other errors result in an internal server error.
+	ErrorKindUnknown ErrorKind = iota
+	// ErrorKindRetryable marks transient failures, e.g. an unavailable database. They are answered with a 5xx status code.
+	ErrorKindRetryable
+	// ErrorKindPermanent marks failures that will not be resolved by retrying, e.g. invalid requests. They are answered with a 4xx status code.
+	ErrorKindPermanent
+)
+
+// kindError attaches an ErrorKind to an error.
+type kindError struct {
+	err  error
+	kind ErrorKind
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+func (e *kindError) Unwrap() error {
+	return e.err
+}
+
+// Retryable marks the error as transient, so the ConnectorHandler answers it with a 5xx status code and the platform retries the request.
+func Retryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &kindError{err: err, kind: ErrorKindRetryable}
+}
+
+// Permanent marks the error as permanent, so the ConnectorHandler answers it with a 4xx status code and the platform does not retry the request.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &kindError{err: err, kind: ErrorKindPermanent}
+}
+
+// KindOf returns the kind of the error.
+// Errors that are not explicitly marked are classified by the status code of a contained connector.Error or APIError.
+func KindOf(err error) ErrorKind {
+	var k *kindError
+	if errors.As(err, &k) {
+		return k.kind
+	}
+
+	status := 0
+	var e *Error
+	var apiErr *APIError
+	if errors.As(err, &e) {
+		status = e.Status
+	} else if errors.As(err, &apiErr) {
+		status = apiErr.StatusCode
+	}
+
+	if status >= http.StatusInternalServerError {
+		return ErrorKindRetryable
+	}
+	if status >= http.StatusBadRequest {
+		return ErrorKindPermanent
+	}
+	return ErrorKindUnknown
+}
+
+// apiError returns the connector.Error written by the ConnectorHandler for the given error.
+// The status code of a contained connector.Error is kept if it matches the kind of the error.
+// Otherwise retryable errors are answered with 503 Service Unavailable and permanent errors with 422 Unprocessable Entity.
+func apiError(err error) *Error {
+	var e *Error
+	hasStatus := errors.As(err, &e)
+
+	var k *kindError
+	if errors.As(err, &k) {
+		switch {
+		case k.kind == ErrorKindRetryable && !(hasStatus && e.Status >= http.StatusInternalServerError):
+			return NewError("SERVICE_UNAVAILABLE", err.Error(), http.StatusServiceUnavailable)
+		case k.kind == ErrorKindPermanent && !(hasStatus && e.Status >= http.StatusBadRequest && e.Status < http.StatusInternalServerError):
+			return NewError("UNPROCESSABLE_REQUEST", err.Error(), http.StatusUnprocessableEntity)
+		}
+	}
+
+	if hasStatus {
+		return e
+	}
+	return NewError("INTERNAL_SERVER_ERROR", err.Error(), http.StatusInternalServerError)
+}
@@ -6,19 +6,29 @@ require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/db-journey/mysql-driver v1.0.1
 	github.com/db-journey/postgresql-driver v0.0.0-20190914135041-b502d4210454
-	github.com/go-logr/logr v1.2.3
+	github.com/go-logr/logr v1.2.4
 	github.com/go-logr/stdr v1.2.2
+	github.com/go-sql-driver/mysql v1.5.0
 	github.com/gorilla/mux v1.8.0
 	github.com/jmoiron/sqlx v1.3.4
+	github.com/lib/pq v1.2.0
 	github.com/mattn/go-sqlite3 v1.14.6
-	github.com/stretchr/testify v1.6.1
+	github.com/stretchr/testify v1.8.3
+	go.opentelemetry.io/otel v1.16.0
+	go.opentelemetry.io/otel/sdk v1.16.0
+	go.opentelemetry.io/otel/trace v1.16.0
 )
 
+require github.com/microsoft/go-mssqldb v1.0.0
+
 require (
 	github.com/db-journey/migrate v2.0.0+incompatible // indirect
 	github.com/db-journey/migrate/v2 v2.0.4 // indirect
-	github.com/go-sql-driver/mysql v1.5.0 // indirect
-	github.com/lib/pq v1.2.0 // indirect
+	github.com/golang-sql/civil v0.0.0-20190719163853-cb61b32ac6fe // indirect
+	github.com/golang-sql/sqlexp v0.1.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c // indirect
+	go.opentelemetry.io/otel/metric v1.16.0 // indirect
+	golang.org/x/crypto v0.0.0-20220622213112-05595931fe9d // indirect
+	golang.org/x/sys v0.8.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
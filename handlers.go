@@ -7,21 +7,55 @@ import (
 	"errors"
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/connctd/connector-go/crypto"
+	"github.com/go-logr/logr"
 )
 
 type signatureValidationHandler struct {
 	preProcessor ValidationPreProcessor
 	next         http.HandlerFunc
 	publicKey    ed25519.PublicKey
+	options      SignatureValidationOptions
+}
+
+// SignatureValidationOptions allow modification of the signature validation behaviour.
+type SignatureValidationOptions struct {
+	// Logger is used to report validation failures. If not set, nothing is logged.
+	Logger logr.Logger
+
+	// Debug enables logging of the reconstructed signable payload and the received signature
+	// at debug level whenever a signature is rejected. The payload contains the request body,
+	// so this must not be enabled in production.
+	Debug bool
+
+	// OnValidation is called after each verified signature with the time spent reading the body and verifying the
+	// signature, the size of the body in bytes and the result of the verification. It can be used to publish metrics.
+	OnValidation func(duration time.Duration, bodySize int, valid bool)
+
+	// OnValidationFailure is called with the request and the returned error whenever a request is rejected,
+	// e.g. to distinguish malformed Date headers from bad signatures in metrics.
+	OnValidationFailure func(r *http.Request, reason *Error)
 }
 
 // NewSignatureValidationHandler creates a new handler capable of verifying the signature header.
+// The signature is verified with the algorithm given in the Signature-Algorithm header, ed25519 by default.
+// Additional algorithms can be registered with crypto.RegisterVerifier.
 // Validation can be influenced by passing a ValidationPreProcessor.
 // Common functionalities are offered by DefaultValidationPreProcessor and ProxiedRequestValidationPreProcessor
 func NewSignatureValidationHandler(validationPreProcessor ValidationPreProcessor, publicKey ed25519.PublicKey, next http.HandlerFunc) http.Handler {
-	return &signatureValidationHandler{preProcessor: validationPreProcessor, publicKey: publicKey, next: next}
+	return NewSignatureValidationHandlerWithOptions(validationPreProcessor, publicKey, next, SignatureValidationOptions{})
+}
+
+// NewSignatureValidationHandlerWithOptions creates a new signature validation handler like NewSignatureValidationHandler
+// and additionally applies the given options.
+func NewSignatureValidationHandlerWithOptions(validationPreProcessor ValidationPreProcessor, publicKey ed25519.PublicKey, next http.HandlerFunc, options SignatureValidationOptions) http.Handler {
+	if options.Logger.GetSink() == nil {
+		options.Logger = logr.Discard()
+	}
+
+	return &signatureValidationHandler{preProcessor: validationPreProcessor, publicKey: publicKey, next: next, options: options}
 }
 
 // ServeHTTP handles request
@@ -30,17 +64,34 @@ func (h *signatureValidationHandler) ServeHTTP(w http.ResponseWriter, r *http.Re
 
 	decodedSignature, err := base64.StdEncoding.DecodeString(signature)
 	if err != nil {
-		ErrorBadSignature.Write(w)
+		h.reject(w, r, ErrorBadSignature)
+		return
+	}
+
+	verifier, err := crypto.LookupVerifier(r.Header.Get(crypto.SignatureAlgorithmHeaderKey))
+	if err != nil {
+		h.options.Logger.V(1).Info("Rejected unknown signature algorithm", "algorithm", r.Header.Get(crypto.SignatureAlgorithmHeaderKey))
+		h.reject(w, r, ErrorUnknownAlgorithm)
 		return
 	}
 
+	// the signable payload contains the raw header value, a malformed date would be reported as bad signature otherwise
+	if date := r.Header.Get("Date"); date != "" {
+		if _, err := http.ParseTime(date); err != nil {
+			h.options.Logger.V(1).Info("Rejected malformed Date header", "date", date)
+			h.reject(w, r, ErrorBadDate)
+			return
+		}
+	}
+
+	start := time.Now()
 	var body []byte
 
 	// in case body is given
 	if r.ContentLength != 0 {
 		body, err = io.ReadAll(r.Body)
 		if err != nil {
-			ErrorInvalidBody.Write(w)
+			h.reject(w, r, ErrorInvalidBody)
 			return
 		}
 
@@ -52,24 +103,45 @@ func (h *signatureValidationHandler) ServeHTTP(w http.ResponseWriter, r *http.Re
 	signaturePayload, err := crypto.SignablePayload(r.Method, extractedValues.Scheme, extractedValues.Host, extractedValues.RequestURI, r.Header, body)
 	if err != nil {
 		if errors.Is(err, crypto.ErrorMissingHeader) {
-			ErrorMissingHeader.Write(w)
+			h.reject(w, r, ErrorMissingHeader)
 			return
 		}
 
-		ErrorSigningFailed.Write(w)
+		h.reject(w, r, ErrorSigningFailed)
 		return
 	}
 
 	// verify the signature
-	if crypto.Verify(h.publicKey, signaturePayload, decodedSignature) {
+	valid := verifier.Verify(h.publicKey, signaturePayload, decodedSignature)
+	duration := time.Since(start)
+	h.options.Logger.V(2).Info("Verified request signature", "duration", duration, "bodySize", len(body), "valid", valid)
+	if h.options.OnValidation != nil {
+		h.options.OnValidation(duration, len(body), valid)
+	}
+
+	if valid {
+		// the body is kept in the context, so downstream handlers do not need to read it again, see BodyFromContext
+		r = r.WithContext(withBufferedBody(r.Context(), body))
 		r.Body = io.NopCloser(bytes.NewReader(body))
 		h.next.ServeHTTP(w, r)
 	} else {
-		ErrorBadSignature.Write(w)
+		if h.options.Debug {
+			h.options.Logger.V(1).Info("Rejected request signature", "signablePayload", string(signaturePayload), "signature", signature)
+		}
+
+		h.reject(w, r, ErrorBadSignature)
 		return
 	}
 }
 
+// reject writes the error and reports it to the OnValidationFailure hook.
+func (h *signatureValidationHandler) reject(w http.ResponseWriter, r *http.Request, reason *Error) {
+	if h.options.OnValidationFailure != nil {
+		h.options.OnValidationFailure(r, reason)
+	}
+	reason.Write(w)
+}
+
 // ValidationPreProcessor can be used to influence the signature validation algorithm by returning a modified url struct.
 // This becomes handy if your service is sitting behind a proxy that modifies the original request headers which normally would lead to a validation error.
 type ValidationPreProcessor func(r *http.Request) ValidationParameters
@@ -131,8 +203,10 @@ type ValidationParameters struct {
 
 // Possible errors returned by NewSignatureValidationHandler:
 var (
-	ErrorMissingHeader = NewError("MISSING_HEADER", "Signable payload can not be generated since a relevant header is missing", http.StatusBadRequest)
-	ErrorBadSignature  = NewError("BAD_SIGNATURE", "Signature seems to be invalid", http.StatusBadRequest)
-	ErrorSigningFailed = NewError("SIGNING_FAILED", "Failed to sign the request", http.StatusBadRequest)
-	ErrorInvalidBody   = NewError("INVALID_BODY", "Unable to read message body", http.StatusBadRequest)
+	ErrorMissingHeader    = NewError("MISSING_HEADER", "Signable payload can not be generated since a relevant header is missing", http.StatusBadRequest)
+	ErrorBadSignature     = NewError("BAD_SIGNATURE", "Signature seems to be invalid", http.StatusBadRequest)
+	ErrorSigningFailed    = NewError("SIGNING_FAILED", "Failed to sign the request", http.StatusBadRequest)
+	ErrorInvalidBody      = NewError("INVALID_BODY", "Unable to read message body", http.StatusBadRequest)
+	ErrorBadDate          = NewError("BAD_DATE", "Date header is not a valid HTTP date", http.StatusBadRequest)
+	ErrorUnknownAlgorithm = NewError("UNKNOWN_SIGNATURE_ALGORITHM", "Signature algorithm is not supported", http.StatusBadRequest)
 )
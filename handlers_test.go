@@ -6,12 +6,15 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/connctd/connector-go/crypto"
+	"github.com/go-logr/logr/funcr"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestSignatureVerificationHandler(t *testing.T) {
@@ -69,3 +72,171 @@ func signRequest(privateKey ed25519.PrivateKey, req *http.Request, body []byte)
 
 	return nil
 }
+
+func TestSignatureValidationDebugLogging(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	okHandler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	for _, debug := range []bool{true, false} {
+		var logs []string
+		logger := funcr.New(func(prefix, args string) {
+			logs = append(logs, args)
+		}, funcr.Options{Verbosity: 1})
+
+		handler := NewSignatureValidationHandlerWithOptions(ProxiedRequestValidationPreProcessor("https", "example.com"), pub, okHandler, SignatureValidationOptions{
+			Logger: logger,
+			Debug:  debug,
+		})
+
+		req := httptest.NewRequest(http.MethodPost, "https://example.com/test", strings.NewReader(`{"hello":"world"}`))
+		req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+		req.Header.Set(crypto.SignatureHeaderKey, base64.StdEncoding.EncodeToString([]byte("foobarsig")))
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, ErrorBadSignature.Status, rec.Code)
+
+		if debug {
+			require.Len(t, logs, 1)
+			assert.Contains(t, logs[0], `(body):{\"hello\":\"world\"}`)
+			assert.Contains(t, logs[0], base64.StdEncoding.EncodeToString([]byte("foobarsig")))
+		} else {
+			assert.Empty(t, logs)
+		}
+	}
+}
+
+func TestSignatureValidationTiming(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	okHandler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	type validation struct {
+		duration time.Duration
+		bodySize int
+		valid    bool
+	}
+	var validations []validation
+
+	handler := NewSignatureValidationHandlerWithOptions(ProxiedRequestValidationPreProcessor("https", "example.com"), pub, okHandler, SignatureValidationOptions{
+		OnValidation: func(duration time.Duration, bodySize int, valid bool) {
+			validations = append(validations, validation{duration, bodySize, valid})
+		},
+	})
+
+	body := `{"hello":"world"}`
+	req := httptest.NewRequest(http.MethodPost, "https://example.com/test", strings.NewReader(body))
+	require.NoError(t, signRequest(priv, req, []byte(body)))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "https://example.com/test", strings.NewReader(body))
+	req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set(crypto.SignatureHeaderKey, base64.StdEncoding.EncodeToString([]byte("foobarsig")))
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, ErrorBadSignature.Status, rec.Code)
+
+	require.Len(t, validations, 2)
+	for i, valid := range []bool{true, false} {
+		assert.True(t, validations[i].duration > 0)
+		assert.Equal(t, len(body), validations[i].bodySize)
+		assert.Equal(t, valid, validations[i].valid)
+	}
+}
+
+func TestSignatureValidationDate(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	okHandler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	var failures []*Error
+	handler := NewSignatureValidationHandlerWithOptions(ProxiedRequestValidationPreProcessor("https", "example.com"), pub, okHandler, SignatureValidationOptions{
+		OnValidationFailure: func(r *http.Request, reason *Error) {
+			failures = append(failures, reason)
+		},
+	})
+
+	sign := func(date string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "https://example.com/test", nil)
+		req.Header.Set("Date", date)
+		signable, err := crypto.SignablePayload(req.Method, "https", "example.com", req.URL.RequestURI(), req.Header, nil)
+		require.NoError(t, err)
+		req.Header.Set(crypto.SignatureHeaderKey, base64.StdEncoding.EncodeToString(crypto.Sign(priv, signable)))
+		return req
+	}
+
+	// a correctly signed request with a malformed date is rejected because of the date
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, sign(time.Now().UTC().Format(time.RFC3339)))
+	assert.Equal(t, ErrorBadDate.Status, rec.Code)
+	assert.Contains(t, rec.Body.String(), "BAD_DATE")
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, sign(time.Now().UTC().Format(http.TimeFormat)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// a valid date with a bad signature is still reported as bad signature
+	req := sign(time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set(crypto.SignatureHeaderKey, base64.StdEncoding.EncodeToString([]byte("foobarsig")))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, ErrorBadSignature.Status, rec.Code)
+
+	assert.Equal(t, []*Error{ErrorBadDate, ErrorBadSignature}, failures)
+}
+
+func TestSignatureAlgorithm(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	okHandler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	var failures []*Error
+	handler := NewSignatureValidationHandlerWithOptions(ProxiedRequestValidationPreProcessor("https", "example.com"), pub, okHandler, SignatureValidationOptions{
+		OnValidationFailure: func(r *http.Request, reason *Error) {
+			failures = append(failures, reason)
+		},
+	})
+
+	tests := []struct {
+		name           string
+		algorithm      string
+		expectedStatus int
+	}{
+		{"default", "", http.StatusOK},
+		{"ed25519", crypto.AlgorithmEd25519, http.StatusOK},
+		{"unknown", "rsa-sha256", ErrorUnknownAlgorithm.Status},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "https://example.com/test", nil)
+			if test.algorithm != "" {
+				req.Header.Set(crypto.SignatureAlgorithmHeaderKey, test.algorithm)
+			}
+			require.NoError(t, signRequest(priv, req, nil))
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, test.expectedStatus, rec.Code)
+		})
+	}
+
+	assert.Equal(t, []*Error{ErrorUnknownAlgorithm}, failures)
+}
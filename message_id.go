@@ -0,0 +1,25 @@
+package connector
+
+import "context"
+
+// MessageIDHeaderKey is the header carrying the ID of the message which caused a request to the connctd platform.
+const MessageIDHeaderKey = "X-Message-ID"
+
+type messageIDContextKey struct{}
+
+// WithMessageID returns a context carrying the ID of the message currently being handled.
+// The client sends it with every request made with the context, so the platform can correlate
+// its callback with the resulting requests. An empty ID returns the context unchanged.
+func WithMessageID(ctx context.Context, messageID string) context.Context {
+	if messageID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, messageIDContextKey{}, messageID)
+}
+
+// MessageIDFromContext returns the message ID set with WithMessageID.
+// It returns false if the context carries no message ID.
+func MessageIDFromContext(ctx context.Context) (string, bool) {
+	messageID, ok := ctx.Value(messageIDContextKey{}).(string)
+	return messageID, ok
+}
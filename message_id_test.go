@@ -0,0 +1,20 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageIDFromContext(t *testing.T) {
+	_, ok := MessageIDFromContext(context.Background())
+	assert.False(t, ok)
+
+	_, ok = MessageIDFromContext(WithMessageID(context.Background(), ""))
+	assert.False(t, ok)
+
+	messageID, ok := MessageIDFromContext(WithMessageID(context.Background(), "message-1"))
+	assert.True(t, ok)
+	assert.Equal(t, "message-1", messageID)
+}
@@ -74,6 +74,8 @@ type InstantiationRequest struct {
 	Token          InstantiationToken `json:"token"`
 	State          InstantiationState `json:"state"`
 	Configuration  []Configuration    `json:"configuration"`
+	// MessageID identifies the request, it is sent with all requests the service makes to the platform while handling it.
+	MessageID string `json:"message_id,omitempty"`
 }
 
 // GetConfig returns the configuration parameter with the given ID.
@@ -119,11 +121,28 @@ type AddThingResponse struct {
 }
 
 // UpdateThingPropertyValueRequest can be used to propagate a new property value.
+// Unit is only sent if set, so the platform keeps the unit declared when the thing was created.
 type UpdateThingPropertyValueRequest struct {
 	Value      string    `json:"value"`
+	Unit       string    `json:"unit,omitempty"`
 	LastUpdate time.Time `json:"lastUpdate"`
 }
 
+// UpdateThingBatchRequest is used to update several property values and the status of a thing at once.
+type UpdateThingBatchRequest struct {
+	Properties []ThingPropertyValue `json:"properties"`
+	Status     connctd.StatusType   `json:"status,omitempty"`
+}
+
+// ThingPropertyValue is a single property value of an UpdateThingBatchRequest.
+type ThingPropertyValue struct {
+	ComponentID string    `json:"componentId"`
+	PropertyID  string    `json:"propertyId"`
+	Value       string    `json:"value"`
+	Unit        string    `json:"unit,omitempty"`
+	LastUpdate  time.Time `json:"lastUpdate"`
+}
+
 // UpdateThingStatusRequest allows updating the status of a thing.
 type UpdateThingStatusRequest struct {
 	Status connctd.StatusType `json:"status"`
@@ -140,8 +159,10 @@ type ActionRequest struct {
 }
 
 // ActionRequestStatus indicates the status of an action request.
+// It is defined by the SDK, so connectors can compare statuses without additional imports.
 type ActionRequestStatus string
 
+// Valid action request states:
 const (
 	ActionRequestStatusPending   ActionRequestStatus = "PENDING"
 	ActionRequestStatusCompleted ActionRequestStatus = "COMPLETED"
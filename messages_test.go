@@ -0,0 +1,59 @@
+package connector
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/connctd/connector-go/connctd"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActionRequestStatusValues(t *testing.T) {
+	// the values are part of the connector protocol and must not change
+	assert.Equal(t, ActionRequestStatus("PENDING"), ActionRequestStatusPending)
+	assert.Equal(t, ActionRequestStatus("COMPLETED"), ActionRequestStatusCompleted)
+	assert.Equal(t, ActionRequestStatus("FAILED"), ActionRequestStatusFailed)
+	assert.Equal(t, ActionRequestStatus("CANCELED"), ActionRequestStatusCanceled)
+
+	var request ActionRequest
+	require.NoError(t, json.Unmarshal([]byte(`{"status":"PENDING"}`), &request))
+	assert.Equal(t, ActionRequestStatusPending, request.Status)
+}
+
+func TestAddThingRequestRoundTrip(t *testing.T) {
+	// connctd.Thing is verified by connectors and sent by the client as is, so encoding must not lose any field
+	thing := connctd.Thing{
+		ID:              "thing-1",
+		Name:            "Lamp",
+		Manufacturer:    "ACME",
+		DisplayType:     "core.LIGHT",
+		MainComponentID: "light",
+		Status:          connctd.StatusTypeAvailable,
+		Components: []connctd.Component{
+			{
+				ID:            "light",
+				Name:          "Light",
+				ComponentType: "core.LIGHT",
+				Capabilities:  []string{"core.SWITCH"},
+				Properties: []connctd.Property{
+					{ID: "on", Name: "On", Value: "true", Unit: "", Type: connctd.ValueTypeBoolean, LastUpdate: time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC), PropertyType: "core.BOOLEAN"},
+				},
+				Actions: []connctd.Action{
+					{ID: "switch", Name: "Switch", Parameters: []connctd.ActionParameter{{Name: "on", Type: connctd.ValueTypeBoolean}}},
+				},
+			},
+		},
+		Attributes: []connctd.ThingAttribute{{Name: "serial", Value: "1234"}},
+	}
+	require.NoError(t, thing.Verify())
+
+	b, err := json.Marshal(AddThingRequest{Thing: thing})
+	require.NoError(t, err)
+
+	var decoded AddThingRequest
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	assert.Equal(t, thing, decoded.Thing)
+}
@@ -0,0 +1,138 @@
+package connector
+
+import (
+	"crypto/ed25519"
+	"net/http"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// DefaultMaxBodyBytes is the body limit used by the DefaultMiddlewareStack if no limit is set.
+const DefaultMaxBodyBytes = 1 << 20
+
+// Errors returned by the middlewares:
+var (
+	ErrorBodyTooLarge = NewError("BODY_TOO_LARGE", "Request body exceeds the size limit", http.StatusRequestEntityTooLarge)
+)
+
+// Chain composes the middlewares into a single middleware. The first middleware is the outermost one,
+// so it sees the request first and the response last.
+func Chain(middlewares ...func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			next = middlewares[i](next)
+		}
+		return next
+	}
+}
+
+// MiddlewareOptions configure the DefaultMiddlewareStack.
+type MiddlewareOptions struct {
+	// Logger is used to log recovered panics and handled requests. If not set, nothing is logged.
+	Logger logr.Logger
+
+	// MaxBodyBytes limits the size of request bodies. Zero uses the DefaultMaxBodyBytes.
+	MaxBodyBytes int64
+
+	// PublicKey is used to validate the request signatures.
+	PublicKey ed25519.PublicKey
+
+	// ValidationPreProcessor is passed to the signature validation.
+	// If it is nil, the AutoProxyRequestValidationPreProcessor is used.
+	ValidationPreProcessor ValidationPreProcessor
+
+	// SignatureValidation allows modification of the signature validation behaviour.
+	SignatureValidation SignatureValidationOptions
+}
+
+// DefaultMiddlewareStack assembles the middlewares needed in front of a connector protocol handler in the correct order:
+// panics are recovered first, then requests are logged, then the content type and body size are checked,
+// and signatures are validated last, since the validation reads the whole body.
+func DefaultMiddlewareStack(opts MiddlewareOptions) func(http.Handler) http.Handler {
+	if opts.MaxBodyBytes <= 0 {
+		opts.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+	if opts.ValidationPreProcessor == nil {
+		opts.ValidationPreProcessor = AutoProxyRequestValidationPreProcessor()
+	}
+	if opts.SignatureValidation.Logger.GetSink() == nil {
+		opts.SignatureValidation.Logger = opts.Logger
+	}
+
+	return Chain(
+		RecoverMiddleware(opts.Logger),
+		LoggingMiddleware(opts.Logger),
+		ContentTypeMiddleware(),
+		MaxBodyBytesMiddleware(opts.MaxBodyBytes),
+		SignatureValidationMiddleware(opts.ValidationPreProcessor, opts.PublicKey, opts.SignatureValidation),
+	)
+}
+
+// LoggingMiddleware logs method, path, status code and duration of each request at debug level.
+func LoggingMiddleware(logger logr.Logger) func(http.Handler) http.Handler {
+	if logger.GetSink() == nil {
+		logger = logr.Discard()
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+			next.ServeHTTP(recorder, r)
+
+			logger.V(1).Info("Handled request", "method", r.Method, "path", r.URL.Path, "status", recorder.status, "duration", time.Since(start))
+		})
+	}
+}
+
+// statusRecorder records the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// ContentTypeMiddleware rejects requests with a body that is not declared as json with ErrorBadContentType.
+func ContentTypeMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength != 0 {
+				if !isJSONContentType(r.Header.Get("Content-Type")) {
+					writeError(w, ErrorBadContentType)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// MaxBodyBytesMiddleware rejects requests with a body larger than maxBytes with ErrorBodyTooLarge.
+// Bodies of unknown length are cut off after maxBytes, so reading them fails.
+func MaxBodyBytesMiddleware(maxBytes int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > maxBytes {
+				writeError(w, ErrorBodyTooLarge)
+				return
+			}
+
+			r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// SignatureValidationMiddleware validates request signatures like the handler returned by NewSignatureValidationHandlerWithOptions.
+func SignatureValidationMiddleware(validationPreProcessor ValidationPreProcessor, publicKey ed25519.PublicKey, options SignatureValidationOptions) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return NewSignatureValidationHandlerWithOptions(validationPreProcessor, publicKey, next.ServeHTTP, options)
+	}
+}
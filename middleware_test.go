@@ -0,0 +1,106 @@
+package connector
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChain(t *testing.T) {
+	var calls []string
+	middleware := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, name+" before")
+				next.ServeHTTP(w, r)
+				calls = append(calls, name+" after")
+			})
+		}
+	}
+
+	handler := Chain(middleware("outer"), middleware("inner"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "handler")
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, []string{"outer before", "inner before", "handler", "inner after", "outer after"}, calls)
+
+	// an empty chain returns the handler itself
+	calls = nil
+	Chain()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "handler")
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, []string{"handler"}, calls)
+}
+
+func TestDefaultMiddlewareStack(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	var logs []string
+	logger := funcr.New(func(prefix, args string) { logs = append(logs, args) }, funcr.Options{Verbosity: 1})
+
+	stack := DefaultMiddlewareStack(MiddlewareOptions{
+		Logger:                 logger,
+		MaxBodyBytes:           32,
+		PublicKey:              pub,
+		ValidationPreProcessor: ProxiedRequestValidationPreProcessor("https", "example.com"),
+	})
+
+	var received string
+	handler := stack(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf := new(bytes.Buffer)
+		buf.ReadFrom(r.Body)
+		received = buf.String()
+		if received == `{"panic":true}` {
+			panic("handler failed")
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	send := func(body string, contentType string, sign bool) int {
+		req := httptest.NewRequest(http.MethodPost, "https://example.com/installations", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		if sign {
+			require.NoError(t, signRequest(priv, req, []byte(body)))
+		}
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusNoContent, send(`{"id":"1"}`, "application/json", true))
+	assert.Equal(t, `{"id":"1"}`, received)
+	assert.Equal(t, ErrorBadContentType.Status, send(`{"id":"1"}`, "text/plain", true))
+	assert.Equal(t, ErrorBodyTooLarge.Status, send(`{"id":"`+strings.Repeat("1", 32)+`"}`, "application/json", true))
+	assert.Equal(t, ErrorBadSignature.Status, send(`{"id":"1"}`, "application/json", false))
+
+	// the recovery is the outermost middleware, so panics are recovered after passing all other middlewares
+	logs = nil
+	assert.Equal(t, ErrorInternal.Status, send(`{"panic":true}`, "application/json", true))
+	require.Len(t, logs, 1)
+	assert.Contains(t, logs[0], "handler failed")
+}
+
+func TestRecoveryCatchesPanicsOfMiddlewares(t *testing.T) {
+	panicking := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("middleware failed")
+		})
+	}
+
+	handler := Chain(RecoverMiddleware(logr.Discard()), LoggingMiddleware(logr.Discard()), panicking)(http.NotFoundHandler())
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, ErrorInternal.Status, rec.Code)
+}
@@ -1,5 +1,13 @@
 package connector
 
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+)
+
 // Installation represents connector installationa and their configuration.
 // We store it in the database to be able to access the configuration and to map instances to specific installations.
 // Also we need to store the installation token, to be able to manage the installation with the platform.
@@ -27,9 +35,26 @@ type Configuration struct {
 	Value string `json:"value"`
 }
 
-// InstallationToken can be used by a connector installation to propagte e.g. state changes
+// Secret is a confidential value of an instance, e.g. an OAuth token obtained during the installation.
+// Unlike configuration parameters, secrets are stored encrypted and their values are redacted when logged.
+type Secret struct {
+	ID    string `json:"id"`
+	Value string `json:"value"`
+}
+
+// InstallationToken can be used by a connector installation to propagte e.g. state changes.
+// Installation and instantiation tokens are distinct types, so one can not be passed where the other is expected
+// without an explicit conversion. Prefer NewInstallationToken over conversions of untrusted strings.
 type InstallationToken string
 
+// NewInstallationToken validates the given string and returns it as installation token.
+func NewInstallationToken(s string) (InstallationToken, error) {
+	if err := verifyToken(s); err != nil {
+		return "", err
+	}
+	return InstallationToken(s), nil
+}
+
 // Instance represents connector instances.
 // We store it in the database to be able to map a instance to a specific thing and installation.
 // We also store its token to be able to manage the instance with the platform.
@@ -43,7 +68,9 @@ type Instance struct {
 }
 
 // ThingIdByExternalId returns the ThingId that is mapped to the given externalID or false if no such mapping exists.
+// The external ID is normalized with NormalizeExternalID before it is compared.
 func (i *Instance) ThingIdByExternalId(externalID string) (string, bool) {
+	externalID = NormalizeExternalID(externalID)
 	for _, m := range i.ThingMapping {
 		if m.ExternalID == externalID {
 			return m.ThingID, true
@@ -76,9 +103,81 @@ func (i *Instance) GetConfig(id string) (*Configuration, bool) {
 // InstantiationToken can be used by a connector instance to send things or updates
 type InstantiationToken string
 
+// NewInstantiationToken validates the given string and returns it as instantiation token.
+func NewInstantiationToken(s string) (InstantiationToken, error) {
+	if err := verifyToken(s); err != nil {
+		return "", err
+	}
+	return InstantiationToken(s), nil
+}
+
+// Errors returned by the token constructors:
+var (
+	ErrorEmptyToken     = errors.New("token must not be empty")
+	ErrorMalformedToken = errors.New("token must not contain whitespace or control characters")
+)
+
+// verifyToken checks that the token can be used as bearer token in an authorization header.
+func verifyToken(s string) error {
+	if s == "" {
+		return ErrorEmptyToken
+	}
+
+	for _, r := range s {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return ErrorMalformedToken
+		}
+	}
+
+	return nil
+}
+
 // ThinkMapping represents a mapping of instances to things and external ID.
 type ThingMapping struct {
 	InstanceID string `db:"instance_id" json:"-"`
 	ThingID    string `db:"thing_id" json:"thing_id"`
 	ExternalID string `db:"external_id" json:"external_id"`
 }
+
+// maxExternalIDLength is the size of the external_id column.
+const maxExternalIDLength = 255
+
+// ErrorInvalidExternalID is returned by ValidateExternalID.
+var ErrorInvalidExternalID = errors.New("external id must not be empty or longer than 255 characters")
+
+// NormalizeExternalID returns the representation of an external ID used to store and look up thing mappings.
+// Surrounding whitespace is removed and letters are lower cased, all other characters are kept, so distinct
+// device IDs like "lamp/1" and "lamp-1" keep distinct mappings. Normalizing the result again does not change it.
+// Changing the normalization is a breaking change, since the external IDs of existing mappings would have to be migrated.
+// The SQL database normalizes the stored external IDs when they are read, so mappings stored with raw IDs are still found.
+func NormalizeExternalID(externalID string) string {
+	return strings.ToLower(strings.TrimSpace(externalID))
+}
+
+// ValidateExternalID checks that the normalized external ID can be stored in a thing mapping.
+func ValidateExternalID(externalID string) error {
+	normalized := NormalizeExternalID(externalID)
+	if normalized == "" || len(normalized) > maxExternalIDLength {
+		return ErrorInvalidExternalID
+	}
+	return nil
+}
+
+// PropertyValue is the last known value of a thing property.
+// It is stored by the default service if persisting property values is enabled.
+type PropertyValue struct {
+	InstanceID  string    `json:"instanceId"`
+	ThingID     string    `json:"thingId"`
+	ComponentID string    `json:"componentId"`
+	PropertyID  string    `json:"propertyId"`
+	Value       string    `json:"value"`
+	LastUpdate  time.Time `json:"lastUpdate"`
+}
+
+// PendingInstanceState is an instance state that still has to be reported to the connctd platform.
+// It is stored by the default service until the platform acknowledged the state.
+type PendingInstanceState struct {
+	InstanceID string             `json:"instanceId"`
+	State      InstantiationState `json:"state"`
+	Details    json.RawMessage    `json:"details,omitempty"`
+}
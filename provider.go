@@ -2,6 +2,9 @@ package connector
 
 import (
 	"context"
+	"encoding/json"
+
+	"github.com/connctd/connector-go/connctd"
 )
 
 // The Provider interface is used in the default service to implement all technology specific details.
@@ -29,6 +32,10 @@ type Provider interface {
 	// RemoveInstance is called by the service if it received an installation removal request.
 	RemoveInstallation(installationId string) error
 
+	// UpdateInstallation is called by the service if it received an update of an existing installation,
+	// e.g. a changed configuration or token. The installation replaces the previously registered one.
+	UpdateInstallation(installation *Installation) error
+
 	// RegisterInstances is called by the connector service to register new instances.
 	// Instances are registered whenever the service received an successful instantiation request or when the connector is started.
 	RegisterInstances(instances ...*Instance) error
@@ -37,15 +44,30 @@ type Provider interface {
 	RemoveInstance(instanceId string) error
 }
 
+// InstallationDetailer can be implemented by providers to attach details to the response of an installation request.
+// The default service calls InstallationDetails after the installation was registered, the details must be valid json.
+type InstallationDetailer interface {
+	InstallationDetails(installation *Installation) (json.RawMessage, error)
+}
+
+// InstanceDetailer can be implemented by providers to attach details, e.g. the number of devices, to the response of an instantiation request.
+// The default service calls InstanceDetails after the instance was registered, the details must be valid json.
+// Details are not returned if the instance is created asynchronously.
+type InstanceDetailer interface {
+	InstanceDetails(instance *Instance) (json.RawMessage, error)
+}
+
 // UpdateEvents are pushed to the UpdateChannel.
 // The default service will listen to the channel.
 // If it receives an UpdateEvent with only a PropertyEventUpdate it will update the specified property with the new value.
 // If it receives an ActionEvent it will update the the state of the specified action request to the state in the ActionResponse.
 // If the same UpdateEvent contains a PropertyUpateEvent it will first update the property and then the action request.
 // If the property update fails it will set the action request state to failed.
+// A BatchUpdateEvent is handled like a property update, but all of its updates are applied with a single request.
 type UpdateEvent struct {
 	ActionEvent         *ActionEvent
 	PropertyUpdateEvent *PropertyUpdateEvent
+	BatchUpdateEvent    *BatchUpdateEvent
 }
 
 // ActionEvent is used to propagate action request results to the service.
@@ -64,4 +86,23 @@ type PropertyUpdateEvent struct {
 	ComponentId string
 	PropertyId  string
 	Value       string
+	// Unit optionally sets the current unit of the property, e.g. if it can be changed at the device.
+	// If empty, the unit declared at thing creation is kept.
+	Unit string
+}
+
+// ThingStatusEvent is used to propagate a new status of a thing to the service.
+type ThingStatusEvent struct {
+	ThingId    string
+	InstanceId string
+	Status     connctd.StatusType
+}
+
+// BatchUpdateEvent is used to propagate several property updates and an optional status of a single thing to the service.
+// The updates are sent with a single request, so the platform never shows a partially updated thing.
+// If the request fails, none of the updates is applied.
+// All updates must refer to the same thing and instance.
+type BatchUpdateEvent struct {
+	PropertyUpdateEvents []PropertyUpdateEvent
+	ThingStatusEvent     *ThingStatusEvent
 }
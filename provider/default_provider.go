@@ -5,8 +5,12 @@ package provider
 import (
 	"context"
 	"errors"
+	"sort"
+	"sync/atomic"
 
 	"github.com/connctd/connector-go"
+	"github.com/connctd/connector-go/connctd"
+	"github.com/go-logr/logr"
 )
 
 var (
@@ -14,6 +18,41 @@ var (
 	actionChannelBufferSize = 5
 )
 
+// Errors returned by EmitPropertyChecked:
+var (
+	ErrorUnknownInstance  = errors.New("instance not found")
+	ErrorUnmappedThing    = errors.New("thing is not mapped to the instance")
+	ErrorUnknownThing     = errors.New("thing is not registered")
+	ErrorUnknownComponent = errors.New("component does not exist")
+	ErrorUnknownProperty  = errors.New("property does not exist")
+)
+
+// OverflowPolicy decides what happens to update events published while the update channel is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock waits until the connector receives from the update channel, so no update event is lost.
+	// This is the default.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest removes the oldest queued update event to make room for the new one.
+	OverflowDropOldest
+	// OverflowDropNewest discards the new update event and keeps the queued ones.
+	OverflowDropNewest
+)
+
+// String returns the name of the policy as used in logs.
+func (o OverflowPolicy) String() string {
+	switch o {
+	case OverflowBlock:
+		return "block"
+	case OverflowDropOldest:
+		return "drop-oldest"
+	case OverflowDropNewest:
+		return "drop-newest"
+	}
+	return "unknown"
+}
+
 type DefaultProvider struct {
 	Installations         map[string]*connector.Installation
 	Instances             []*connector.Instance
@@ -23,16 +62,54 @@ type DefaultProvider struct {
 	instancesToRemove     []string
 	newInstallations      []*connector.Installation
 	installationsToRemove []string
+	things                map[string]connctd.Thing
+	overflowPolicy        OverflowPolicy
+	droppedUpdateEvents   *atomic.Uint64
+	logger                logr.Logger
+}
+
+// Options allow modification of the default provider.
+type Options struct {
+	// UpdateChannelBufferSize is the number of update events buffered before UpdateEvent blocks.
+	// Zero uses the default buffer size.
+	UpdateChannelBufferSize int
+
+	// ActionChannelBufferSize is the number of pending actions buffered before ActionEvent blocks.
+	// Zero uses the default buffer size.
+	ActionChannelBufferSize int
+
+	// UpdateChannelOverflowPolicy decides what happens to update events published while the update channel is full.
+	// Blocking never loses an update event, but a slow connector also stalls the code emitting updates.
+	// The drop policies never block and count every dropped update event, see DroppedUpdateEvents.
+	UpdateChannelOverflowPolicy OverflowPolicy
+
+	// Logger is used to log dropped update events. Nothing is logged by default.
+	Logger logr.Logger
 }
 
 func New() DefaultProvider {
+	return NewWithOptions(Options{})
+}
+
+// NewWithOptions creates a default provider like New and additionally applies the given options.
+func NewWithOptions(options Options) DefaultProvider {
+	if options.UpdateChannelBufferSize <= 0 {
+		options.UpdateChannelBufferSize = updateChannelBufferSize
+	}
+	if options.ActionChannelBufferSize <= 0 {
+		options.ActionChannelBufferSize = actionChannelBufferSize
+	}
+
 	return DefaultProvider{
-		Installations:    make(map[string]*connector.Installation),
-		Instances:        []*connector.Instance{},
-		newInstances:     []*connector.Instance{},
-		newInstallations: []*connector.Installation{},
-		updateChannel:    make(chan connector.UpdateEvent, updateChannelBufferSize),
-		actionChannel:    make(chan PendingAction, actionChannelBufferSize),
+		Installations:       make(map[string]*connector.Installation),
+		Instances:           []*connector.Instance{},
+		newInstances:        []*connector.Instance{},
+		newInstallations:    []*connector.Installation{},
+		updateChannel:       make(chan connector.UpdateEvent, options.UpdateChannelBufferSize),
+		actionChannel:       make(chan PendingAction, options.ActionChannelBufferSize),
+		overflowPolicy:      options.UpdateChannelOverflowPolicy,
+		droppedUpdateEvents: &atomic.Uint64{},
+		logger:              options.Logger,
 	}
 }
 
@@ -47,8 +124,123 @@ func (p *DefaultProvider) UpdateChannel() <-chan connector.UpdateEvent {
 }
 
 // UpdateEvent publishes the update event on the update event channel.
+// If the channel is full, the overflow policy of the provider is applied.
 func (p *DefaultProvider) UpdateEvent(update connector.UpdateEvent) {
-	p.updateChannel <- update
+	p.UpdateEventContext(context.Background(), update)
+}
+
+// UpdateEventContext publishes the update event like UpdateEvent.
+// With the blocking overflow policy it stops waiting for a full channel once the context is done
+// and returns the error of the context. The drop policies never return an error.
+func (p *DefaultProvider) UpdateEventContext(ctx context.Context, update connector.UpdateEvent) error {
+	switch p.overflowPolicy {
+	case OverflowDropOldest:
+		for {
+			select {
+			case p.updateChannel <- update:
+				return nil
+			default:
+			}
+			// the connector may have received the oldest event in the meantime, then there is room again
+			select {
+			case <-p.updateChannel:
+				p.droppedUpdateEvent()
+			default:
+			}
+		}
+	case OverflowDropNewest:
+		select {
+		case p.updateChannel <- update:
+		default:
+			p.droppedUpdateEvent()
+		}
+		return nil
+	default:
+		select {
+		case p.updateChannel <- update:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// droppedUpdateEvent counts and logs an update event dropped by the overflow policy.
+func (p *DefaultProvider) droppedUpdateEvent() {
+	dropped := p.droppedUpdateEvents.Add(1)
+	p.logger.Info("Dropped update event since the update channel is full", "policy", p.overflowPolicy, "droppedUpdateEvents", dropped)
+}
+
+// DroppedUpdateEvents returns the number of update events dropped by the overflow policy so far.
+// It can be published as metric together with UpdateChannelDepth.
+func (p *DefaultProvider) DroppedUpdateEvents() uint64 {
+	return p.droppedUpdateEvents.Load()
+}
+
+// UpdateChannelDepth returns the number of queued update events and the buffer size of the update channel.
+// It can be published as metric to detect backpressure before UpdateEvent blocks.
+func (p *DefaultProvider) UpdateChannelDepth() (length int, capacity int) {
+	return len(p.updateChannel), cap(p.updateChannel)
+}
+
+// RegisterThings makes the definition of created things known to the provider, so EmitPropertyChecked can validate updates.
+// Things are identified by their ID, e.g. the connector can register the things returned by CreateThing.
+func (p *DefaultProvider) RegisterThings(things ...connctd.Thing) {
+	if p.things == nil {
+		p.things = make(map[string]connctd.Thing, len(things))
+	}
+	for _, thing := range things {
+		p.things[thing.ID] = thing
+	}
+}
+
+// EmitPropertyChecked publishes a property update like UpdateEvent, but first validates its address.
+// The thing must be mapped to a registered instance and the component and property must exist at the thing registered with RegisterThings.
+// Otherwise an error is returned and no event is published, so typos are caught where the update is emitted.
+func (p *DefaultProvider) EmitPropertyChecked(instanceID, thingID, componentID, propertyID, value string) error {
+	index := findIndex(p.Instances, instanceID)
+	if index < 0 {
+		return ErrorUnknownInstance
+	}
+	if _, ok := p.Instances[index].ExternalIdByThingId(thingID); !ok {
+		return ErrorUnmappedThing
+	}
+
+	thing, ok := p.things[thingID]
+	if !ok {
+		return ErrorUnknownThing
+	}
+
+	if err := verifyPropertyAddress(thing, componentID, propertyID); err != nil {
+		return err
+	}
+
+	p.UpdateEvent(connector.UpdateEvent{
+		PropertyUpdateEvent: &connector.PropertyUpdateEvent{
+			InstanceId:  instanceID,
+			ThingId:     thingID,
+			ComponentId: componentID,
+			PropertyId:  propertyID,
+			Value:       value,
+		},
+	})
+	return nil
+}
+
+// verifyPropertyAddress checks that the component and property exist at the thing.
+func verifyPropertyAddress(thing connctd.Thing, componentID, propertyID string) error {
+	for _, component := range thing.Components {
+		if component.ID != componentID {
+			continue
+		}
+		for _, property := range component.Properties {
+			if property.ID == propertyID {
+				return nil
+			}
+		}
+		return ErrorUnknownProperty
+	}
+	return ErrorUnknownComponent
 }
 
 // UpdateChannel returns the action channel and allows the provider to listen for action events.
@@ -91,6 +283,21 @@ func (p *DefaultProvider) RegisterInstallations(installations ...*connector.Inst
 	return nil
 }
 
+// UpdateInstallation replaces the registered installation with the same id.
+// Like new installations, the update is applied with the next call of AddNewInstallations.
+func (p *DefaultProvider) UpdateInstallation(installation *connector.Installation) error {
+	_, ok := p.Installations[installation.ID]
+	for _, newInstallation := range p.newInstallations {
+		ok = ok || newInstallation.ID == installation.ID
+	}
+	if !ok {
+		return errors.New("installation not found")
+	}
+
+	p.newInstallations = append(p.newInstallations, installation)
+	return nil
+}
+
 // RemoveInstallation removes the installation with the given id from the provider.
 func (p *DefaultProvider) RemoveInstallation(installationId string) error {
 	_, ok := p.Installations[installationId]
@@ -113,12 +320,44 @@ func (p *DefaultProvider) RequestAction(ctx context.Context, instance *connector
 	return connector.ActionRequestStatusPending, nil
 }
 
+// RegisteredInstallationIDs returns the IDs of all registered installations.
+// Like RegisteredInstanceIDs it already applies registrations and removals which are not yet applied with Update.
+func (p *DefaultProvider) RegisteredInstallationIDs() []string {
+	registered := make(map[string]bool, len(p.Installations)+len(p.newInstallations))
+	for id := range p.Installations {
+		registered[id] = true
+	}
+	for _, id := range p.installationsToRemove {
+		delete(registered, id)
+	}
+	for _, installation := range p.newInstallations {
+		registered[installation.ID] = true
+	}
+	return sortedIDs(registered)
+}
+
+// RegisteredInstanceIDs returns the IDs of all registered instances.
+func (p *DefaultProvider) RegisteredInstanceIDs() []string {
+	registered := make(map[string]bool, len(p.Instances)+len(p.newInstances))
+	for _, instance := range p.Instances {
+		registered[instance.ID] = true
+	}
+	for _, id := range p.instancesToRemove {
+		delete(registered, id)
+	}
+	for _, instance := range p.newInstances {
+		registered[instance.ID] = true
+	}
+	return sortedIDs(registered)
+}
+
 // AddNewInstallations will add all newly registered installations to p.Instances.
 // The provider is expected to call this to be able to use newly registered installations.
 func (p *DefaultProvider) AddNewInstallations() {
 	for _, installation := range p.newInstallations {
 		p.Installations[installation.ID] = installation
 	}
+	p.newInstallations = nil
 }
 
 // RemoveInstallations removes all installations that are marked for removal.
@@ -162,6 +401,16 @@ func (p *DefaultProvider) Update() {
 	p.AddNewInstallations()
 }
 
+// sortedIDs returns the keys of the set in ascending order.
+func sortedIDs(set map[string]bool) []string {
+	ids := make([]string, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
 // findIndex return the index of the instance with the given id.
 func findIndex(instances []*connector.Instance, instanceId string) int {
 	for i := range instances {
@@ -0,0 +1,157 @@
+package provider
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/connctd/connector-go"
+	"github.com/connctd/connector-go/connctd"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateChannelBufferSize(t *testing.T) {
+	p := NewWithOptions(Options{UpdateChannelBufferSize: 20})
+
+	length, capacity := p.UpdateChannelDepth()
+	assert.Equal(t, 0, length)
+	assert.Equal(t, 20, capacity)
+
+	for i := 0; i < 3; i++ {
+		p.UpdateEvent(connector.UpdateEvent{PropertyUpdateEvent: &connector.PropertyUpdateEvent{ThingId: "thing-1"}})
+	}
+	length, capacity = p.UpdateChannelDepth()
+	assert.Equal(t, 3, length)
+	assert.Equal(t, 20, capacity)
+
+	<-p.UpdateChannel()
+	length, _ = p.UpdateChannelDepth()
+	assert.Equal(t, 2, length)
+}
+
+func TestDefaultBufferSize(t *testing.T) {
+	p := New()
+
+	_, capacity := p.UpdateChannelDepth()
+	assert.Equal(t, updateChannelBufferSize, capacity)
+	assert.Equal(t, actionChannelBufferSize, cap(p.actionChannel))
+}
+
+func TestUpdateChannelOverflowPolicy(t *testing.T) {
+	update := func(thingID string) connector.UpdateEvent {
+		return connector.UpdateEvent{PropertyUpdateEvent: &connector.PropertyUpdateEvent{ThingId: thingID}}
+	}
+	queued := func(p *DefaultProvider) []string {
+		var thingIDs []string
+		for len(p.updateChannel) > 0 {
+			thingIDs = append(thingIDs, (<-p.UpdateChannel()).PropertyUpdateEvent.ThingId)
+		}
+		return thingIDs
+	}
+
+	tests := []struct {
+		policy  OverflowPolicy
+		queued  []string
+		dropped uint64
+	}{
+		{OverflowDropOldest, []string{"thing-3", "thing-4"}, 2},
+		{OverflowDropNewest, []string{"thing-1", "thing-2"}, 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.policy.String(), func(t *testing.T) {
+			p := NewWithOptions(Options{UpdateChannelBufferSize: 2, UpdateChannelOverflowPolicy: test.policy})
+			for _, thingID := range []string{"thing-1", "thing-2", "thing-3", "thing-4"} {
+				assert.NoError(t, p.UpdateEventContext(context.Background(), update(thingID)))
+			}
+
+			assert.Equal(t, test.dropped, p.DroppedUpdateEvents())
+			assert.Equal(t, test.queued, queued(&p))
+		})
+	}
+
+	t.Run("block", func(t *testing.T) {
+		p := NewWithOptions(Options{UpdateChannelBufferSize: 1})
+		p.UpdateEvent(update("thing-1"))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		assert.Equal(t, context.DeadlineExceeded, p.UpdateEventContext(ctx, update("thing-2")))
+
+		// a blocked update is published as soon as the connector receives from the channel
+		published := make(chan struct{})
+		go func() {
+			p.UpdateEvent(update("thing-3"))
+			close(published)
+		}()
+		assert.Equal(t, "thing-1", (<-p.UpdateChannel()).PropertyUpdateEvent.ThingId)
+		<-published
+
+		assert.Equal(t, uint64(0), p.DroppedUpdateEvents())
+		assert.Equal(t, []string{"thing-3"}, queued(&p))
+	})
+}
+
+func TestEmitPropertyChecked(t *testing.T) {
+	p := New()
+	p.RegisterInstances(&connector.Instance{ID: "instance-1", ThingMapping: []connector.ThingMapping{
+		{ThingID: "thing-1", ExternalID: "external-1"},
+		{ThingID: "thing-2", ExternalID: "external-2"},
+	}})
+	p.Update()
+	p.RegisterThings(connctd.Thing{
+		ID: "thing-1",
+		Components: []connctd.Component{
+			{ID: "sensor", Properties: []connctd.Property{{ID: "temperature"}}},
+		},
+	})
+
+	tests := []struct {
+		name        string
+		instanceID  string
+		thingID     string
+		componentID string
+		propertyID  string
+		err         error
+	}{
+		{"valid address", "instance-1", "thing-1", "sensor", "temperature", nil},
+		{"unknown instance", "instance-2", "thing-1", "sensor", "temperature", ErrorUnknownInstance},
+		{"unmapped thing", "instance-1", "thing-3", "sensor", "temperature", ErrorUnmappedThing},
+		{"unregistered thing", "instance-1", "thing-2", "sensor", "temperature", ErrorUnknownThing},
+		{"unknown component", "instance-1", "thing-1", "sensors", "temperature", ErrorUnknownComponent},
+		{"unknown property", "instance-1", "thing-1", "sensor", "temprature", ErrorUnknownProperty},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := p.EmitPropertyChecked(test.instanceID, test.thingID, test.componentID, test.propertyID, "21.5")
+			assert.Equal(t, test.err, err)
+
+			length, _ := p.UpdateChannelDepth()
+			if test.err != nil {
+				assert.Equal(t, 0, length)
+				return
+			}
+
+			assert.Equal(t, 1, length)
+			update := <-p.UpdateChannel()
+			assert.Equal(t, &connector.PropertyUpdateEvent{InstanceId: test.instanceID, ThingId: test.thingID, ComponentId: test.componentID, PropertyId: test.propertyID, Value: "21.5"}, update.PropertyUpdateEvent)
+		})
+	}
+}
+
+func TestRegisteredIDs(t *testing.T) {
+	p := New()
+	p.RegisterInstallations(&connector.Installation{ID: "installation-2"}, &connector.Installation{ID: "installation-1"})
+	p.RegisterInstances(&connector.Instance{ID: "instance-1"}, &connector.Instance{ID: "instance-2"})
+
+	// pending registrations are reported before they are applied
+	assert.Equal(t, []string{"installation-1", "installation-2"}, p.RegisteredInstallationIDs())
+	assert.Equal(t, []string{"instance-1", "instance-2"}, p.RegisteredInstanceIDs())
+
+	p.Update()
+	assert.NoError(t, p.RemoveInstallation("installation-2"))
+	assert.NoError(t, p.RemoveInstance("instance-1"))
+	assert.Equal(t, []string{"installation-1"}, p.RegisteredInstallationIDs())
+	assert.Equal(t, []string{"instance-2"}, p.RegisteredInstanceIDs())
+}
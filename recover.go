@@ -0,0 +1,37 @@
+package connector
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/go-logr/logr"
+)
+
+// RecoverMiddleware returns a middleware recovering panics of the wrapped handler, e.g. of a custom ConnectorService or provider.
+// Recovered panics are logged with a stack trace and answered with ErrorInternal, so a single bad request does not stop the connector.
+// Panics with http.ErrAbortHandler are passed on, since they are used to abort a response on purpose.
+func RecoverMiddleware(logger logr.Logger) func(http.Handler) http.Handler {
+	if logger.GetSink() == nil {
+		logger = logr.Discard()
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+				if recovered == http.ErrAbortHandler {
+					panic(recovered)
+				}
+
+				logger.Error(fmt.Errorf("%v", recovered), "Recovered from panic while handling request", "method", r.Method, "path", r.URL.Path, "stack", string(debug.Stack()))
+				writeError(w, ErrorInternal)
+			}()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package connector
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-logr/logr/funcr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecoverMiddleware(t *testing.T) {
+	var logs []string
+	logger := funcr.New(func(prefix, args string) { logs = append(logs, args) }, funcr.Options{})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("something went wrong")
+	})
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	server := httptest.NewServer(RecoverMiddleware(logger)(mux))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/panic")
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.NotContains(t, string(body), "something went wrong")
+	require.Len(t, logs, 1)
+	assert.Contains(t, logs[0], "something went wrong")
+	assert.Contains(t, logs[0], "stack")
+
+	// the server keeps serving requests
+	resp, err = http.Get(server.URL + "/ok")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
@@ -0,0 +1,76 @@
+package connector
+
+// redactedTokenPrefixLength is the number of characters of a token kept when it is redacted.
+// Shorter tokens are redacted completely.
+const redactedTokenPrefixLength = 4
+
+// redactTokenValue returns a representation of the token that is safe to be logged.
+// It keeps a short prefix, so tokens can still be told apart in logs, if the token is long enough.
+func redactTokenValue(token string) string {
+	if len(token) <= 2*redactedTokenPrefixLength {
+		return redactedToken
+	}
+	return token[:redactedTokenPrefixLength] + "..." + redactedToken
+}
+
+// String implements fmt.Stringer and returns the redacted token.
+// Use a conversion to string to retrieve the token itself.
+func (t InstallationToken) String() string {
+	return redactTokenValue(string(t))
+}
+
+// MarshalLog implements logr.Marshaler and returns the redacted token.
+func (t InstallationToken) MarshalLog() interface{} {
+	return t.String()
+}
+
+// String implements fmt.Stringer and returns the redacted token.
+// Use a conversion to string to retrieve the token itself.
+func (t InstantiationToken) String() string {
+	return redactTokenValue(string(t))
+}
+
+// MarshalLog implements logr.Marshaler and returns the redacted token.
+func (t InstantiationToken) MarshalLog() interface{} {
+	return t.String()
+}
+
+// MarshalLog implements logr.Marshaler and returns the request with redacted token.
+func (i InstallationRequest) MarshalLog() interface{} {
+	type request InstallationRequest
+	i.Token = InstallationToken(i.Token.String())
+	return request(i)
+}
+
+// MarshalLog implements logr.Marshaler and returns the request with redacted token.
+func (i InstantiationRequest) MarshalLog() interface{} {
+	type request InstantiationRequest
+	i.Token = InstantiationToken(i.Token.String())
+	return request(i)
+}
+
+// MarshalLog implements logr.Marshaler and returns the installation with redacted token.
+func (i Installation) MarshalLog() interface{} {
+	type installation Installation
+	i.Token = InstallationToken(i.Token.String())
+	return installation(i)
+}
+
+// MarshalLog implements logr.Marshaler and returns the instance with redacted token.
+func (i Instance) MarshalLog() interface{} {
+	type instance Instance
+	i.Token = InstantiationToken(i.Token.String())
+	return instance(i)
+}
+
+// String implements fmt.Stringer and returns the id of the secret with redacted value.
+func (s Secret) String() string {
+	return s.ID + "=" + redactedToken
+}
+
+// MarshalLog implements logr.Marshaler and returns the secret with redacted value.
+func (s Secret) MarshalLog() interface{} {
+	type secret Secret
+	s.Value = redactedToken
+	return secret(s)
+}
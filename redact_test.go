@@ -0,0 +1,63 @@
+package connector
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	stdlog "log"
+	"testing"
+
+	"github.com/go-logr/stdr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoggedTokensAreRedacted(t *testing.T) {
+	var buf bytes.Buffer
+	logger := stdr.New(stdlog.New(&buf, "", 0))
+
+	installationToken := InstallationToken("installation-secret-token")
+	instanceToken := InstantiationToken("instance-secret-token")
+
+	logger.WithValues("installationRequest", InstallationRequest{ID: "installation-1", Token: installationToken}).Info("Received an installation request")
+	logger.WithValues("instantiationRequest", &InstantiationRequest{ID: "instance-1", Token: instanceToken}).Info("Received an instantiation request")
+	logger.WithValues("installation", Installation{ID: "installation-1", Token: installationToken}).Info("Installation")
+	logger.WithValues("instance", &Instance{ID: "instance-1", Token: instanceToken}).Info("Instance")
+	logger.WithValues("token", instanceToken).Info("Token")
+
+	output := buf.String()
+	assert.Contains(t, output, "installation-1")
+	assert.Contains(t, output, "inst...REDACTED")
+	assert.NotContains(t, output, string(installationToken))
+	assert.NotContains(t, output, string(instanceToken))
+
+	// formatting redacts the token as well
+	assert.NotContains(t, fmt.Sprintf("%v %+v", InstallationRequest{Token: installationToken}, Instance{Token: instanceToken}), "secret")
+	assert.Equal(t, redactedToken, InstantiationToken("short").String())
+}
+
+func TestTokensAreNotRedactedInMessages(t *testing.T) {
+	request := InstantiationRequest{ID: "instance-1", Token: "instance-secret-token"}
+
+	body, err := json.Marshal(request)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `"token":"instance-secret-token"`)
+
+	var decoded InstantiationRequest
+	require.NoError(t, json.Unmarshal(body, &decoded))
+	assert.Equal(t, request, decoded)
+}
+
+func TestLoggedSecretsAreRedacted(t *testing.T) {
+	var buf bytes.Buffer
+	logger := stdr.New(stdlog.New(&buf, "", 0))
+
+	secrets := []Secret{{ID: "oauth-token", Value: "very-secret-value"}}
+	logger.WithValues("secrets", secrets).Info("Stored secrets")
+	logger.V(0).Info("Stored secret", "secret", secrets[0])
+
+	output := buf.String()
+	assert.Contains(t, output, "oauth-token")
+	assert.NotContains(t, output, "very-secret-value")
+	assert.NotContains(t, fmt.Sprintf("%v %+v %s", secrets, secrets[0], secrets), "very-secret-value")
+}
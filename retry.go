@@ -0,0 +1,193 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/connctd/connector-go/connctd"
+)
+
+// RetryPolicy defines how calls of a client created with NewRetryingClient are retried.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts including the first call. Values below 2 disable retries.
+	MaxAttempts int
+
+	// BaseDelay is the delay before the first retry. It is doubled for each further retry.
+	BaseDelay time.Duration
+
+	// MaxDelay limits the delay between two attempts. Zero means unlimited.
+	MaxDelay time.Duration
+
+	// Retryable decides whether a failed call is retried. IsRetryable is used if it is nil.
+	Retryable func(err error) bool
+
+	// RetryCreateThing enables retries of CreateThing.
+	// The platform does not support idempotency keys for thing creation, so a retry after a lost response
+	// can create the thing twice. Only enable this if duplicated things are acceptable.
+	RetryCreateThing bool
+}
+
+// DefaultRetryPolicy retries three times with a delay starting at 100ms.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   100 * time.Millisecond,
+	MaxDelay:    2 * time.Second,
+}
+
+// IsRetryable returns true for errors that are likely transient: network errors and errors marked retryable,
+// see KindOf. Errors caused by the context of the caller are never retryable.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	return KindOf(err) == ErrorKindRetryable
+}
+
+// retryingClient decorates a client with retries.
+type retryingClient struct {
+	inner  Client
+	policy RetryPolicy
+}
+
+// NewRetryingClient wraps the given client and retries failed calls according to the policy.
+// It can be used to add retries to any Client implementation, e.g. the one returned by NewClient.
+func NewRetryingClient(inner Client, policy RetryPolicy) Client {
+	if policy.Retryable == nil {
+		policy.Retryable = IsRetryable
+	}
+	return &retryingClient{inner: inner, policy: policy}
+}
+
+// retry calls f until it succeeds, returns an error that is not retryable, the attempts are exhausted or the context is done.
+func (c *retryingClient) retry(ctx context.Context, f func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := f()
+		if err == nil || attempt >= c.policy.MaxAttempts || !c.policy.Retryable(err) {
+			return err
+		}
+
+		timer := time.NewTimer(retryDelay(c.policy.BaseDelay, c.policy.MaxDelay, 0, attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+// retryDelay returns the exponential delay before the given retry, starting with 1.
+// The given fraction of the delay is randomized.
+func retryDelay(baseDelay time.Duration, maxDelay time.Duration, jitter float64, retry int) time.Duration {
+	delay := baseDelay
+	for i := 1; i < retry && (maxDelay <= 0 || delay < maxDelay); i++ {
+		delay *= 2
+	}
+	if maxDelay > 0 && delay > maxDelay {
+		delay = maxDelay
+	}
+
+	if jitter > 0 {
+		if jitter > 1 {
+			jitter = 1
+		}
+		delay -= time.Duration(jitter * rand.Float64() * float64(delay))
+	}
+	return delay
+}
+
+// CreateThing implements interface definition. It is only retried if RetryCreateThing is enabled.
+func (c *retryingClient) CreateThing(ctx context.Context, token InstantiationToken, thing connctd.Thing) (result connctd.Thing, err error) {
+	if !c.policy.RetryCreateThing {
+		return c.inner.CreateThing(ctx, token, thing)
+	}
+
+	err = c.retry(ctx, func() error {
+		result, err = c.inner.CreateThing(ctx, token, thing)
+		return err
+	})
+	return result, err
+}
+
+// UpdateThingPropertyValue implements interface definition.
+func (c *retryingClient) UpdateThingPropertyValue(ctx context.Context, token InstantiationToken, thingID string, componentID string, propertyID string, value string, lastUpdate time.Time) error {
+	return c.retry(ctx, func() error {
+		return c.inner.UpdateThingPropertyValue(ctx, token, thingID, componentID, propertyID, value, lastUpdate)
+	})
+}
+
+// UpdateThingPropertyValueWithUnit implements interface definition.
+func (c *retryingClient) UpdateThingPropertyValueWithUnit(ctx context.Context, token InstantiationToken, thingID string, componentID string, propertyID string, value string, unit string, lastUpdate time.Time) error {
+	return c.retry(ctx, func() error {
+		return c.inner.UpdateThingPropertyValueWithUnit(ctx, token, thingID, componentID, propertyID, value, unit, lastUpdate)
+	})
+}
+
+// UpdateThingStatus implements interface definition.
+func (c *retryingClient) UpdateThingStatus(ctx context.Context, token InstantiationToken, thingID string, status connctd.StatusType) error {
+	return c.retry(ctx, func() error {
+		return c.inner.UpdateThingStatus(ctx, token, thingID, status)
+	})
+}
+
+// UpdateActionStatus implements interface definition.
+func (c *retryingClient) UpdateActionStatus(ctx context.Context, token InstantiationToken, actionRequestID string, status ActionRequestStatus, err string) error {
+	return c.retry(ctx, func() error {
+		return c.inner.UpdateActionStatus(ctx, token, actionRequestID, status, err)
+	})
+}
+
+// UpdateInstallationState implements interface definition.
+func (c *retryingClient) UpdateInstallationState(ctx context.Context, token InstallationToken, state InstallationState, details json.RawMessage) error {
+	return c.retry(ctx, func() error {
+		return c.inner.UpdateInstallationState(ctx, token, state, details)
+	})
+}
+
+// UpdateInstanceState implements interface definition.
+func (c *retryingClient) UpdateInstanceState(ctx context.Context, token InstantiationToken, state InstantiationState, details json.RawMessage) error {
+	return c.retry(ctx, func() error {
+		return c.inner.UpdateInstanceState(ctx, token, state, details)
+	})
+}
+
+// DeleteThing implements interface definition.
+func (c *retryingClient) DeleteThing(ctx context.Context, token InstantiationToken, thingID string) error {
+	return c.retry(ctx, func() error {
+		return c.inner.DeleteThing(ctx, token, thingID)
+	})
+}
+
+// ListThings implements interface definition.
+func (c *retryingClient) ListThings(ctx context.Context, token InstantiationToken) (things []connctd.Thing, err error) {
+	err = c.retry(ctx, func() error {
+		things, err = c.inner.ListThings(ctx, token)
+		return err
+	})
+	return things, err
+}
+
+func (c *retryingClient) GetThing(ctx context.Context, token InstantiationToken, thingID string) (thing connctd.Thing, err error) {
+	err = c.retry(ctx, func() error {
+		thing, err = c.inner.GetThing(ctx, token, thingID)
+		return err
+	})
+	return thing, err
+}
+
+// UpdateThingBatch implements interface definition.
+func (c *retryingClient) UpdateThingBatch(ctx context.Context, token InstantiationToken, thingID string, batch UpdateThingBatchRequest) error {
+	return c.retry(ctx, func() error {
+		return c.inner.UpdateThingBatch(ctx, token, thingID, batch)
+	})
+}
@@ -0,0 +1,107 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/connctd/connector-go/connctd"
+	"github.com/stretchr/testify/assert"
+)
+
+// flakyClient fails the first failures calls with err.
+type flakyClient struct {
+	Client
+
+	failures int
+	err      error
+	calls    int
+}
+
+func (c *flakyClient) call() error {
+	c.calls++
+	if c.calls <= c.failures {
+		return c.err
+	}
+	return nil
+}
+
+func (c *flakyClient) UpdateThingStatus(ctx context.Context, token InstantiationToken, thingID string, status connctd.StatusType) error {
+	return c.call()
+}
+
+func (c *flakyClient) CreateThing(ctx context.Context, token InstantiationToken, thing connctd.Thing) (connctd.Thing, error) {
+	if err := c.call(); err != nil {
+		return connctd.Thing{}, err
+	}
+	thing.ID = "thing-1"
+	return thing, nil
+}
+
+var testRetryPolicy = RetryPolicy{MaxAttempts: 4, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond}
+
+func TestRetryingClient(t *testing.T) {
+	unavailable := NewError("SERVICE_UNAVAILABLE", "Service unavailable", http.StatusServiceUnavailable)
+	badRequest := NewError("BAD_REQUEST", "Bad request", http.StatusBadRequest)
+
+	tests := []struct {
+		name          string
+		failures      int
+		err           error
+		expectedCalls int
+		expectedError error
+	}{
+		{"success", 0, nil, 1, nil},
+		{"succeeds after failures", 3, unavailable, 4, nil},
+		{"attempts exhausted", 5, unavailable, 4, unavailable},
+		{"network error", 2, &net.OpError{Op: "dial", Err: errors.New("connection refused")}, 3, nil},
+		{"no retries on client errors", 3, badRequest, 1, badRequest},
+		{"no retries on unclassified errors", 3, ErrorUnexpectedStatusCode, 1, ErrorUnexpectedStatusCode},
+		{"platform server error", 2, &APIError{StatusCode: http.StatusBadGateway}, 3, nil},
+		{"no retries on platform client errors", 2, &APIError{StatusCode: http.StatusNotFound}, 1, &APIError{StatusCode: http.StatusNotFound}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			inner := &flakyClient{failures: test.failures, err: test.err}
+			client := NewRetryingClient(inner, testRetryPolicy)
+
+			err := client.UpdateThingStatus(context.Background(), "token", "thing-1", connctd.StatusTypeAvailable)
+			assert.Equal(t, test.expectedError, err)
+			assert.Equal(t, test.expectedCalls, inner.calls)
+		})
+	}
+}
+
+func TestRetryingClientCreateThing(t *testing.T) {
+	unavailable := NewError("SERVICE_UNAVAILABLE", "Service unavailable", http.StatusServiceUnavailable)
+
+	// thing creation is not retried by default
+	inner := &flakyClient{failures: 1, err: unavailable}
+	_, err := NewRetryingClient(inner, testRetryPolicy).CreateThing(context.Background(), "token", connctd.Thing{})
+	assert.Equal(t, unavailable, err)
+	assert.Equal(t, 1, inner.calls)
+
+	policy := testRetryPolicy
+	policy.RetryCreateThing = true
+	inner = &flakyClient{failures: 1, err: unavailable}
+	thing, err := NewRetryingClient(inner, policy).CreateThing(context.Background(), "token", connctd.Thing{})
+	assert.NoError(t, err)
+	assert.Equal(t, "thing-1", thing.ID)
+	assert.Equal(t, 2, inner.calls)
+}
+
+func TestRetryingClientStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	inner := &flakyClient{failures: 3, err: Retryable(errors.New("timeout"))}
+	policy := testRetryPolicy
+	policy.BaseDelay = time.Hour
+	err := NewRetryingClient(inner, policy).UpdateThingStatus(ctx, "token", "thing-1", connctd.StatusTypeAvailable)
+	assert.Error(t, err)
+	assert.Equal(t, 1, inner.calls)
+}
@@ -5,6 +5,9 @@ package connector
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/connctd/connector-go/connctd"
 )
@@ -21,6 +24,11 @@ type ConnectorService interface {
 	// The status code will be set to one defined in the error and the InstallationResponse will be returned to the connctd platform.
 	AddInstallation(ctx context.Context, request InstallationRequest) (*InstallationResponse, error)
 
+	// UpdateInstallation is called by the ConnectorHandler when it received an update of an existing installation.
+	// The configuration and token of the installation are replaced by the ones in the request.
+	// Responses and errors are handled like the ones of AddInstallation.
+	UpdateInstallation(ctx context.Context, request InstallationRequest) (*InstallationResponse, error)
+
 	// RemoveInstallation is called whenever an installation is removed by the the connctd platform.
 	// The connector should remove the installation and can return an error if needed.
 	// Regardless of the return value, the installation is removed from the connctd platform.
@@ -45,13 +53,21 @@ type ConnectorService interface {
 	// If the action is successfully completed, the service should return nil.
 	// In case of an error, the service should respond with an appropriate error from errors.go.
 	PerformAction(ctx context.Context, request ActionRequest) (*ActionResponse, error)
+
+	// PerformActions is used for batched dispatch of several action requests.
+	// It returns one ActionResponse per request in the order of the requests.
+	// Failing actions are reported with a failed ActionResponse, an error is only returned if the whole batch failed.
+	PerformActions(ctx context.Context, requests []ActionRequest) ([]*ActionResponse, error)
 }
 
 // ThingTemplate describes the thing together with an external ID that is created for each new instance.
 // If the connector doesn't need an external ID it can be left blank.
+// Templates can depend on the external IDs of other templates, e.g. the things of child devices on the thing
+// of their gateway. The default service creates dependencies before the templates depending on them.
 type ThingTemplate struct {
 	Thing      connctd.Thing
 	ExternalID string
+	DependsOn  []string
 }
 
 // ThingTemplates is used by the default connector service to create a set of connctd.Thing for each new instantiation request.
@@ -61,25 +77,140 @@ type ThingTemplate struct {
 // - register the new thing with the provider
 type ThingTemplates func(request InstantiationRequest) []ThingTemplate
 
+// ValidateTemplates verifies the things of all templates and ensures that the normalized external IDs are unique within the set.
+// Blank external IDs are not checked for uniqueness.
+// The default service validates the templates before creating any thing, so an invalid set does not result in a partially created instance.
+func ValidateTemplates(templates []ThingTemplate) error {
+	externalIDs := make(map[string]bool, len(templates))
+	for _, template := range templates {
+		thing := template.Thing
+		if err := thing.Verify(); err != nil {
+			return fmt.Errorf("invalid thing template %s: %w", template.ExternalID, err)
+		}
+
+		// blank external IDs are allowed for any number of templates
+		externalID := NormalizeExternalID(template.ExternalID)
+		if externalID == "" {
+			continue
+		}
+		if externalIDs[externalID] {
+			return fmt.Errorf("duplicate external id %s in thing templates", template.ExternalID)
+		}
+		externalIDs[externalID] = true
+	}
+	return nil
+}
+
+var (
+	ErrorUnknownDependency = errors.New("thing template depends on an unknown external id")
+	ErrorDependencyCycle   = errors.New("thing templates contain a dependency cycle")
+)
+
+// SortTemplates orders the templates so that every template follows the templates it depends on.
+// Otherwise the order of the templates is kept. It returns ErrorUnknownDependency if a template depends on an
+// external ID of none of the templates and ErrorDependencyCycle if templates depend on each other.
+// The templates are expected to be validated with ValidateTemplates beforehand.
+func SortTemplates(templates []ThingTemplate) ([]ThingTemplate, error) {
+	indices := make(map[string]int, len(templates))
+	for i, template := range templates {
+		indices[NormalizeExternalID(template.ExternalID)] = i
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	states := make([]int, len(templates))
+	sorted := make([]ThingTemplate, 0, len(templates))
+	var path []string
+
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch states[i] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("%w: %s -> %s", ErrorDependencyCycle, strings.Join(path, " -> "), templates[i].ExternalID)
+		}
+
+		states[i] = visiting
+		path = append(path, templates[i].ExternalID)
+		for _, dependency := range templates[i].DependsOn {
+			j, ok := indices[NormalizeExternalID(dependency)]
+			if !ok {
+				return fmt.Errorf("%w: %s depends on %s", ErrorUnknownDependency, templates[i].ExternalID, dependency)
+			}
+			if err := visit(j); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		states[i] = visited
+
+		sorted = append(sorted, templates[i])
+		return nil
+	}
+
+	for i := range templates {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
 // Database interface is used in the default service to persist new installations, instances, configurations and external device mappings.
 // The SDK provides a default implementation supporting Postgresql, Mysql and Sqlite3.
 type Database interface {
 	AddInstallation(ctx context.Context, installationRequest InstallationRequest) error
 	AddInstallationConfiguration(ctx context.Context, installationId string, config []Configuration) error
+	UpdateInstallation(ctx context.Context, installationRequest InstallationRequest) error
+	UpdateInstallationToken(ctx context.Context, installationId string, token InstallationToken) error
 	GetInstallations(ctx context.Context) ([]*Installation, error)
+	GetInstallationConfiguration(ctx context.Context, installationId string) ([]Configuration, error)
+	GetInstallationByToken(ctx context.Context, token InstallationToken) (*Installation, error)
 	RemoveInstallation(ctx context.Context, installationId string) error
 	GetInstancesInstallationConfiguration(ctx context.Context, instanceID string) ([]*Configuration, error)
 
 	AddInstance(ctx context.Context, instantiationRequest InstantiationRequest) error
 	AddInstanceConfiguration(ctx context.Context, instanceId string, config []Configuration) error
+	UpdateInstanceToken(ctx context.Context, instanceId string, token InstantiationToken) error
+	SetInstanceConfigurationValue(ctx context.Context, instanceId string, key string, value string) error
+	DeleteInstanceConfigurationValue(ctx context.Context, instanceId string, key string) error
 	GetInstance(ctx context.Context, instanceId string) (*Instance, error)
 	GetInstances(ctx context.Context) ([]*Instance, error)
 	GetInstanceByThingId(ctx context.Context, thingId string) (*Instance, error)
+	GetInstancesByThingIds(ctx context.Context, thingIds []string) (map[string]*Instance, error)
 	GetInstanceConfiguration(ctx context.Context, instanceId string) ([]Configuration, error)
+	GetInstanceConfigurations(ctx context.Context, instanceIds []string) (map[string][]Configuration, error)
+	AddInstanceSecrets(ctx context.Context, instanceId string, secrets []Secret) error
+	GetInstanceSecrets(ctx context.Context, instanceId string) ([]Secret, error)
 	GetMappingByInstanceId(ctx context.Context, instanceId string) ([]ThingMapping, error)
 	GetMappingByExternalId(ctx context.Context, instanceId string, externalID string) (*ThingMapping, error)
 	RemoveInstance(ctx context.Context, instanceId string) error
 
 	AddThingMapping(ctx context.Context, instanceID string, thingID string, externalId string) error
 	RemoveThingMapping(ctx context.Context, instanceID string, thingID string) error
+	UpdateThingMappingExternalId(ctx context.Context, instanceID string, thingID string, newExternalID string) error
+
+	CountInstallations(ctx context.Context) (int, error)
+	CountInstances(ctx context.Context) (int, error)
+	CountThingMappings(ctx context.Context) (int, error)
+
+	SetLastPropertyValue(ctx context.Context, value PropertyValue) error
+	GetLastPropertyValues(ctx context.Context, thingId string) ([]PropertyValue, error)
+
+	SetPendingInstanceState(ctx context.Context, state PendingInstanceState) error
+	GetPendingInstanceStates(ctx context.Context) ([]PendingInstanceState, error)
+	GetPendingInstanceState(ctx context.Context, instanceId string) (*PendingInstanceState, error)
+	RemovePendingInstanceState(ctx context.Context, state PendingInstanceState) error
+}
+
+// TransactionalDatabase can be implemented by databases to store an installation or instance together with its
+// configuration atomically. The default service uses it if available so that a failure does not leave an
+// installation or instance without its configuration behind.
+type TransactionalDatabase interface {
+	AddInstallationWithConfiguration(ctx context.Context, installationRequest InstallationRequest) error
+	AddInstanceWithConfiguration(ctx context.Context, instantiationRequest InstantiationRequest) error
 }
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"net/url"
+
+	"github.com/connctd/connector-go"
+)
+
+// defaultClientOptions returns the options of clients for base URLs if no ClientOptions are configured.
+var defaultClientOptions = connector.DefaultOptions
+
+// clientFor returns the client used for calls on behalf of the installation.
+// If a BaseURLResolver is configured and returns a base URL for the installation, a client for this base URL is
+// created once and reused. Otherwise the client passed to NewConnectorService is used.
+func (s *DefaultConnectorService) clientFor(installationId string) (connector.Client, error) {
+	if s.options.BaseURLResolver == nil {
+		return s.connctdClient, nil
+	}
+
+	baseURL := s.options.BaseURLResolver(installationId)
+	if baseURL == nil {
+		return s.connctdClient, nil
+	}
+
+	s.clientsMutex.Lock()
+	defer s.clientsMutex.Unlock()
+
+	if client, ok := s.clients[baseURL.String()]; ok {
+		return client, nil
+	}
+
+	newClient := s.options.NewClientForBaseURL
+	if newClient == nil {
+		newClient = func(baseURL *url.URL) (connector.Client, error) {
+			opts := *defaultClientOptions()
+			if s.options.ClientOptions != nil {
+				opts = *s.options.ClientOptions
+			}
+			opts.ConnctdBaseURL = baseURL
+			return connector.NewClient(&opts, s.logger)
+		}
+	}
+
+	client, err := newClient(baseURL)
+	if err != nil {
+		s.scopedLogger(installationId, "").Error(err, "failed to create client for base url", "baseUrl", baseURL.String())
+		return nil, err
+	}
+
+	s.clients[baseURL.String()] = client
+	return client, nil
+}
@@ -6,8 +6,12 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
+	"runtime/debug"
+	"sync"
 	"time"
 
 	"github.com/connctd/connector-go/connctd"
@@ -24,6 +28,37 @@ type DefaultConnectorService struct {
 	provider       connector.Provider
 	thingTemplates connector.ThingTemplates
 	options        ConnectorServiceOptions
+
+	// things caches the things created by the service, used to validate property updates
+	thingsMutex sync.RWMutex
+	things      map[string]connctd.Thing
+
+	// liveness tracks the last property update of things matched by a StatusRule
+	livenessMutex sync.Mutex
+	liveness      map[string]*thingLiveness
+
+	// clients caches the clients created for base URLs returned by the BaseURLResolver
+	clientsMutex sync.Mutex
+	clients      map[string]connector.Client
+
+	// stateReports tracks the instances with a running state report,
+	// true if a newer state was stored while the report was running
+	stateReportsMutex sync.Mutex
+	stateReports      map[string]bool
+
+	// instanceStates tracks the last known state of instances that were reported or are created asynchronously.
+	// Instances without a tracked state are considered operational.
+	instanceStatesMutex sync.RWMutex
+	instanceStates      map[string]connector.InstantiationState
+
+	// pendingActions holds the timers failing pending action requests, by action request id
+	pendingActionsMutex sync.Mutex
+	pendingActions      map[string]*time.Timer
+
+	// eventHandlers tracks the goroutines started by the EventHandler, which return once shutdown is closed
+	eventHandlers sync.WaitGroup
+	shutdown      chan struct{}
+	shutdownOnce  sync.Once
 }
 
 type ConnectorServiceOptions struct {
@@ -35,8 +70,116 @@ type ConnectorServiceOptions struct {
 	// if true instance creation will fail if at least one thing can not be created. You cannot
 	// enforce thing creation if asyncInstanceCreation is enabled
 	EnforceThingCreation bool
+
+	// if true property updates are checked against the components and properties of the thing, including
+	// their value types and constraints, before they are sent to the connctd platform. Things are known from their creation or, after a restart,
+	// from the thing templates of the instance. Updates of unknown things are not checked.
+	ValidatePropertyUpdates bool
+
+	// if true the parameters of action requests are checked against the parameters declared by the action
+	// before the provider is called. Like property updates, only actions of known things are checked.
+	ValidateActionParameters bool
+
+	// if true things are recreated from their template if the provider reports them as missing at the platform
+	// by returning connector.ErrorThingNotFound from RequestAction. The action is retried once with the recreated thing.
+	RecreateMissingThings bool
+
+	// if true SyncThings deletes the things of an instance whose external ID is not part of its thing templates anymore.
+	DeleteStaleThings bool
+
+	// InstallationRemovalOrder defines whether installations are removed from the provider or from the database first.
+	// Removing them from the provider first stops device traffic before the installation token is lost,
+	// removing them from the database first ensures that a failing provider cleanup does not keep the installation.
+	InstallationRemovalOrder RemovalOrder
+
+	// if true a failing removal from the provider aborts the installation removal with an error.
+	// If the provider is notified first, the installation stays in the database. Otherwise it is already removed from
+	// the database and the error is only returned so the platform can retry the removal.
+	// By default provider errors are only logged.
+	AbortRemovalOnProviderError bool
+
+	// StatusRules mark things as unavailable if they did not receive a property update within the staleness timeout.
+	// They are marked as available again with the next property update. Rules are checked by the EventHandler.
+	StatusRules []StatusRule
+
+	// OnEventPanic is called with the update event and the recovered value whenever processing
+	// an update event panics. The event handler continues with the next event afterwards.
+	OnEventPanic func(update connector.UpdateEvent, recovered interface{})
+
+	// if true the value of each successful property update is stored in the database.
+	// The last known values can be retrieved with GetLastPropertyValues, e.g. to push them again after a restart.
+	// This is disabled by default since it adds a database write to each property update.
+	PersistPropertyValues bool
+
+	// BaseURLResolver returns the connctd platform base URL used for all calls on behalf of an installation,
+	// e.g. to serve staging and production installations from a single service. If it is not set or returns nil,
+	// the client passed to NewConnectorService is used.
+	BaseURLResolver func(installationID string) *url.URL
+
+	// NewClientForBaseURL creates the clients for base URLs returned by the BaseURLResolver.
+	// Each client is created once per base URL. By default connector.NewClient is used with a copy of the ClientOptions.
+	NewClientForBaseURL func(baseURL *url.URL) (connector.Client, error)
+
+	// ClientOptions are the options of the client passed to NewConnectorService. The default NewClientForBaseURL
+	// only replaces their ConnctdBaseURL, so clients for other base URLs keep e.g. the HTTP client, retries and the rate limiter.
+	// If they are nil, the clients for other base URLs are created with connector.DefaultOptions.
+	ClientOptions *connector.ClientOptions
+
+	// StateReportBaseDelay is the delay before the first retry of a failed ReportInstanceState.
+	// It is doubled for each further retry up to StateReportMaxDelay. Defaults to one second and five minutes.
+	StateReportBaseDelay time.Duration
+	StateReportMaxDelay  time.Duration
+
+	// RegistrationBatchSize limits the number of existing instances registered with the provider at once during startup.
+	// The instances are registered in batches with a pause of RegistrationBatchPause in between, so a provider connecting
+	// each instance upstream is not overwhelmed. Zero registers all instances at once.
+	RegistrationBatchSize int
+	// RegistrationBatchPause is the pause between two batches. Defaults to 100ms.
+	RegistrationBatchPause time.Duration
+
+	// PendingActionTimeout bounds the time an action request stays pending. If the provider does not report the
+	// completion of a pending action within the timeout, e.g. via an ActionEvent, a failed status is sent instead.
+	// By default pending actions are not bounded.
+	PendingActionTimeout time.Duration
+
+	// EventRetryPolicy defines how failed property updates and action status updates of update events are retried.
+	// Only errors accepted by its Retryable function are retried, connector.IsRetryable by default.
+	// Retries run in a goroutine per event, so the event handler continues with the next event in the meantime
+	// and a retried update may arrive after updates of subsequent events. By default failed updates are not retried.
+	EventRetryPolicy connector.RetryPolicy
 }
 
+// RemovalOrder defines the order in which an installation is removed from the provider and the database.
+type RemovalOrder int
+
+// Removal orders:
+const (
+	// RemoveFromProviderFirst removes the installation from the provider and afterwards from the database.
+	RemoveFromProviderFirst RemovalOrder = iota
+	// RemoveFromDatabaseFirst removes the installation from the database and afterwards from the provider.
+	RemoveFromDatabaseFirst
+)
+
+// Errors returned by the default service:
+var (
+	ErrorUnknownComponent = errors.New("component does not exist")
+	ErrorUnknownProperty  = errors.New("property does not exist")
+
+	ErrorUpdateChannelConsumed = errors.New("update channel is already consumed by another event handler")
+
+	ErrorInvalidDetails = errors.New("details are not valid json")
+
+	ErrorInstanceNotOperational = errors.New("instance not operational")
+
+	ErrorPendingActionTimeout = errors.New("action was not completed in time")
+
+	ErrorEmptyBatch = errors.New("batch does not contain any update")
+	ErrorMixedBatch = errors.New("updates of a batch must refer to the same thing and instance")
+)
+
+// defaultRegistrationBatchPause is used if RegistrationBatchSize is set without RegistrationBatchPause.
+const defaultRegistrationBatchPause = 100 * time.Millisecond
+
 var DefaultConnectorServiceOptions = ConnectorServiceOptions{
 	AsyncInstanceCreation: false,
 	EnforceThingCreation:  true,
@@ -50,12 +193,19 @@ func NewConnectorService(dbClient connector.Database, connctdClient connector.Cl
 	}
 
 	connector := &DefaultConnectorService{
-		logger,
-		dbClient,
-		connctdClient,
-		provider,
-		thingTemplates,
-		options,
+		logger:         logger,
+		db:             dbClient,
+		connctdClient:  connctdClient,
+		provider:       provider,
+		thingTemplates: thingTemplates,
+		options:        options,
+		things:         make(map[string]connctd.Thing),
+		liveness:       make(map[string]*thingLiveness),
+		clients:        make(map[string]connector.Client),
+		stateReports:   make(map[string]bool),
+		instanceStates: make(map[string]connector.InstantiationState),
+		pendingActions: make(map[string]*time.Timer),
+		shutdown:       make(chan struct{}),
 	}
 
 	err := connector.init()
@@ -79,33 +229,230 @@ func (s *DefaultConnectorService) init() error {
 		return fmt.Errorf("failed to retrieve instance from db: %v", err)
 	}
 
-	s.provider.RegisterInstances(instances...)
+	s.registerExistingInstances(instances)
+
+	if s.cachesThings() {
+		for _, instance := range instances {
+			s.cacheThingsFromTemplates(instance)
+		}
+	}
+
+	if err := s.resumeStateReports(context.Background()); err != nil {
+		s.logger.Error(err, "Failed to retrieve pending instance states from db")
+		return fmt.Errorf("failed to retrieve pending instance states from db: %v", err)
+	}
 
 	return nil
 }
 
+// registerExistingInstances registers the instances with the provider in batches of RegistrationBatchSize.
+func (s *DefaultConnectorService) registerExistingInstances(instances []*connector.Instance) {
+	batchSize := s.options.RegistrationBatchSize
+	if batchSize <= 0 || batchSize >= len(instances) {
+		s.provider.RegisterInstances(instances...)
+		return
+	}
+
+	pause := s.options.RegistrationBatchPause
+	if pause <= 0 {
+		pause = defaultRegistrationBatchPause
+	}
+
+	for start := 0; start < len(instances); start += batchSize {
+		if start > 0 {
+			time.Sleep(pause)
+		}
+		end := start + batchSize
+		if end > len(instances) {
+			end = len(instances)
+		}
+		s.provider.RegisterInstances(instances[start:end]...)
+	}
+}
+
+// cacheThingsFromTemplates restores the things of an existing instance by matching
+// the external IDs of its thing mappings with the external IDs of the thing templates.
+func (s *DefaultConnectorService) cacheThingsFromTemplates(instance *connector.Instance) {
+	templates := s.thingTemplates(instantiationRequest(instance))
+
+	for _, mapping := range instance.ThingMapping {
+		for _, template := range templates {
+			if connector.NormalizeExternalID(template.ExternalID) == mapping.ExternalID {
+				s.cacheThing(mapping.ThingID, template.Thing)
+				break
+			}
+		}
+	}
+}
+
+// cachesThings reports whether things have to be cached for one of the validation options.
+func (s *DefaultConnectorService) cachesThings() bool {
+	return s.options.ValidatePropertyUpdates || s.options.ValidateActionParameters
+}
+
+// instantiationRequest reconstructs the request of an existing instance, e.g. to retrieve its thing templates.
+func instantiationRequest(instance *connector.Instance) connector.InstantiationRequest {
+	return connector.InstantiationRequest{
+		ID:             instance.ID,
+		InstallationID: instance.InstallationID,
+		Token:          instance.Token,
+		Configuration:  instance.Configuration,
+	}
+}
+
+func (s *DefaultConnectorService) cacheThing(thingID string, thing connctd.Thing) {
+	s.thingsMutex.Lock()
+	defer s.thingsMutex.Unlock()
+
+	s.things[thingID] = thing
+}
+
+// verifyPropertyUpdate checks that the component and property exist at the given thing
+// and that the value matches the value type and satisfies the constraints of the property.
+// It does not return an error if the thing is unknown.
+func (s *DefaultConnectorService) verifyPropertyUpdate(thingId, componentId, propertyId, value string) error {
+	s.thingsMutex.RLock()
+	thing, ok := s.things[thingId]
+	s.thingsMutex.RUnlock()
+
+	if !ok {
+		return nil
+	}
+
+	for _, component := range thing.Components {
+		if component.ID != componentId {
+			continue
+		}
+
+		for _, property := range component.Properties {
+			if property.ID == propertyId {
+				return property.ValidateValue(value)
+			}
+		}
+		return fmt.Errorf("%w: thing %s component %s property %s", ErrorUnknownProperty, thingId, componentId, propertyId)
+	}
+
+	return fmt.Errorf("%w: thing %s component %s", ErrorUnknownComponent, thingId, componentId)
+}
+
+// verifyActionParameters checks the parameters of the action request if the action is known.
+func (s *DefaultConnectorService) verifyActionParameters(actionRequest connector.ActionRequest) error {
+	s.thingsMutex.RLock()
+	thing, ok := s.things[actionRequest.ThingID]
+	s.thingsMutex.RUnlock()
+
+	if !ok {
+		return nil
+	}
+
+	for _, component := range thing.Components {
+		if component.ID != actionRequest.ComponentID {
+			continue
+		}
+
+		for _, action := range component.Actions {
+			if action.ID == actionRequest.ActionID {
+				return connector.ValidateActionRequestParameters(actionRequest, action.Parameters)
+			}
+		}
+	}
+
+	return nil
+}
+
+// scopedLogger returns a logger carrying the given installation and instance IDs,
+// so log output can be filtered per tenant. Empty IDs are omitted.
+func (s *DefaultConnectorService) scopedLogger(installationId string, instanceId string) logr.Logger {
+	logger := s.logger
+	if installationId != "" {
+		logger = logger.WithValues("installationId", installationId)
+	}
+	if instanceId != "" {
+		logger = logger.WithValues("instanceId", instanceId)
+	}
+	return logger
+}
+
+// cancelled returns the error of the context if it is done.
+// It is checked between the steps of an operation, so a cancelled request does not cause further side effects.
+func cancelled(ctx context.Context, logger logr.Logger) error {
+	if err := ctx.Err(); err != nil {
+		logger.Error(err, "Aborting since the request was cancelled")
+		return err
+	}
+	return nil
+}
+
 // AddInstallation is called by the HTTP handler when it receives an installation request.
 // It will persist the new installation and its configuration and register the new installation with the provider.
 func (s *DefaultConnectorService) AddInstallation(ctx context.Context, request connector.InstallationRequest) (*connector.InstallationResponse, error) {
-	s.logger.WithValues("installationRequest", request).Info("Received an installation request")
+	logger := s.scopedLogger(request.ID, "")
+	logger.WithValues("installationRequest", request).Info("Received an installation request")
 
-	if err := s.db.AddInstallation(ctx, request); err != nil {
-		s.logger.WithValues("installationRequest", request).Error(err, "Failed to add installation")
+	if err := s.storeInstallation(ctx, logger, request); err != nil {
 		return nil, err
 	}
 
-	if len(request.Configuration) > 0 {
-		if err := s.db.AddInstallationConfiguration(ctx, request.ID, request.Configuration); err != nil {
-			s.logger.WithValues("config", request.Configuration).Error(err, "Failed to add installation configuration")
-			return nil, err
+	if err := cancelled(ctx, logger); err != nil {
+		return nil, err
+	}
+
+	installation := &connector.Installation{
+		ID:            request.ID,
+		Token:         request.Token,
+		Configuration: request.Configuration,
+	}
+	s.provider.RegisterInstallations(installation)
+
+	if detailer, ok := s.provider.(connector.InstallationDetailer); ok {
+		details, err := detailer.InstallationDetails(installation)
+		if details = validDetails(logger, details, err); details != nil {
+			return &connector.InstallationResponse{Details: details}, nil
 		}
 	}
 
-	s.provider.RegisterInstallations(&connector.Installation{
+	return nil, nil
+}
+
+// validDetails returns the details provided by the provider if they are valid json.
+// Since the request itself succeeded, invalid details are only logged and dropped.
+func validDetails(logger logr.Logger, details json.RawMessage, err error) json.RawMessage {
+	if err != nil {
+		logger.Error(err, "Failed to retrieve details from provider")
+		return nil
+	}
+	if len(details) == 0 {
+		return nil
+	}
+	if !json.Valid(details) {
+		logger.Error(ErrorInvalidDetails, "Dropping details of provider", "details", string(details))
+		return nil
+	}
+	return details
+}
+
+// UpdateInstallation is called by the HTTP handler when it receives an update of an existing installation.
+// It will replace the persisted token and configuration and pass the updated installation to the provider.
+func (s *DefaultConnectorService) UpdateInstallation(ctx context.Context, request connector.InstallationRequest) (*connector.InstallationResponse, error) {
+	logger := s.scopedLogger(request.ID, "")
+	logger.WithValues("installationRequest", request).Info("Received an installation update")
+
+	if err := s.db.UpdateInstallation(ctx, request); err != nil {
+		logger.WithValues("installationRequest", request).Error(err, "Failed to update installation")
+		return nil, err
+	}
+
+	if err := cancelled(ctx, logger); err != nil {
+		return nil, err
+	}
+
+	if err := s.provider.UpdateInstallation(&connector.Installation{
 		ID:            request.ID,
 		Token:         request.Token,
 		Configuration: request.Configuration,
-	})
+	}); err != nil {
+		logger.Error(err, "tried to update installation that is not registered")
+	}
 
 	return nil, nil
 }
@@ -114,114 +461,282 @@ func (s *DefaultConnectorService) AddInstallation(ctx context.Context, request c
 // It will remove the installation from the database (including the installation token) and from the provider.
 // Note that we will not be able to communicate with the connctd platform about the removed installation after this, since the token is deleted.
 func (s *DefaultConnectorService) RemoveInstallation(ctx context.Context, installationId string) error {
-	s.logger.WithValues("installationId", installationId).Info("Received an installation removal request")
+	logger := s.scopedLogger(installationId, "")
+	logger.Info("Received an installation removal request")
+
+	if s.options.InstallationRemovalOrder == RemoveFromDatabaseFirst {
+		if err := s.removeInstallationFromDB(ctx, logger, installationId); err != nil {
+			return err
+		}
+		return s.removeInstallationFromProvider(logger, installationId)
+	}
 
+	if err := s.removeInstallationFromProvider(logger, installationId); err != nil {
+		return err
+	}
+	return s.removeInstallationFromDB(ctx, logger, installationId)
+}
+
+// removeInstallationFromProvider removes the installation from the provider.
+// Errors are only returned if AbortRemovalOnProviderError is enabled.
+func (s *DefaultConnectorService) removeInstallationFromProvider(logger logr.Logger, installationId string) error {
 	if err := s.provider.RemoveInstallation(installationId); err != nil {
-		s.logger.WithValues("installationID", installationId).Error(err, "tried to remove installation that is not registered")
+		logger.Error(err, "tried to remove installation that is not registered")
+		if s.options.AbortRemovalOnProviderError {
+			return err
+		}
 	}
+	return nil
+}
 
+// removeInstallationFromDB removes the installation and its instances from the database.
+func (s *DefaultConnectorService) removeInstallationFromDB(ctx context.Context, logger logr.Logger, installationId string) error {
 	if err := s.db.RemoveInstallation(ctx, installationId); err != nil {
-		s.logger.WithValues("installationId", installationId).Error(err, "failed to remove installation from db")
+		logger.Error(err, "failed to remove installation from db")
 		return err
 	}
 	return nil
 }
 
-// AddInstantiation is called by the HTTP handler when it receives an instantiation request.
-// It will persist the new instance, create new things for the instance
-// and register the new instance with the provider.
-func (s *DefaultConnectorService) AddInstance(ctx context.Context, request connector.InstantiationRequest) (*connector.InstantiationResponse, error) {
-	s.logger.WithValues("instantiationRequest", request).Info("Received an instantiation request")
+// storeInstallation persists the installation and its configuration. Databases implementing
+// connector.TransactionalDatabase store both atomically.
+func (s *DefaultConnectorService) storeInstallation(ctx context.Context, logger logr.Logger, request connector.InstallationRequest) error {
+	if db, ok := s.db.(connector.TransactionalDatabase); ok {
+		if err := db.AddInstallationWithConfiguration(ctx, request); err != nil {
+			logger.WithValues("installationRequest", request).Error(err, "Failed to add installation")
+			return err
+		}
+		return nil
+	}
+
+	if err := s.db.AddInstallation(ctx, request); err != nil {
+		logger.WithValues("installationRequest", request).Error(err, "Failed to add installation")
+		return err
+	}
+
+	if err := cancelled(ctx, logger); err != nil {
+		return err
+	}
+
+	if len(request.Configuration) > 0 {
+		if err := s.db.AddInstallationConfiguration(ctx, request.ID, request.Configuration); err != nil {
+			logger.WithValues("config", request.Configuration).Error(err, "Failed to add installation configuration")
+			return err
+		}
+	}
+
+	return nil
+}
+
+// storeInstance persists the instance and its configuration. Databases implementing
+// connector.TransactionalDatabase store both atomically.
+func (s *DefaultConnectorService) storeInstance(ctx context.Context, logger logr.Logger, request connector.InstantiationRequest) error {
+	if db, ok := s.db.(connector.TransactionalDatabase); ok {
+		if err := db.AddInstanceWithConfiguration(ctx, request); err != nil {
+			logger.WithValues("instantiationRequest", request).Error(err, "Failed to add instance")
+			return err
+		}
+		return nil
+	}
 
 	if err := s.db.AddInstance(ctx, request); err != nil {
-		s.logger.WithValues("instantiationRequest", request).Error(err, "Failed to add instance")
-		return nil, err
+		logger.WithValues("instantiationRequest", request).Error(err, "Failed to add instance")
+		return err
+	}
+
+	if err := cancelled(ctx, logger); err != nil {
+		return err
 	}
 
 	if len(request.Configuration) > 0 {
 		if err := s.db.AddInstanceConfiguration(ctx, request.ID, request.Configuration); err != nil {
-			s.logger.WithValues("config", request.Configuration).Error(err, "Failed to add instance configuration")
-			return nil, err
+			logger.WithValues("config", request.Configuration).Error(err, "Failed to add instance configuration")
+			return err
 		}
 	}
 
+	return nil
+}
+
+// AddInstantiation is called by the HTTP handler when it receives an instantiation request.
+// It will persist the new instance, create new things for the instance
+// and register the new instance with the provider.
+func (s *DefaultConnectorService) AddInstance(ctx context.Context, request connector.InstantiationRequest) (*connector.InstantiationResponse, error) {
+	logger := s.scopedLogger(request.InstallationID, request.ID)
+	logger.WithValues("instantiationRequest", request).Info("Received an instantiation request")
+
+	// requests to the platform caused by the instantiation carry its message ID
+	ctx = connector.WithMessageID(ctx, request.MessageID)
+
+	// templates are checked before the instance is stored, so an invalid set does not leave a stored instance behind
 	thingTemplates := s.thingTemplates(request)
+	if err := connector.ValidateTemplates(thingTemplates); err != nil {
+		logger.Error(err, "Invalid thing templates")
+		return nil, err
+	}
+
+	thingTemplates, err := connector.SortTemplates(thingTemplates)
+	if err != nil {
+		logger.Error(err, "Invalid thing template dependencies")
+		return nil, err
+	}
+
+	if err := s.storeInstance(ctx, logger, request); err != nil {
+		return nil, err
+	}
+
+	if err := cancelled(ctx, logger); err != nil {
+		return nil, err
+	}
 
 	if s.options.AsyncInstanceCreation {
-		go s.synchronizeThings(context.Background(), request.ID, request.InstallationID, request.Token, request.Configuration, thingTemplates)
+		// actions are rejected until all things are created, the platform learns about the outcome via the reported state
+		s.setInstanceState(request.ID, connector.InstantiationStateOngoing)
+		go func() {
+			ctx := connector.WithMessageID(context.Background(), request.MessageID)
+			state := connector.InstantiationStateComplete
+			if _, err := s.registerInstance(ctx, request, thingTemplates); err != nil {
+				logger.Error(err, "Failed to create instance asynchronously")
+				state = connector.InstantiationStateFailed
+			}
+			if err := s.ReportInstanceState(ctx, request.ID, state, nil); err != nil {
+				s.setInstanceState(request.ID, state)
+			}
+		}()
 	} else {
-		if err := s.synchronizeThings(ctx, request.ID, request.InstallationID, request.Token, request.Configuration, thingTemplates); err != nil {
+		instance, err := s.registerInstance(ctx, request, thingTemplates)
+		if err != nil {
 			return nil, err
 		}
+
+		if detailer, ok := s.provider.(connector.InstanceDetailer); ok {
+			details, err := detailer.InstanceDetails(instance)
+			if details = validDetails(logger, details, err); details != nil {
+				return &connector.InstantiationResponse{Details: details}, nil
+			}
+		}
 	}
 
 	return nil, nil
 }
 
-func (s *DefaultConnectorService) synchronizeThings(ctx context.Context, instanceID string, installationID string, token connector.InstantiationToken, configuration []connector.Configuration, thingTemplates []connector.ThingTemplate) error {
+// registerInstance creates the things of a new instance and registers the instance with the provider.
+func (s *DefaultConnectorService) registerInstance(ctx context.Context, request connector.InstantiationRequest, thingTemplates []connector.ThingTemplate) (*connector.Instance, error) {
+	instance := &connector.Instance{
+		ID:             request.ID,
+		InstallationID: request.InstallationID,
+		Token:          request.Token,
+		ThingMapping:   []connector.ThingMapping{},
+		Configuration:  request.Configuration,
+	}
+
+	thingMapping, err := s.synchronizeThings(ctx, instance, thingTemplates)
+	if err != nil {
+		return nil, err
+	}
+	instance.ThingMapping = thingMapping
+
+	if err := cancelled(ctx, s.scopedLogger(instance.InstallationID, instance.ID)); err != nil {
+		return nil, err
+	}
+
+	s.provider.RegisterInstances(instance)
+	return instance, nil
+}
+
+// synchronizeThings creates a thing for each template in the given order and returns the mappings of the created things.
+func (s *DefaultConnectorService) synchronizeThings(ctx context.Context, instance *connector.Instance, thingTemplates []connector.ThingTemplate) ([]connector.ThingMapping, error) {
+	logger := s.scopedLogger(instance.InstallationID, instance.ID)
+
 	thingMapping := []connector.ThingMapping{}
 	for _, template := range thingTemplates {
-		thing, err := s.CreateThing(ctx, instanceID, template.Thing, template.ExternalID)
+		if err := cancelled(ctx, logger); err != nil {
+			return nil, err
+		}
+
+		thing, err := s.CreateThing(ctx, instance.ID, template.Thing, template.ExternalID)
 		if err != nil {
-			s.logger.WithValues("thing", template).Error(err, "Failed to create new thing")
+			logger.WithValues("thing", template).Error(err, "Failed to create new thing")
 
 			// return error and abort instance creation
 			if s.options.EnforceThingCreation && !s.options.AsyncInstanceCreation {
-				s.logger.Info("Cancelling instance creation since enforeThingCreation is enabled")
-				return err
+				logger.Info("Cancelling instance creation since enforeThingCreation is enabled")
+				return nil, err
 			}
 
 			continue
 		}
 
 		thingMapping = append(thingMapping, connector.ThingMapping{
-			InstanceID: instanceID,
+			InstanceID: instance.ID,
 			ThingID:    thing.ID,
-			ExternalID: template.ExternalID,
+			ExternalID: connector.NormalizeExternalID(template.ExternalID),
 		})
 	}
 
-	s.provider.RegisterInstances(&connector.Instance{
-		ID:             instanceID,
-		InstallationID: installationID,
-		Token:          token,
-		ThingMapping:   thingMapping,
-		Configuration:  configuration,
-	})
-
-	return nil
+	return thingMapping, nil
 }
 
 // RemoveInstance is called by the HTTP handler when it receives an instance removal request.
 // It will remove the instance from the database (including the instance token) and from the provider.
 // Note that we will not be able to communicate with the connctd platform about the removed instance after this, since the token is deleted.
 func (s *DefaultConnectorService) RemoveInstance(ctx context.Context, instanceId string) error {
-	s.logger.WithValues("instanceId", instanceId).Info("Received an instance removal request")
+	logger := s.scopedLogger("", instanceId)
+	logger.Info("Received an instance removal request")
 
 	if err := s.provider.RemoveInstance(instanceId); err != nil {
-		s.logger.WithValues("instanceId", instanceId).Error(err, "tried to remove instance that is not registered")
+		logger.Error(err, "tried to remove instance that is not registered")
 	}
+	s.removeInstanceState(instanceId)
 
 	if err := s.db.RemoveInstance(ctx, instanceId); err != nil {
-		s.logger.WithValues("instanceId", instanceId).Error(err, "failed to remove instance from db")
+		logger.Error(err, "failed to remove instance from db")
 		return err
 	}
 	return nil
 }
 
 // PerformAction is called by the HTTP handler when it receives an action request.
+// Requests of instances that are not operational, e.g. because a failed state was reported, fail without calling the provider.
 func (s *DefaultConnectorService) PerformAction(ctx context.Context, actionRequest connector.ActionRequest) (*connector.ActionResponse, error) {
-	s.logger.WithValues("actionRequest", actionRequest).Info("Received an action request")
-
 	instance, err := s.db.GetInstanceByThingId(ctx, actionRequest.ThingID)
 	if err != nil {
 		s.logger.WithValues("actionRequest", actionRequest).Error(err, "Could not retrieve the instance for thing ID")
 		return &connector.ActionResponse{Status: connector.ActionRequestStatusFailed, Error: "thing ID was not found at connector"}, nil
 	}
 
+	logger := s.scopedLogger(instance.InstallationID, instance.ID).WithValues("actionRequest", actionRequest)
+	logger.Info("Received an action request")
+
+	if !s.instanceOperational(instance.ID) {
+		logger.Info("Rejected action request of instance that is not operational")
+		return &connector.ActionResponse{Status: connector.ActionRequestStatusFailed, Error: ErrorInstanceNotOperational.Error()}, nil
+	}
+
+	if s.options.ValidateActionParameters {
+		if err := s.verifyActionParameters(actionRequest); err != nil {
+			logger.Error(err, "Rejected action request")
+			return &connector.ActionResponse{Status: connector.ActionRequestStatusFailed, Error: err.Error()}, nil
+		}
+	}
+
+	// the provider may report the completion before RequestAction returns, so the action is watched in advance
+	s.watchPendingAction(instance.ID, actionRequest.ID)
 	status, err := s.provider.RequestAction(ctx, instance, actionRequest)
+	if err != nil && s.options.RecreateMissingThings && errors.Is(err, connector.ErrorThingNotFound) {
+		logger.Info("Thing is missing at the platform, recreating it")
+
+		var thingId string
+		if instance, thingId, err = s.recreateThing(ctx, instance, actionRequest.ThingID); err == nil {
+			actionRequest.ThingID = thingId
+			status, err = s.provider.RequestAction(ctx, instance, actionRequest)
+		}
+	}
+	if err != nil || status != connector.ActionRequestStatusPending {
+		s.stopPendingAction(actionRequest.ID)
+	}
 	if err != nil {
-		s.logger.WithValues("actionRequest", actionRequest).Error(err, "failed to perform action")
+		logger.Error(err, "failed to perform action")
 		return &connector.ActionResponse{Status: status, Error: err.Error()}, err
 	}
 
@@ -238,95 +753,534 @@ func (s *DefaultConnectorService) PerformAction(ctx context.Context, actionReque
 	case connector.ActionRequestStatusFailed:
 		// This should not happen.
 		// The provider is expected to return an error if the action failed, which we catch above.
-		s.logger.WithValues("actionRequest", actionRequest).Error(errors.New("implementation should return an error if action failed"), "connector did not send an error but set action state to FAILED")
+		logger.Error(errors.New("implementation should return an error if action failed"), "connector did not send an error but set action state to FAILED")
 	}
 
 	return nil, nil
 }
 
+// recreateThing creates the thing with the given id again from the template with the same external id.
+// It returns the updated instance together with the id of the recreated thing.
+func (s *DefaultConnectorService) recreateThing(ctx context.Context, instance *connector.Instance, thingId string) (*connector.Instance, string, error) {
+	logger := s.scopedLogger(instance.InstallationID, instance.ID).WithValues("thingId", thingId)
+
+	externalId, ok := instance.ExternalIdByThingId(thingId)
+	if !ok {
+		return nil, "", fmt.Errorf("thing %s is not mapped to instance %s", thingId, instance.ID)
+	}
+
+	var template *connector.ThingTemplate
+	for _, t := range s.thingTemplates(instantiationRequest(instance)) {
+		if connector.NormalizeExternalID(t.ExternalID) == externalId {
+			template = &t
+			break
+		}
+	}
+	if template == nil {
+		return nil, "", fmt.Errorf("no thing template found for external id %s", externalId)
+	}
+
+	if err := s.db.RemoveThingMapping(ctx, instance.ID, thingId); err != nil {
+		logger.Error(err, "Failed to remove mapping of missing thing")
+		return nil, "", err
+	}
+
+	thing, err := s.CreateThing(ctx, instance.ID, template.Thing, externalId)
+	if err != nil {
+		return nil, "", err
+	}
+
+	instance, err = s.db.GetInstance(ctx, instance.ID)
+	if err != nil {
+		logger.Error(err, "failed to retrieve instance")
+		return nil, "", err
+	}
+
+	logger.WithValues("recreatedThingId", thing.ID).Info("Recreated missing thing")
+	return instance, thing.ID, nil
+}
+
+// PerformActions is used for batched dispatch of action requests.
+// The instances of all requests are retrieved at once and the provider is called for each action.
+// In contrast to PerformAction completed actions are reported with an explicit ActionResponse.
+func (s *DefaultConnectorService) PerformActions(ctx context.Context, actionRequests []connector.ActionRequest) ([]*connector.ActionResponse, error) {
+	s.logger.WithValues("actionRequests", len(actionRequests)).Info("Received a batch of action requests")
+
+	thingIds := make([]string, 0, len(actionRequests))
+	for _, actionRequest := range actionRequests {
+		thingIds = append(thingIds, actionRequest.ThingID)
+	}
+
+	instances, err := s.db.GetInstancesByThingIds(ctx, thingIds)
+	if err != nil {
+		s.logger.Error(err, "Could not retrieve the instances for thing IDs")
+		return nil, err
+	}
+
+	responses := make([]*connector.ActionResponse, len(actionRequests))
+	for i, actionRequest := range actionRequests {
+		instance, ok := instances[actionRequest.ThingID]
+		if !ok {
+			responses[i] = &connector.ActionResponse{Status: connector.ActionRequestStatusFailed, Error: "thing ID was not found at connector"}
+			continue
+		}
+
+		if !s.instanceOperational(instance.ID) {
+			responses[i] = &connector.ActionResponse{Status: connector.ActionRequestStatusFailed, Error: ErrorInstanceNotOperational.Error()}
+			continue
+		}
+
+		if s.options.ValidateActionParameters {
+			if err := s.verifyActionParameters(actionRequest); err != nil {
+				s.scopedLogger(instance.InstallationID, instance.ID).WithValues("actionRequest", actionRequest).Error(err, "Rejected action request")
+				responses[i] = &connector.ActionResponse{Status: connector.ActionRequestStatusFailed, Error: err.Error()}
+				continue
+			}
+		}
+
+		s.watchPendingAction(instance.ID, actionRequest.ID)
+		status, err := s.provider.RequestAction(ctx, instance, actionRequest)
+		if err != nil || status != connector.ActionRequestStatusPending {
+			s.stopPendingAction(actionRequest.ID)
+		}
+		if err != nil {
+			s.scopedLogger(instance.InstallationID, instance.ID).WithValues("actionRequest", actionRequest).Error(err, "failed to perform action")
+			responses[i] = &connector.ActionResponse{Status: connector.ActionRequestStatusFailed, Error: err.Error()}
+			continue
+		}
+
+		responses[i] = &connector.ActionResponse{Status: status}
+	}
+
+	return responses, nil
+}
+
+// updateChannelConsumers keeps track of the update channels consumed by an event handler.
+var updateChannelConsumers sync.Map
+
 // EventHandler handles events coming from the provider.
+// Each update event can only be received by a single consumer, so the event handler must only be started
+// once per provider. Any further event handler, e.g. of a second service sharing the same provider,
+// would silently receive a share of the updates. It is therefore rejected and an error is logged.
+// The event handler stops consuming updates when the context is done or the service is shut down.
 func (s *DefaultConnectorService) EventHandler(ctx context.Context) {
+	updates := s.provider.UpdateChannel()
+	if _, consumed := updateChannelConsumers.LoadOrStore(updates, s); consumed {
+		s.logger.Error(ErrorUpdateChannelConsumed, "Refusing to start a second event handler for the same provider")
+		return
+	}
+
+	if len(s.options.StatusRules) > 0 {
+		s.eventHandlers.Add(1)
+		go func() {
+			defer s.eventHandlers.Done()
+			s.checkStatusRules(ctx)
+		}()
+	}
+
 	// wait for update events
+	s.eventHandlers.Add(1)
 	go func() {
-		for update := range s.provider.UpdateChannel() {
-			var err error
-			if update.PropertyUpdateEvent != nil {
-				propertyUpdate := update.PropertyUpdateEvent
-				err = s.UpdateProperty(ctx, propertyUpdate.InstanceId, propertyUpdate.ThingId, propertyUpdate.ComponentId, propertyUpdate.PropertyId, propertyUpdate.Value)
-				if err != nil {
-					s.logger.WithValues("propertyUpdate", propertyUpdate).Error(err, "failed to update property")
-				}
+		defer s.eventHandlers.Done()
+		// a stopped event handler releases the update channel, so it can be consumed again
+		defer updateChannelConsumers.Delete(updates)
+
+		for {
+			// stop before receiving the next update, even if updates are queued
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.shutdown:
+				return
+			default:
 			}
-			if update.ActionEvent != nil {
-				actionEvent := update.ActionEvent
-				if err != nil {
-					actionEvent.Response.Status = connector.ActionRequestStatusFailed
-					actionEvent.Response.Error = fmt.Sprintf("failed to update property %v", err)
-					s.logger.WithValues("actionEvent", actionEvent).Error(err, "action failed: failed to update property")
-				}
-				err := s.UpdateActionStatus(ctx, actionEvent.InstanceId, actionEvent.RequestId, actionEvent.Response)
-				if err != nil {
-					s.logger.WithValues("actionEvent", actionEvent).Error(err, "Failed to update action status")
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.shutdown:
+				return
+			case update, ok := <-updates:
+				if !ok {
+					return
 				}
+				s.handleUpdateEvent(ctx, update)
 			}
 		}
 	}()
 }
 
+// Shutdown stops the event handler from consuming further update events.
+// It waits until the update event currently processed is handled or the context is done,
+// in which case the error of the context is returned. Updates not yet received remain in the update channel.
+// Pending actions are no longer failed after the PendingActionTimeout and instance states which were not reported yet
+// remain in the database, so they are reported after the next start.
+func (s *DefaultConnectorService) Shutdown(ctx context.Context) error {
+	s.shutdownOnce.Do(func() { close(s.shutdown) })
+	s.stopPendingActions()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.eventHandlers.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// handleUpdateEvent processes a single update event.
+// Panics are recovered, so a malformed event does not stop the processing of subsequent events.
+// Failed updates are retried according to the EventRetryPolicy, see retryUpdateEvent.
+func (s *DefaultConnectorService) handleUpdateEvent(ctx context.Context, update connector.UpdateEvent) {
+	defer s.recoverUpdateEvent(update)
+
+	propertyUpdates := s.propertyUpdatesOf(update)
+	err := propertyUpdates(ctx)
+	if s.retriesUpdateEvent(err) {
+		s.retryUpdateEvent(update, func() {
+			err := s.retryUpdate(ctx, err, propertyUpdates)
+			if err != nil {
+				s.logger.WithValues("update", update).Error(err, "Dropped property update after retries")
+			}
+			s.completeActionEvent(ctx, update, err, true)
+		})
+		return
+	}
+	s.completeActionEvent(ctx, update, err, false)
+}
+
+// recoverUpdateEvent recovers from a panic while processing the update event.
+func (s *DefaultConnectorService) recoverUpdateEvent(update connector.UpdateEvent) {
+	if r := recover(); r != nil {
+		s.logger.Error(fmt.Errorf("%v", r), "Recovered from panic while processing update event", "update", update, "stack", string(debug.Stack()))
+		if s.options.OnEventPanic != nil {
+			s.options.OnEventPanic(update, r)
+		}
+	}
+}
+
+// propertyUpdatesOf returns a function sending the batch and property update of the event.
+// The property update is only sent if the batch succeeded. A successful batch is not sent again by subsequent calls.
+func (s *DefaultConnectorService) propertyUpdatesOf(update connector.UpdateEvent) func(ctx context.Context) error {
+	batchSent := update.BatchUpdateEvent == nil
+	return func(ctx context.Context) error {
+		if !batchSent {
+			if err := s.UpdateThingBatch(ctx, update.BatchUpdateEvent); err != nil {
+				s.logger.WithValues("batchUpdate", update.BatchUpdateEvent).Error(err, "failed to apply batch update")
+				return err
+			}
+			batchSent = true
+		}
+		if update.PropertyUpdateEvent != nil {
+			propertyUpdate := update.PropertyUpdateEvent
+			err := s.UpdatePropertyWithUnit(ctx, propertyUpdate.InstanceId, propertyUpdate.ThingId, propertyUpdate.ComponentId, propertyUpdate.PropertyId, propertyUpdate.Value, propertyUpdate.Unit)
+			if err != nil {
+				s.scopedLogger("", propertyUpdate.InstanceId).WithValues("propertyUpdate", propertyUpdate).Error(err, "failed to update property")
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// completeActionEvent sends the action status of the event. The action fails if the property updates of the event failed.
+// If retried is true, the event is already retried in its own goroutine and a failed status update is retried inline.
+func (s *DefaultConnectorService) completeActionEvent(ctx context.Context, update connector.UpdateEvent, err error, retried bool) {
+	if update.ActionEvent == nil {
+		return
+	}
+
+	actionEvent := update.ActionEvent
+	logger := s.scopedLogger("", actionEvent.InstanceId).WithValues("actionEvent", actionEvent)
+	if err != nil {
+		actionEvent.Response.Status = connector.ActionRequestStatusFailed
+		actionEvent.Response.Error = fmt.Sprintf("failed to update property %v", err)
+		logger.Error(err, "action failed: failed to update property")
+	}
+
+	updateActionStatus := func(ctx context.Context) error {
+		err := s.UpdateActionStatus(ctx, actionEvent.InstanceId, actionEvent.RequestId, actionEvent.Response)
+		if err != nil {
+			logger.Error(err, "Failed to update action status")
+		}
+		return err
+	}
+
+	err = updateActionStatus(ctx)
+	if !s.retriesUpdateEvent(err) {
+		return
+	}
+	retry := func() {
+		if err := s.retryUpdate(ctx, err, updateActionStatus); err != nil {
+			logger.Error(err, "Dropped action status update after retries")
+		}
+	}
+	if retried {
+		retry()
+	} else {
+		s.retryUpdateEvent(update, retry)
+	}
+}
+
 // CreateThing can be called by the connector to register a new thing for the given instance.
 // It retrieves the instance token from the database and uses the token to create a new thing via the connctd API client.
 // The new thing ID is then stored in the database referencing the instance id.
 func (s *DefaultConnectorService) CreateThing(ctx context.Context, instanceId string, thing connctd.Thing, externalId string) (*connctd.Thing, error) {
 	instance, err := s.db.GetInstance(ctx, instanceId)
 	if err != nil {
-		s.logger.WithValues("instanceId", instanceId).Error(err, "failed to retrieve instance from database")
+		s.scopedLogger("", instanceId).Error(err, "failed to retrieve instance from database")
+		return nil, err
+	}
+
+	logger := s.scopedLogger(instance.InstallationID, instanceId)
+
+	client, err := s.clientFor(instance.InstallationID)
+	if err != nil {
 		return nil, err
 	}
 
 	// CreateThing() will create the thing at the connctd platform.
 	// Since the platform will manage the thing, we only need to store its ID.
-	createdThing, err := s.connctdClient.CreateThing(ctx, instance.Token, thing)
+	createdThing, err := client.CreateThing(ctx, instance.Token, thing)
 	if err != nil {
-		s.logger.WithValues("thing", thing).Error(err, "failed to register new Thing")
+		logger.WithValues("thing", thing).Error(err, "failed to register new Thing")
 		return nil, err
 	}
 
 	// Save the thing ID with the instance, so we have a mapping of things to instances.
+	// An existing mapping is caused by a redelivered request and treated as success.
+	// If the mapping can't be stored, the thing is deleted again so it isn't left unmanaged at the platform.
 	err = s.db.AddThingMapping(ctx, instanceId, createdThing.ID, externalId)
-	if err != nil {
-		s.logger.WithValues("thing", thing).Error(err, "failed to insert new Thing into database")
+	if errors.Is(err, connector.ErrorMappingExists) {
+		logger.WithValues("thing", createdThing).Info("Thing is already mapped")
+	} else if err != nil {
+		logger.WithValues("thing", thing).Error(err, "failed to insert new Thing into database")
+		if deleteErr := client.DeleteThing(ctx, instance.Token, createdThing.ID); deleteErr != nil {
+			logger.WithValues("thingId", createdThing.ID).Error(deleteErr, "failed to delete unmapped Thing")
+		} else {
+			logger.WithValues("thingId", createdThing.ID).Info("Deleted unmapped Thing")
+		}
 		return nil, err
 	}
 
-	s.logger.WithValues("thing", createdThing).Info("Created new thing")
+	logger.WithValues("thing", createdThing).Info("Created new thing")
+
+	if s.cachesThings() {
+		s.cacheThing(createdThing.ID, createdThing)
+	}
 
 	return &createdThing, nil
 }
 
+// DeleteThing can be called by the connector to delete a thing of an instance.
+// The thing is deleted at the connctd platform first, afterwards its mapping is removed from the database.
+func (s *DefaultConnectorService) DeleteThing(ctx context.Context, instanceId string, thingId string) error {
+	instance, err := s.db.GetInstance(ctx, instanceId)
+	if err != nil {
+		s.scopedLogger("", instanceId).Error(err, "failed to retrieve instance from database")
+		return err
+	}
+
+	logger := s.scopedLogger(instance.InstallationID, instanceId).WithValues("thingId", thingId)
+
+	client, err := s.clientFor(instance.InstallationID)
+	if err != nil {
+		return err
+	}
+
+	if err := client.DeleteThing(ctx, instance.Token, thingId); err != nil {
+		logger.Error(err, "failed to delete thing")
+		return err
+	}
+
+	if err := s.db.RemoveThingMapping(ctx, instanceId, thingId); err != nil {
+		logger.Error(err, "failed to remove thing mapping from database")
+		return err
+	}
+
+	if s.cachesThings() {
+		s.thingsMutex.Lock()
+		delete(s.things, thingId)
+		s.thingsMutex.Unlock()
+	}
+
+	logger.Info("Deleted thing")
+	return nil
+}
+
 // UpdateProperty can be called by the connector to update a component property of a thing belonging to an instance.
+// If ValidatePropertyUpdates is enabled, updates of non existing components or properties and values violating the
+// constraints of the property are rejected without contacting the platform.
 func (s *DefaultConnectorService) UpdateProperty(ctx context.Context, instanceId, thingId, componentId, propertyId, value string) error {
+	return s.UpdatePropertyWithUnit(ctx, instanceId, thingId, componentId, propertyId, value, "")
+}
+
+// UpdatePropertyWithUnit updates a property like UpdateProperty and additionally sets its current unit.
+// An empty unit keeps the unit declared at thing creation.
+func (s *DefaultConnectorService) UpdatePropertyWithUnit(ctx context.Context, instanceId, thingId, componentId, propertyId, value, unit string) error {
+	if s.options.ValidatePropertyUpdates {
+		if err := s.verifyPropertyUpdate(thingId, componentId, propertyId, value); err != nil {
+			s.scopedLogger("", instanceId).WithValues("thingId", thingId).Error(err, "Rejected property update")
+			return err
+		}
+	}
+
 	instance, err := s.db.GetInstance(ctx, instanceId)
 	if err != nil {
-		s.logger.WithValues("instanceId", instanceId).Error(err, "failed to retrieve instance")
+		s.scopedLogger("", instanceId).Error(err, "failed to retrieve instance")
 		return err
 	}
 
 	timestamp := time.Now()
 
+	client, err := s.clientFor(instance.InstallationID)
+	if err != nil {
+		return err
+	}
+
 	// Use the client from the SDK to update the action status
-	err = s.connctdClient.UpdateThingPropertyValue(ctx, instance.Token, thingId, componentId, propertyId, value, timestamp)
+	err = client.UpdateThingPropertyValueWithUnit(ctx, instance.Token, thingId, componentId, propertyId, value, unit, timestamp)
+	if err != nil {
+		s.scopedLogger(instance.InstallationID, instanceId).WithValues("thingId", thingId, "componentId", componentId, "propertyId", propertyId).Error(err, "failed to send property update")
+		return err
+	}
+
+	s.recordPropertyUpdate(ctx, instance, thingId, timestamp)
+
+	if s.options.PersistPropertyValues {
+		err := s.db.SetLastPropertyValue(ctx, connector.PropertyValue{
+			InstanceID:  instanceId,
+			ThingID:     thingId,
+			ComponentID: componentId,
+			PropertyID:  propertyId,
+			Value:       value,
+			LastUpdate:  timestamp,
+		})
+		if err != nil {
+			// the update itself succeeded, so failing to persist the value is not reported to the caller
+			s.scopedLogger(instance.InstallationID, instanceId).WithValues("thingId", thingId, "componentId", componentId, "propertyId", propertyId).Error(err, "failed to persist property value")
+		}
+	}
 
-	return err
+	return nil
+}
+
+// UpdateThingBatch can be called by the connector to update several properties and the status of a thing with a single request.
+// Either all or none of the updates are applied. Property values are only persisted if the request succeeded.
+func (s *DefaultConnectorService) UpdateThingBatch(ctx context.Context, batch *connector.BatchUpdateEvent) error {
+	var instanceId, thingId string
+	if batch.ThingStatusEvent != nil {
+		instanceId, thingId = batch.ThingStatusEvent.InstanceId, batch.ThingStatusEvent.ThingId
+	} else if len(batch.PropertyUpdateEvents) > 0 {
+		instanceId, thingId = batch.PropertyUpdateEvents[0].InstanceId, batch.PropertyUpdateEvents[0].ThingId
+	} else {
+		return ErrorEmptyBatch
+	}
+
+	logger := s.scopedLogger("", instanceId).WithValues("thingId", thingId)
+
+	for _, update := range batch.PropertyUpdateEvents {
+		if update.InstanceId != instanceId || update.ThingId != thingId {
+			logger.Error(ErrorMixedBatch, "Rejected batch update")
+			return ErrorMixedBatch
+		}
+		if s.options.ValidatePropertyUpdates {
+			if err := s.verifyPropertyUpdate(thingId, update.ComponentId, update.PropertyId, update.Value); err != nil {
+				logger.Error(err, "Rejected batch update")
+				return err
+			}
+		}
+	}
+
+	instance, err := s.db.GetInstance(ctx, instanceId)
+	if err != nil {
+		logger.Error(err, "failed to retrieve instance")
+		return err
+	}
+
+	timestamp := time.Now()
+	request := connector.UpdateThingBatchRequest{Properties: make([]connector.ThingPropertyValue, 0, len(batch.PropertyUpdateEvents))}
+	for _, update := range batch.PropertyUpdateEvents {
+		request.Properties = append(request.Properties, connector.ThingPropertyValue{
+			ComponentID: update.ComponentId,
+			PropertyID:  update.PropertyId,
+			Value:       update.Value,
+			Unit:        update.Unit,
+			LastUpdate:  timestamp,
+		})
+	}
+	if batch.ThingStatusEvent != nil {
+		request.Status = batch.ThingStatusEvent.Status
+	}
+
+	client, err := s.clientFor(instance.InstallationID)
+	if err != nil {
+		return err
+	}
+
+	if err := client.UpdateThingBatch(ctx, instance.Token, thingId, request); err != nil {
+		logger.Error(err, "failed to send batch update")
+		return err
+	}
+
+	if len(batch.PropertyUpdateEvents) > 0 {
+		s.recordPropertyUpdate(ctx, instance, thingId, timestamp)
+	}
+
+	if s.options.PersistPropertyValues {
+		for _, update := range batch.PropertyUpdateEvents {
+			err := s.db.SetLastPropertyValue(ctx, connector.PropertyValue{
+				InstanceID:  instanceId,
+				ThingID:     thingId,
+				ComponentID: update.ComponentId,
+				PropertyID:  update.PropertyId,
+				Value:       update.Value,
+				LastUpdate:  timestamp,
+			})
+			if err != nil {
+				logger.WithValues("componentId", update.ComponentId, "propertyId", update.PropertyId).Error(err, "failed to persist property value")
+			}
+		}
+	}
+
+	return nil
+}
+
+// GetInstallationConfiguration returns the current configuration of the installation with the given id.
+// Providers can use it to fetch fresh configuration parameters instead of relying on the ones passed at registration.
+func (s *DefaultConnectorService) GetInstallationConfiguration(ctx context.Context, installationId string) ([]connector.Configuration, error) {
+	return s.db.GetInstallationConfiguration(ctx, installationId)
+}
+
+// GetLastPropertyValues returns the last known values of all properties of the given thing.
+// Values are only available if PersistPropertyValues is enabled.
+func (s *DefaultConnectorService) GetLastPropertyValues(ctx context.Context, thingId string) ([]connector.PropertyValue, error) {
+	return s.db.GetLastPropertyValues(ctx, thingId)
 }
 
 // UpdateActionStatus can be called by the connector to update the status of an action request.
+// Reporting a status other than pending completes the action, so it is no longer failed after the PendingActionTimeout.
 func (s *DefaultConnectorService) UpdateActionStatus(ctx context.Context, instanceId string, actionRequestId string, actionResponse *connector.ActionResponse) error {
+	if actionResponse.Status != connector.ActionRequestStatusPending {
+		s.stopPendingAction(actionRequestId)
+	}
+
 	instance, err := s.db.GetInstance(ctx, instanceId)
 	if err != nil {
-		s.logger.WithValues("instanceId", instanceId).Error(err, "failed to retrieve instance")
+		s.scopedLogger("", instanceId).Error(err, "failed to retrieve instance")
+		return err
+	}
+
+	client, err := s.clientFor(instance.InstallationID)
+	if err != nil {
 		return err
 	}
 
 	// Use the client from the SDK to update the action status
-	return s.connctdClient.UpdateActionStatus(ctx, instance.Token, actionRequestId, actionResponse.Status, actionResponse.Error)
+	return client.UpdateActionStatus(ctx, instance.Token, actionRequestId, actionResponse.Status, actionResponse.Error)
 }
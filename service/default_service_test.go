@@ -0,0 +1,1939 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/connctd/connector-go"
+	"github.com/connctd/connector-go/connctd"
+	"github.com/connctd/connector-go/db"
+	"github.com/connctd/connector-go/provider"
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClient records calls to the connctd API.
+// Calls to methods that are not implemented panic.
+type fakeClient struct {
+	connector.Client
+
+	mutex             sync.Mutex
+	createdThings     []connctd.Thing
+	propertyUpdates   []propertyUpdate
+	propertyUpdateErr error
+	batchUpdates      []batchUpdate
+	batchUpdateErr    error
+	deletedThings     []string
+	statusUpdates     []statusUpdate
+	actionStatuses    []actionStatus
+	platformThings    []connctd.Thing
+	deleteThingErr    error
+	onCreateThing     func()
+	onPropertyUpdate  func()
+
+	// the first instanceStateFailures state updates fail, with instanceStateErr if it is set
+	instanceStates        []connector.InstantiationState
+	instanceStateFailures int
+	instanceStateErr      error
+
+	// the first propertyUpdateFailures property updates and actionStatusFailures action status updates fail with a retryable error
+	propertyUpdateFailures int
+	actionStatusFailures   int
+}
+
+// errorTransient is returned by the fakeClient for the configured failures.
+var errorTransient = connector.Retryable(errors.New("platform unavailable"))
+
+type statusUpdate struct {
+	thingID string
+	status  connctd.StatusType
+}
+
+type actionStatus struct {
+	actionRequestID string
+	status          connector.ActionRequestStatus
+	err             string
+}
+
+type batchUpdate struct {
+	thingID string
+	request connector.UpdateThingBatchRequest
+}
+
+type propertyUpdate struct {
+	thingID     string
+	componentID string
+	propertyID  string
+	value       string
+	unit        string
+}
+
+func (c *fakeClient) UpdateThingPropertyValue(ctx context.Context, token connector.InstantiationToken, thingID string, componentID string, propertyID string, value string, lastUpdate time.Time) error {
+	return c.UpdateThingPropertyValueWithUnit(ctx, token, thingID, componentID, propertyID, value, "", lastUpdate)
+}
+
+func (c *fakeClient) UpdateThingPropertyValueWithUnit(ctx context.Context, token connector.InstantiationToken, thingID string, componentID string, propertyID string, value string, unit string, lastUpdate time.Time) error {
+	if c.onPropertyUpdate != nil {
+		c.onPropertyUpdate()
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.propertyUpdates = append(c.propertyUpdates, propertyUpdate{thingID, componentID, propertyID, value, unit})
+	if len(c.propertyUpdates) <= c.propertyUpdateFailures {
+		return errorTransient
+	}
+	return c.propertyUpdateErr
+}
+
+func (c *fakeClient) UpdateThingBatch(ctx context.Context, token connector.InstantiationToken, thingID string, batch connector.UpdateThingBatchRequest) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.batchUpdates = append(c.batchUpdates, batchUpdate{thingID, batch})
+	return c.batchUpdateErr
+}
+
+func (c *fakeClient) UpdateThingStatus(ctx context.Context, token connector.InstantiationToken, thingID string, status connctd.StatusType) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.statusUpdates = append(c.statusUpdates, statusUpdate{thingID, status})
+	return nil
+}
+
+// recordedStatusUpdates returns a copy of the recorded thing status updates.
+func (c *fakeClient) recordedStatusUpdates() []statusUpdate {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return append([]statusUpdate{}, c.statusUpdates...)
+}
+
+func (c *fakeClient) UpdateActionStatus(ctx context.Context, token connector.InstantiationToken, actionId string, status connector.ActionRequestStatus, e string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.actionStatuses = append(c.actionStatuses, actionStatus{actionId, status, e})
+	if len(c.actionStatuses) <= c.actionStatusFailures {
+		return errorTransient
+	}
+	return nil
+}
+
+// recordedActionStatuses returns a copy of the recorded action status updates.
+func (c *fakeClient) recordedActionStatuses() []actionStatus {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return append([]actionStatus{}, c.actionStatuses...)
+}
+
+func (c *fakeClient) UpdateInstanceState(ctx context.Context, token connector.InstantiationToken, state connector.InstantiationState, details json.RawMessage) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.instanceStates = append(c.instanceStates, state)
+	if len(c.instanceStates) <= c.instanceStateFailures {
+		if c.instanceStateErr != nil {
+			return c.instanceStateErr
+		}
+		return connector.ErrorUnexpectedStatusCode
+	}
+	return nil
+}
+
+func (c *fakeClient) recordedInstanceStates() []connector.InstantiationState {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return append([]connector.InstantiationState{}, c.instanceStates...)
+}
+
+func (c *fakeClient) ListThings(ctx context.Context, token connector.InstantiationToken) ([]connctd.Thing, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.platformThings, nil
+}
+
+func (c *fakeClient) GetThing(ctx context.Context, token connector.InstantiationToken, thingID string) (connctd.Thing, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, thing := range c.platformThings {
+		if thing.ID == thingID {
+			return thing, nil
+		}
+	}
+	return connctd.Thing{}, connector.ErrorUnexpectedStatusCode
+}
+
+func (c *fakeClient) DeleteThing(ctx context.Context, token connector.InstantiationToken, thingID string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.deletedThings = append(c.deletedThings, thingID)
+	return c.deleteThingErr
+}
+
+func (c *fakeClient) CreateThing(ctx context.Context, token connector.InstantiationToken, thing connctd.Thing) (connctd.Thing, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.createdThings = append(c.createdThings, thing)
+	thing.ID = fmt.Sprintf("thing-%d", len(c.createdThings))
+
+	if c.onCreateThing != nil {
+		c.onCreateThing()
+	}
+	return thing, nil
+}
+
+// fakeProvider records action requests and completes them synchronously
+// unless a different status is configured for the action request.
+// Actions for missing things fail with connector.ErrorThingNotFound.
+type fakeProvider struct {
+	provider.DefaultProvider
+
+	statuses                map[string]connector.ActionRequestStatus
+	missingThings           map[string]bool
+	actionRequests          []connector.ActionRequest
+	instanceIDs             []string
+	registeredInstallations []string
+	registeredInstances     []string
+	instanceBatchSizes      []int
+
+	// onRequestAction is called while the action request is dispatched
+	onRequestAction func(actionRequest connector.ActionRequest)
+}
+
+func newFakeProvider() *fakeProvider {
+	return &fakeProvider{DefaultProvider: provider.New()}
+}
+
+func (p *fakeProvider) RequestAction(ctx context.Context, instance *connector.Instance, actionRequest connector.ActionRequest) (connector.ActionRequestStatus, error) {
+	p.actionRequests = append(p.actionRequests, actionRequest)
+	p.instanceIDs = append(p.instanceIDs, instance.ID)
+
+	if p.onRequestAction != nil {
+		p.onRequestAction(actionRequest)
+	}
+	if p.missingThings[actionRequest.ThingID] {
+		return connector.ActionRequestStatusFailed, connector.ErrorThingNotFound
+	}
+	if status, ok := p.statuses[actionRequest.ID]; ok {
+		return status, nil
+	}
+	return connector.ActionRequestStatusCompleted, nil
+}
+
+func (p *fakeProvider) RegisterInstallations(installations ...*connector.Installation) error {
+	for _, installation := range installations {
+		p.registeredInstallations = append(p.registeredInstallations, installation.ID)
+	}
+	return p.DefaultProvider.RegisterInstallations(installations...)
+}
+
+func (p *fakeProvider) RegisterInstances(instances ...*connector.Instance) error {
+	p.instanceBatchSizes = append(p.instanceBatchSizes, len(instances))
+	for _, instance := range instances {
+		p.registeredInstances = append(p.registeredInstances, instance.ID)
+	}
+	return p.DefaultProvider.RegisterInstances(instances...)
+}
+
+// newTestDB returns a migrated database backed by an in-memory sqlite database.
+func newTestDB(t *testing.T) *db.DBClient {
+	client, err := db.NewDBClient(&db.DBOptions{Driver: db.DriverSqlite3, DSN: "file::memory:?_foreign_keys=on"}, logr.Discard())
+	require.NoError(t, err)
+	client.DB.SetMaxOpenConns(1)
+
+	require.NoError(t, client.Migrate())
+	t.Cleanup(func() { client.DB.Close() })
+
+	return client
+}
+
+// addInstallation stores an installation.
+func addInstallation(t *testing.T, database connector.Database, installationID string) {
+	require.NoError(t, database.AddInstallation(context.Background(), connector.InstallationRequest{ID: installationID, Token: "installation-token"}))
+}
+
+// addInstance stores an instance with the given things mapped to it.
+func addInstance(t *testing.T, database connector.Database, installationID string, instanceID string, thingIDs ...string) {
+	ctx := context.Background()
+
+	require.NoError(t, database.AddInstance(ctx, connector.InstantiationRequest{ID: instanceID, InstallationID: installationID, Token: "instance-token"}))
+
+	for _, thingID := range thingIDs {
+		require.NoError(t, database.AddThingMapping(ctx, instanceID, thingID, "external-"+thingID))
+	}
+}
+
+func noThings(request connector.InstantiationRequest) []connector.ThingTemplate {
+	return nil
+}
+
+// testThing returns a thing with a single component holding a single property.
+func testThing(name string) connctd.Thing {
+	return connctd.Thing{
+		Name:            name,
+		DisplayType:     "core.SENSOR",
+		MainComponentID: "sensor",
+		Components: []connctd.Component{
+			{
+				ID:            "sensor",
+				ComponentType: "core.SENSOR",
+				Properties: []connctd.Property{
+					{ID: "value", Type: connctd.ValueTypeNumber},
+				},
+			},
+		},
+	}
+}
+
+func singleThing(request connector.InstantiationRequest) []connector.ThingTemplate {
+	return []connector.ThingTemplate{{Thing: testThing("sensor"), ExternalID: "external-1"}}
+}
+
+func TestPerformActions(t *testing.T) {
+	database := newTestDB(t)
+	addInstallation(t, database, "installation-1")
+	addInstance(t, database, "installation-1", "instance-1", "thing-1", "thing-2")
+	addInstance(t, database, "installation-1", "instance-2", "thing-3")
+
+	p := newFakeProvider()
+	p.statuses = map[string]connector.ActionRequestStatus{"action-2": connector.ActionRequestStatusPending}
+	service, err := NewConnectorService(database, &fakeClient{}, p, noThings, DefaultConnectorServiceOptions, logr.Discard())
+	require.NoError(t, err)
+
+	requests := []connector.ActionRequest{
+		{ID: "action-1", ThingID: "thing-3"},
+		{ID: "action-2", ThingID: "thing-1"},
+		{ID: "action-3", ThingID: "thing-2"},
+	}
+
+	responses, err := service.PerformActions(context.Background(), requests)
+	require.NoError(t, err)
+	require.Len(t, responses, 3)
+
+	assert.Equal(t, connector.ActionRequestStatusCompleted, responses[0].Status)
+	assert.Equal(t, connector.ActionRequestStatusPending, responses[1].Status)
+	assert.Equal(t, connector.ActionRequestStatusCompleted, responses[2].Status)
+	assert.Equal(t, []string{"instance-2", "instance-1", "instance-1"}, p.instanceIDs)
+
+	// unknown things fail without reaching the provider
+	responses, err = service.PerformActions(context.Background(), []connector.ActionRequest{{ID: "action-4", ThingID: "unknown"}})
+	require.NoError(t, err)
+	require.Len(t, responses, 1)
+	assert.Equal(t, connector.ActionRequestStatusFailed, responses[0].Status)
+	assert.Len(t, p.actionRequests, 3)
+}
+
+func TestPendingActionTimeout(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	addInstallation(t, database, "installation-1")
+	addInstance(t, database, "installation-1", "instance-1", "thing-1")
+
+	p := newFakeProvider()
+	p.statuses = map[string]connector.ActionRequestStatus{
+		"stuck":     connector.ActionRequestStatusPending,
+		"completed": connector.ActionRequestStatusPending,
+	}
+	client := &fakeClient{}
+	options := DefaultConnectorServiceOptions
+	options.PendingActionTimeout = 50 * time.Millisecond
+	service, err := NewConnectorService(database, client, p, noThings, options, logr.Discard())
+	require.NoError(t, err)
+
+	response, err := service.PerformAction(ctx, connector.ActionRequest{ID: "stuck", ThingID: "thing-1"})
+	require.NoError(t, err)
+	assert.Equal(t, connector.ActionRequestStatusPending, response.Status)
+
+	responses, err := service.PerformActions(ctx, []connector.ActionRequest{{ID: "completed", ThingID: "thing-1"}})
+	require.NoError(t, err)
+	require.Len(t, responses, 1)
+	assert.Equal(t, connector.ActionRequestStatusPending, responses[0].Status)
+
+	require.NoError(t, service.UpdateActionStatus(ctx, "instance-1", "completed", &connector.ActionResponse{Status: connector.ActionRequestStatusCompleted}))
+
+	require.Eventually(t, func() bool { return len(client.recordedActionStatuses()) == 2 }, time.Second, 5*time.Millisecond)
+	time.Sleep(2 * options.PendingActionTimeout)
+
+	assert.Equal(t, []actionStatus{
+		{actionRequestID: "completed", status: connector.ActionRequestStatusCompleted},
+		{actionRequestID: "stuck", status: connector.ActionRequestStatusFailed, err: ErrorPendingActionTimeout.Error()},
+	}, client.recordedActionStatuses())
+}
+
+func TestPendingActionCompletedDuringDispatch(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	addInstallation(t, database, "installation-1")
+	addInstance(t, database, "installation-1", "instance-1", "thing-1")
+
+	p := newFakeProvider()
+	p.statuses = map[string]connector.ActionRequestStatus{"fast": connector.ActionRequestStatusPending}
+	client := &fakeClient{}
+	options := DefaultConnectorServiceOptions
+	options.PendingActionTimeout = 50 * time.Millisecond
+	service, err := NewConnectorService(database, client, p, noThings, options, logr.Discard())
+	require.NoError(t, err)
+
+	// the provider reports the completion before it returns the pending status
+	p.onRequestAction = func(actionRequest connector.ActionRequest) {
+		require.NoError(t, service.UpdateActionStatus(ctx, "instance-1", actionRequest.ID, &connector.ActionResponse{Status: connector.ActionRequestStatusCompleted}))
+	}
+
+	response, err := service.PerformAction(ctx, connector.ActionRequest{ID: "fast", ThingID: "thing-1"})
+	require.NoError(t, err)
+	assert.Equal(t, connector.ActionRequestStatusPending, response.Status)
+
+	time.Sleep(2 * options.PendingActionTimeout)
+	assert.Equal(t, []actionStatus{{actionRequestID: "fast", status: connector.ActionRequestStatusCompleted}}, client.recordedActionStatuses())
+}
+
+func TestShutdownStopsPendingActions(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	addInstallation(t, database, "installation-1")
+	addInstance(t, database, "installation-1", "instance-1", "thing-1")
+
+	p := newFakeProvider()
+	p.statuses = map[string]connector.ActionRequestStatus{"stuck": connector.ActionRequestStatusPending}
+	client := &fakeClient{}
+	options := DefaultConnectorServiceOptions
+	options.PendingActionTimeout = 50 * time.Millisecond
+	service, err := NewConnectorService(database, client, p, noThings, options, logr.Discard())
+	require.NoError(t, err)
+
+	_, err = service.PerformAction(ctx, connector.ActionRequest{ID: "stuck", ThingID: "thing-1"})
+	require.NoError(t, err)
+	require.NoError(t, service.Shutdown(ctx))
+
+	time.Sleep(2 * options.PendingActionTimeout)
+	assert.Empty(t, client.recordedActionStatuses())
+}
+
+func TestUpdatePropertyValidation(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	addInstallation(t, database, "installation-1")
+
+	// values of the property must not be negative
+	min := 0.0
+	constrainedThing := func(request connector.InstantiationRequest) []connector.ThingTemplate {
+		templates := singleThing(request)
+		templates[0].Thing.Components[0].Properties[0].Min = &min
+		return templates
+	}
+
+	client := &fakeClient{}
+	options := DefaultConnectorServiceOptions
+	options.ValidatePropertyUpdates = true
+	service, err := NewConnectorService(database, client, newFakeProvider(), constrainedThing, options, logr.Discard())
+	require.NoError(t, err)
+
+	_, err = service.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "token"})
+	require.NoError(t, err)
+
+	require.NoError(t, service.UpdateProperty(ctx, "instance-1", "thing-1", "sensor", "value", "42"))
+
+	err = service.UpdateProperty(ctx, "instance-1", "thing-1", "sensr", "value", "42")
+	assert.True(t, errors.Is(err, ErrorUnknownComponent))
+
+	err = service.UpdateProperty(ctx, "instance-1", "thing-1", "sensor", "valeu", "42")
+	assert.True(t, errors.Is(err, ErrorUnknownProperty))
+
+	err = service.UpdateProperty(ctx, "instance-1", "thing-1", "sensor", "value", "-1")
+	assert.True(t, errors.Is(err, connctd.ErrorValueOutOfRange))
+
+	assert.Equal(t, []propertyUpdate{{"thing-1", "sensor", "value", "42", ""}}, client.propertyUpdates)
+
+	// after a restart things are restored from the templates
+	client = &fakeClient{}
+	service, err = NewConnectorService(database, client, newFakeProvider(), constrainedThing, options, logr.Discard())
+	require.NoError(t, err)
+
+	err = service.UpdateProperty(ctx, "instance-1", "thing-1", "sensr", "value", "42")
+	assert.True(t, errors.Is(err, ErrorUnknownComponent))
+	assert.Empty(t, client.propertyUpdates)
+}
+
+// logRecorder collects the output of a logger.
+type logRecorder struct {
+	mutex sync.Mutex
+	lines []string
+}
+
+func (r *logRecorder) logger() logr.Logger {
+	return funcr.New(func(prefix, args string) {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		r.lines = append(r.lines, args)
+	}, funcr.Options{})
+}
+
+func (r *logRecorder) reset() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	lines := r.lines
+	r.lines = nil
+	return lines
+}
+
+func TestScopedLogging(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	addInstallation(t, database, "installation-1")
+
+	recorder := &logRecorder{}
+	client := &fakeClient{propertyUpdateErr: errors.New("platform unavailable")}
+	p := newFakeProvider()
+	service, err := NewConnectorService(database, client, p, singleThing, DefaultConnectorServiceOptions, recorder.logger())
+	require.NoError(t, err)
+	recorder.reset()
+
+	assertScoped := func(lines []string, ids ...string) {
+		require.NotEmpty(t, lines)
+		for _, line := range lines {
+			for _, id := range ids {
+				assert.Contains(t, line, id)
+			}
+		}
+	}
+
+	_, err = service.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "token"})
+	require.NoError(t, err)
+	assertScoped(recorder.reset(), `"installationId"="installation-1"`, `"instanceId"="instance-1"`)
+
+	_, err = service.PerformAction(ctx, connector.ActionRequest{ID: "action-1", ThingID: "thing-1"})
+	require.NoError(t, err)
+	assertScoped(recorder.reset(), `"installationId"="installation-1"`, `"instanceId"="instance-1"`)
+
+	assert.Error(t, service.UpdateProperty(ctx, "instance-1", "thing-1", "sensor", "value", "42"))
+	assertScoped(recorder.reset(), `"installationId"="installation-1"`, `"instanceId"="instance-1"`)
+
+	service.EventHandler(ctx)
+	p.UpdateEvent(connector.UpdateEvent{PropertyUpdateEvent: &connector.PropertyUpdateEvent{InstanceId: "instance-1", ThingId: "thing-1", ComponentId: "sensor", PropertyId: "value", Value: "42"}})
+
+	var lines []string
+	assert.Eventually(t, func() bool {
+		lines = append(lines, recorder.reset()...)
+		return len(lines) >= 2
+	}, time.Second, time.Millisecond)
+	assertScoped(lines, `"instanceId"="instance-1"`)
+}
+
+func TestCreateThingWithExistingMapping(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	addInstallation(t, database, "installation-1")
+	// the fake client will return thing-1 for the first created thing
+	addInstance(t, database, "installation-1", "instance-1", "thing-1")
+
+	service, err := NewConnectorService(database, &fakeClient{}, newFakeProvider(), noThings, DefaultConnectorServiceOptions, logr.Discard())
+	require.NoError(t, err)
+
+	thing, err := service.CreateThing(ctx, "instance-1", testThing("sensor"), "external-thing-1")
+	require.NoError(t, err)
+	assert.Equal(t, "thing-1", thing.ID)
+
+	mappings, err := database.CountThingMappings(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, mappings)
+}
+
+func TestEventHandlerRecoversFromPanic(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	addInstallation(t, database, "installation-1")
+	addInstance(t, database, "installation-1", "instance-1", "thing-1")
+
+	panics := make(chan interface{}, 1)
+	options := DefaultConnectorServiceOptions
+	options.OnEventPanic = func(update connector.UpdateEvent, recovered interface{}) {
+		panics <- recovered
+	}
+
+	client := &fakeClient{}
+	p := newFakeProvider()
+	service, err := NewConnectorService(database, client, p, noThings, options, logr.Discard())
+	require.NoError(t, err)
+	service.EventHandler(ctx)
+
+	// an action event without response panics while processing
+	p.UpdateEvent(connector.UpdateEvent{ActionEvent: &connector.ActionEvent{InstanceId: "instance-1", RequestId: "action-1"}})
+	p.UpdateEvent(connector.UpdateEvent{PropertyUpdateEvent: &connector.PropertyUpdateEvent{InstanceId: "instance-1", ThingId: "thing-1", ComponentId: "sensor", PropertyId: "value", Value: "42"}})
+
+	select {
+	case <-panics:
+	case <-time.After(time.Second):
+		t.Fatal("panic was not reported")
+	}
+
+	assert.Eventually(t, func() bool {
+		client.mutex.Lock()
+		defer client.mutex.Unlock()
+		return len(client.propertyUpdates) == 1
+	}, time.Second, time.Millisecond)
+}
+
+func TestEventHandlerRejectsSecondConsumer(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	addInstallation(t, database, "installation-1")
+	addInstance(t, database, "installation-1", "instance-1", "thing-1")
+
+	client := &fakeClient{}
+	p := newFakeProvider()
+
+	first, err := NewConnectorService(database, client, p, noThings, DefaultConnectorServiceOptions, logr.Discard())
+	require.NoError(t, err)
+	first.EventHandler(ctx)
+
+	recorder := &logRecorder{}
+	second, err := NewConnectorService(database, client, p, noThings, DefaultConnectorServiceOptions, recorder.logger())
+	require.NoError(t, err)
+	recorder.reset()
+	second.EventHandler(ctx)
+
+	lines := recorder.reset()
+	require.Len(t, lines, 1)
+	assert.Contains(t, lines[0], ErrorUpdateChannelConsumed.Error())
+
+	// all updates are still processed by the first event handler
+	for i := 0; i < 10; i++ {
+		p.UpdateEvent(connector.UpdateEvent{PropertyUpdateEvent: &connector.PropertyUpdateEvent{InstanceId: "instance-1", ThingId: "thing-1", ComponentId: "sensor", PropertyId: "value", Value: "42"}})
+	}
+	assert.Eventually(t, func() bool {
+		client.mutex.Lock()
+		defer client.mutex.Unlock()
+		return len(client.propertyUpdates) == 10
+	}, time.Second, time.Millisecond)
+}
+
+// waitForEventHandlers fails the test if the goroutines of the event handler do not exit.
+func waitForEventHandlers(t *testing.T, service *DefaultConnectorService) {
+	stopped := make(chan struct{})
+	go func() {
+		service.eventHandlers.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("event handler did not stop")
+	}
+}
+
+func TestEventHandlerStopsWhenContextIsDone(t *testing.T) {
+	database := newTestDB(t)
+	addInstallation(t, database, "installation-1")
+	addInstance(t, database, "installation-1", "instance-1", "thing-1")
+
+	client := &fakeClient{}
+	p := newFakeProvider()
+	options := DefaultConnectorServiceOptions
+	options.StatusRules = []StatusRule{{StalenessTimeout: time.Hour}}
+	service, err := NewConnectorService(database, client, p, noThings, options, logr.Discard())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	service.EventHandler(ctx)
+	cancel()
+	waitForEventHandlers(t, service)
+
+	// updates are no longer consumed
+	p.UpdateEvent(connector.UpdateEvent{PropertyUpdateEvent: &connector.PropertyUpdateEvent{InstanceId: "instance-1", ThingId: "thing-1", ComponentId: "sensor", PropertyId: "value", Value: "42"}})
+	time.Sleep(10 * time.Millisecond)
+	length, _ := p.UpdateChannelDepth()
+	assert.Equal(t, 1, length)
+
+	// the update channel is released for a new event handler
+	service.EventHandler(context.Background())
+	assert.Eventually(t, func() bool {
+		client.mutex.Lock()
+		defer client.mutex.Unlock()
+		return len(client.propertyUpdates) == 1
+	}, time.Second, time.Millisecond)
+	require.NoError(t, service.Shutdown(context.Background()))
+}
+
+func TestShutdown(t *testing.T) {
+	database := newTestDB(t)
+	addInstallation(t, database, "installation-1")
+	addInstance(t, database, "installation-1", "instance-1", "thing-1")
+
+	handling := make(chan struct{})
+	release := make(chan struct{})
+	client := &fakeClient{onPropertyUpdate: func() {
+		handling <- struct{}{}
+		<-release
+	}}
+	p := newFakeProvider()
+	service, err := NewConnectorService(database, client, p, noThings, DefaultConnectorServiceOptions, logr.Discard())
+	require.NoError(t, err)
+	service.EventHandler(context.Background())
+
+	update := connector.UpdateEvent{PropertyUpdateEvent: &connector.PropertyUpdateEvent{InstanceId: "instance-1", ThingId: "thing-1", ComponentId: "sensor", PropertyId: "value", Value: "42"}}
+	p.UpdateEvent(update)
+	<-handling
+
+	// the current update is still handled, so shutdown times out
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, service.Shutdown(ctx))
+
+	p.UpdateEvent(update)
+	close(release)
+	require.NoError(t, service.Shutdown(context.Background()))
+	waitForEventHandlers(t, service)
+
+	// the update received after the shutdown remains in the channel
+	client.mutex.Lock()
+	assert.Len(t, client.propertyUpdates, 1)
+	client.mutex.Unlock()
+	length, _ := p.UpdateChannelDepth()
+	assert.Equal(t, 1, length)
+}
+
+// recordedPropertyUpdates returns a copy of the recorded property updates.
+func (c *fakeClient) recordedPropertyUpdates() []propertyUpdate {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return append([]propertyUpdate{}, c.propertyUpdates...)
+}
+
+func TestEventRetryPolicy(t *testing.T) {
+	database := newTestDB(t)
+	addInstallation(t, database, "installation-1")
+	addInstance(t, database, "installation-1", "instance-1", "thing-1")
+
+	newService := func(t *testing.T, client *fakeClient, baseDelay time.Duration) (*DefaultConnectorService, *fakeProvider) {
+		p := newFakeProvider()
+		options := DefaultConnectorServiceOptions
+		options.EventRetryPolicy = connector.RetryPolicy{MaxAttempts: 4, BaseDelay: baseDelay}
+		service, err := NewConnectorService(database, client, p, noThings, options, logr.Discard())
+		require.NoError(t, err)
+		return service, p
+	}
+	propertyUpdateEvent := func(value string) connector.UpdateEvent {
+		return connector.UpdateEvent{PropertyUpdateEvent: &connector.PropertyUpdateEvent{InstanceId: "instance-1", ThingId: "thing-1", ComponentId: "sensor", PropertyId: "value", Value: value}}
+	}
+
+	t.Run("property update", func(t *testing.T) {
+		client := &fakeClient{propertyUpdateFailures: 2}
+		service, p := newService(t, client, time.Millisecond)
+		service.EventHandler(context.Background())
+
+		p.UpdateEvent(propertyUpdateEvent("42"))
+		assert.Eventually(t, func() bool { return len(client.recordedPropertyUpdates()) == 3 }, time.Second, time.Millisecond)
+
+		require.NoError(t, service.Shutdown(context.Background()))
+		assert.Len(t, client.recordedPropertyUpdates(), 3)
+	})
+
+	t.Run("action status", func(t *testing.T) {
+		client := &fakeClient{actionStatusFailures: 2}
+		service, p := newService(t, client, time.Millisecond)
+		service.EventHandler(context.Background())
+
+		p.UpdateEvent(connector.UpdateEvent{ActionEvent: &connector.ActionEvent{InstanceId: "instance-1", RequestId: "action-1", Response: &connector.ActionResponse{Status: connector.ActionRequestStatusCompleted}}})
+		assert.Eventually(t, func() bool { return len(client.recordedActionStatuses()) == 3 }, time.Second, time.Millisecond)
+
+		require.NoError(t, service.Shutdown(context.Background()))
+		for _, status := range client.recordedActionStatuses() {
+			assert.Equal(t, actionStatus{"action-1", connector.ActionRequestStatusCompleted, ""}, status)
+		}
+	})
+
+	t.Run("subsequent events are not delayed", func(t *testing.T) {
+		client := &fakeClient{propertyUpdateFailures: 1}
+		service, p := newService(t, client, time.Hour)
+		ctx, cancel := context.WithCancel(context.Background())
+		service.EventHandler(ctx)
+
+		p.UpdateEvent(propertyUpdateEvent("1"))
+		p.UpdateEvent(propertyUpdateEvent("2"))
+		assert.Eventually(t, func() bool { return len(client.recordedPropertyUpdates()) == 2 }, time.Second, time.Millisecond)
+
+		// the pending retry is abandoned with the context of the event handler
+		cancel()
+		waitForEventHandlers(t, service)
+		assert.Len(t, client.recordedPropertyUpdates(), 2)
+	})
+
+	t.Run("permanent errors", func(t *testing.T) {
+		client := &fakeClient{propertyUpdateErr: connector.Permanent(errors.New("invalid value"))}
+		service, p := newService(t, client, time.Millisecond)
+		service.EventHandler(context.Background())
+
+		p.UpdateEvent(propertyUpdateEvent("42"))
+		assert.Eventually(t, func() bool { return len(client.recordedPropertyUpdates()) == 1 }, time.Second, time.Millisecond)
+		// give a retry the chance to happen
+		time.Sleep(20 * time.Millisecond)
+		require.NoError(t, service.Shutdown(context.Background()))
+		assert.Len(t, client.recordedPropertyUpdates(), 1)
+	})
+}
+
+func TestRegistrationBatchSize(t *testing.T) {
+	database := newTestDB(t)
+	addInstallation(t, database, "installation-1")
+	for i := 1; i <= 7; i++ {
+		addInstance(t, database, "installation-1", fmt.Sprintf("instance-%d", i))
+	}
+
+	p := newFakeProvider()
+	options := DefaultConnectorServiceOptions
+	options.RegistrationBatchSize = 3
+	options.RegistrationBatchPause = 10 * time.Millisecond
+	start := time.Now()
+	_, err := NewConnectorService(database, &fakeClient{}, p, noThings, options, logr.Discard())
+	require.NoError(t, err)
+
+	assert.Equal(t, []int{3, 3, 1}, p.instanceBatchSizes)
+	assert.Len(t, p.registeredInstances, 7)
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+
+	// all instances are registered at once by default
+	p = newFakeProvider()
+	_, err = NewConnectorService(database, &fakeClient{}, p, noThings, DefaultConnectorServiceOptions, logr.Discard())
+	require.NoError(t, err)
+	assert.Equal(t, []int{7}, p.instanceBatchSizes)
+}
+
+func TestPersistPropertyValues(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	addInstallation(t, database, "installation-1")
+	addInstance(t, database, "installation-1", "instance-1", "thing-1")
+
+	for _, persist := range []bool{false, true} {
+		options := DefaultConnectorServiceOptions
+		options.PersistPropertyValues = persist
+
+		service, err := NewConnectorService(database, &fakeClient{}, newFakeProvider(), noThings, options, logr.Discard())
+		require.NoError(t, err)
+		require.NoError(t, service.UpdateProperty(ctx, "instance-1", "thing-1", "sensor", "value", "42"))
+
+		// a new service simulates a restart of the connector
+		restarted, err := NewConnectorService(database, &fakeClient{}, newFakeProvider(), noThings, options, logr.Discard())
+		require.NoError(t, err)
+
+		values, err := restarted.GetLastPropertyValues(ctx, "thing-1")
+		require.NoError(t, err)
+		if !persist {
+			assert.Empty(t, values)
+			continue
+		}
+		require.Len(t, values, 1)
+		assert.Equal(t, "instance-1", values[0].InstanceID)
+		assert.Equal(t, "sensor", values[0].ComponentID)
+		assert.Equal(t, "value", values[0].PropertyID)
+		assert.Equal(t, "42", values[0].Value)
+		assert.False(t, values[0].LastUpdate.IsZero())
+	}
+}
+
+func TestPerformActionParameterValidation(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	addInstallation(t, database, "installation-1")
+
+	dimmableThing := func(request connector.InstantiationRequest) []connector.ThingTemplate {
+		thing := testThing("lamp")
+		thing.Components[0].Actions = []connctd.Action{
+			{ID: "dim", Parameters: []connctd.ActionParameter{{Name: "brightness", Type: connctd.ValueTypeNumber}}},
+		}
+		return []connector.ThingTemplate{{Thing: thing, ExternalID: "external-1"}}
+	}
+
+	p := newFakeProvider()
+	options := DefaultConnectorServiceOptions
+	options.ValidateActionParameters = true
+	service, err := NewConnectorService(database, &fakeClient{}, p, dimmableThing, options, logr.Discard())
+	require.NoError(t, err)
+
+	_, err = service.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "token"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		parameters map[string]string
+		err        error
+	}{
+		{"valid", map[string]string{"brightness": "50"}, nil},
+		{"missing parameter", map[string]string{}, connector.ErrorMissingActionParameter},
+		{"undeclared parameter", map[string]string{"brightness": "50", "color": "red"}, connector.ErrorUndeclaredActionParameter},
+		{"invalid value", map[string]string{"brightness": "bright"}, connector.ErrorInvalidActionParameter},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p.actionRequests = nil
+			request := connector.ActionRequest{ID: "action-1", ThingID: "thing-1", ComponentID: "sensor", ActionID: "dim", Parameters: test.parameters}
+
+			response, err := service.PerformAction(ctx, request)
+			require.NoError(t, err)
+			if test.err == nil {
+				assert.Nil(t, response)
+				assert.Len(t, p.actionRequests, 1)
+				return
+			}
+
+			require.NotNil(t, response)
+			assert.Equal(t, connector.ActionRequestStatusFailed, response.Status)
+			assert.Contains(t, response.Error, test.err.Error())
+			assert.Empty(t, p.actionRequests)
+		})
+	}
+}
+
+func TestCancelledRequestsAreNotRegistered(t *testing.T) {
+	database := newTestDB(t)
+
+	twoThings := func(request connector.InstantiationRequest) []connector.ThingTemplate {
+		return []connector.ThingTemplate{{Thing: testThing("first"), ExternalID: "external-1"}, {Thing: testThing("second"), ExternalID: "external-2"}}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := &fakeClient{onCreateThing: cancel}
+	p := newFakeProvider()
+	service, err := NewConnectorService(database, client, p, twoThings, DefaultConnectorServiceOptions, logr.Discard())
+	require.NoError(t, err)
+
+	// the context is cancelled while the first thing is created
+	addInstallation(t, database, "installation-1")
+	_, err = service.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "token"})
+	assert.True(t, errors.Is(err, context.Canceled), err)
+	assert.Len(t, client.createdThings, 1)
+	assert.Empty(t, p.registeredInstances)
+
+	// the context is already cancelled
+	_, err = service.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-2", Token: "token"})
+	assert.True(t, errors.Is(err, context.Canceled), err)
+	assert.Empty(t, p.registeredInstallations)
+}
+
+func TestUpdateInstallation(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+
+	p := newFakeProvider()
+	service, err := NewConnectorService(database, &fakeClient{}, p, noThings, DefaultConnectorServiceOptions, logr.Discard())
+	require.NoError(t, err)
+
+	_, err = service.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token-1", Configuration: []connector.Configuration{{ID: "username", Value: "foo"}}})
+	require.NoError(t, err)
+	p.Update()
+
+	_, err = service.UpdateInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token-2", Configuration: []connector.Configuration{{ID: "username", Value: "bar"}, {ID: "region", Value: "eu"}}})
+	require.NoError(t, err)
+
+	config, err := database.GetInstallationConfiguration(ctx, "installation-1")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []connector.Configuration{{ID: "username", Value: "bar"}, {ID: "region", Value: "eu"}}, config)
+
+	installation, err := database.GetInstallationByToken(ctx, "token-2")
+	require.NoError(t, err)
+	assert.Equal(t, "installation-1", installation.ID)
+
+	p.Update()
+	require.Contains(t, p.Installations, "installation-1")
+	assert.Equal(t, connector.InstallationToken("token-2"), p.Installations["installation-1"].Token)
+	assert.ElementsMatch(t, config, p.Installations["installation-1"].Configuration)
+
+	_, err = service.UpdateInstallation(ctx, connector.InstallationRequest{ID: "unknown", Token: "token"})
+	assert.Equal(t, connector.ErrorInstallationNotFound, err)
+}
+
+func TestRecreateMissingThings(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	addInstallation(t, database, "installation-1")
+
+	client := &fakeClient{}
+	p := newFakeProvider()
+	options := DefaultConnectorServiceOptions
+	options.RecreateMissingThings = true
+	service, err := NewConnectorService(database, client, p, singleThing, options, logr.Discard())
+	require.NoError(t, err)
+
+	_, err = service.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "token"})
+	require.NoError(t, err)
+	require.Len(t, client.createdThings, 1)
+
+	// thing-1 was deleted at the platform
+	p.missingThings = map[string]bool{"thing-1": true}
+
+	response, err := service.PerformAction(ctx, connector.ActionRequest{ID: "action-1", ThingID: "thing-1", ComponentID: "sensor", ActionID: "dim"})
+	require.NoError(t, err)
+	assert.Nil(t, response)
+
+	assert.Len(t, client.createdThings, 2)
+	require.Len(t, p.actionRequests, 2)
+	assert.Equal(t, "thing-1", p.actionRequests[0].ThingID)
+	assert.Equal(t, "thing-2", p.actionRequests[1].ThingID)
+
+	instance, err := database.GetInstanceByThingId(ctx, "thing-2")
+	require.NoError(t, err)
+	assert.Equal(t, "instance-1", instance.ID)
+	externalId, ok := instance.ExternalIdByThingId("thing-2")
+	assert.True(t, ok)
+	assert.Equal(t, "external-1", externalId)
+
+	_, err = database.GetInstanceByThingId(ctx, "thing-1")
+	assert.Error(t, err)
+
+	// without the option the error is returned
+	service, err = NewConnectorService(database, client, p, singleThing, DefaultConnectorServiceOptions, logr.Discard())
+	require.NoError(t, err)
+	p.missingThings["thing-2"] = true
+
+	response, err = service.PerformAction(ctx, connector.ActionRequest{ID: "action-2", ThingID: "thing-2", ComponentID: "sensor", ActionID: "dim"})
+	assert.Equal(t, connector.ErrorThingNotFound, err)
+	require.NotNil(t, response)
+	assert.Equal(t, connector.ActionRequestStatusFailed, response.Status)
+	assert.Len(t, client.createdThings, 2)
+}
+
+func TestInvalidTemplatesCreateNoThings(t *testing.T) {
+	invalidThing := testThing("invalid")
+	invalidThing.MainComponentID = "unknown"
+
+	tests := []struct {
+		name      string
+		templates []connector.ThingTemplate
+	}{
+		{"invalid thing", []connector.ThingTemplate{{Thing: testThing("first"), ExternalID: "external-1"}, {Thing: invalidThing, ExternalID: "external-2"}}},
+		{"duplicate external id", []connector.ThingTemplate{{Thing: testThing("first"), ExternalID: "external-1"}, {Thing: testThing("second"), ExternalID: "external-1"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			database := newTestDB(t)
+			addInstallation(t, database, "installation-1")
+
+			templates := func(request connector.InstantiationRequest) []connector.ThingTemplate {
+				return test.templates
+			}
+
+			client := &fakeClient{}
+			p := newFakeProvider()
+			service, err := NewConnectorService(database, client, p, templates, DefaultConnectorServiceOptions, logr.Discard())
+			require.NoError(t, err)
+
+			_, err = service.AddInstance(context.Background(), connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "token"})
+			assert.Error(t, err)
+			assert.Empty(t, client.createdThings)
+			assert.Empty(t, p.registeredInstances)
+
+			// the instance is not stored, so the request can be retried
+			_, err = database.GetInstance(context.Background(), "instance-1")
+			assert.Equal(t, connector.ErrorInstanceNotFound, err)
+		})
+	}
+}
+
+func TestTemplatesWithBlankExternalIDs(t *testing.T) {
+	database := newTestDB(t)
+	addInstallation(t, database, "installation-1")
+
+	templates := func(request connector.InstantiationRequest) []connector.ThingTemplate {
+		return []connector.ThingTemplate{{Thing: testThing("first")}, {Thing: testThing("second")}}
+	}
+
+	client := &fakeClient{}
+	service, err := NewConnectorService(database, client, newFakeProvider(), templates, DefaultConnectorServiceOptions, logr.Discard())
+	require.NoError(t, err)
+
+	_, err = service.AddInstance(context.Background(), connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "token"})
+	require.NoError(t, err)
+	assert.Len(t, client.createdThings, 2)
+}
+
+func TestPropertyUpdateEventWithUnit(t *testing.T) {
+	database := newTestDB(t)
+	addInstallation(t, database, "installation-1")
+	addInstance(t, database, "installation-1", "instance-1", "thing-1")
+
+	client := &fakeClient{}
+	p := newFakeProvider()
+	service, err := NewConnectorService(database, client, p, noThings, DefaultConnectorServiceOptions, logr.Discard())
+	require.NoError(t, err)
+	service.EventHandler(context.Background())
+
+	p.UpdateEvent(connector.UpdateEvent{PropertyUpdateEvent: &connector.PropertyUpdateEvent{InstanceId: "instance-1", ThingId: "thing-1", ComponentId: "sensor", PropertyId: "value", Value: "70", Unit: "°F"}})
+
+	assert.Eventually(t, func() bool {
+		client.mutex.Lock()
+		defer client.mutex.Unlock()
+		return len(client.propertyUpdates) == 1
+	}, time.Second, time.Millisecond)
+	client.mutex.Lock()
+	assert.Equal(t, propertyUpdate{"thing-1", "sensor", "value", "70", "°F"}, client.propertyUpdates[0])
+	client.mutex.Unlock()
+}
+
+func TestBatchUpdateEvent(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	addInstallation(t, database, "installation-1")
+	addInstance(t, database, "installation-1", "instance-1", "thing-1")
+
+	client := &fakeClient{}
+	p := newFakeProvider()
+	options := DefaultConnectorServiceOptions
+	options.PersistPropertyValues = true
+	service, err := NewConnectorService(database, client, p, noThings, options, logr.Discard())
+	require.NoError(t, err)
+	service.EventHandler(ctx)
+
+	batch := &connector.BatchUpdateEvent{
+		PropertyUpdateEvents: []connector.PropertyUpdateEvent{
+			{InstanceId: "instance-1", ThingId: "thing-1", ComponentId: "sensor", PropertyId: "temperature", Value: "21.5", Unit: "°C"},
+			{InstanceId: "instance-1", ThingId: "thing-1", ComponentId: "sensor", PropertyId: "humidity", Value: "40"},
+			{InstanceId: "instance-1", ThingId: "thing-1", ComponentId: "sensor", PropertyId: "pressure", Value: "1013"},
+		},
+		ThingStatusEvent: &connector.ThingStatusEvent{InstanceId: "instance-1", ThingId: "thing-1", Status: connctd.StatusTypeAvailable},
+	}
+	p.UpdateEvent(connector.UpdateEvent{BatchUpdateEvent: batch})
+
+	assert.Eventually(t, func() bool {
+		client.mutex.Lock()
+		defer client.mutex.Unlock()
+		return len(client.batchUpdates) == 1
+	}, time.Second, time.Millisecond)
+
+	client.mutex.Lock()
+	update := client.batchUpdates[0]
+	assert.Empty(t, client.propertyUpdates)
+	client.mutex.Unlock()
+
+	assert.Equal(t, "thing-1", update.thingID)
+	assert.Equal(t, connctd.StatusTypeAvailable, update.request.Status)
+	require.Len(t, update.request.Properties, 3)
+	for i, property := range update.request.Properties {
+		assert.Equal(t, batch.PropertyUpdateEvents[i].PropertyId, property.PropertyID)
+		assert.Equal(t, batch.PropertyUpdateEvents[i].Value, property.Value)
+		assert.Equal(t, batch.PropertyUpdateEvents[i].Unit, property.Unit)
+	}
+
+	assert.Eventually(t, func() bool {
+		values, err := service.GetLastPropertyValues(ctx, "thing-1")
+		return err == nil && len(values) == 3
+	}, time.Second, time.Millisecond)
+}
+
+func TestBatchUpdateFailure(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	addInstallation(t, database, "installation-1")
+	addInstance(t, database, "installation-1", "instance-1", "thing-1", "thing-2")
+
+	client := &fakeClient{batchUpdateErr: connector.ErrorUnexpectedStatusCode}
+	options := DefaultConnectorServiceOptions
+	options.PersistPropertyValues = true
+	service, err := NewConnectorService(database, client, newFakeProvider(), noThings, options, logr.Discard())
+	require.NoError(t, err)
+
+	// none of the updates is persisted if the batch fails
+	err = service.UpdateThingBatch(ctx, &connector.BatchUpdateEvent{PropertyUpdateEvents: []connector.PropertyUpdateEvent{
+		{InstanceId: "instance-1", ThingId: "thing-1", ComponentId: "sensor", PropertyId: "temperature", Value: "21.5"},
+		{InstanceId: "instance-1", ThingId: "thing-1", ComponentId: "sensor", PropertyId: "humidity", Value: "40"},
+	}})
+	assert.Equal(t, connector.ErrorUnexpectedStatusCode, err)
+
+	values, err := service.GetLastPropertyValues(ctx, "thing-1")
+	require.NoError(t, err)
+	assert.Empty(t, values)
+
+	err = service.UpdateThingBatch(ctx, &connector.BatchUpdateEvent{})
+	assert.Equal(t, ErrorEmptyBatch, err)
+
+	err = service.UpdateThingBatch(ctx, &connector.BatchUpdateEvent{PropertyUpdateEvents: []connector.PropertyUpdateEvent{
+		{InstanceId: "instance-1", ThingId: "thing-1", ComponentId: "sensor", PropertyId: "temperature", Value: "21.5"},
+		{InstanceId: "instance-1", ThingId: "thing-2", ComponentId: "sensor", PropertyId: "temperature", Value: "19"},
+	}})
+	assert.Equal(t, ErrorMixedBatch, err)
+	assert.Len(t, client.batchUpdates, 1)
+}
+
+// detailingProvider attaches details to installation and instantiation responses.
+type detailingProvider struct {
+	*fakeProvider
+
+	details json.RawMessage
+}
+
+func (p *detailingProvider) InstallationDetails(installation *connector.Installation) (json.RawMessage, error) {
+	return p.details, nil
+}
+
+func (p *detailingProvider) InstanceDetails(instance *connector.Instance) (json.RawMessage, error) {
+	return json.RawMessage(fmt.Sprintf(`{"things":%d}`, len(instance.ThingMapping))), nil
+}
+
+func TestProviderDetails(t *testing.T) {
+	database := newTestDB(t)
+	p := &detailingProvider{fakeProvider: newFakeProvider(), details: json.RawMessage(`{"reference":"external-account"}`)}
+	service, err := NewConnectorService(database, &fakeClient{}, p, singleThing, DefaultConnectorServiceOptions, logr.Discard())
+	require.NoError(t, err)
+
+	post := func(handler http.HandlerFunc, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := post(connector.AddInstallation(service), `{"id":"installation-1","token":"token","state":1}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.JSONEq(t, `{"details":{"reference":"external-account"},"furtherStep":{"type":0,"content":""}}`, rec.Body.String())
+
+	rec = post(connector.AddInstance(service), `{"id":"instance-1","installation_id":"installation-1","token":"token","state":1}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.JSONEq(t, `{"details":{"things":1},"furtherStep":{"type":0,"content":""}}`, rec.Body.String())
+
+	// invalid details are dropped
+	p.details = json.RawMessage(`{"reference":`)
+	response, err := service.AddInstallation(context.Background(), connector.InstallationRequest{ID: "installation-2", Token: "token"})
+	require.NoError(t, err)
+	assert.Nil(t, response)
+}
+
+// removalRecordingProvider records whether an installation was still stored when it was removed from the provider.
+type removalRecordingProvider struct {
+	*fakeProvider
+
+	database        connector.Database
+	storedAtRemoval []bool
+	removalErr      error
+}
+
+func (p *removalRecordingProvider) RemoveInstallation(installationId string) error {
+	_, err := p.database.GetInstallationConfiguration(context.Background(), installationId)
+	p.storedAtRemoval = append(p.storedAtRemoval, err == nil)
+	return p.removalErr
+}
+
+func TestInstallationRemovalOrder(t *testing.T) {
+	providerErr := errors.New("cleanup failed")
+
+	tests := []struct {
+		name             string
+		order            RemovalOrder
+		abort            bool
+		removalErr       error
+		expectedErr      error
+		storedAtRemoval  bool
+		storedAfterwards bool
+	}{
+		{"provider first", RemoveFromProviderFirst, false, nil, nil, true, false},
+		{"database first", RemoveFromDatabaseFirst, false, nil, nil, false, false},
+		{"provider first ignores provider error", RemoveFromProviderFirst, false, providerErr, nil, true, false},
+		{"database first ignores provider error", RemoveFromDatabaseFirst, false, providerErr, nil, false, false},
+		{"provider first aborts on provider error", RemoveFromProviderFirst, true, providerErr, providerErr, true, true},
+		{"database first reports provider error", RemoveFromDatabaseFirst, true, providerErr, providerErr, false, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			database := newTestDB(t)
+			addInstallation(t, database, "installation-1")
+
+			p := &removalRecordingProvider{fakeProvider: newFakeProvider(), database: database, removalErr: test.removalErr}
+			options := DefaultConnectorServiceOptions
+			options.InstallationRemovalOrder = test.order
+			options.AbortRemovalOnProviderError = test.abort
+			service, err := NewConnectorService(database, &fakeClient{}, p, noThings, options, logr.Discard())
+			require.NoError(t, err)
+
+			err = service.RemoveInstallation(ctx, "installation-1")
+			assert.Equal(t, test.expectedErr, err)
+			assert.Equal(t, []bool{test.storedAtRemoval}, p.storedAtRemoval)
+
+			_, err = database.GetInstallationConfiguration(ctx, "installation-1")
+			assert.Equal(t, test.storedAfterwards, err == nil)
+		})
+	}
+}
+
+// failingMappingDatabase fails to store thing mappings.
+type failingMappingDatabase struct {
+	connector.Database
+}
+
+func (f failingMappingDatabase) AddThingMapping(ctx context.Context, instanceID string, thingID string, externalId string) error {
+	return errors.New("mapping failed")
+}
+
+func TestCreateThingDeletesUnmappedThing(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	addInstallation(t, database, "installation-1")
+	addInstance(t, database, "installation-1", "instance-1")
+
+	client := &fakeClient{}
+	service, err := NewConnectorService(failingMappingDatabase{database}, client, newFakeProvider(), noThings, DefaultConnectorServiceOptions, logr.Discard())
+	require.NoError(t, err)
+
+	_, err = service.CreateThing(ctx, "instance-1", testThing("thing"), "external")
+	assert.Error(t, err)
+	assert.Equal(t, []string{"thing-1"}, client.deletedThings)
+}
+
+// TestDeleteThingWithAlternativeDatabase verifies that deleting things only relies on the Database interface.
+func TestDeleteThingWithAlternativeDatabase(t *testing.T) {
+	ctx := context.Background()
+	database := db.NewInMemoryDatabase()
+	require.NoError(t, database.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "token"}))
+	require.NoError(t, database.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "token"}))
+	require.NoError(t, database.AddThingMapping(ctx, "instance-1", "thing-1", "external-1"))
+
+	client := &fakeClient{}
+	service, err := NewConnectorService(database, client, newFakeProvider(), noThings, DefaultConnectorServiceOptions, logr.Discard())
+	require.NoError(t, err)
+
+	require.NoError(t, service.DeleteThing(ctx, "instance-1", "thing-1"))
+	assert.Equal(t, []string{"thing-1"}, client.deletedThings)
+
+	mapping, err := database.GetMappingByExternalId(ctx, "instance-1", "external-1")
+	require.NoError(t, err)
+	assert.Empty(t, mapping.ThingID)
+}
+
+func TestDeleteThing(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	addInstallation(t, database, "installation-1")
+	addInstance(t, database, "installation-1", "instance-1", "thing-1", "thing-2")
+
+	client := &fakeClient{}
+	service, err := NewConnectorService(database, client, newFakeProvider(), noThings, DefaultConnectorServiceOptions, logr.Discard())
+	require.NoError(t, err)
+
+	require.NoError(t, service.DeleteThing(ctx, "instance-1", "thing-1"))
+	assert.Equal(t, []string{"thing-1"}, client.deletedThings)
+
+	mappings, err := database.GetMappingByInstanceId(ctx, "instance-1")
+	require.NoError(t, err)
+	require.Len(t, mappings, 1)
+	assert.Equal(t, "thing-2", mappings[0].ThingID)
+
+	// the mapping is kept if the thing could not be deleted at the platform
+	client.deleteThingErr = connector.ErrorUnexpectedStatusCode
+	assert.Equal(t, connector.ErrorUnexpectedStatusCode, service.DeleteThing(ctx, "instance-1", "thing-2"))
+
+	mappings, err = database.GetMappingByInstanceId(ctx, "instance-1")
+	require.NoError(t, err)
+	assert.Len(t, mappings, 1)
+}
+
+func TestStatusRules(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	database := newTestDB(t)
+	addInstallation(t, database, "installation-1")
+	addInstance(t, database, "installation-1", "instance-1", "heartbeat-1", "sensor-1")
+
+	client := &fakeClient{}
+	options := DefaultConnectorServiceOptions
+	options.StatusRules = []StatusRule{{Pattern: "heartbeat-*", StalenessTimeout: 50 * time.Millisecond}}
+	service, err := NewConnectorService(database, client, newFakeProvider(), noThings, options, logr.Discard())
+	require.NoError(t, err)
+	service.EventHandler(ctx)
+
+	require.NoError(t, service.UpdateProperty(ctx, "instance-1", "heartbeat-1", "sensor", "value", "1"))
+	require.NoError(t, service.UpdateProperty(ctx, "instance-1", "sensor-1", "sensor", "value", "1"))
+	assert.Empty(t, client.recordedStatusUpdates())
+
+	// no update arrives within the timeout
+	assert.Eventually(t, func() bool {
+		return len(client.recordedStatusUpdates()) == 1
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, statusUpdate{"heartbeat-1", connctd.StatusTypeUnavailable}, client.recordedStatusUpdates()[0])
+
+	// the thing recovers with the next update
+	require.NoError(t, service.UpdateProperty(ctx, "instance-1", "heartbeat-1", "sensor", "value", "2"))
+	updates := client.recordedStatusUpdates()
+	require.Len(t, updates, 2)
+	assert.Equal(t, statusUpdate{"heartbeat-1", connctd.StatusTypeAvailable}, updates[1])
+}
+
+func TestOrphanThings(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	addInstallation(t, database, "installation-1")
+	addInstance(t, database, "installation-1", "instance-1", "thing-1")
+
+	client := &fakeClient{platformThings: []connctd.Thing{{ID: "thing-1", Name: "mapped"}, {ID: "thing-2", Name: "orphan"}}}
+	service, err := NewConnectorService(database, client, newFakeProvider(), noThings, DefaultConnectorServiceOptions, logr.Discard())
+	require.NoError(t, err)
+
+	orphans, err := service.FindOrphanThings(ctx, "instance-1")
+	require.NoError(t, err)
+	require.Len(t, orphans, 1)
+	assert.Equal(t, "thing-2", orphans[0].ID)
+	assert.Empty(t, client.deletedThings)
+
+	deleted, err := service.DeleteOrphanThings(ctx, "instance-1")
+	require.NoError(t, err)
+	assert.Equal(t, orphans, deleted)
+	assert.Equal(t, []string{"thing-2"}, client.deletedThings)
+
+	_, err = service.FindOrphanThings(ctx, "unknown")
+	assert.Error(t, err)
+}
+
+func TestBaseURLResolver(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	addInstallation(t, database, "staging-installation")
+	addInstance(t, database, "staging-installation", "staging-instance", "staging-thing")
+	addInstallation(t, database, "prod-installation")
+	addInstance(t, database, "prod-installation", "prod-instance", "prod-thing")
+
+	// each environment records the paths of the requests it received
+	var mtx sync.Mutex
+	received := map[string][]string{}
+	newEnvironment := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mtx.Lock()
+			received[name] = append(received[name], r.URL.Path)
+			mtx.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+		}))
+	}
+	staging := newEnvironment("staging")
+	defer staging.Close()
+	prod := newEnvironment("prod")
+	defer prod.Close()
+
+	baseURLs := map[string]*url.URL{}
+	for installationID, server := range map[string]*httptest.Server{"staging-installation": staging, "prod-installation": prod} {
+		baseURL, err := url.Parse(server.URL + "/")
+		require.NoError(t, err)
+		baseURLs[installationID] = baseURL
+	}
+
+	createdClients := 0
+	options := DefaultConnectorServiceOptions
+	options.BaseURLResolver = func(installationID string) *url.URL {
+		return baseURLs[installationID]
+	}
+	options.NewClientForBaseURL = func(baseURL *url.URL) (connector.Client, error) {
+		createdClients++
+		return connector.NewClient(&connector.ClientOptions{ConnctdBaseURL: baseURL}, logr.Discard())
+	}
+
+	// the default client is not used for resolved installations
+	service, err := NewConnectorService(database, &fakeClient{}, newFakeProvider(), noThings, options, logr.Discard())
+	require.NoError(t, err)
+
+	require.NoError(t, service.UpdateProperty(ctx, "staging-instance", "staging-thing", "sensor", "value", "1"))
+	require.NoError(t, service.UpdateProperty(ctx, "prod-instance", "prod-thing", "sensor", "value", "1"))
+	require.NoError(t, service.UpdateProperty(ctx, "prod-instance", "prod-thing", "sensor", "value", "2"))
+
+	assert.Equal(t, []string{"/connectorhub/callback/instances/things/staging-thing/components/sensor/properties/value"}, received["staging"])
+	assert.Len(t, received["prod"], 2)
+	assert.Equal(t, "/connectorhub/callback/instances/things/prod-thing/components/sensor/properties/value", received["prod"][0])
+	assert.Equal(t, 2, createdClients)
+}
+
+func TestBaseURLResolverKeepsClientOptions(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	addInstallation(t, database, "installation-1")
+	addInstance(t, database, "installation-1", "instance-1", "thing-1")
+
+	var userAgent, header string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.UserAgent()
+		header = r.Header.Get("X-Environment")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	baseURL, err := url.Parse(server.URL + "/")
+	require.NoError(t, err)
+
+	options := DefaultConnectorServiceOptions
+	options.BaseURLResolver = func(installationID string) *url.URL { return baseURL }
+	options.ClientOptions = &connector.ClientOptions{
+		HTTPClient:     server.Client(),
+		UserAgent:      "test-connector",
+		DefaultHeaders: http.Header{"X-Environment": []string{"staging"}},
+	}
+
+	service, err := NewConnectorService(database, &fakeClient{}, newFakeProvider(), noThings, options, logr.Discard())
+	require.NoError(t, err)
+
+	require.NoError(t, service.UpdateProperty(ctx, "instance-1", "thing-1", "sensor", "value", "1"))
+	assert.Equal(t, "test-connector", userAgent)
+	assert.Equal(t, "staging", header)
+	// the options of the service are not modified
+	assert.Nil(t, options.ClientOptions.ConnctdBaseURL)
+}
+
+func TestBaseURLResolverUsesDefaultOptions(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	addInstallation(t, database, "installation-1")
+	addInstance(t, database, "installation-1", "instance-1", "thing-1")
+
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+	baseURL, err := url.Parse(server.URL + "/")
+	require.NoError(t, err)
+
+	defaults := defaultClientOptions
+	t.Cleanup(func() { defaultClientOptions = defaults })
+	defaultClientOptions = func() *connector.ClientOptions {
+		opts := connector.DefaultOptions()
+		opts.RequestTimeout = 10 * time.Millisecond
+		return opts
+	}
+
+	options := DefaultConnectorServiceOptions
+	options.BaseURLResolver = func(installationID string) *url.URL { return baseURL }
+
+	service, err := NewConnectorService(database, &fakeClient{}, newFakeProvider(), noThings, options, logr.Discard())
+	require.NoError(t, err)
+
+	// the routed client times out instead of waiting for the hanging server
+	err = service.UpdateProperty(ctx, "instance-1", "thing-1", "sensor", "value", "1")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestLoggedRequestsDoNotContainTokens(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	recorder := &logRecorder{}
+
+	service, err := NewConnectorService(database, &fakeClient{}, newFakeProvider(), noThings, DefaultConnectorServiceOptions, recorder.logger())
+	require.NoError(t, err)
+
+	_, err = service.AddInstallation(ctx, connector.InstallationRequest{ID: "installation-1", Token: "installation-secret-token"})
+	require.NoError(t, err)
+	_, err = service.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "instance-secret-token"})
+	require.NoError(t, err)
+
+	logs := strings.Join(recorder.lines, "\n")
+	assert.Contains(t, logs, "installationRequest")
+	assert.Contains(t, logs, "instantiationRequest")
+	assert.NotContains(t, logs, "secret-token")
+}
+
+func TestAddInstanceCreatesDependenciesFirst(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	addInstallation(t, database, "installation-1")
+
+	templates := func(request connector.InstantiationRequest) []connector.ThingTemplate {
+		return []connector.ThingTemplate{
+			{Thing: testThing("lamp"), ExternalID: "lamp", DependsOn: []string{"gateway"}},
+			{Thing: testThing("gateway"), ExternalID: "gateway"},
+		}
+	}
+
+	client := &fakeClient{}
+	service, err := NewConnectorService(database, client, newFakeProvider(), templates, DefaultConnectorServiceOptions, logr.Discard())
+	require.NoError(t, err)
+
+	_, err = service.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "token"})
+	require.NoError(t, err)
+	require.Len(t, client.createdThings, 2)
+	assert.Equal(t, "gateway", client.createdThings[0].Name)
+	assert.Equal(t, "lamp", client.createdThings[1].Name)
+
+	cyclic := func(request connector.InstantiationRequest) []connector.ThingTemplate {
+		return []connector.ThingTemplate{
+			{Thing: testThing("lamp"), ExternalID: "lamp", DependsOn: []string{"gateway"}},
+			{Thing: testThing("gateway"), ExternalID: "gateway", DependsOn: []string{"lamp"}},
+		}
+	}
+	service, err = NewConnectorService(database, client, newFakeProvider(), cyclic, DefaultConnectorServiceOptions, logr.Discard())
+	require.NoError(t, err)
+
+	_, err = service.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-2", InstallationID: "installation-1", Token: "token"})
+	assert.True(t, errors.Is(err, connector.ErrorDependencyCycle))
+	assert.Len(t, client.createdThings, 2)
+}
+
+func TestInstantiationMessageID(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	addInstallation(t, database, "installation-1")
+
+	var messageIDs []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		messageIDs = append(messageIDs, r.Header.Get(connector.MessageIDHeaderKey))
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"thing-1"}`))
+	}))
+	defer server.Close()
+
+	baseURL, err := url.Parse(server.URL + "/")
+	require.NoError(t, err)
+	client, err := connector.NewClient(&connector.ClientOptions{ConnctdBaseURL: baseURL}, logr.Discard())
+	require.NoError(t, err)
+
+	service, err := NewConnectorService(database, client, newFakeProvider(), singleThing, DefaultConnectorServiceOptions, logr.Discard())
+	require.NoError(t, err)
+
+	_, err = service.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "token", MessageID: "message-1"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"message-1"}, messageIDs)
+
+	// requests without a message ID don't carry the header
+	_, err = service.CreateThing(ctx, "instance-1", testThing("other"), "external-2")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"message-1", ""}, messageIDs)
+}
+
+func TestReportInstanceState(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	addInstallation(t, database, "installation-1")
+	addInstance(t, database, "installation-1", "instance-1")
+
+	client := &fakeClient{instanceStateFailures: 2}
+	options := DefaultConnectorServiceOptions
+	options.StateReportBaseDelay = time.Millisecond
+	service, err := NewConnectorService(database, client, newFakeProvider(), noThings, options, logr.Discard())
+	require.NoError(t, err)
+
+	require.NoError(t, service.ReportInstanceState(ctx, "instance-1", connector.InstantiationStateComplete, json.RawMessage(`{"things":1}`)))
+
+	// the state is retried until the platform acknowledged it
+	assert.Eventually(t, func() bool {
+		states, err := database.GetPendingInstanceStates(ctx)
+		return err == nil && len(states) == 0
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []connector.InstantiationState{
+		connector.InstantiationStateComplete,
+		connector.InstantiationStateComplete,
+		connector.InstantiationStateComplete,
+	}, client.recordedInstanceStates())
+}
+
+func TestShutdownStopsInstanceStateReports(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	addInstallation(t, database, "installation-1")
+	addInstance(t, database, "installation-1", "instance-1")
+
+	client := &fakeClient{instanceStateFailures: 1000}
+	options := DefaultConnectorServiceOptions
+	options.StateReportBaseDelay = time.Millisecond
+	options.StateReportMaxDelay = time.Millisecond
+	service, err := NewConnectorService(database, client, newFakeProvider(), noThings, options, logr.Discard())
+	require.NoError(t, err)
+
+	require.NoError(t, service.ReportInstanceState(ctx, "instance-1", connector.InstantiationStateComplete, nil))
+	require.Eventually(t, func() bool { return len(client.recordedInstanceStates()) > 1 }, time.Second, time.Millisecond)
+	require.NoError(t, service.Shutdown(ctx))
+
+	// the report stops retrying, but the state remains pending
+	time.Sleep(20 * time.Millisecond)
+	reported := len(client.recordedInstanceStates())
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, reported, len(client.recordedInstanceStates()))
+
+	states, err := database.GetPendingInstanceStates(ctx)
+	require.NoError(t, err)
+	assert.Len(t, states, 1)
+}
+
+func TestPermanentInstanceStateFailures(t *testing.T) {
+	errs := map[string]error{
+		"rejected":  &connector.APIError{StatusCode: http.StatusBadRequest},
+		"not found": connector.ErrorInstanceNotFound,
+	}
+
+	for name, reportErr := range errs {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			database := newTestDB(t)
+			addInstallation(t, database, "installation-1")
+			addInstance(t, database, "installation-1", "instance-1")
+
+			client := &fakeClient{instanceStateFailures: 1000, instanceStateErr: reportErr}
+			options := DefaultConnectorServiceOptions
+			options.StateReportBaseDelay = time.Millisecond
+			options.StateReportMaxDelay = time.Millisecond
+			service, err := NewConnectorService(database, client, newFakeProvider(), noThings, options, logr.Discard())
+			require.NoError(t, err)
+
+			// the state is dropped instead of being retried
+			require.NoError(t, service.ReportInstanceState(ctx, "instance-1", connector.InstantiationStateComplete, nil))
+			assert.Eventually(t, func() bool {
+				states, err := database.GetPendingInstanceStates(ctx)
+				return err == nil && len(states) == 0
+			}, time.Second, time.Millisecond)
+			time.Sleep(20 * time.Millisecond)
+			assert.Len(t, client.recordedInstanceStates(), 1)
+		})
+	}
+}
+
+func TestAsyncInstanceCreationReportsState(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	addInstallation(t, database, "installation-1")
+
+	client := &fakeClient{}
+	options := DefaultConnectorServiceOptions
+	options.AsyncInstanceCreation = true
+	options.EnforceThingCreation = false
+	service, err := NewConnectorService(database, client, newFakeProvider(), singleThing, options, logr.Discard())
+	require.NoError(t, err)
+
+	response, err := service.AddInstance(ctx, connector.InstantiationRequest{ID: "instance-1", InstallationID: "installation-1", Token: "token"})
+	require.NoError(t, err)
+	assert.Nil(t, response)
+
+	// the platform is informed once all things are created
+	require.Eventually(t, func() bool { return len(client.recordedInstanceStates()) == 1 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []connector.InstantiationState{connector.InstantiationStateComplete}, client.recordedInstanceStates())
+	assert.True(t, service.instanceOperational("instance-1"))
+}
+
+func TestResumeInstanceStateReports(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	addInstallation(t, database, "installation-1")
+	addInstance(t, database, "installation-1", "instance-1")
+
+	// the state was stored before a restart
+	require.NoError(t, database.SetPendingInstanceState(ctx, connector.PendingInstanceState{InstanceID: "instance-1", State: connector.InstantiationStateFailed}))
+
+	client := &fakeClient{}
+	_, err := NewConnectorService(database, client, newFakeProvider(), noThings, DefaultConnectorServiceOptions, logr.Discard())
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		states, err := database.GetPendingInstanceStates(ctx)
+		return err == nil && len(states) == 0
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []connector.InstantiationState{connector.InstantiationStateFailed}, client.recordedInstanceStates())
+}
+
+func TestRetryInstanceThings(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	addInstallation(t, database, "installation-1")
+	addInstance(t, database, "installation-1", "instance-1", "existing")
+
+	templates := func(request connector.InstantiationRequest) []connector.ThingTemplate {
+		return []connector.ThingTemplate{
+			{Thing: testThing("existing"), ExternalID: "external-existing"},
+			{Thing: testThing("missing"), ExternalID: "external-missing"},
+		}
+	}
+
+	client := &fakeClient{}
+	service, err := NewConnectorService(database, client, newFakeProvider(), templates, DefaultConnectorServiceOptions, logr.Discard())
+	require.NoError(t, err)
+
+	require.NoError(t, service.RetryInstanceThings(ctx, "instance-1"))
+	require.Len(t, client.createdThings, 1)
+	assert.Equal(t, "missing", client.createdThings[0].Name)
+
+	instance, err := database.GetInstance(ctx, "instance-1")
+	require.NoError(t, err)
+	assert.Len(t, instance.ThingMapping, 2)
+	thingId, ok := instance.ThingIdByExternalId("external-missing")
+	assert.True(t, ok)
+	assert.Equal(t, "thing-1", thingId)
+
+	// all things exist now
+	require.NoError(t, service.RetryInstanceThings(ctx, "instance-1"))
+	assert.Len(t, client.createdThings, 1)
+
+	assert.Error(t, service.RetryInstanceThings(ctx, "unknown"))
+}
+
+func TestSyncThings(t *testing.T) {
+	ctx := context.Background()
+	templates := func(request connector.InstantiationRequest) []connector.ThingTemplate {
+		return []connector.ThingTemplate{
+			{Thing: testThing("existing"), ExternalID: "external-existing"},
+			{Thing: testThing("missing"), ExternalID: "external-missing"},
+		}
+	}
+
+	t.Run("create missing", func(t *testing.T) {
+		database := newTestDB(t)
+		addInstallation(t, database, "installation-1")
+		addInstance(t, database, "installation-1", "instance-1", "existing", "stale")
+
+		client := &fakeClient{}
+		service, err := NewConnectorService(database, client, newFakeProvider(), templates, DefaultConnectorServiceOptions, logr.Discard())
+		require.NoError(t, err)
+
+		require.NoError(t, service.SyncThings(ctx, "instance-1"))
+		require.Len(t, client.createdThings, 1)
+		assert.Equal(t, "missing", client.createdThings[0].Name)
+
+		// stale things are kept by default
+		assert.Empty(t, client.deletedThings)
+		instance, err := database.GetInstance(ctx, "instance-1")
+		require.NoError(t, err)
+		assert.Len(t, instance.ThingMapping, 3)
+
+		require.NoError(t, service.SyncThings(ctx, "instance-1"))
+		assert.Len(t, client.createdThings, 1)
+	})
+
+	t.Run("delete stale", func(t *testing.T) {
+		database := newTestDB(t)
+		addInstallation(t, database, "installation-1")
+		addInstance(t, database, "installation-1", "instance-1", "existing", "stale")
+
+		client := &fakeClient{}
+		options := DefaultConnectorServiceOptions
+		options.DeleteStaleThings = true
+		service, err := NewConnectorService(database, client, newFakeProvider(), templates, options, logr.Discard())
+		require.NoError(t, err)
+
+		require.NoError(t, service.SyncThings(ctx, "instance-1"))
+		assert.Len(t, client.createdThings, 1)
+		assert.Equal(t, []string{"stale"}, client.deletedThings)
+
+		instance, err := database.GetInstance(ctx, "instance-1")
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []connector.ThingMapping{
+			{InstanceID: "instance-1", ThingID: "existing", ExternalID: "external-existing"},
+			{InstanceID: "instance-1", ThingID: "thing-1", ExternalID: "external-missing"},
+		}, instance.ThingMapping)
+
+		// the instance matches its templates now
+		require.NoError(t, service.SyncThings(ctx, "instance-1"))
+		assert.Len(t, client.createdThings, 1)
+		assert.Len(t, client.deletedThings, 1)
+	})
+
+	t.Run("failed deletion", func(t *testing.T) {
+		database := newTestDB(t)
+		addInstallation(t, database, "installation-1")
+		addInstance(t, database, "installation-1", "instance-1", "existing", "missing", "stale")
+
+		client := &fakeClient{deleteThingErr: connector.ErrorUnexpectedStatusCode}
+		options := DefaultConnectorServiceOptions
+		options.DeleteStaleThings = true
+		service, err := NewConnectorService(database, client, newFakeProvider(), templates, options, logr.Discard())
+		require.NoError(t, err)
+
+		assert.Equal(t, connector.ErrorUnexpectedStatusCode, service.SyncThings(ctx, "instance-1"))
+
+		// the mapping is kept, so the deletion is retried with the next sync
+		mapping, err := database.GetMappingByExternalId(ctx, "instance-1", "external-stale")
+		require.NoError(t, err)
+		assert.Equal(t, "stale", mapping.ThingID)
+	})
+}
+
+func TestSyncInstanceThings(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	addInstallation(t, database, "installation-1")
+	addInstance(t, database, "installation-1", "instance-1", "existing")
+
+	client := &fakeClient{}
+	service, err := NewConnectorService(database, client, newFakeProvider(), noThings, DefaultConnectorServiceOptions, logr.Discard())
+	require.NoError(t, err)
+
+	instance, err := database.GetInstance(ctx, "instance-1")
+	require.NoError(t, err)
+
+	mappings, err := service.SyncInstanceThings(ctx, instance, []connector.ThingTemplate{
+		{Thing: testThing("lamp"), ExternalID: "Lamp", DependsOn: []string{"gateway"}},
+		{Thing: testThing("gateway"), ExternalID: "gateway"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []connector.ThingMapping{
+		{InstanceID: "instance-1", ThingID: "thing-1", ExternalID: "gateway"},
+		{InstanceID: "instance-1", ThingID: "thing-2", ExternalID: connector.NormalizeExternalID("Lamp")},
+	}, mappings)
+	require.Len(t, client.createdThings, 2)
+	assert.Equal(t, "gateway", client.createdThings[0].Name)
+	assert.Len(t, instance.ThingMapping, 3)
+
+	stored, err := database.GetMappingByInstanceId(ctx, "instance-1")
+	require.NoError(t, err)
+	assert.Subset(t, stored, mappings)
+
+	_, err = service.SyncInstanceThings(ctx, instance, []connector.ThingTemplate{{Thing: testThing("lamp"), ExternalID: "lamp", DependsOn: []string{"unknown"}}})
+	assert.True(t, errors.Is(err, connector.ErrorUnknownDependency))
+	assert.Len(t, client.createdThings, 2)
+}
+
+func TestActionsOfNotOperationalInstances(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	addInstallation(t, database, "installation-1")
+	addInstance(t, database, "installation-1", "instance-1", "thing-1")
+	addInstance(t, database, "installation-1", "instance-2", "thing-2")
+
+	p := newFakeProvider()
+	options := DefaultConnectorServiceOptions
+	options.StateReportBaseDelay = time.Millisecond
+	service, err := NewConnectorService(database, &fakeClient{}, p, noThings, options, logr.Discard())
+	require.NoError(t, err)
+
+	require.NoError(t, service.ReportInstanceState(ctx, "instance-1", connector.InstantiationStateFailed, nil))
+
+	response, err := service.PerformAction(ctx, connector.ActionRequest{ID: "action-1", ThingID: "thing-1", ComponentID: "sensor", ActionID: "dim"})
+	require.NoError(t, err)
+	require.NotNil(t, response)
+	assert.Equal(t, connector.ActionRequestStatusFailed, response.Status)
+	assert.Equal(t, ErrorInstanceNotOperational.Error(), response.Error)
+	assert.Empty(t, p.actionRequests)
+
+	responses, err := service.PerformActions(ctx, []connector.ActionRequest{
+		{ID: "action-2", ThingID: "thing-1", ComponentID: "sensor", ActionID: "dim"},
+		{ID: "action-3", ThingID: "thing-2", ComponentID: "sensor", ActionID: "dim"},
+	})
+	require.NoError(t, err)
+	require.Len(t, responses, 2)
+	assert.Equal(t, ErrorInstanceNotOperational.Error(), responses[0].Error)
+	assert.Equal(t, connector.ActionRequestStatusCompleted, responses[1].Status)
+	require.Len(t, p.actionRequests, 1)
+	assert.Equal(t, "thing-2", p.actionRequests[0].ThingID)
+
+	// the instance is operational again once it is complete
+	require.NoError(t, service.ReportInstanceState(ctx, "instance-1", connector.InstantiationStateComplete, nil))
+
+	response, err = service.PerformAction(ctx, connector.ActionRequest{ID: "action-4", ThingID: "thing-1", ComponentID: "sensor", ActionID: "dim"})
+	require.NoError(t, err)
+	assert.Nil(t, response)
+	require.Len(t, p.actionRequests, 2)
+	assert.Equal(t, "thing-1", p.actionRequests[1].ThingID)
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/connctd/connector-go"
+)
+
+// retriesUpdateEvent reports whether the failed update is retried according to the EventRetryPolicy.
+func (s *DefaultConnectorService) retriesUpdateEvent(err error) bool {
+	if err == nil || s.options.EventRetryPolicy.MaxAttempts < 2 {
+		return false
+	}
+	retryable := s.options.EventRetryPolicy.Retryable
+	if retryable == nil {
+		retryable = connector.IsRetryable
+	}
+	return retryable(err)
+}
+
+// retryUpdateEvent runs the retries of an update event in their own goroutine,
+// so a failing update does not delay subsequent events. The goroutine is awaited by Shutdown.
+func (s *DefaultConnectorService) retryUpdateEvent(update connector.UpdateEvent, retry func()) {
+	s.eventHandlers.Add(1)
+	go func() {
+		defer s.eventHandlers.Done()
+		defer s.recoverUpdateEvent(update)
+		retry()
+	}()
+}
+
+// retryUpdate calls send with exponential backoff after the first attempt failed with err.
+// It returns nil once send succeeds, or the last error if it is not retryable, all attempts failed,
+// the context is done or the service is shut down.
+func (s *DefaultConnectorService) retryUpdate(ctx context.Context, err error, send func(ctx context.Context) error) error {
+	policy := s.options.EventRetryPolicy
+	delay := policy.BaseDelay
+
+	for attempt := 2; attempt <= policy.MaxAttempts && s.retriesUpdateEvent(err); attempt++ {
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-s.shutdown:
+			timer.Stop()
+			return err
+		}
+
+		if err = send(ctx); err == nil {
+			return nil
+		}
+		if delay *= 2; policy.MaxDelay > 0 && delay > policy.MaxDelay {
+			delay = policy.MaxDelay
+		}
+	}
+	return err
+}
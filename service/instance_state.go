@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/connctd/connector-go"
+)
+
+// Default delays between attempts to report an instance state:
+const (
+	defaultStateReportBaseDelay = time.Second
+	defaultStateReportMaxDelay  = 5 * time.Minute
+)
+
+// ReportInstanceState reports the state of an instance to the connctd platform, e.g. the completion of an
+// instantiation that was answered with a further step. The state is stored in the database and reported in the
+// background with exponential backoff until the platform acknowledged it. Pending states are resumed after a restart.
+// States of removed instances and states the platform rejects permanently are dropped and logged instead of retried.
+// A newer state of the same instance replaces a state that was not reported yet.
+// Action requests of instances whose last reported state is not complete are rejected, see PerformAction.
+// It returns an error if the state could not be stored.
+func (s *DefaultConnectorService) ReportInstanceState(ctx context.Context, instanceId string, state connector.InstantiationState, details json.RawMessage) error {
+	pending := connector.PendingInstanceState{InstanceID: instanceId, State: state, Details: details}
+	if err := s.db.SetPendingInstanceState(ctx, pending); err != nil {
+		s.scopedLogger("", instanceId).Error(err, "failed to store pending instance state", "state", state)
+		return err
+	}
+
+	s.setInstanceState(instanceId, state)
+	s.startStateReport(instanceId)
+	return nil
+}
+
+// setInstanceState records the last known state of the instance.
+func (s *DefaultConnectorService) setInstanceState(instanceId string, state connector.InstantiationState) {
+	s.instanceStatesMutex.Lock()
+	defer s.instanceStatesMutex.Unlock()
+
+	s.instanceStates[instanceId] = state
+}
+
+// removeInstanceState stops tracking the state of a removed instance.
+func (s *DefaultConnectorService) removeInstanceState(instanceId string) {
+	s.instanceStatesMutex.Lock()
+	defer s.instanceStatesMutex.Unlock()
+
+	delete(s.instanceStates, instanceId)
+}
+
+// instanceOperational reports whether the instance can handle action requests.
+// Instances are operational unless their last known state is initialized, ongoing or failed.
+func (s *DefaultConnectorService) instanceOperational(instanceId string) bool {
+	s.instanceStatesMutex.RLock()
+	defer s.instanceStatesMutex.RUnlock()
+
+	state, ok := s.instanceStates[instanceId]
+	return !ok || state == connector.InstantiationStateComplete
+}
+
+// resumeStateReports starts reporting all states that were pending when the connector stopped.
+func (s *DefaultConnectorService) resumeStateReports(ctx context.Context) error {
+	states, err := s.db.GetPendingInstanceStates(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, state := range states {
+		s.setInstanceState(state.InstanceID, state.State)
+		s.startStateReport(state.InstanceID)
+	}
+	return nil
+}
+
+// startStateReport starts reporting the pending state of the instance unless a report is already running.
+// A running report checks for newer states before it finishes.
+func (s *DefaultConnectorService) startStateReport(instanceId string) {
+	s.stateReportsMutex.Lock()
+	defer s.stateReportsMutex.Unlock()
+
+	if _, running := s.stateReports[instanceId]; running {
+		s.stateReports[instanceId] = true
+		return
+	}
+
+	s.stateReports[instanceId] = false
+	go s.reportPendingStates(instanceId)
+}
+
+// finishStateReport ends the report of the instance and returns true, unless a newer state was stored meanwhile.
+func (s *DefaultConnectorService) finishStateReport(instanceId string) bool {
+	s.stateReportsMutex.Lock()
+	defer s.stateReportsMutex.Unlock()
+
+	if s.stateReports[instanceId] {
+		s.stateReports[instanceId] = false
+		return false
+	}
+
+	delete(s.stateReports, instanceId)
+	return true
+}
+
+// stopStateReport ends the report of the instance without checking for newer states.
+func (s *DefaultConnectorService) stopStateReport(instanceId string) {
+	s.stateReportsMutex.Lock()
+	defer s.stateReportsMutex.Unlock()
+
+	delete(s.stateReports, instanceId)
+}
+
+// reportPendingStates reports the pending state of the instance until no state is pending anymore
+// or the service is shut down. States that were not reported yet are resumed after a restart.
+func (s *DefaultConnectorService) reportPendingStates(instanceId string) {
+	ctx := context.Background()
+	logger := s.scopedLogger("", instanceId)
+
+	baseDelay := s.options.StateReportBaseDelay
+	if baseDelay <= 0 {
+		baseDelay = defaultStateReportBaseDelay
+	}
+	maxDelay := s.options.StateReportMaxDelay
+	if maxDelay <= 0 {
+		maxDelay = defaultStateReportMaxDelay
+	}
+
+	delay := baseDelay
+	for {
+		select {
+		case <-s.shutdown:
+			s.stopStateReport(instanceId)
+			return
+		default:
+		}
+
+		state, err := s.db.GetPendingInstanceState(ctx, instanceId)
+		if err == nil && state == nil {
+			if s.finishStateReport(instanceId) {
+				return
+			}
+			continue
+		}
+
+		if err == nil {
+			err = s.sendInstanceState(ctx, *state)
+			if permanentStateReportError(err) {
+				// retrying does not help, e.g. if the instance was removed or the platform rejects the state
+				logger.Error(err, "failed to report instance state, dropping it", "state", state.State)
+				err = s.db.RemovePendingInstanceState(ctx, *state)
+			} else if err == nil {
+				if err = s.db.RemovePendingInstanceState(ctx, *state); err == nil {
+					logger.Info("Reported instance state", "state", state.State)
+				}
+			}
+			if err == nil {
+				delay = baseDelay
+				continue
+			}
+		}
+
+		logger.Error(err, "failed to report instance state, retrying", "delay", delay)
+		select {
+		case <-time.After(delay):
+		case <-s.shutdown:
+			s.stopStateReport(instanceId)
+			return
+		}
+
+		if delay *= 2; delay > maxDelay {
+			delay = maxDelay
+		}
+	}
+}
+
+// permanentStateReportError reports whether the state report failed with an error that won't be resolved by retrying.
+func permanentStateReportError(err error) bool {
+	return errors.Is(err, connector.ErrorInstanceNotFound) || connector.KindOf(err) == connector.ErrorKindPermanent
+}
+
+// sendInstanceState sends the state to the connctd platform.
+func (s *DefaultConnectorService) sendInstanceState(ctx context.Context, state connector.PendingInstanceState) error {
+	instance, err := s.db.GetInstance(ctx, state.InstanceID)
+	if err != nil {
+		return err
+	}
+
+	client, err := s.clientFor(instance.InstallationID)
+	if err != nil {
+		return err
+	}
+
+	return client.UpdateInstanceState(ctx, instance.Token, state.State, state.Details)
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+
+	"github.com/connctd/connector-go"
+)
+
+// RetryInstanceThings creates the things of the instance that are missing after a partially failed instance creation.
+// The thing templates of the instance are compared with its thing mappings by external ID
+// and only the templates without mapping are created, so existing things are not duplicated.
+// It stops at the first thing that can not be created and returns the error.
+func (s *DefaultConnectorService) RetryInstanceThings(ctx context.Context, instanceId string) error {
+	instance, err := s.db.GetInstance(ctx, instanceId)
+	if err != nil {
+		s.scopedLogger("", instanceId).Error(err, "failed to retrieve instance from database")
+		return err
+	}
+
+	logger := s.scopedLogger(instance.InstallationID, instanceId)
+
+	thingTemplates := s.thingTemplates(instantiationRequest(instance))
+	if err := connector.ValidateTemplates(thingTemplates); err != nil {
+		logger.Error(err, "Invalid thing templates")
+		return err
+	}
+
+	// missing dependencies are created before the things depending on them
+	thingTemplates, err = connector.SortTemplates(thingTemplates)
+	if err != nil {
+		logger.Error(err, "Invalid thing template dependencies")
+		return err
+	}
+
+	mapped := make(map[string]bool, len(instance.ThingMapping))
+	for _, mapping := range instance.ThingMapping {
+		mapped[mapping.ExternalID] = true
+	}
+
+	for _, template := range thingTemplates {
+		if mapped[connector.NormalizeExternalID(template.ExternalID)] {
+			continue
+		}
+
+		if err := cancelled(ctx, logger); err != nil {
+			return err
+		}
+
+		if _, err := s.CreateThing(ctx, instanceId, template.Thing, template.ExternalID); err != nil {
+			logger.WithValues("thing", template).Error(err, "Failed to create missing thing")
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SyncInstanceThings creates a thing for each of the templates, dependencies first, and maps it to the instance.
+// It returns the mappings of the created things, which are also appended to the thing mappings of the instance.
+// Templates whose thing can not be created are skipped, unless EnforceThingCreation is enabled.
+// The instance is not registered with the provider again, so a registered instance can be passed.
+func (s *DefaultConnectorService) SyncInstanceThings(ctx context.Context, instance *connector.Instance, thingTemplates []connector.ThingTemplate) ([]connector.ThingMapping, error) {
+	logger := s.scopedLogger(instance.InstallationID, instance.ID)
+
+	if err := connector.ValidateTemplates(thingTemplates); err != nil {
+		logger.Error(err, "Invalid thing templates")
+		return nil, err
+	}
+
+	thingTemplates, err := connector.SortTemplates(thingTemplates)
+	if err != nil {
+		logger.Error(err, "Invalid thing template dependencies")
+		return nil, err
+	}
+
+	thingMapping, err := s.synchronizeThings(ctx, instance, thingTemplates)
+	if err != nil {
+		return nil, err
+	}
+
+	instance.ThingMapping = append(instance.ThingMapping, thingMapping...)
+	return thingMapping, nil
+}
+
+// SyncThings brings the things of the instance in line with its thing templates, e.g. after the templates changed
+// with a new connector version. Things of templates without thing mapping are created like with RetryInstanceThings.
+// If DeleteStaleThings is enabled, things whose external ID is not part of the templates anymore are deleted.
+// An instance already matching its templates is left unchanged, so it is safe to call SyncThings for all instances on startup.
+// Like RetryInstanceThings it does not notify the provider about changed thing mappings.
+func (s *DefaultConnectorService) SyncThings(ctx context.Context, instanceId string) error {
+	if err := s.RetryInstanceThings(ctx, instanceId); err != nil {
+		return err
+	}
+
+	if !s.options.DeleteStaleThings {
+		return nil
+	}
+
+	instance, err := s.db.GetInstance(ctx, instanceId)
+	if err != nil {
+		s.scopedLogger("", instanceId).Error(err, "failed to retrieve instance from database")
+		return err
+	}
+
+	logger := s.scopedLogger(instance.InstallationID, instanceId)
+
+	templated := make(map[string]bool)
+	for _, template := range s.thingTemplates(instantiationRequest(instance)) {
+		templated[connector.NormalizeExternalID(template.ExternalID)] = true
+	}
+
+	for _, mapping := range instance.ThingMapping {
+		if templated[mapping.ExternalID] {
+			continue
+		}
+
+		if err := cancelled(ctx, logger); err != nil {
+			return err
+		}
+
+		if err := s.DeleteThing(ctx, instanceId, mapping.ThingID); err != nil {
+			logger.WithValues("thingId", mapping.ThingID, "externalId", mapping.ExternalID).Error(err, "Failed to delete stale thing")
+			return err
+		}
+	}
+
+	return nil
+}
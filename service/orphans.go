@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+
+	"github.com/connctd/connector-go/connctd"
+)
+
+// FindOrphanThings returns the things of the instance that exist at the connctd platform but are not mapped locally,
+// e.g. after a failed instance creation. See DeleteOrphanThings for their removal.
+func (s *DefaultConnectorService) FindOrphanThings(ctx context.Context, instanceId string) ([]connctd.Thing, error) {
+	instance, err := s.db.GetInstance(ctx, instanceId)
+	if err != nil {
+		s.scopedLogger("", instanceId).Error(err, "failed to retrieve instance from database")
+		return nil, err
+	}
+
+	logger := s.scopedLogger(instance.InstallationID, instanceId)
+
+	client, err := s.clientFor(instance.InstallationID)
+	if err != nil {
+		return nil, err
+	}
+
+	things, err := client.ListThings(ctx, instance.Token)
+	if err != nil {
+		logger.Error(err, "failed to list things of instance")
+		return nil, err
+	}
+
+	mappings, err := s.db.GetMappingByInstanceId(ctx, instanceId)
+	if err != nil {
+		logger.Error(err, "failed to retrieve thing mappings")
+		return nil, err
+	}
+
+	mapped := make(map[string]bool, len(mappings))
+	for _, mapping := range mappings {
+		mapped[mapping.ThingID] = true
+	}
+
+	orphans := []connctd.Thing{}
+	for _, thing := range things {
+		if !mapped[thing.ID] {
+			orphans = append(orphans, thing)
+		}
+	}
+	return orphans, nil
+}
+
+// DeleteOrphanThings deletes all orphan things of the instance at the connctd platform and returns the deleted things.
+// It stops at the first thing that can not be deleted and returns the things deleted so far together with the error.
+func (s *DefaultConnectorService) DeleteOrphanThings(ctx context.Context, instanceId string) ([]connctd.Thing, error) {
+	orphans, err := s.FindOrphanThings(ctx, instanceId)
+	if err != nil {
+		return nil, err
+	}
+
+	instance, err := s.db.GetInstance(ctx, instanceId)
+	if err != nil {
+		s.scopedLogger("", instanceId).Error(err, "failed to retrieve instance from database")
+		return nil, err
+	}
+
+	client, err := s.clientFor(instance.InstallationID)
+	if err != nil {
+		return nil, err
+	}
+
+	deleted := []connctd.Thing{}
+	for _, orphan := range orphans {
+		if err := client.DeleteThing(ctx, instance.Token, orphan.ID); err != nil {
+			s.scopedLogger(instance.InstallationID, instanceId).WithValues("thingId", orphan.ID).Error(err, "failed to delete orphan thing")
+			return deleted, err
+		}
+		deleted = append(deleted, orphan)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/connctd/connector-go"
+)
+
+// watchPendingAction fails the pending action request if its completion is not reported
+// within the PendingActionTimeout. It does nothing if no timeout is configured or the service is shut down.
+func (s *DefaultConnectorService) watchPendingAction(instanceId string, actionRequestId string) {
+	if s.options.PendingActionTimeout <= 0 {
+		return
+	}
+
+	s.pendingActionsMutex.Lock()
+	defer s.pendingActionsMutex.Unlock()
+
+	select {
+	case <-s.shutdown:
+		return
+	default:
+	}
+
+	if timer, ok := s.pendingActions[actionRequestId]; ok {
+		timer.Stop()
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(s.options.PendingActionTimeout, func() {
+		s.pendingActionsMutex.Lock()
+		// the completion arrived while the timer fired
+		if s.pendingActions[actionRequestId] != timer {
+			s.pendingActionsMutex.Unlock()
+			return
+		}
+		delete(s.pendingActions, actionRequestId)
+		s.pendingActionsMutex.Unlock()
+
+		logger := s.scopedLogger("", instanceId).WithValues("actionRequestId", actionRequestId)
+		logger.Info("Pending action was not completed in time, failing it")
+
+		response := &connector.ActionResponse{Status: connector.ActionRequestStatusFailed, Error: ErrorPendingActionTimeout.Error()}
+		if err := s.UpdateActionStatus(context.Background(), instanceId, actionRequestId, response); err != nil {
+			logger.Error(err, "Failed to fail pending action")
+		}
+	})
+	s.pendingActions[actionRequestId] = timer
+}
+
+// stopPendingAction stops watching the action request once its completion was reported.
+func (s *DefaultConnectorService) stopPendingAction(actionRequestId string) {
+	s.pendingActionsMutex.Lock()
+	defer s.pendingActionsMutex.Unlock()
+
+	if timer, ok := s.pendingActions[actionRequestId]; ok {
+		timer.Stop()
+		delete(s.pendingActions, actionRequestId)
+	}
+}
+
+// stopPendingActions stops watching all pending action requests when the service is shut down.
+func (s *DefaultConnectorService) stopPendingActions() {
+	s.pendingActionsMutex.Lock()
+	defer s.pendingActionsMutex.Unlock()
+
+	for actionRequestId, timer := range s.pendingActions {
+		timer.Stop()
+		delete(s.pendingActions, actionRequestId)
+	}
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"path"
+	"time"
+
+	"github.com/connctd/connector-go"
+	"github.com/connctd/connector-go/connctd"
+)
+
+// StatusRule marks a thing as unavailable if no property update arrived within the StalenessTimeout,
+// e.g. because a heartbeat property went stale. The thing is marked as available again with the next property update.
+// Things are tracked from their first property update after the start of the connector.
+type StatusRule struct {
+	// ThingID selects a single thing. If it is empty, Pattern is used.
+	ThingID string
+
+	// Pattern selects things by matching their IDs with path.Match, e.g. "*" for all things.
+	Pattern string
+
+	StalenessTimeout time.Duration
+}
+
+// matches returns true if the rule applies to the thing.
+func (r StatusRule) matches(thingId string) bool {
+	if r.ThingID != "" {
+		return r.ThingID == thingId
+	}
+	matched, err := path.Match(r.Pattern, thingId)
+	return err == nil && matched
+}
+
+// thingLiveness is the state of a thing tracked by a StatusRule.
+type thingLiveness struct {
+	instance    *connector.Instance
+	timeout     time.Duration
+	lastUpdate  time.Time
+	unavailable bool
+}
+
+// statusRule returns the first rule matching the thing.
+func (s *DefaultConnectorService) statusRule(thingId string) (StatusRule, bool) {
+	for _, rule := range s.options.StatusRules {
+		if rule.matches(thingId) {
+			return rule, true
+		}
+	}
+	return StatusRule{}, false
+}
+
+// recordPropertyUpdate tracks the update of a thing matched by a status rule.
+// If the thing was marked as unavailable, it is marked as available again.
+func (s *DefaultConnectorService) recordPropertyUpdate(ctx context.Context, instance *connector.Instance, thingId string, timestamp time.Time) {
+	rule, ok := s.statusRule(thingId)
+	if !ok {
+		return
+	}
+
+	s.livenessMutex.Lock()
+	liveness, ok := s.liveness[thingId]
+	if !ok {
+		liveness = &thingLiveness{instance: instance, timeout: rule.StalenessTimeout}
+		s.liveness[thingId] = liveness
+	}
+	liveness.lastUpdate = timestamp
+	recovered := liveness.unavailable
+	liveness.unavailable = false
+	s.livenessMutex.Unlock()
+
+	if recovered {
+		s.updateThingStatus(ctx, instance, thingId, connctd.StatusTypeAvailable)
+	}
+}
+
+// checkStatusRules periodically marks stale things as unavailable until the context is done.
+func (s *DefaultConnectorService) checkStatusRules(ctx context.Context) {
+	interval := s.options.StatusRules[0].StalenessTimeout
+	for _, rule := range s.options.StatusRules {
+		if rule.StalenessTimeout < interval {
+			interval = rule.StalenessTimeout
+		}
+	}
+	if interval /= 2; interval <= 0 {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.shutdown:
+			return
+		case now := <-ticker.C:
+			s.markStaleThings(ctx, now)
+		}
+	}
+}
+
+// markStaleThings marks all things without a property update within their staleness timeout as unavailable.
+func (s *DefaultConnectorService) markStaleThings(ctx context.Context, now time.Time) {
+	stale := map[string]*connector.Instance{}
+
+	s.livenessMutex.Lock()
+	for thingId, liveness := range s.liveness {
+		if !liveness.unavailable && now.Sub(liveness.lastUpdate) > liveness.timeout {
+			liveness.unavailable = true
+			stale[thingId] = liveness.instance
+		}
+	}
+	s.livenessMutex.Unlock()
+
+	for thingId, instance := range stale {
+		s.updateThingStatus(ctx, instance, thingId, connctd.StatusTypeUnavailable)
+	}
+}
+
+// updateThingStatus sends the status of the thing to the platform and logs failures.
+func (s *DefaultConnectorService) updateThingStatus(ctx context.Context, instance *connector.Instance, thingId string, status connctd.StatusType) {
+	logger := s.scopedLogger(instance.InstallationID, instance.ID).WithValues("thingId", thingId, "status", status)
+	client, err := s.clientFor(instance.InstallationID)
+	if err != nil {
+		return
+	}
+
+	if err := client.UpdateThingStatus(ctx, instance.Token, thingId, status); err != nil {
+		logger.Error(err, "Failed to update thing status")
+		return
+	}
+	logger.Info("Updated thing status")
+}
@@ -0,0 +1,47 @@
+package connector
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSortTemplates(t *testing.T) {
+	externalIDs := func(templates []ThingTemplate) []string {
+		ids := []string{}
+		for _, template := range templates {
+			ids = append(ids, template.ExternalID)
+		}
+		return ids
+	}
+
+	// templates without dependencies keep their order
+	sorted, err := SortTemplates([]ThingTemplate{{ExternalID: "a"}, {ExternalID: "b"}, {ExternalID: "c"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, externalIDs(sorted))
+
+	sorted, err = SortTemplates([]ThingTemplate{
+		{ExternalID: "lamp", DependsOn: []string{"Gateway"}},
+		{ExternalID: "switch", DependsOn: []string{"gateway", "lamp"}},
+		{ExternalID: "sensor"},
+		{ExternalID: "gateway"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"gateway", "lamp", "switch", "sensor"}, externalIDs(sorted))
+
+	_, err = SortTemplates([]ThingTemplate{{ExternalID: "lamp", DependsOn: []string{"gateway"}}})
+	assert.True(t, errors.Is(err, ErrorUnknownDependency))
+
+	_, err = SortTemplates([]ThingTemplate{
+		{ExternalID: "a", DependsOn: []string{"b"}},
+		{ExternalID: "b", DependsOn: []string{"c"}},
+		{ExternalID: "c", DependsOn: []string{"a"}},
+	})
+	assert.True(t, errors.Is(err, ErrorDependencyCycle))
+	assert.Contains(t, err.Error(), "a -> b -> c -> a")
+
+	_, err = SortTemplates([]ThingTemplate{{ExternalID: "a", DependsOn: []string{"a"}}})
+	assert.True(t, errors.Is(err, ErrorDependencyCycle))
+}
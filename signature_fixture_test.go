@@ -0,0 +1,89 @@
+package connector
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// signatureFixture is a recorded callback request of the connctd platform together with the key it was signed with.
+// Fixtures are stored as json in testdata/signatures. Recorded requests of the real platform can be added
+// by copying method, url, headers (including the signature) and body from a captured request.
+// Where the real key or signature can not be committed, fixtures are signed with a stub key.
+type signatureFixture struct {
+	Description string      `json:"description"`
+	PublicKey   string      `json:"publicKey"`
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	Headers     http.Header `json:"headers"`
+	Body        string      `json:"body"`
+}
+
+// loadSignatureFixtures loads all fixtures matching the glob pattern.
+func loadSignatureFixtures(t *testing.T, pattern string) map[string]signatureFixture {
+	files, err := filepath.Glob(pattern)
+	require.NoError(t, err)
+	require.NotEmpty(t, files, "no fixtures found for %s", pattern)
+
+	fixtures := make(map[string]signatureFixture, len(files))
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		require.NoError(t, err)
+
+		var fixture signatureFixture
+		require.NoError(t, json.Unmarshal(b, &fixture), file)
+		fixtures[filepath.Base(file)] = fixture
+	}
+	return fixtures
+}
+
+// publicKey decodes the public key of the fixture.
+func (f signatureFixture) publicKey(t *testing.T) ed25519.PublicKey {
+	key, err := base64.StdEncoding.DecodeString(f.PublicKey)
+	require.NoError(t, err)
+	require.Len(t, key, ed25519.PublicKeySize)
+	return ed25519.PublicKey(key)
+}
+
+// request recreates the recorded request as received by the connector.
+func (f signatureFixture) request() *http.Request {
+	req := httptest.NewRequest(f.Method, f.URL, bytes.NewReader([]byte(f.Body)))
+	for key, values := range f.Headers {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	return req
+}
+
+func TestSignatureValidationFixtures(t *testing.T) {
+	okHandler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	for name, fixture := range loadSignatureFixtures(t, filepath.Join("testdata", "signatures", "*.json")) {
+		t.Run(name, func(t *testing.T) {
+			handler := NewSignatureValidationHandler(DefaultValidationPreProcessor(), fixture.publicKey(t), okHandler)
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, fixture.request())
+			assert.Equal(t, http.StatusOK, rec.Code, fixture.Description)
+
+			// any modification of the recorded request invalidates the signature
+			tampered := fixture
+			tampered.Body += " "
+			rec = httptest.NewRecorder()
+			handler.ServeHTTP(rec, tampered.request())
+			assert.Equal(t, ErrorBadSignature.Status, rec.Code, fixture.Description)
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package connector
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation name of the spans created by the APIClient.
+const tracerName = "github.com/connctd/connector-go"
+
+// startSpan starts the client span of the operation.
+func (a *APIClient) startSpan(ctx context.Context, operation Operation) (context.Context, trace.Span) {
+	return a.tracer.Start(ctx, "connector."+string(operation),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("connector.operation", string(operation)),
+			attribute.String("http.method", a.endpoints[operation].Method),
+		),
+	)
+}
+
+// endSpan records the result of the operation and ends the span.
+// Failed requests and error status codes set the span status to error.
+func endSpan(span trace.Span, statusCode int, err error) {
+	if statusCode != 0 {
+		span.SetAttributes(attribute.Int("http.status_code", statusCode))
+	}
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else if statusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, http.StatusText(statusCode))
+	}
+
+	span.End()
+}
@@ -0,0 +1,85 @@
+package connector
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/connctd/connector-go/connctd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracing(t *testing.T) {
+	var traceparent string
+	dummyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"42"}`))
+	}))
+	defer dummyServer.Close()
+
+	url, err := url.Parse(dummyServer.URL + "/")
+	require.Nil(t, err)
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	client, err := NewClient(&ClientOptions{
+		ConnctdBaseURL: url,
+		TracerProvider: provider,
+		Propagator:     propagation.TraceContext{},
+	}, DefaultLogger)
+	require.Nil(t, err)
+
+	_, err = client.CreateThing(context.Background(), "", connctd.Thing{Name: "DummyThing"})
+	require.Nil(t, err)
+
+	err = client.DeleteThing(context.Background(), "", "42")
+	assert.Error(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+
+	assert.Equal(t, "connector.CreateThing", spans[0].Name())
+	assert.Contains(t, spans[0].Attributes(), attribute.Int("http.status_code", http.StatusCreated))
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+
+	assert.Equal(t, "connector.DeleteThing", spans[1].Name())
+	assert.Contains(t, spans[1].Attributes(), attribute.Int("http.status_code", http.StatusNotFound))
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+
+	// the span context of the last request was propagated
+	require.NotEmpty(t, traceparent)
+	assert.Contains(t, traceparent, spans[1].SpanContext().TraceID().String())
+	assert.Contains(t, traceparent, spans[1].SpanContext().SpanID().String())
+}
+
+func TestTracingDisabled(t *testing.T) {
+	var traceparent string
+	dummyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer dummyServer.Close()
+
+	url, err := url.Parse(dummyServer.URL + "/")
+	require.Nil(t, err)
+
+	client, err := NewClient(&ClientOptions{ConnctdBaseURL: url, Propagator: propagation.TraceContext{}}, DefaultLogger)
+	require.Nil(t, err)
+
+	require.Nil(t, client.DeleteThing(context.Background(), "", "42"))
+	assert.Empty(t, traceparent)
+}
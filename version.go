@@ -0,0 +1,15 @@
+package connector
+
+import "fmt"
+
+// APIVersion is the version of the connctd API targeted by the client.
+const APIVersion = "v1"
+
+// SDKVersion is the version of connector-go. It is injected at build time, e.g. via
+// -ldflags "-X github.com/connctd/connector-go.SDKVersion=v1.2.3"
+var SDKVersion = "dev"
+
+// UserAgent returns the User-Agent sent with every request of the API client.
+func UserAgent() string {
+	return fmt.Sprintf("connector-go/%s api/%s", SDKVersion, APIVersion)
+}